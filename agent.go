@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-ObuStat/conn"
+	"go-ObuStat/obustatpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// --- agent モード (collector へのイベントの送信) ---
+// 例: agent -p 0 -collector collector01:9479 -ca ca.pem -cert agent.pem -key agent-key.pem
+const (
+	agentQueueSize    = 1024 // 送信待ちにできる取得 1 回分のイベントのまとまりの数
+	agentMaxBackoff   = 30 * time.Second
+	agentFlushTimeout = 5 * time.Second // 終了時に送信待ちのイベントを送り切るまで待つ時間
+)
+
+func runAgentMode(args []string) error {
+	fs := newFlagSet("agent")
+	opts := setupAgentFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+	if opts.collectorAddr == "" {
+		return usageError(tr("-collector で送信先を指定してください。"))
+	}
+	host := opts.hostname
+	if host == "" {
+		var err error
+		if host, err = os.Hostname(); err != nil {
+			return usageErrorf(tr("コンピューター名を取得できません。-hostname で指定してください: %w"), err)
+		}
+	}
+	creds, err := clientCredentials(opts.caFile, opts.certFile, opts.keyFile, opts.plaintext)
+	if err != nil {
+		return &exitError{code: exitUsage, err: err}
+	}
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if opts.tokenFile != "" {
+		token, err := readToken(opts.tokenFile)
+		if err != nil {
+			return usageErrorf("-token-file: %w", err)
+		}
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials{token: token, requireTLS: !opts.plaintext}))
+	}
+	client, err := grpc.NewClient(opts.collectorAddr, dialOpts...)
+	if err != nil {
+		return usageErrorf(tr("-collector の指定が不正です: %w"), err)
+	}
+	defer client.Close()
+
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	sender := newAgentSender(obustatpb.NewCollectorClient(client), host)
+	outputs, err := opts.newOutputs()
+	if err != nil {
+		return err
+	}
+	// 付加情報を設定した後の接続を送るため、送信は付加情報の内側に置く。
+	formatter, err := opts.withEnrichers(&agentFormatter{outputFormatter: outputs, sender: sender})
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	infoLog.Printf(tr("送信先: %s (ホスト名: %s)"), opts.collectorAddr, host)
+	ctx, stop := opts.runContext()
+	defer stop()
+	go sender.run()
+	defer sender.stop(agentFlushTimeout)
+	return runMonitor(ctx, opts.options, filter, monitorTarget, formatter, new(atomic.Bool))
+}
+
+// agentOptions は agent のオプション。
+type agentOptions struct {
+	*options
+	collectorAddr             string
+	caFile, certFile, keyFile string
+	tokenFile                 string
+	plaintext                 bool
+	hostname                  string
+}
+
+// setupAgentFlags は agent のフラグを定義する。
+func setupAgentFlags(fs *flag.FlagSet) *agentOptions {
+	opts := &agentOptions{options: setupFlags(fs, filterFlags, pollFlags, periodFlags, outputFlags, sinkFlags, enrichFlags, eventFlags, monitorFlags)}
+	fs.StringVar(&opts.collectorAddr, "collector", "", tr("イベントを送る collector のアドレス (例: collector01:9479)"))
+	fs.StringVar(&opts.caFile, "ca", "", tr("collector のサーバー証明書を検証する CA 証明書 (PEM)。省略時は OS の証明書ストアを使う"))
+	fs.StringVar(&opts.certFile, "cert", "", tr("collector のクライアント証明書の検証 (-client-ca) に使う、agent の証明書 (PEM)"))
+	fs.StringVar(&opts.keyFile, "key", "", tr("-cert の証明書の秘密鍵 (PEM)"))
+	fs.StringVar(&opts.tokenFile, "token-file", "", tr("collector の -token-file と同じ Bearer トークンを記載したファイル"))
+	fs.BoolVar(&opts.plaintext, "insecure", false, tr("TLS を使わずに送信する (検証環境用)"))
+	fs.StringVar(&opts.hostname, "hostname", "", tr("collector に送るホスト名 (省略時はコンピューター名)"))
+	return opts
+}
+
+// clientCredentials は collector へ接続するときの TLS の設定を返す。certFile はクライアント証明書 (mTLS) で、省略できる。
+func clientCredentials(caFile, certFile, keyFile string, plaintext bool) (credentials.TransportCredentials, error) {
+	if plaintext {
+		return insecure.NewCredentials(), nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("-ca: %w", err)
+		}
+		cfg.RootCAs = pool
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New(tr("-cert と -key は両方指定してください"))
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf(tr("クライアント証明書を読み込めません: %w"), err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// agentFormatter は元の出力に加えて、イベントを collector へ送る。
+type agentFormatter struct {
+	outputFormatter
+	sender *agentSender
+}
+
+func (f *agentFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	f.outputFormatter.writeEvents(timestamp, events)
+	f.sender.enqueue(timestamp, events)
+}
+
+// agentSender は送信待ちのイベントを 1 本のストリームで collector へ送る。
+// collector に接続できない間のイベントは送らず、再接続したときに現在の接続一覧を送り直して状態を合わせる。
+type agentSender struct {
+	client obustatpb.CollectorClient
+	host   string
+	queue  chan *obustatpb.AgentBatch
+
+	mu      sync.Mutex
+	current conn.Snapshot // 送信の成否にかかわらず、イベントから組み立てた現在の接続一覧
+	resync  bool          // 送信待ちがあふれたため、次の送信の前に接続一覧を送り直す
+	dropped int
+
+	ctx    context.Context // stop の待ち時間を過ぎると取り消され、送信と再接続を打ち切る
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newAgentSender(client obustatpb.CollectorClient, host string) *agentSender {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &agentSender{
+		client:  client,
+		host:    host,
+		queue:   make(chan *obustatpb.AgentBatch, agentQueueSize),
+		current: make(conn.Snapshot),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// enqueue はイベントを送信待ちに加える。送信が追いつかない場合は待たずに捨て、後で接続一覧を送り直す。
+func (s *agentSender) enqueue(timestamp time.Time, events []conn.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		switch e.Type {
+		case conn.EventNew, conn.EventChange, conn.EventRebound:
+			s.current[e.Key] = e.Conn
+		case conn.EventClosed:
+			delete(s.current, e.Key)
+		}
+	}
+	batch := &obustatpb.AgentBatch{Host: s.host, TimestampMs: timestamp.UnixMilli(), Events: protoEvents(events)}
+	select {
+	case s.queue <- batch:
+	default:
+		if !s.resync {
+			warnLog.Print(tr("警告: collector への送信が追いつかないため、イベントを破棄して後で接続一覧を送り直します"))
+		}
+		s.resync = true
+		s.dropped++
+	}
+}
+
+// snapshotBatch は現在の接続一覧を作り、それまでの送信待ちを捨てる (接続一覧に反映済みのため)。
+func (s *agentSender) snapshotBatch() *obustatpb.AgentBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for drained := false; !drained; {
+		select {
+		case _, ok := <-s.queue:
+			drained = !ok
+		default:
+			drained = true
+		}
+	}
+	s.resync = false
+	conns := make([]*obustatpb.Connection, 0, len(s.current))
+	for _, c := range s.current {
+		conns = append(conns, protoConnection(c))
+	}
+	return &obustatpb.AgentBatch{Host: s.host, TimestampMs: time.Now().UnixMilli(), IsSnapshot: true, Snapshot: conns}
+}
+
+func (s *agentSender) needsResync() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resync
+}
+
+// run は stop されるまで、collector への接続と送信を繰り返す。
+func (s *agentSender) run() {
+	defer close(s.done)
+	backoff := time.Second
+	for {
+		closed, err := s.stream()
+		if closed {
+			return
+		}
+		warnLog.Printf(tr("エラー: collector への送信に失敗しました。%s 後に再接続します: %v"), backoff, err)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, agentMaxBackoff)
+	}
+}
+
+// stream は 1 本のストリームで接続一覧と以降のイベントを送る。送信待ちが閉じられて送り切った場合は closed が true になる。
+func (s *agentSender) stream() (closed bool, err error) {
+	stream, err := s.client.Push(s.ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := stream.Send(s.snapshotBatch()); err != nil {
+		return false, err
+	}
+	infoLog.Print(tr("collector に接続しました"))
+	for {
+		batch, ok := <-s.queue
+		if !ok {
+			_, err := stream.CloseAndRecv()
+			if err != nil && !errors.Is(err, context.Canceled) {
+				warnLog.Printf(tr("エラー: collector への送信を完了できませんでした: %v"), err)
+			}
+			return true, nil
+		}
+		if s.needsResync() {
+			batch = s.snapshotBatch()
+		}
+		if err := stream.Send(batch); err != nil {
+			return false, err
+		}
+	}
+}
+
+// stop は送信待ちを閉じ、送り切るまで最大 timeout 待つ。
+func (s *agentSender) stop(timeout time.Duration) {
+	s.mu.Lock()
+	close(s.queue)
+	dropped := s.dropped
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-time.After(timeout):
+		warnLog.Printf(tr("警告: collector への送信が %s 以内に完了しなかったため打ち切ります"), timeout)
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+	if dropped > 0 {
+		warnLog.Printf(tr("送信が追いつかずに破棄したイベントのまとまり: %d 件"), dropped)
+	}
+}
+
+// --- gRPC のメッセージとの変換 ---
+func protoConnection(c conn.Connection) *obustatpb.Connection {
+	return &obustatpb.Connection{
+		Protocol: c.Protocol, Process: c.ProcessName, Pid: c.PID, ProcessStartMs: unixMillis(c.ProcessStart),
+		LocalAddr: c.LocalAddr, LocalPort: uint32(c.LocalPort), LocalInterface: c.LocalInterface,
+		RemoteAddr: c.RemoteAddr, RemotePort: uint32(c.RemotePort), RemoteHost: c.RemoteHost, RemoteService: c.RemoteService,
+		State: c.State, FirstSeenMs: unixMillis(c.FirstSeen),
+		ImagePath: c.ImagePath, CommandLine: c.CommandLine, User: c.User,
+		Module: c.Module, Services: c.Services, Container: c.Container, InJob: c.InJob,
+		Country: c.Country, Asn: c.ASN, AsOrg: c.ASOrg,
+	}
+}
+
+func connectionFromProto(pc *obustatpb.Connection) conn.Connection {
+	return conn.Connection{
+		Protocol: pc.GetProtocol(), ProcessName: pc.GetProcess(), PID: pc.GetPid(), ProcessStart: fromUnixMillis(pc.GetProcessStartMs()),
+		LocalAddr: pc.GetLocalAddr(), LocalPort: uint16(pc.GetLocalPort()), LocalInterface: pc.GetLocalInterface(),
+		RemoteAddr: pc.GetRemoteAddr(), RemotePort: uint16(pc.GetRemotePort()), RemoteHost: pc.GetRemoteHost(), RemoteService: pc.GetRemoteService(),
+		State: pc.GetState(), FirstSeen: fromUnixMillis(pc.GetFirstSeenMs()),
+		ImagePath: pc.GetImagePath(), CommandLine: pc.GetCommandLine(), User: pc.GetUser(),
+		Module: pc.GetModule(), Services: pc.GetServices(), Container: pc.GetContainer(), InJob: pc.GetInJob(),
+		Country: pc.GetCountry(), ASN: pc.GetAsn(), ASOrg: pc.GetAsOrg(),
+	}
+}
+
+func protoEvents(events []conn.Event) []*obustatpb.Event {
+	pes := make([]*obustatpb.Event, len(events))
+	for i, e := range events {
+		pes[i] = &obustatpb.Event{Type: string(e.Type), Connection: protoConnection(e.Conn), PrevState: e.PrevState, Count: int32(e.Count), Detail: e.Detail}
+	}
+	return pes
+}
+
+func eventsFromProto(pes []*obustatpb.Event) []conn.Event {
+	events := make([]conn.Event, len(pes))
+	for i, pe := range pes {
+		c := connectionFromProto(pe.GetConnection())
+		events[i] = conn.Event{Type: conn.EventType(pe.GetType()), Key: c.Key(), Conn: c, PrevState: pe.GetPrevState(), Count: int(pe.GetCount()), Detail: pe.GetDetail()}
+	}
+	return events
+}
+
+// unixMillis は時刻を Unix 時刻 (ミリ秒) にする。ゼロ値は 0 にする。
+func unixMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromUnixMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// snapshotFromProto は接続一覧を Snapshot にする。
+func snapshotFromProto(pcs []*obustatpb.Connection) conn.Snapshot {
+	conns := make(conn.Snapshot, len(pcs))
+	for _, pc := range pcs {
+		c := connectionFromProto(pc)
+		conns[c.Key()] = c
+	}
+	return conns
+}
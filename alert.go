@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"go-ObuStat/conn"
+)
+
+// --- 同時接続数のしきい値による警告 ---
+// eventAlert は、プロセスの同時接続数が -alert-count を超えたときに出力するイベントの種別。
+const eventAlert conn.EventType = "ALERT"
+
+// alertTracker はプロセスごとの同時接続数を監視し、しきい値を超えた時点で 1 回だけ ALERT を発生させる。
+// しきい値以下に戻ると、再び超えたときに改めて ALERT を発生させる。
+type alertTracker struct {
+	threshold int
+	alerting  map[processKey]bool
+}
+
+func newAlertTracker(threshold int) *alertTracker {
+	return &alertTracker{threshold: threshold, alerting: make(map[processKey]bool)}
+}
+
+// check は今回の接続一覧から、新たにしきい値を超えたプロセスの ALERT イベントを返す。
+func (t *alertTracker) check(current conn.Snapshot) []conn.Event {
+	if t == nil {
+		return nil
+	}
+	counts := make(map[processKey]int)
+	samples := make(map[processKey]conn.Connection)
+	for _, c := range current {
+		key := processKey{c.ProcessName, c.PID}
+		counts[key]++
+		samples[key] = c
+	}
+
+	var events []conn.Event
+	for key, n := range counts {
+		if n <= t.threshold {
+			continue
+		}
+		if !t.alerting[key] {
+			t.alerting[key] = true
+			events = append(events, alertEvent(samples[key], n, t.threshold))
+		}
+	}
+	for key := range t.alerting {
+		if counts[key] <= t.threshold {
+			delete(t.alerting, key)
+		}
+	}
+	return events
+}
+
+func alertEvent(sample conn.Connection, count, threshold int) conn.Event {
+	return conn.Event{
+		Type:   eventAlert,
+		Key:    processLabel(sample),
+		Conn:   conn.Connection{ProcessName: sample.ProcessName, PID: sample.PID},
+		Count:  count,
+		Detail: fmt.Sprintf(tr("同時接続数が %d 件になり、しきい値 %d 件を超えました"), count, threshold),
+	}
+}
+
+// alertTracker は -alert-count が指定されていれば alertTracker を返す。未指定の場合は nil を返す。
+func (o *options) alertTracker() *alertTracker {
+	if o.alertCount <= 0 {
+		return nil
+	}
+	return newAlertTracker(o.alertCount)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"go-ObuStat/conn"
+
+	"golang.org/x/sys/windows"
+)
+
+// fakeCollector は FakeTables の行を app.exe (PID 100) の接続として返す Collector を返す。
+func fakeCollector() (*conn.FakeTables, *conn.Collector) {
+	tables, processes := &conn.FakeTables{}, &conn.FakeProcesses{}
+	processes.Set(conn.ProcessEntry{PID: 100, Name: "app.exe", Created: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)})
+	filter := conn.Filter{Protocols: []string{"tcp"}, Families: []uint32{windows.AF_INET}, AllProcesses: true}
+	return tables, conn.NewCollectorWith(filter, tables, processes)
+}
+
+// fakeRow は app.exe のローカルポート port から 10.0.0.2:443 への TCP の行を返す。
+func fakeRow(port uint16, state string) conn.TableRow {
+	return conn.TableRow{
+		Protocol: "TCP", LocalAddr: netip.MustParseAddr("10.0.0.1"), LocalPort: port,
+		RemoteAddr: netip.MustParseAddr("10.0.0.2"), RemotePort: 443, State: state, PID: 100,
+	}
+}
+
+func fakeRows(n int) []conn.TableRow {
+	rows := make([]conn.TableRow, n)
+	for i := range rows {
+		rows[i] = fakeRow(uint16(50000+i), "ESTABLISHED")
+	}
+	return rows
+}
+
+func TestAlertTracker(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []int // 取得ごとの接続数
+		want   []int // ALERT を発生させた取得の接続数 (発生させなかった取得は 0)
+	}{
+		{"しきい値以下", []int{1, 2, 2}, []int{0, 0, 0}},
+		{"超えた時点で 1 回だけ", []int{2, 3, 4, 3}, []int{0, 3, 0, 0}},
+		{"しきい値以下に戻ると再び発生", []int{3, 2, 4}, []int{3, 0, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables, collector := fakeCollector()
+			tracker := newAlertTracker(2)
+			var got []int
+			for _, n := range tt.counts {
+				tables.Set(fakeRows(n)...)
+				current, err := collector.Collect()
+				if err != nil {
+					t.Fatal(err)
+				}
+				events := tracker.check(current)
+				switch len(events) {
+				case 0:
+					got = append(got, 0)
+				case 1:
+					if events[0].Type != eventAlert || events[0].Key != "app.exe (PID: 100)" {
+						t.Fatalf("check = %+v", events[0])
+					}
+					got = append(got, events[0].Count)
+				default:
+					t.Fatalf("check = %d 件, want 1 件以下", len(events))
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ALERT = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertTrackerNil(t *testing.T) {
+	var tracker *alertTracker
+	if events := tracker.check(conn.Snapshot{}); events != nil {
+		t.Errorf("nil の check = %v, want nil", events)
+	}
+}
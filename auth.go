@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+
+	"go-ObuStat/conn"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// --- 待ち受けの TLS・認証・接続元の制限 (exporter, serve, collector) ---
+// プロセスと接続の情報を公開するため、TLS とクライアント証明書 (-client-ca) またはトークン (-token-file) で
+// 接続元を確認し、-allow で接続を受け付けるアドレスを制限できるようにする。
+type listenSecurity struct {
+	certFile, keyFile string
+	clientCAFile      string
+	tokenFile         string
+	allow             string
+
+	tlsConfig *tls.Config
+	token     string
+	allowNets []netip.Prefix
+
+	// publicRoot は / (serve のダッシュボード) をトークン無しで返す。
+	// ブラウザーは Authorization を付けられないため、ダッシュボードは ?token= で API を呼び出す。
+	publicRoot bool
+}
+
+func setupListenSecurity(fs *flag.FlagSet) *listenSecurity {
+	s := &listenSecurity{}
+	fs.StringVar(&s.certFile, "tls-cert", "", tr("TLS のサーバー証明書 (PEM)。-tls-key と合わせて指定すると TLS で待ち受ける"))
+	fs.StringVar(&s.keyFile, "tls-key", "", tr("TLS のサーバー証明書の秘密鍵 (PEM)"))
+	fs.StringVar(&s.clientCAFile, "client-ca", "", tr("クライアント証明書を検証する CA 証明書 (PEM)。指定するとクライアント証明書の無い接続を拒否する (要 -tls-cert)"))
+	fs.StringVar(&s.tokenFile, "token-file", "", tr("Bearer トークンを記載したファイル。指定すると Authorization: Bearer か ?token= の無い要求を拒否する"))
+	fs.StringVar(&s.allow, "allow", "", tr("接続を受け付ける接続元のアドレス (IP または CIDR のカンマ区切り, 例: 10.0.0.0/8,127.0.0.1)"))
+	return s
+}
+
+// load は指定された証明書・トークン・アドレスを読み込む。requireTLS の場合は -tls-cert を必須にする。
+func (s *listenSecurity) load(requireTLS bool) error {
+	if (s.certFile == "") != (s.keyFile == "") {
+		return errors.New(tr("-tls-cert と -tls-key は両方指定してください"))
+	}
+	if s.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			return fmt.Errorf(tr("サーバー証明書を読み込めません: %w"), err)
+		}
+		s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	} else if requireTLS {
+		return errors.New(tr("-tls-cert と -tls-key でサーバー証明書を指定してください (TLS を使わない場合は -insecure)"))
+	}
+	if s.clientCAFile != "" {
+		if s.tlsConfig == nil {
+			return errors.New(tr("-client-ca には -tls-cert と -tls-key の指定が必要です"))
+		}
+		pool, err := loadCertPool(s.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("-client-ca: %w", err)
+		}
+		s.tlsConfig.ClientCAs = pool
+		s.tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if s.tokenFile != "" {
+		token, err := readToken(s.tokenFile)
+		if err != nil {
+			return fmt.Errorf("-token-file: %w", err)
+		}
+		s.token = token
+		if s.tlsConfig == nil {
+			warnLog.Print(tr("警告: TLS を使わずにトークンで認証するため、トークンが平文で送られます"))
+		}
+	}
+	if s.allow != "" {
+		nets, err := conn.ParsePrefixes(s.allow)
+		if err != nil {
+			return fmt.Errorf(tr("-allow の指定が不正です: %w"), err)
+		}
+		s.allowNets = nets
+	}
+	return nil
+}
+
+// loadFlags は load に失敗した場合に、使用方法の誤りとしてエラーを返す。
+func (s *listenSecurity) loadFlags(requireTLS bool) error {
+	if err := s.load(requireTLS); err != nil {
+		return &exitError{code: exitUsage, err: err}
+	}
+	return nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf(tr("PEM 形式の証明書がありません: %s"), path)
+	}
+	return pool, nil
+}
+
+// readToken はトークンのファイルを読み込む。前後の空白と改行は除く。
+func readToken(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf(tr("トークンが空です: %s"), path)
+	}
+	return token, nil
+}
+
+// scheme は待ち受けの URL の表示に使うスキーム。
+func (s *listenSecurity) scheme() string {
+	if s.tlsConfig != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// listen は -allow に一致しない接続元を切断するリスナーを返す。TLS は呼び出し側 (HTTP または gRPC) で処理する。
+func (s *listenSecurity) listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.allowNets) == 0 {
+		return l, nil
+	}
+	return &allowListener{Listener: l, allow: s.allowNets}, nil
+}
+
+// serveHTTP は TLS を指定した場合は HTTPS で、それ以外は HTTP で server を開始する。
+func (s *listenSecurity) serveHTTP(server *http.Server, l net.Listener) error {
+	if s.token != "" {
+		server.Handler = s.requireToken(server.Handler)
+	}
+	if s.tlsConfig == nil {
+		return server.Serve(l)
+	}
+	server.TLSConfig = s.tlsConfig
+	return server.ServeTLS(l, "", "")
+}
+
+// startHTTP は server を別の goroutine で開始し、停止したときのエラーを受け取るチャネルを返す。
+// Shutdown 以外の理由で停止した場合は cancel を呼んで取得を終わらせ、エラーを送る。Shutdown で停止した場合は何も送らずに閉じる。
+func (s *listenSecurity) startHTTP(server *http.Server, l net.Listener, cancel context.CancelFunc) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		if err := s.serveHTTP(server, l); err != nil && err != http.ErrServerClosed {
+			errc <- fmt.Errorf(tr("HTTP サーバーが停止しました: %w"), err)
+			cancel()
+		}
+	}()
+	return errc
+}
+
+// requireToken は Authorization: Bearer または ?token= のトークンが一致しない要求を 401 で拒否する。
+// ?token= は Authorization を付けられない EventSource (ダッシュボードの /events) のために受け付ける。
+func (s *listenSecurity) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		public := s.publicRoot && r.URL.Path == "/"
+		if !public && !s.validToken(r.Header.Get("Authorization")) && !s.matchToken(r.URL.Query().Get("token")) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-ObuStat"`)
+			http.Error(w, tr("認証が必要です"), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *listenSecurity) validToken(authorization string) bool {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	return ok && s.matchToken(token)
+}
+
+func (s *listenSecurity) matchToken(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// grpcOptions は TLS とトークンの確認を行う gRPC サーバーのオプションを返す。
+func (s *listenSecurity) grpcOptions() []grpc.ServerOption {
+	creds := insecure.NewCredentials()
+	if s.tlsConfig != nil {
+		creds = credentials.NewTLS(s.tlsConfig)
+	}
+	opts := []grpc.ServerOption{grpc.Creds(creds)}
+	if s.token != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := s.checkGRPCToken(ctx); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := s.checkGRPCToken(ss.Context()); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}),
+		)
+	}
+	return opts
+}
+
+func (s *listenSecurity) checkGRPCToken(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if s.validToken(v) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, tr("認証が必要です"))
+}
+
+// allowListener は -allow に一致しない接続元の接続を、受け付けた直後に切断する。
+type allowListener struct {
+	net.Listener
+	allow []netip.Prefix
+}
+
+func (l *allowListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if ap, err := netip.ParseAddrPort(c.RemoteAddr().String()); err == nil && containsAddr(l.allow, ap.Addr().Unmap()) {
+			return c, nil
+		}
+		warnLog.Printf(tr("警告: -allow に含まれない接続元からの接続を拒否しました: %s"), c.RemoteAddr())
+		c.Close()
+	}
+}
+
+// --- クライアント側 (agent) の TLS とトークン ---
+// tokenCredentials は gRPC の要求ごとに Authorization: Bearer を付ける。
+type tokenCredentials struct {
+	token      string
+	requireTLS bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool { return t.requireTLS }
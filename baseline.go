@@ -0,0 +1,227 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-ObuStat/conn"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- ベースライン (通常の接続先の記録と、それ以外の接続の検出) ---
+// eventAnomaly は、-baseline に記録されていない (プロセス, リモートアドレス, リモートポート) への接続を表すイベントの種別。
+const eventAnomaly conn.EventType = "ANOMALY"
+
+// baselineEntry はベースラインの 1 件。process はワイルドカード、remote は IP・CIDR・* (全て)、port は 0 で全てのポートに一致する。
+type baselineEntry struct {
+	Process string `yaml:"process"`
+	Remote  string `yaml:"remote"`
+	Port    uint16 `yaml:"port"`
+
+	prefix netip.Prefix // remote を解析した範囲 (* の場合は無効な値)
+}
+
+type baselineFile struct {
+	Recorded    string          `yaml:"recorded,omitempty"` // 記録した期間 (表示用)
+	Connections []baselineEntry `yaml:"connections"`
+}
+
+func (e baselineEntry) matches(c conn.Connection) bool {
+	if !conn.MatchProcessName(c.ProcessName, e.Process) {
+		return false
+	}
+	if e.Port != 0 && e.Port != c.RemotePort {
+		return false
+	}
+	if e.Remote == "*" {
+		return true
+	}
+	ip, err := netip.ParseAddr(c.RemoteAddr)
+	return err == nil && e.prefix.Contains(ip.Unmap())
+}
+
+// loadBaseline はベースラインのファイルを読み込む。
+func loadBaseline(path string) ([]baselineEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f baselineFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, e := range f.Connections {
+		if e.Process == "" || e.Remote == "" {
+			return nil, fmt.Errorf(tr("%s: %d 件目: process と remote は必須です"), path, i+1)
+		}
+		if e.Remote == "*" {
+			continue
+		}
+		prefixes, err := conn.ParsePrefixes(e.Remote)
+		if err != nil || len(prefixes) != 1 {
+			return nil, fmt.Errorf(tr("%s: %d 件目: remote には IP、CIDR、または * を指定してください: %q"), path, i+1, e.Remote)
+		}
+		f.Connections[i].prefix = prefixes[0]
+	}
+	return f.Connections, nil
+}
+
+// baselineChecker は新しい接続のうち、ベースラインのどれにも一致しないものを ANOMALY として報告する。
+// 同じ (プロセス, リモートアドレス, リモートポート) は、監視中に 1 回だけ報告する。
+type baselineChecker struct {
+	entries  []baselineEntry
+	reported map[baselineKey]bool
+}
+
+type baselineKey struct {
+	process string
+	remote  string
+	port    uint16
+}
+
+func keyOfBaseline(c conn.Connection) baselineKey {
+	return baselineKey{strings.ToLower(c.ProcessName), c.RemoteAddr, c.RemotePort}
+}
+
+// check は NEW イベントの接続を判定し、ANOMALY イベントを返す。待ち受け (リモートポートが 0) は対象外。
+func (b *baselineChecker) check(events []conn.Event) []conn.Event {
+	if b == nil {
+		return nil
+	}
+	var anomalies []conn.Event
+	for _, e := range events {
+		if !e.Opened() || e.Conn.RemotePort == 0 {
+			continue
+		}
+		if slices.ContainsFunc(b.entries, func(entry baselineEntry) bool { return entry.matches(e.Conn) }) {
+			continue
+		}
+		key := keyOfBaseline(e.Conn)
+		if b.reported[key] {
+			continue
+		}
+		b.reported[key] = true
+		anomalies = append(anomalies, conn.Event{
+			Type:   eventAnomaly,
+			Key:    e.Key,
+			Conn:   e.Conn,
+			Detail: fmt.Sprintf(tr("ベースラインに無い接続先です (%s -> %s:%d)"), e.Conn.ProcessName, e.Conn.RemoteAddr, e.Conn.RemotePort),
+		})
+	}
+	return anomalies
+}
+
+// baselineChecker は -baseline が指定されていればファイルを読み込んだ baselineChecker を返す。未指定の場合は nil を返す。
+func (o *options) baselineChecker() (*baselineChecker, error) {
+	if o.baseline == "" {
+		return nil, nil
+	}
+	entries, err := loadBaseline(o.baseline)
+	if err != nil {
+		return nil, usageErrorf(tr("-baseline を読み込めませんでした: %w"), err)
+	}
+	return &baselineChecker{entries: entries, reported: make(map[baselineKey]bool)}, nil
+}
+
+// --- baseline record ---
+// 例: baseline record -p 0 -duration 24h -out baseline.yaml
+func runBaselineCommand(args []string) error {
+	if len(args) < 1 || args[0] != "record" {
+		return usageErrorf(tr("使用方法: %s baseline record [オプション] -out <ファイル>"), os.Args[0])
+	}
+	fs := newFlagSet("baseline record")
+	opts := setupBaselineFlags(fs)
+	if err := parseFlags(fs, opts.options, args[1:]); err != nil {
+		return err
+	}
+	if opts.outPath == "" {
+		return usageError(tr("-out で書き出すファイルを指定してください。"))
+	}
+
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	formatter, err := opts.newFormatter()
+	if err != nil {
+		return err
+	}
+	recorder := &baselineRecorder{outputFormatter: formatter, seen: make(map[baselineKey]baselineEntry)}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	ctx, stop := opts.runContext()
+	defer stop()
+	start := time.Now()
+	// -exit-on-alert で終了した場合も、それまでに記録した接続先は書き出す。
+	monitorErr := runMonitor(ctx, opts.options, filter, monitorTarget, recorder, new(atomic.Bool))
+	if monitorErr != nil && exitCodeOf(monitorErr) != exitAlert {
+		return monitorErr
+	}
+
+	period := fmt.Sprintf("%s - %s", start.Format("2006-01-02 15:04:05"), time.Now().Format("2006-01-02 15:04:05"))
+	if err := recorder.save(opts.outPath, period); err != nil {
+		return fmt.Errorf(tr("ベースラインを書き出せませんでした: %w"), err)
+	}
+	infoLog.Printf(tr("ベースラインを書き出しました: %s (%d 件)"), opts.outPath, len(recorder.seen))
+	return monitorErr
+}
+
+// baselineOptions は baseline record のオプション。
+type baselineOptions struct {
+	*options
+	outPath string
+}
+
+// setupBaselineFlags は baseline record のフラグを定義する。
+func setupBaselineFlags(fs *flag.FlagSet) *baselineOptions {
+	opts := &baselineOptions{options: setupFlags(fs, monitorFlagGroups...)}
+	fs.StringVar(&opts.outPath, "out", "", tr("記録したベースラインを書き出すファイル (YAML, 必須)"))
+	return opts
+}
+
+// baselineRecorder は元の出力に加えて、新しい接続の (プロセス, リモートアドレス, リモートポート) を集める。
+type baselineRecorder struct {
+	outputFormatter
+	seen map[baselineKey]baselineEntry
+}
+
+func (r *baselineRecorder) writeEvents(timestamp time.Time, events []conn.Event) {
+	r.outputFormatter.writeEvents(timestamp, events)
+	for _, e := range events {
+		if e.Type != conn.EventNew || e.Conn.RemotePort == 0 {
+			continue
+		}
+		key := keyOfBaseline(e.Conn)
+		if _, ok := r.seen[key]; !ok {
+			r.seen[key] = baselineEntry{Process: e.Conn.ProcessName, Remote: e.Conn.RemoteAddr, Port: e.Conn.RemotePort}
+		}
+	}
+}
+
+func (r *baselineRecorder) save(path, period string) error {
+	f := baselineFile{Recorded: period, Connections: make([]baselineEntry, 0, len(r.seen))}
+	for _, e := range r.seen {
+		f.Connections = append(f.Connections, e)
+	}
+	slices.SortFunc(f.Connections, func(a, b baselineEntry) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Process), strings.ToLower(b.Process)), cmp.Compare(a.Remote, b.Remote), cmp.Compare(a.Port, b.Port))
+	})
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+	header := tr("# baseline record で記録したベースライン。remote には CIDR や * (全て)、port には 0 (全て) も指定できる。\n")
+	return os.WriteFile(path, append([]byte(header), data...), 0o644)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 条件による詳細な記録 (-capture-when) ---
+// まれにしか起きない事象を細かい間隔で記録しつつ、普段は出力を抑えて保存する量を減らす。
+// -capture-when の条件のいずれかを満たすと、-capture-interval の間隔で取得して接続の変化のイベントを出力し、
+// どの条件も満たさない状態が -capture-cooldown 続いたら記録をやめて -i の間隔の取得に戻る。
+// 記録していない間も接続の一覧は取得し続け、ALERT などの判定のイベントは出力する。
+//
+// 条件はカンマ区切りで指定する。
+//   - process:名前          このプロセスの接続がある (例: process:sqlservr.exe)
+//   - host:アドレス         このリモートアドレス (IP または CIDR) への接続がある (例: host:10.0.0.5, host:10.1.0.0/16)
+//   - state:状態>件数       この状態の接続が件数を超える (例: state:CLOSE_WAIT>50。>件数 を省略すると 1 件以上)
+
+const (
+	// eventCaptureStart は、-capture-when の条件を満たして詳細な記録を始めたことを示すイベントの種別。
+	eventCaptureStart conn.EventType = "CAPTURE_START"
+	// eventCaptureStop は、条件を満たさない状態が -capture-cooldown 続いて記録をやめたことを示すイベントの種別。
+	eventCaptureStop conn.EventType = "CAPTURE_STOP"
+)
+
+// captureCondition は -capture-when の 1 つの条件。
+type captureCondition struct {
+	text  string
+	match func(current conn.Snapshot) bool
+}
+
+// captureSwitch は -capture-when の条件に応じて詳細な記録を開始・停止する。
+type captureSwitch struct {
+	conditions []captureCondition
+	interval   time.Duration
+	cooldown   time.Duration
+	ticker     *time.Ticker // 記録している間だけ動かす
+	active     bool
+	started    time.Time // 記録を始めた時刻
+	lastMatch  time.Time // 最後に条件を満たした時刻
+}
+
+// captureSwitch は -capture-when が指定されていれば captureSwitch を返す。未指定の場合は nil を返す。
+func (o *options) captureSwitch() (*captureSwitch, error) {
+	if o.captureWhen == "" {
+		return nil, nil
+	}
+	s := &captureSwitch{
+		interval: time.Duration(o.captureInterval) * time.Millisecond,
+		cooldown: o.captureCooldown,
+	}
+	if s.interval <= 0 {
+		return nil, usageErrorf(tr("-capture-interval の指定が不正です: %d"), o.captureInterval)
+	}
+	for _, text := range splitList(o.captureWhen) {
+		c, err := parseCaptureCondition(text)
+		if err != nil {
+			return nil, err
+		}
+		s.conditions = append(s.conditions, c)
+	}
+	s.ticker = time.NewTicker(s.interval)
+	s.ticker.Stop()
+	return s, nil
+}
+
+func parseCaptureCondition(text string) (captureCondition, error) {
+	kind, value, _ := strings.Cut(text, ":")
+	c := captureCondition{text: text}
+	switch strings.ToLower(kind) {
+	case "process":
+		if value == "" {
+			break
+		}
+		c.match = func(current conn.Snapshot) bool {
+			for _, cn := range current {
+				if strings.EqualFold(cn.ProcessName, value) {
+					return true
+				}
+			}
+			return false
+		}
+		return c, nil
+	case "host":
+		prefixes, err := conn.ParsePrefixes(value)
+		if err != nil || len(prefixes) != 1 {
+			break
+		}
+		prefix := prefixes[0]
+		c.match = func(current conn.Snapshot) bool {
+			for _, cn := range current {
+				if addr, err := netip.ParseAddr(cn.RemoteAddr); err == nil && prefix.Contains(addr.Unmap()) {
+					return true
+				}
+			}
+			return false
+		}
+		return c, nil
+	case "state":
+		state, limit, hasLimit := strings.Cut(strings.ToUpper(value), ">")
+		threshold := 0
+		if hasLimit {
+			n, err := strconv.Atoi(strings.TrimSpace(limit))
+			if err != nil || n < 0 {
+				break
+			}
+			threshold = n
+		}
+		state = strings.TrimSpace(state)
+		if !slices.Contains(conn.TCPStateNames, state) {
+			return c, usageErrorf(tr("-capture-when の状態が不明です: %q (指定可能: %s)"), state, strings.Join(conn.TCPStateNames, ","))
+		}
+		c.match = func(current conn.Snapshot) bool {
+			count := 0
+			for _, cn := range current {
+				if cn.State == state {
+					count++
+				}
+			}
+			return count > threshold
+		}
+		return c, nil
+	}
+	return c, usageErrorf(tr("-capture-when の条件が不正です: %q (process:名前, host:アドレス, state:状態>件数 のいずれか)"), text)
+}
+
+// C は記録している間、-capture-interval ごとに取得の契機を知らせる。記録していない間と nil の場合は nil を返す。
+func (s *captureSwitch) C() <-chan time.Time {
+	if s == nil || !s.active {
+		return nil
+	}
+	return s.ticker.C
+}
+
+func (s *captureSwitch) Stop() {
+	if s != nil {
+		s.ticker.Stop()
+	}
+}
+
+// capturing は接続の変化のイベントを出力するかを返す。nil の場合は常に出力する。
+func (s *captureSwitch) capturing() bool {
+	return s == nil || s.active
+}
+
+// update は current で条件を判定して記録を開始・停止し、CAPTURE_START または CAPTURE_STOP のイベントを返す。
+func (s *captureSwitch) update(now time.Time, current conn.Snapshot) []conn.Event {
+	if s == nil {
+		return nil
+	}
+	var matched []string
+	for _, c := range s.conditions {
+		if c.match(current) {
+			matched = append(matched, c.text)
+		}
+	}
+	if len(matched) > 0 {
+		s.lastMatch = now
+	}
+	switch {
+	case !s.active && len(matched) > 0:
+		s.active, s.started = true, now
+		s.ticker.Reset(s.interval)
+		infoLog.Printf(tr("詳細な記録を開始しました (条件: %s)"), strings.Join(matched, ", "))
+		return []conn.Event{{
+			Type:   eventCaptureStart,
+			Count:  len(current),
+			Detail: fmt.Sprintf(tr("条件: %s, 取得間隔: %d ミリ秒"), strings.Join(matched, ", "), s.interval.Milliseconds()),
+		}}
+	case s.active && now.Sub(s.lastMatch) >= s.cooldown:
+		s.active = false
+		s.ticker.Stop()
+		infoLog.Print(tr("詳細な記録を停止しました"))
+		return []conn.Event{{
+			Type:   eventCaptureStop,
+			Count:  len(current),
+			Detail: fmt.Sprintf(tr("条件を満たさなくなってから %s 経過, 記録した期間: %s"), s.cooldown, now.Sub(s.started).Round(time.Second)),
+		}}
+	}
+	return nil
+}
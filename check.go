@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"go-ObuStat/conn"
+)
+
+// --- 設定の確認 (-check) ---
+// -n の綴りを誤ると、監視は始まってもどの接続にも一致せず、何も出力されないまま動き続ける。
+// -check はフラグと設定ファイルを解析して有効な設定を表示し、フィルターや出力の指定を検証してから、監視を始めずに終了する。
+// 指定が不正な場合は終了コード 2、実行中のプロセスに一致しない -n/-p がある場合は終了コード 1 で終了する。
+
+// runCheck は有効な設定と検証の結果を表示し、終了させるためのエラーを返す (問題が無い場合も exitOK で終了させる)。
+func (o *options) runCheck(fs *flag.FlagSet) error {
+	fmt.Println(tr("--- 有効な設定 ---"))
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "check":
+		case "redact-key":
+			// 鍵そのものは表示しない。
+			fmt.Printf("  -%s = ***\n", f.Name)
+		default:
+			fmt.Printf("  -%s = %s\n", f.Name, f.Value)
+		}
+	})
+	if len(o.profiles) > 0 {
+		fmt.Printf(tr("  プロファイル: %s\n"), strings.Join(profileNames(o.profiles), ", "))
+	}
+
+	var problems []error
+	// 監視対象の指定 (-n/-p) は、絞り込みのオプションを定義したサブコマンド (query などを除く) だけで検証する。
+	var filter conn.Filter
+	checkFilter := definesGroup(fs, filterFlags)
+	if checkFilter {
+		var monitorTarget string
+		var err error
+		if filter, monitorTarget, err = o.connFilter(); err != nil {
+			problems = append(problems, err)
+		} else {
+			fmt.Printf(tr("  監視対象: %s\n"), monitorTarget)
+		}
+	}
+	problems = append(problems, o.checkOutputs()...)
+	if _, err := o.redactor(); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := o.eventSampler(); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := o.baselineChecker(); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := o.geoAlerter(); err != nil {
+		problems = append(problems, err)
+	}
+
+	fmt.Println(tr("--- 検証結果 ---"))
+	for _, err := range problems {
+		fmt.Printf("[NG] %v\n", err)
+	}
+	if len(problems) > 0 {
+		return exitStatus(exitUsage)
+	}
+	var unmatched []string
+	if checkFilter && !filter.AllProcesses {
+		unmatched = conn.UnmatchedTargets(filter)
+	}
+	for _, target := range unmatched {
+		fmt.Printf(tr("[警告] -n/-p の %q に一致する実行中のプロセスがありません (プロセスの起動を待つ場合は問題ありません)\n"), target)
+	}
+	if len(unmatched) > 0 {
+		return exitStatus(exitNotFound)
+	}
+	fmt.Println(tr("[OK] 設定に問題はありません"))
+	return exitStatus(exitOK)
+}
+
+// definesGroup は group のフラグが全て fs に定義されているかを返す。
+func definesGroup(fs *flag.FlagSet, group flagGroup) bool {
+	for _, name := range group {
+		if fs.Lookup(name) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// checkOutputs は -format と -o の各出力先の形式を、出力先を開かずに検証する。
+func (o *options) checkOutputs() []error {
+	primary, extras := o.outputTargets()
+	var problems []error
+	for _, t := range append([]outputTarget{primary}, extras...) {
+		if t.isWebhook() {
+			continue
+		}
+		if _, err := newOutputFormatterTo(t.format, o, log.New(io.Discard, "", 0)); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
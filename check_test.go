@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestRunCheckFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(fs *flag.FlagSet) *options
+		args  []string
+		want  int
+	}{
+		{"query は -n/-p を検証しない", flagsOf(setupQueryFlags), []string{"-store", "events.db"}, exitOK},
+		{"collector は -n/-p を検証しない", flagsOf(setupCollectorFlags), []string{"-store", "all.db"}, exitOK},
+		{"monitor は -n/-p が必要", setupMonitorFlags, nil, exitUsage},
+		{"monitor の不正な -state", setupMonitorFlags, []string{"-p", "0", "-state", "SYN_RCVD"}, exitUsage},
+		{"monitor の全てのプロセス", setupMonitorFlags, []string{"-p", "0"}, exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("check", flag.ContinueOnError)
+			opts := tt.setup(fs)
+			err := parseFlags(fs, opts, append([]string{"-check", "-q"}, tt.args...))
+			if got := exitCodeOf(err); got != tt.want {
+				t.Errorf("終了コード = %d (%v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
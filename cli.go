@@ -0,0 +1,340 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// --- サブコマンドの一覧と使用方法・補完 ---
+// サブコマンドは commandList に登録する。全体の使用方法、各サブコマンドの -h (newFlagSet で作った FlagSet)、
+// help サブコマンド、シェルの補完 (completion) はこの一覧と、command.flags で定義するフラグから作る。
+// サブコマンド固有のオプションと、setupFlags で定義する共通のオプションは -h で分けて表示する。
+
+// command は 1 つのサブコマンド。
+type command struct {
+	name    string
+	summary string                          // 一覧と -h に表示する説明
+	args    string                          // オプションの後に指定する引数の書式 (例: <変更前の記録> <変更後の記録>)
+	note    string                          // -h で説明の後に表示する補足
+	actions []string                        // 最初の引数に指定する操作 (service install など)
+	flags   func(fs *flag.FlagSet) *options // サブコマンドのフラグを定義し、共通のオプションを返す (run と同じ定義を使う)。フラグが無い場合は nil
+	run     func(args []string) error       // サブコマンド名より後の引数で実行する
+}
+
+// flagsOf は setupXxxFlags (フラグを定義して値の格納先を返す関数) を command.flags に登録できる形にする。
+// 格納先が共通のオプション (*options またはそれを埋め込んだ構造体) でない場合、command.flags は nil を返す。
+func flagsOf[T any](setup func(fs *flag.FlagSet) T) func(fs *flag.FlagSet) *options {
+	return func(fs *flag.FlagSet) *options {
+		if c, ok := any(setup(fs)).(interface{ commonOptions() *options }); ok {
+			return c.commonOptions()
+		}
+		return nil
+	}
+}
+
+// commandList はサブコマンドの一覧を、全体の使用方法に表示する順に返す。
+// 説明を表示言語で返すため、言語を決めた後に呼び出す。
+func commandList() []command {
+	return []command{
+		{name: "monitor", run: runMonitorMode, flags: flagsOf(setupMonitorFlags), summary: tr("接続の状態変化 (新規、変化、終了) を監視します。")},
+		{name: "snapshot", run: runSnapshotMode, flags: flagsOf(setupSnapshotFlags), summary: tr("指定した間隔で、現在の全接続状態をスナップショットとして表示します。")},
+		{name: "listen", run: runListenMode, flags: flagsOf(setupListenFlags), summary: tr("待ち受け (LISTEN) ソケットをインターフェースとともに一覧表示し、増減を監視します。")},
+		{name: "stats", run: runStatsMode, flags: flagsOf(setupStatsFlags), summary: tr("指定した間隔で、プロセス別の集計 (状態別件数、リモートホスト数、新規/終了数) を表示します。")},
+		{name: "top", run: runTopMode, flags: flagsOf(setupTopFlags), summary: tr("プロセス別の接続数を画面上で更新しながら表示します (接続数・状態・プロセス名で並べ替え)。")},
+		{name: "trace", run: runTraceMode, flags: flagsOf(setupTraceFlags), summary: tr("ETW でカーネルの接続・切断イベントを受け取り、ポーリング間隔より短い接続も記録します (要管理者権限)。")},
+		{name: "exporter", run: runExporterMode, flags: flagsOf(setupExporterFlags), summary: tr("Prometheus 形式のメトリクスを HTTP (/metrics) で公開します。")},
+		{name: "serve", run: runServeMode, flags: flagsOf(setupServeFlags), summary: tr("HTTP API (/connections, /events) で現在の接続と状態変化 (NDJSON/SSE) を提供します。")},
+		{name: "query", run: runQueryMode, flags: flagsOf(setupQueryFlags), summary: tr("-store で保存したイベントを、プロセス・リモート・時間帯で検索します。")},
+		{name: "replay", run: runReplayMode, flags: flagsOf(setupReplayFlags), summary: tr("記録 (json 出力または -store のデータベース) を別のフィルタで再生し、差分と ALERT を判定し直します。"),
+			args: tr("<記録ファイル (.json または .db)>")},
+		{name: "export", run: runExportMode, flags: flagsOf(setupExportFlags), summary: tr("-store のデータベース、または一定時間の監視結果を NDJSON/Parquet に書き出します。"),
+			args: tr("[データベース (.db)]"), note: tr("データベースを省略すると、-duration/-until の間だけ監視した状態変化を書き出します。")},
+		{name: "diff", run: runDiffMode, flags: flagsOf(setupDiffFlags), summary: tr("保存した 2 つの接続一覧 (json 出力または -store) を比べ、追加・削除・状態の変化を表示します。"),
+			args: tr("<変更前の記録> <変更後の記録>"), note: tr("記録は -format json の出力、または -store のデータベース。複数の時点を含む場合は最後の時点の接続一覧を使う。")},
+		{name: "baseline", run: runBaselineCommand, flags: flagsOf(setupBaselineFlags), summary: tr("record: 一定期間の監視で通常の接続先を記録し、monitor -baseline で使うファイルに書き出します。"),
+			actions: []string{"record"}},
+		{name: "agent", run: runAgentMode, flags: flagsOf(setupAgentFlags), summary: tr("monitor と同じく監視し、イベントを gRPC (TLS) で collector へ送ります。")},
+		{name: "collector", run: runCollectorMode, flags: flagsOf(setupCollectorFlags), summary: tr("複数のホストの agent からイベントを受け取り、ホスト名を付けて 1 つの -store に保存します。")},
+		{name: "doctor", run: runDoctorMode, flags: flagsOf(setupDoctorFlags), summary: tr("権限、API、ETW、ファイアウォールなど動作に必要な条件を確認し、問題があれば対処方法を表示します。")},
+		{name: "service", run: runServiceCommand, flags: flagsOf(setupServiceFlags), summary: tr("Windows サービスとして monitor を登録・削除・実行します (install/uninstall/run)。"),
+			actions: []string{"install", "uninstall", "run"},
+			note: strings.Join([]string{
+				tr("  install    monitor のオプションを引き継いでサービスを登録します (自動起動)。"),
+				tr("  uninstall  サービスを削除します。"),
+				tr("  run        サービスとして監視を実行します (サービスマネージャーから呼ばれます)。"),
+			}, "\n")},
+		{name: "flight-dump", run: runFlightDumpCommand, summary: tr("-flight-recorder を指定して動作中の monitor に、メモリに保持しているイベントの書き出しを通知します。")},
+		{name: "version", run: runVersionCommand, flags: flagsOf(setupVersionFlags), summary: tr("バージョン、コミット、ビルド日時と、Go・Windows のバージョンを表示します。")},
+		{name: "help", run: runHelpCommand, summary: tr("全体、またはサブコマンドの使用方法を表示します。"), args: tr("[サブコマンド]")},
+		{name: "completion", run: runCompletionCommand, summary: tr("PowerShell または bash の補完スクリプトを出力します。"), args: "<powershell|bash>",
+			note: tr("PowerShell: プロファイルに「obustat completion powershell | Out-String | Invoke-Expression」を追加します。\nbash: ~/.bashrc に「source <(obustat completion bash)」を追加します。")},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commandList() {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, tr("使用方法: %s [-lang ja|en] <サブコマンド> [オプション]\n\n"), os.Args[0])
+	fmt.Fprintln(os.Stderr, tr("サブコマンド:"))
+	for _, c := range commandList() {
+		fmt.Fprintf(os.Stderr, "  %-11s%s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, tr("\n終了コード:"))
+	fmt.Fprintln(os.Stderr, tr("  0  正常終了"))
+	fmt.Fprintln(os.Stderr, tr("  1  snapshot で、監視対象に一致する接続が無かった"))
+	fmt.Fprintln(os.Stderr, tr("  2  引数や設定ファイルの指定が不正"))
+	fmt.Fprintln(os.Stderr, tr("  3  接続情報の取得や出力先の操作に失敗した"))
+	fmt.Fprintln(os.Stderr, tr("  4  -exit-on-alert により ALERT で終了した"))
+	fmt.Fprintf(os.Stderr, tr("\n各サブコマンドのオプションは %s help <サブコマンド> (または -h) で確認できます。\n"), os.Args[0])
+	fmt.Fprintf(os.Stderr, tr("例: %s monitor -n java.exe -i 200\n"), os.Args[0])
+}
+
+// newFlagSet はサブコマンドの FlagSet を作る。-h では printCommandUsage の使用方法を表示する。
+// name はサブコマンド名 (操作がある場合は "service install" のように操作を続ける)。
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() { printCommandUsage(fs) }
+	return fs
+}
+
+// commandFlags は c を実行せずに、c のフラグを定義した FlagSet を返す。action は FlagSet の名前に続ける操作 (省略時は空)。
+func commandFlags(c command, action string) *flag.FlagSet {
+	name := c.name
+	if action != "" {
+		name += " " + action
+	}
+	fs := newFlagSet(name)
+	if c.flags != nil {
+		c.flags(fs)
+	}
+	return fs
+}
+
+// commonFlagNames は defineCommonFlags で定義する、共通のオプションの名前を返す。
+func commonFlagNames() map[string]bool {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defineCommonFlags(fs)
+	names := make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) { names[f.Name] = true })
+	return names
+}
+
+// printCommandUsage はサブコマンドの使用方法を、固有のオプションと共通のオプションに分けて表示する。
+func printCommandUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	name, _, _ := strings.Cut(fs.Name(), " ")
+	c, _ := findCommand(name)
+	synopsis := fs.Name()
+	if len(c.actions) > 1 && synopsis == name {
+		synopsis += " <" + strings.Join(c.actions, "|") + ">"
+	}
+	synopsis += tr(" [オプション]")
+	if c.args != "" {
+		synopsis += " " + c.args
+	}
+	fmt.Fprintf(w, tr("使用方法: %s %s\n"), os.Args[0], synopsis)
+	if c.summary != "" {
+		fmt.Fprintln(w, c.summary)
+	}
+	if c.note != "" {
+		fmt.Fprintln(w, c.note)
+	}
+	common := commonFlagNames()
+	var own, shared []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		if common[f.Name] {
+			shared = append(shared, f)
+		} else {
+			own = append(own, f)
+		}
+	})
+	if len(own) > 0 {
+		fmt.Fprintf(w, tr("\n%s のオプション:\n"), fs.Name())
+		printFlags(w, own)
+	}
+	if len(shared) > 0 {
+		fmt.Fprintln(w, tr("\n共通のオプション (全てのサブコマンドで同じ意味):"))
+		printFlags(w, shared)
+	}
+}
+
+// printFlags は flag.PrintDefaults と同じ書式でフラグを表示する。既定値の表記は表示言語に合わせる。
+func printFlags(w io.Writer, flags []*flag.Flag) {
+	for _, f := range flags {
+		valueName, usage := flag.UnquoteUsage(f)
+		line := "  -" + f.Name
+		if valueName != "" {
+			line += " " + valueName
+		}
+		// 1 文字のフラグは説明を同じ行に続ける (flag.PrintDefaults と同じ)。
+		if len(line) <= 4 {
+			line += "\t"
+		} else {
+			line += "\n    \t"
+		}
+		line += strings.ReplaceAll(usage, "\n", "\n    \t")
+		if !slices.Contains([]string{"", "0", "false", "0s"}, f.DefValue) {
+			def := f.DefValue
+			if valueName == "string" {
+				def = fmt.Sprintf("%q", def)
+			}
+			line += fmt.Sprintf(tr(" (既定値: %s)"), def)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// --- help サブコマンド ---
+// 例: help diff, help service install
+func runHelpCommand(args []string) error {
+	fs := newFlagSet("help")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		printUsage()
+		return nil
+	}
+	c, ok := findCommand(fs.Arg(0))
+	if !ok || c.name == "help" {
+		return usageErrorf(tr("不明なサブコマンドです: %s"), fs.Arg(0))
+	}
+	var action string
+	if len(c.actions) == 1 {
+		action = c.actions[0]
+	}
+	if fs.NArg() > 1 && len(c.actions) > 0 {
+		if action = fs.Arg(1); !slices.Contains(c.actions, action) {
+			return usageErrorf(tr("不明なサブコマンドです: %s"), c.name+" "+action)
+		}
+	}
+	// 操作を省略した場合は、操作の一覧を使用方法の書式に含める。
+	commandFlags(c, action).Usage()
+	return nil
+}
+
+// --- completion サブコマンド ---
+// サブコマンド・操作・フラグの名前を埋め込んだ補完スクリプトを出力する。フラグの値は補完しない。
+func runCompletionCommand(args []string) error {
+	fs := newFlagSet("completion")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitStatus(exitUsage)
+	}
+	program := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	switch strings.ToLower(fs.Arg(0)) {
+	case "powershell", "pwsh":
+		writePowerShellCompletion(os.Stdout, program)
+	case "bash":
+		writeBashCompletion(os.Stdout, program)
+	default:
+		return usageErrorf(tr("補完に対応していないシェルです: %s (指定可能: powershell, bash)"), fs.Arg(0))
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, program string) {
+	fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program)
+	commands := commandList()
+	fmt.Fprintf(w, "# %s completion bash\n", program)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintln(w, `	local cur=${COMP_WORDS[COMP_CWORD]} words=""`)
+	fmt.Fprintln(w, `	if [ "$COMP_CWORD" -eq 1 ]; then`)
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, `	case ${COMP_WORDS[1]} in`)
+	for _, c := range commands {
+		var flagNames []string
+		commandFlags(c, "").VisitAll(func(f *flag.Flag) { flagNames = append(flagNames, "-"+f.Name) })
+		fmt.Fprintf(w, "\t%s)\n", c.name)
+		if c.name == "help" {
+			fmt.Fprintf(w, "\t\twords=%q ;;\n", strings.Join(names, " "))
+			continue
+		}
+		if len(c.actions) > 0 {
+			fmt.Fprintf(w, "\t\tif [ \"$COMP_CWORD\" -eq 2 ]; then words=%q; else words=%q; fi ;;\n",
+				strings.Join(c.actions, " "), strings.Join(flagNames, " "))
+			continue
+		}
+		fmt.Fprintf(w, "\t\twords=%q ;;\n", strings.Join(flagNames, " "))
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, `	COMPREPLY=($(compgen -W "$words" -- "$cur"))`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, "complete -F %s %s %s.exe\n", fn, program, program)
+}
+
+func writePowerShellCompletion(w io.Writer, program string) {
+	quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+	commands := commandList()
+	fmt.Fprintf(w, "# %s completion powershell\n", program)
+	fmt.Fprintf(w, "Register-ArgumentCompleter -Native -CommandName %s, %s -ScriptBlock {\n", quote(program), quote(program+".exe"))
+	fmt.Fprintln(w, "    param($wordToComplete, $commandAst, $cursorPosition)")
+	fmt.Fprintln(w, "    $commands = [ordered]@{")
+	for _, c := range commands {
+		fmt.Fprintf(w, "        %s = %s\n", quote(c.name), quote(c.summary))
+	}
+	fmt.Fprintln(w, "    }")
+	fmt.Fprintln(w, "    $actions = @{")
+	for _, c := range commands {
+		if len(c.actions) > 0 {
+			quoted := make([]string, len(c.actions))
+			for i, a := range c.actions {
+				quoted[i] = quote(a)
+			}
+			fmt.Fprintf(w, "        %s = @(%s)\n", quote(c.name), strings.Join(quoted, ", "))
+		}
+	}
+	fmt.Fprintln(w, "    }")
+	fmt.Fprintln(w, "    $flags = @{")
+	for _, c := range commands {
+		fmt.Fprintf(w, "        %s = [ordered]@{\n", quote(c.name))
+		commandFlags(c, "").VisitAll(func(f *flag.Flag) {
+			_, usage := flag.UnquoteUsage(f)
+			fmt.Fprintf(w, "            %s = %s\n", quote("-"+f.Name), quote(usage))
+		})
+		fmt.Fprintln(w, "        }")
+	}
+	fmt.Fprintln(w, "    }")
+	fmt.Fprint(w, `    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -ne '' -and $words.Count -gt 0) { $words = @($words | Select-Object -SkipLast 1) }
+    if ($words.Count -eq 0) {
+        $candidates = $commands
+    } elseif ($words[0] -eq 'help' -and $words.Count -eq 1) {
+        $candidates = $commands
+    } elseif ($actions.Contains($words[0]) -and $words.Count -eq 1) {
+        $candidates = [ordered]@{}
+        foreach ($a in $actions[$words[0]]) { $candidates[$a] = $a }
+    } elseif ($flags.Contains($words[0])) {
+        $candidates = $flags[$words[0]]
+    } else {
+        return
+    }
+    foreach ($name in $candidates.Keys) {
+        if ($name -like "$wordToComplete*") {
+            [System.Management.Automation.CompletionResult]::new($name, $name, 'ParameterValue', $candidates[$name])
+        }
+    }
+}
+`)
+}
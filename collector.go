@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go-ObuStat/conn"
+	"go-ObuStat/obustatpb"
+
+	"google.golang.org/grpc"
+)
+
+// --- collector モード (複数のホストの agent からイベントを受け取る) ---
+// 例: collector -listen :9479 -store all.db -tls-cert server.pem -tls-key server-key.pem -client-ca agents-ca.pem
+func runCollectorMode(args []string) error {
+	fs := newFlagSet("collector")
+	opts := setupCollectorFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+	if opts.store == "" {
+		return usageError(tr("-store で保存先のデータベースを指定してください。"))
+	}
+	if err := opts.security.loadFlags(!opts.plaintext); err != nil {
+		return err
+	}
+	output, err := newOutputFormatter(opts.format, opts.options)
+	if err != nil {
+		return err
+	}
+
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	db, err := openStoreDB(opts.store)
+	if err != nil {
+		return fmt.Errorf(tr("-store のデータベースを開けませんでした: %w"), err)
+	}
+	defer db.Close()
+
+	listener, err := opts.security.listen(opts.listenAddr)
+	if err != nil {
+		return fmt.Errorf(tr("%s で待ち受けできませんでした: %w"), opts.listenAddr, err)
+	}
+	server := grpc.NewServer(opts.security.grpcOptions()...)
+	obustatpb.RegisterCollectorServer(server, &collectorServer{
+		output: output,
+		store: func(host string, output outputFormatter) outputFormatter {
+			return &storeFormatter{outputFormatter: output, db: db, snapshotInterval: opts.storeSnapshot, current: make(conn.Snapshot), host: host}
+		},
+	})
+
+	infoLog.Print(tr("--- collector モード開始 ---"))
+	infoLog.Printf(tr("待ち受け: %s (保存先: %s, Ctrl+Cで停止)"), opts.listenAddr, opts.store)
+	ctx, stop := opts.runContext()
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	if err := server.Serve(listener); err != nil {
+		return fmt.Errorf(tr("gRPC サーバーが停止しました: %w"), err)
+	}
+	infoLog.Print(tr("--- collector モード終了 ---"))
+	return nil
+}
+
+// collectorOptions は collector のオプション。
+type collectorOptions struct {
+	*options
+	listenAddr string
+	security   *listenSecurity
+	plaintext  bool
+}
+
+// setupCollectorFlags は collector のフラグを定義する。
+func setupCollectorFlags(fs *flag.FlagSet) *collectorOptions {
+	opts := &collectorOptions{options: setupFlags(fs, periodFlags, outputFlags, flagGroup{"store", "store-snapshot"})}
+	fs.StringVar(&opts.listenAddr, "listen", ":9479", tr("gRPC の待ち受けアドレス"))
+	opts.security = setupListenSecurity(fs)
+	fs.BoolVar(&opts.plaintext, "insecure", false, tr("TLS を使わずに待ち受ける (検証環境用)"))
+	return opts
+}
+
+// collectorServer は agent ごとのストリームを受け取り、送信元のホスト名を付けて 1 つの -store に保存する。
+type collectorServer struct {
+	obustatpb.UnimplementedCollectorServer
+	store func(host string, output outputFormatter) outputFormatter
+
+	mu     sync.Mutex // 出力とデータベースへの書き込みを、全てのストリームで 1 つずつにする
+	output outputFormatter
+}
+
+func (s *collectorServer) Push(stream grpc.ClientStreamingServer[obustatpb.AgentBatch, obustatpb.PushSummary]) error {
+	var (
+		host      string
+		formatter outputFormatter
+		batches   uint64
+	)
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			infoLog.Printf(tr("agent が切断しました: %s (受信: %d 回)"), host, batches)
+			return stream.SendAndClose(&obustatpb.PushSummary{Batches: batches})
+		}
+		if err != nil {
+			if host != "" {
+				warnLog.Printf(tr("agent との接続が切れました: %s: %v"), host, err)
+			}
+			return err
+		}
+		if formatter == nil {
+			// 送信元ごとに現在の接続一覧を持つため、最初のまとまりのホスト名で保存先を作る。
+			host = batch.GetHost()
+			formatter = s.store(host, s.output)
+			infoLog.Printf(tr("agent が接続しました: %s"), host)
+		}
+		batches++
+		s.write(formatter, host, batch)
+	}
+}
+
+func (s *collectorServer) write(formatter outputFormatter, host string, batch *obustatpb.AgentBatch) {
+	timestamp := time.UnixMilli(batch.GetTimestampMs())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if batch.GetIsSnapshot() {
+		conns := snapshotFromProto(batch.GetSnapshot())
+		for key, c := range conns {
+			c.Host = host
+			conns[key] = c
+		}
+		formatter.writeSnapshot(timestamp, conns)
+		return
+	}
+	events := eventsFromProto(batch.GetEvents())
+	for i := range events {
+		events[i].Conn.Host = host
+	}
+	if len(events) > 0 {
+		formatter.writeEvents(timestamp, events)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- 設定ファイル (YAML) ---
+// 設定ファイルのキーはフラグ名そのもの (例: proto, format) か、以下の別名で指定する。
+var configKeyAliases = map[string]string{
+	"processes": "n",
+	"pids":      "p",
+	"output":    "o",
+	"interval":  "i",
+	"protocols": "proto",
+}
+
+// applyConfigFile は設定ファイルの値を、コマンドラインで明示されていないフラグに反映する。
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	setOnCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setOnCommandLine[f.Name] = true })
+	common := commonFlagNames()
+
+	for key, value := range values {
+		if key == "profiles" {
+			continue // loadProfiles で読み込む
+		}
+		name := key
+		if alias, ok := configKeyAliases[key]; ok {
+			name = alias
+		}
+		if fs.Lookup(name) == nil {
+			if common[name] {
+				// 同じ設定ファイルを複数のサブコマンドで使えるよう、このサブコマンドが読み取らない共通のオプションは無視する。
+				continue
+			}
+			return fmt.Errorf(tr("%s: 不明な設定項目です: %q"), path, key)
+		}
+		if setOnCommandLine[name] {
+			continue
+		}
+		// 繰り返し指定できるフラグ (-o) は、リストの要素ごとに指定したものとして扱う。
+		if items, ok := value.([]any); ok {
+			if _, repeatable := fs.Lookup(name).Value.(*outputList); repeatable {
+				for _, item := range items {
+					s, err := configValueString(item)
+					if err != nil {
+						return fmt.Errorf("%s: %s: %w", path, key, err)
+					}
+					if err := fs.Set(name, s); err != nil {
+						return fmt.Errorf("%s: %s: %w", path, key, err)
+					}
+				}
+				continue
+			}
+		}
+		s, err := configValueString(value)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+		if err := fs.Set(name, s); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// configValueString は YAML の値をフラグに渡せる文字列に変換する。リストはカンマ区切りにする。
+func configValueString(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configValueString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		return "", errors.New(tr("入れ子の設定には対応していません"))
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(fs *flag.FlagSet) *options
+		args    []string
+		config  string
+		want    map[string]string // フラグ名 → 反映後の値
+		wantErr bool
+	}{
+		{"フラグ名", setupMonitorFlags, nil, "format: json\nmax-files: 3\n", map[string]string{"format": "json", "max-files": "3"}, false},
+		{"別名とリスト", setupMonitorFlags, nil, "processes: [app.exe, svc.exe]\nprotocols: [tcp, udp]\n",
+			map[string]string{"n": "app.exe,svc.exe", "proto": "tcp,udp"}, false},
+		{"コマンドラインを優先", setupMonitorFlags, []string{"-format", "csv"}, "format: json\n", map[string]string{"format": "csv"}, false},
+		{"繰り返し指定できるフラグ", setupMonitorFlags, nil, "output: [a.log, json=b.jsonl]\n", map[string]string{"o": "a.log, json=b.jsonl"}, false},
+		{"profiles は読み飛ばす", setupMonitorFlags, nil, "format: json\nprofiles:\n  web:\n    n: w3wp.exe\n", map[string]string{"format": "json", "n": ""}, false},
+		{"このサブコマンドが読み取らない共通のオプション", flagsOf(setupQueryFlags), nil, "interval: 5s\nformat: json\n", map[string]string{"format": "json"}, false},
+		{"不明な設定項目", setupMonitorFlags, nil, "unknown: 1\n", nil, true},
+		{"入れ子の設定", setupMonitorFlags, nil, "format:\n  name: json\n", nil, true},
+		{"フラグに渡せない値", setupMonitorFlags, nil, "max-files: many\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "obustat.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			fs := flag.NewFlagSet("monitor", flag.ContinueOnError)
+			tt.setup(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			err := applyConfigFile(fs, path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyConfigFile = %v, wantErr %v", err, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("-%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValueString(t *testing.T) {
+	tests := []struct {
+		value   any
+		want    string
+		wantErr bool
+	}{
+		{nil, "", false},
+		{"json", "json", false},
+		{5, "5", false},
+		{true, "true", false},
+		{[]any{"tcp", "udp"}, "tcp,udp", false},
+		{[]any{80, []any{443, 8443}}, "80,443,8443", false},
+		{map[string]any{"name": "json"}, "", true},
+		{[]any{map[string]any{"name": "json"}}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := configValueString(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("configValueString(%v) = %q, %v, want %q (wantErr %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
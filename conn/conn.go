@@ -0,0 +1,128 @@
+// Package conn は Windows の TCP/UDP テーブルを取得し、プロセス単位で接続を監視するための機能を提供する。
+package conn
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// Connection は 1 つの TCP 接続または UDP エンドポイントを表す。
+type Connection struct {
+	Protocol       string
+	ProcessName    string
+	PID            uint32
+	ProcessStart   time.Time // プロセスの開始時刻。PID が再利用された別のプロセスと区別するために使う (取得できない場合はゼロ値)
+	LocalAddr      string
+	LocalPort      uint16
+	LocalInterface string // 呼び出し側で付加したローカルアドレスのインターフェース名 (conn パッケージは設定しない)
+	RemoteAddr     string
+	RemotePort     uint16
+	RemoteHost     string // 呼び出し側で逆引きしたリモートのホスト名 (conn パッケージは設定しない)
+	RemoteService  string // 呼び出し側で付加したリモートポートのサービス名 (conn パッケージは設定しない)
+	State          string
+	FirstSeen      time.Time     // 初めて検出された時刻 (Diff により前回の値が引き継がれる)
+	Traffic        *TrafficStats // Filter.CollectTraffic 指定時のみ、ESTABLISHED の TCP 接続に設定される
+	ImagePath      string        // 呼び出し側で付加した実行ファイルのパス (conn パッケージは設定しない)
+	CommandLine    string        // 呼び出し側で付加したコマンドライン (conn パッケージは設定しない)
+	User           string        // 呼び出し側で付加したプロセスの所有者 (conn パッケージは設定しない)
+	Module         string        // Filter.CollectModules 指定時のみ、TCP 接続を所有するモジュール (サービス名など)
+	Services       []string      // 呼び出し側で付加した、プロセスがホストしているサービス名 (conn パッケージは設定しない)
+	Container      string        // 呼び出し側で付加した、プロセスが属する Windows コンテナの ID (conn パッケージは設定しない)
+	InJob          bool          // 呼び出し側で付加した、プロセスがジョブオブジェクトに属しているか (conn パッケージは設定しない)
+	Host           string        // 呼び出し側で付加した、接続を観測したホスト名 (collector が受け取った接続のみ)
+	Country        string        // 呼び出し側で付加した、リモートアドレスの国コード (ISO 3166-1, conn パッケージは設定しない)
+	ASN            uint32        // 呼び出し側で付加した、リモートアドレスの AS 番号 (conn パッケージは設定しない)
+	ASOrg          string        // 呼び出し側で付加した、リモートアドレスの AS の組織名 (conn パッケージは設定しない)
+	Profiles       []string      // 呼び出し側で付加した、接続が一致した監視プロファイルの名前 (conn パッケージは設定しない)
+	Peer           *Peer         // 呼び出し側で付加した、ループバック接続の相手側のプロセス (conn パッケージは設定しない)
+}
+
+// Peer は、同じマシン上にあるループバック接続の相手側の端点を所有するプロセス。
+type Peer struct {
+	ProcessName string
+	PID         uint32
+	Server      bool // 相手側が待ち受けているポートの側 (サーバー) か
+}
+
+// Snapshot はある時点の接続一覧で、キーは Connection.Key の値。
+type Snapshot map[string]Connection
+
+// Lifetime は初めて検出されてから now までの経過時間を返す。
+func (conn Connection) Lifetime(now time.Time) time.Duration {
+	if conn.FirstSeen.IsZero() {
+		return 0
+	}
+	return now.Sub(conn.FirstSeen)
+}
+
+// UDPState は状態を持たない UDP エンドポイントの状態欄に設定される値。
+const UDPState = "-"
+
+// Key は接続を一意に識別する文字列 (例: "TCP 10.0.0.1:50000 -> 10.0.0.2:443") を返す。
+func (conn Connection) Key() string {
+	local := net.JoinHostPort(conn.LocalAddr, strconv.Itoa(int(conn.LocalPort)))
+	if conn.Protocol == "UDP" {
+		return fmt.Sprintf("UDP %s", local)
+	}
+	return fmt.Sprintf("TCP %s -> %s", local, net.JoinHostPort(conn.RemoteAddr, strconv.Itoa(int(conn.RemotePort))))
+}
+
+// OwnerKey は Key に PID を加えた文字列 (例: "UDP 0.0.0.0:5353 (PID 1234)") を返す。
+// 同じアドレスとポートの組を複数のプロセスが同時に使っている場合 (SO_REUSEADDR など) に、2 つ目以降の接続のキーに使う。
+func (conn Connection) OwnerKey() string {
+	return fmt.Sprintf("%s (PID %d)", conn.Key(), conn.PID)
+}
+
+func ipv4Addr(ip uint32) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(ip), byte(ip >> 8), byte(ip >> 16), byte(ip >> 24)})
+}
+
+// addrString はアドレスを Connection の表記にする。IPv6 のテーブルの IPv4 射影アドレスは IPv4 の表記になる。
+func addrString(addr netip.Addr) string {
+	if addr.Is4() {
+		return addr.String()
+	}
+	ip := addr.As16()
+	return net.IP(ip[:]).String()
+}
+func portToUint16(port uint32) uint16 { return uint16((port >> 8) | ((port & 0xFF) << 8)) }
+
+// TCPStateNames は Connection.State に設定される TCP の状態名の一覧。
+var TCPStateNames = []string{
+	"CLOSED", "LISTEN", "SYN_SENT", "SYN_RECV", "ESTABLISHED", "FIN_WAIT1", "FIN_WAIT2",
+	"CLOSE_WAIT", "CLOSING", "LAST_ACK", "TIME_WAIT", "DELETE_TCB",
+}
+
+func getTCPStateName(state uint32) string {
+	switch state {
+	case 1:
+		return "CLOSED"
+	case 2:
+		return "LISTEN"
+	case 3:
+		return "SYN_SENT"
+	case 4:
+		return "SYN_RECV"
+	case 5:
+		return "ESTABLISHED"
+	case 6:
+		return "FIN_WAIT1"
+	case 7:
+		return "FIN_WAIT2"
+	case 8:
+		return "CLOSE_WAIT"
+	case 9:
+		return "CLOSING"
+	case 10:
+		return "LAST_ACK"
+	case 11:
+		return "TIME_WAIT"
+	case 12:
+		return "DELETE_TCB"
+	default:
+		return "UNKNOWN"
+	}
+}
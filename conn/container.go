@@ -0,0 +1,184 @@
+package conn
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- ジョブオブジェクトと Windows コンテナ ---
+// プロセス分離の Windows コンテナはホストとカーネルを共有するため、コンテナ内のプロセスもホストの PID で接続を持つ。
+// どのコンテナのプロセスかは Host Compute Service (vmcompute.dll) の ProcessList で調べる (要管理者権限)。
+// Hyper-V 分離のコンテナはプロセスがホストから見えないため対象外。
+
+var (
+	kernel32           = windows.NewLazySystemDLL("kernel32.dll")
+	procIsProcessInJob = kernel32.NewProc("IsProcessInJob")
+
+	vmcompute                         = windows.NewLazySystemDLL("vmcompute.dll")
+	procHcsEnumerateComputeSystems    = vmcompute.NewProc("HcsEnumerateComputeSystems")
+	procHcsOpenComputeSystem          = vmcompute.NewProc("HcsOpenComputeSystem")
+	procHcsGetComputeSystemProperties = vmcompute.NewProc("HcsGetComputeSystemProperties")
+	procHcsCloseComputeSystem         = vmcompute.NewProc("HcsCloseComputeSystem")
+)
+
+// AnyContainer は Filter.Containers で、いずれかのコンテナのプロセスを表す指定。
+const AnyContainer = "*"
+
+// minContainerRefresh は ContainerOf で、コンテナの一覧を取得し直す最短の間隔。
+const minContainerRefresh = 5 * time.Second
+
+// ProcessInJob は PID のプロセスがジョブオブジェクトに属しているかを返す。
+// ジョブの名前は他のプロセスからは取得できないため、属しているかどうかのみ判定する。
+func ProcessInJob(pid uint32) (bool, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return false, err
+	}
+	defer windows.CloseHandle(h)
+	var result int32
+	if ret, _, err := procIsProcessInJob.Call(uintptr(h), 0, uintptr(unsafe.Pointer(&result))); ret == 0 {
+		return false, err
+	}
+	return result != 0, nil
+}
+
+// containerTable はコンテナ内のプロセスの PID → コンテナ ID の対応表。
+type containerTable struct {
+	mu        sync.Mutex
+	byPID     map[uint32]string
+	updatedAt time.Time
+}
+
+var containers = &containerTable{byPID: make(map[uint32]string)}
+
+// refresh は実行中のコンテナとそのプロセスを取得し直す。HCS に接続できない場合は前回の対応表を残す。
+func (t *containerTable) refresh() {
+	byPID, err := listContainerProcesses()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.updatedAt = time.Now()
+	if err == nil {
+		t.byPID = byPID
+	}
+}
+
+func (t *containerTable) get(pid uint32) string {
+	t.mu.Lock()
+	stale := time.Since(t.updatedAt) >= minContainerRefresh
+	t.mu.Unlock()
+	if stale {
+		t.refresh()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.byPID[pid]
+}
+
+// ContainerOf は PID のプロセスが属する Windows コンテナの ID を返す。コンテナのプロセスでない場合は空文字列を返す。
+func ContainerOf(pid uint32) string {
+	if pid == 0 || pid == 4 {
+		return ""
+	}
+	return containers.get(pid)
+}
+
+// MatchContainer は containerID が Filter.Containers の指定 (ID の前方一致、または AnyContainer) に一致するかを判定する。
+func MatchContainer(containerID string, patterns []string) bool {
+	if containerID == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if p == AnyContainer || (p != "" && strings.HasPrefix(strings.ToLower(containerID), strings.ToLower(p))) {
+			return true
+		}
+	}
+	return false
+}
+
+type hcsSystem struct {
+	ID         string `json:"Id"`
+	SystemType string `json:"SystemType"`
+}
+
+type hcsProperties struct {
+	ProcessList []struct {
+		ProcessID uint32 `json:"ProcessId"`
+	} `json:"ProcessList"`
+}
+
+// listContainerProcesses は HCS からコンテナの一覧と、それぞれのプロセスを取得する。
+func listContainerProcesses() (map[uint32]string, error) {
+	if err := vmcompute.Load(); err != nil {
+		return nil, err // コンテナ機能が無い環境
+	}
+	var systems []hcsSystem
+	if err := hcsCall(procHcsEnumerateComputeSystems, &systems, `{"Types":["Container"]}`); err != nil {
+		return nil, err
+	}
+	byPID := make(map[uint32]string)
+	for _, s := range systems {
+		if s.SystemType != "" && s.SystemType != "Container" {
+			continue
+		}
+		var props hcsProperties
+		if err := containerProperties(s.ID, &props); err != nil {
+			continue // 列挙の後に停止したコンテナ
+		}
+		for _, p := range props.ProcessList {
+			byPID[p.ProcessID] = s.ID
+		}
+	}
+	return byPID, nil
+}
+
+func containerProperties(id string, props *hcsProperties) error {
+	idPtr, err := windows.UTF16PtrFromString(id)
+	if err != nil {
+		return err
+	}
+	var system windows.Handle
+	var result *uint16
+	hr, _, _ := procHcsOpenComputeSystem.Call(uintptr(unsafe.Pointer(idPtr)), uintptr(unsafe.Pointer(&system)), uintptr(unsafe.Pointer(&result)))
+	if err := hcsResult("HcsOpenComputeSystem", hr, result); err != nil {
+		return err
+	}
+	defer procHcsCloseComputeSystem.Call(uintptr(system))
+	return hcsCall(procHcsGetComputeSystemProperties, props, `{"PropertyTypes":["ProcessList"]}`, uintptr(system))
+}
+
+// hcsCall は (前置の引数..., query, &output, &result) の形の HCS 関数を呼び出し、出力の JSON を out に読み込む。
+func hcsCall(proc *windows.LazyProc, out any, query string, leading ...uintptr) error {
+	queryPtr, err := windows.UTF16PtrFromString(query)
+	if err != nil {
+		return err
+	}
+	var output, result *uint16
+	args := append(leading, uintptr(unsafe.Pointer(queryPtr)), uintptr(unsafe.Pointer(&output)), uintptr(unsafe.Pointer(&result)))
+	hr, _, _ := proc.Call(args...)
+	if output != nil {
+		defer windows.CoTaskMemFree(unsafe.Pointer(output))
+	}
+	if err := hcsResult(proc.Name, hr, result); err != nil {
+		return err
+	}
+	if output == nil {
+		return nil
+	}
+	return json.Unmarshal([]byte(windows.UTF16PtrToString(output)), out)
+}
+
+// hcsResult は HRESULT をエラーにする。HCS が返す結果の JSON は解放する。
+func hcsResult(op string, hr uintptr, result *uint16) error {
+	if result != nil {
+		defer windows.CoTaskMemFree(unsafe.Pointer(result))
+	}
+	if int32(hr) < 0 {
+		return &APIError{Op: op, Err: HResult(hr)}
+	}
+	return nil
+}
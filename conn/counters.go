@@ -0,0 +1,57 @@
+package conn
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- プロセスのハンドル数・スレッド数 ---
+// 接続のリークはハンドルのリークと同時に起きることが多いため、stats で接続数と並べて出力する。
+
+var procGetProcessHandleCount = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetProcessHandleCount")
+
+// ProcessCounters はプロセスのハンドル数とスレッド数。
+type ProcessCounters struct {
+	Handles uint32
+	Threads uint32
+}
+
+// SampleProcessCounters は pids のハンドル数とスレッド数を取得する。
+// 終了していた、または権限が無くハンドル数を取得できなかった PID は結果に含めない。
+func SampleProcessCounters(pids []uint32) map[uint32]ProcessCounters {
+	result := make(map[uint32]ProcessCounters, len(pids))
+	if len(pids) == 0 {
+		return result
+	}
+	wanted := make(map[uint32]bool, len(pids))
+	for _, pid := range pids {
+		wanted[pid] = true
+	}
+	// スレッド数はプロセス一覧 (Toolhelp) から、全 PID の分を 1 回で取得する。
+	threads := make(map[uint32]uint32, len(pids))
+	if snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0); err == nil {
+		var entry windows.ProcessEntry32
+		entry.Size = uint32(unsafe.Sizeof(entry))
+		for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+			if wanted[entry.ProcessID] {
+				threads[entry.ProcessID] = entry.Threads
+			}
+		}
+		windows.CloseHandle(snapshot)
+	}
+	for pid := range wanted {
+		h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+		if err != nil {
+			continue
+		}
+		var handles uint32
+		r, _, _ := procGetProcessHandleCount.Call(uintptr(h), uintptr(unsafe.Pointer(&handles)))
+		windows.CloseHandle(h)
+		if r == 0 {
+			continue
+		}
+		result[pid] = ProcessCounters{Handles: handles, Threads: threads[pid]}
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package conn
+
+import (
+	"fmt"
+	"time"
+)
+
+// --- イベント定義 ---
+type EventType string
+
+const (
+	EventNew    EventType = "NEW"
+	EventChange EventType = "CHANGE"
+	EventClosed EventType = "CLOSED"
+	// EventRebound は、同じアドレスとポートの組を別のプロセスが使い始めたこと (TIME_WAIT 後の再バインドや PID の再利用) を表す。
+	EventRebound EventType = "REBOUND"
+)
+
+// Event は 1 つの接続に起きた状態変化を表す。
+type Event struct {
+	Time      time.Time // Watcher が検出した時刻 (Diff 単体では設定されない)
+	Type      EventType
+	Key       string
+	Conn      Connection
+	PrevState string
+
+	// 以下は呼び出し側で生成するイベント (同時接続数の警告など) で使う。
+	Count  int    // イベントの対象となった件数
+	Detail string // イベントの説明
+}
+
+// Opened は、イベントの接続がそのプロセスにとって新しいもの (NEW または REBOUND) かを返す。
+func (e Event) Opened() bool {
+	return e.Type == EventNew || e.Type == EventRebound
+}
+
+// Diff は前回と今回の Snapshot を比較し、NEW / CHANGE / CLOSED / REBOUND のイベントを返す。
+// 継続している接続については、currentConns の FirstSeen を前回の値で置き換える。
+// 同じアドレスでも所有するプロセスが変わっている場合 (PID の再利用を含む) は、新しい接続として REBOUND にする。
+// Detail には以前の所有プロセスを設定する。
+func Diff(prevConns, currentConns Snapshot) []Event {
+	var events []Event
+	for key, current := range currentConns {
+		prev, existed := prevConns[key]
+		if existed && !SameProcess(prev, current) {
+			events = append(events, Event{
+				Type: EventRebound, Key: key, Conn: current, PrevState: prev.State,
+				Detail: fmt.Sprintf("%s (PID %d) -> %s (PID %d)", prev.ProcessName, prev.PID, current.ProcessName, current.PID),
+			})
+			continue
+		}
+		if existed && !prev.FirstSeen.IsZero() {
+			current.FirstSeen = prev.FirstSeen
+			currentConns[key] = current
+		}
+		if !existed {
+			events = append(events, Event{Type: EventNew, Key: key, Conn: current})
+		} else if prev.State != current.State {
+			events = append(events, Event{Type: EventChange, Key: key, Conn: current, PrevState: prev.State})
+		}
+	}
+	for key, prev := range prevConns {
+		if _, exists := currentConns[key]; !exists {
+			events = append(events, Event{Type: EventClosed, Key: key, Conn: prev})
+		}
+	}
+	return events
+}
+
+// SameProcess は 2 つの接続が同じプロセスのものかを、PID と開始時刻で判定する。
+// 開始時刻が取得できていない場合は PID とプロセス名で判定する。名前を問い合わせ中 (ResolvingName) の場合は同じとみなす。
+func SameProcess(a, b Connection) bool {
+	if a.PID != b.PID {
+		return false
+	}
+	if !a.ProcessStart.IsZero() && !b.ProcessStart.IsZero() {
+		return a.ProcessStart.Equal(b.ProcessStart)
+	}
+	return a.ProcessName == b.ProcessName || a.ProcessName == ResolvingName || b.ProcessName == ResolvingName
+}
@@ -0,0 +1,185 @@
+package conn
+
+import (
+	"cmp"
+	"maps"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	started  = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	restart  = started.Add(time.Hour)
+	firstRun = started.Add(time.Minute)
+)
+
+// tcp は app.exe (PID 100) の 10.0.0.1:50000 -> 10.0.0.2:443 の接続を state で返す。
+func tcp(state string) Connection {
+	return Connection{
+		Protocol: "TCP", ProcessName: "app.exe", PID: 100, ProcessStart: started,
+		LocalAddr: "10.0.0.1", LocalPort: 50000, RemoteAddr: "10.0.0.2", RemotePort: 443,
+		State: state,
+	}
+}
+
+func snapshotOf(conns ...Connection) Snapshot {
+	s := make(Snapshot)
+	for _, c := range conns {
+		s[c.Key()] = c
+	}
+	return s
+}
+
+type eventSummary struct {
+	Type      EventType
+	Key       string
+	PrevState string
+}
+
+func summarize(events []Event) []eventSummary {
+	summaries := make([]eventSummary, len(events))
+	for i, e := range events {
+		summaries[i] = eventSummary{e.Type, e.Key, e.PrevState}
+	}
+	slices.SortFunc(summaries, func(a, b eventSummary) int {
+		return cmp.Or(cmp.Compare(a.Key, b.Key), cmp.Compare(a.Type, b.Type))
+	})
+	return summaries
+}
+
+func TestDiff(t *testing.T) {
+	key := tcp("ESTABLISHED").Key()
+	other := tcp("ESTABLISHED")
+	other.LocalPort = 50001
+
+	reusedPID := tcp("ESTABLISHED")
+	reusedPID.ProcessName, reusedPID.ProcessStart = "other.exe", restart
+	otherPID := tcp("ESTABLISHED")
+	otherPID.ProcessName, otherPID.PID = "other.exe", 200
+
+	tests := []struct {
+		name          string
+		prev, current Snapshot
+		want          []eventSummary
+	}{
+		{"変化なし", snapshotOf(tcp("ESTABLISHED")), snapshotOf(tcp("ESTABLISHED")), []eventSummary{}},
+		{"NEW", snapshotOf(), snapshotOf(tcp("SYN_SENT")), []eventSummary{{EventNew, key, ""}}},
+		{"CHANGE", snapshotOf(tcp("ESTABLISHED")), snapshotOf(tcp("CLOSE_WAIT")), []eventSummary{{EventChange, key, "ESTABLISHED"}}},
+		{"CLOSED", snapshotOf(tcp("ESTABLISHED"), other), snapshotOf(other), []eventSummary{{EventClosed, key, ""}}},
+		{"REBOUND (別の PID)", snapshotOf(tcp("TIME_WAIT")), snapshotOf(otherPID), []eventSummary{{EventRebound, key, "TIME_WAIT"}}},
+		{"REBOUND (PID の再利用)", snapshotOf(tcp("ESTABLISHED")), snapshotOf(reusedPID), []eventSummary{{EventRebound, key, "ESTABLISHED"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarize(Diff(tt.prev, tt.current))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Diff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffKeepsFirstSeen(t *testing.T) {
+	prev := tcp("ESTABLISHED")
+	prev.FirstSeen = firstRun
+	current := tcp("CLOSE_WAIT")
+	current.FirstSeen = firstRun.Add(time.Second)
+	currentConns := snapshotOf(current)
+
+	Diff(snapshotOf(prev), currentConns)
+	if got := currentConns[current.Key()].FirstSeen; !got.Equal(firstRun) {
+		t.Errorf("FirstSeen = %v, want %v", got, firstRun)
+	}
+}
+
+func TestSameProcess(t *testing.T) {
+	conn := func(pid uint32, name string, start time.Time) Connection {
+		return Connection{PID: pid, ProcessName: name, ProcessStart: start}
+	}
+	tests := []struct {
+		name string
+		a, b Connection
+		want bool
+	}{
+		{"同じ PID と開始時刻", conn(100, "app.exe", started), conn(100, "app.exe", started), true},
+		{"別の PID", conn(100, "app.exe", started), conn(200, "app.exe", started), false},
+		{"PID の再利用 (開始時刻が違う)", conn(100, "app.exe", started), conn(100, "app.exe", restart), false},
+		{"開始時刻が無く名前が同じ", conn(100, "app.exe", time.Time{}), conn(100, "app.exe", started), true},
+		{"開始時刻が無く名前が違う", conn(100, "app.exe", time.Time{}), conn(100, "other.exe", time.Time{}), false},
+		{"名前を問い合わせ中", conn(100, ResolvingName, time.Time{}), conn(100, "other.exe", time.Time{}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SameProcess(tt.a, tt.b); got != tt.want {
+				t.Errorf("SameProcess = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCollectorDiffPIDReuse は FakeTables と FakeProcesses で、同じアドレスの組を再利用された PID の
+// 別のプロセスが使い始めた場合に REBOUND になることを確認する。
+func TestCollectorDiffPIDReuse(t *testing.T) {
+	tables, processes := &FakeTables{}, &FakeProcesses{}
+	row := TableRow{
+		Protocol: "TCP", LocalAddr: netip.MustParseAddr("10.0.0.1"), LocalPort: 50000,
+		RemoteAddr: netip.MustParseAddr("10.0.0.2"), RemotePort: 443, State: "ESTABLISHED", PID: 100,
+	}
+	tables.Set(row)
+	processes.Set(ProcessEntry{PID: 100, Name: "app.exe", Created: started})
+	c := NewCollectorWith(Filter{Protocols: []string{"tcp"}, Families: []uint32{windows.AF_INET}, AllProcesses: true}, tables, processes)
+
+	prev, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	processes.Set(ProcessEntry{PID: 100, Name: "other.exe", Created: restart})
+	current, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := tcp("ESTABLISHED").Key()
+	want := []eventSummary{{EventRebound, key, "ESTABLISHED"}}
+	if got := summarize(Diff(prev, current)); !slices.Equal(got, want) {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+	if got := current[key]; got.ProcessName != "other.exe" || !got.ProcessStart.Equal(restart) {
+		t.Errorf("current[%q] = %s (開始 %v), want other.exe (開始 %v)", key, got.ProcessName, got.ProcessStart, restart)
+	}
+}
+
+// BenchmarkDiff は 10000 件の接続のうち 1% が閉じて同じ数が開き、さらに 1% の状態が変わった Snapshot との差分を計算する。
+// Diff は current の FirstSeen を書き換えるが、繰り返しても結果は変わらない。
+func BenchmarkDiff(b *testing.B) {
+	rows, entries := syntheticRows(10100)
+	tables, processes := &FakeTables{}, &FakeProcesses{}
+	processes.Set(entries...)
+	c := NewCollectorWith(Filter{Protocols: []string{"tcp"}, Families: []uint32{windows.AF_INET}, AllProcesses: true}, tables, processes)
+	tables.Set(rows[:10000]...)
+	prev, err := c.Collect()
+	if err != nil {
+		b.Fatal(err)
+	}
+	changed := rows[100:10100]
+	for i := range 100 {
+		changed[i].State = "CLOSE_WAIT"
+	}
+	tables.Set(changed...)
+	current, err := c.Collect()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if events := len(Diff(maps.Clone(prev), maps.Clone(current))); events != 300 {
+		b.Fatalf("Diff = %d 件, want 300 件", events)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		Diff(prev, current)
+	}
+}
@@ -0,0 +1,62 @@
+package conn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// --- エラー ---
+// conn のエラーは英語の既定のメッセージだけを持ち、表示言語への翻訳は呼び出し側 (main) が行う。
+// 呼び出し側は errors.Is で理由を、errors.As で ValueError や APIError の詳細を取り出して表示する。
+
+var (
+	ErrInvalidPort      = errors.New("invalid port")
+	ErrInvalidPortRange = errors.New("invalid port range")
+	ErrUnknownState     = errors.New("unknown state")
+	ErrUnknownScope     = errors.New("unknown address scope")
+	ErrNetshOutput      = errors.New("cannot read the dynamic port range from the netsh output")
+	ErrTableGrowing     = errors.New("the table kept growing")
+)
+
+// ValueError は Value の指定が Err (ErrInvalidPort など) の理由で不正であることを表す。
+type ValueError struct {
+	Err   error
+	Value string
+	Valid []string // 指定可能な値。列挙できない場合は nil
+}
+
+func (e *ValueError) Error() string {
+	if len(e.Valid) > 0 {
+		return fmt.Sprintf("%v: %q (valid: %s)", e.Err, e.Value, strings.Join(e.Valid, ","))
+	}
+	return fmt.Sprintf("%v: %q", e.Err, e.Value)
+}
+
+func (e *ValueError) Unwrap() error { return e.Err }
+
+// APIError は Windows の API (または netsh) の呼び出しが失敗したことを表す。
+type APIError struct {
+	Op       string // 失敗した呼び出し (GetExtendedTcpTable など)
+	Err      error  // windows.Errno、PDHStatus、HResult、または ErrTableGrowing
+	Attempts int    // 再試行した場合の試行回数。再試行しない場合は 0
+}
+
+func (e *APIError) Error() string {
+	if e.Attempts > 0 {
+		return fmt.Sprintf("%s failed: %v (%d attempts)", e.Op, e.Err, e.Attempts)
+	}
+	return fmt.Sprintf("%s failed: %v", e.Op, e.Err)
+}
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// PDHStatus は PDH の関数が返したエラーコード。
+type PDHStatus uint32
+
+func (s PDHStatus) Error() string { return fmt.Sprintf("PDH error 0x%08X", uint32(s)) }
+
+// HResult は HCS の関数が返した HRESULT。
+type HResult uint32
+
+func (h HResult) Error() string { return fmt.Sprintf("HRESULT 0x%08X", uint32(h)) }
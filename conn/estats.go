@@ -0,0 +1,198 @@
+package conn
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// --- TCP ESTATS (接続ごとの通信量・再送・RTT) ---
+// GetPerTcpConnectionEStats で取得する値。収集の有効化 (SetPerTcpConnectionEStats) には管理者権限が必要。
+type TrafficStats struct {
+	BytesIn     uint64
+	BytesOut    uint64
+	Retransmits uint32        // 再送したセグメント数
+	RTT         time.Duration // 平滑化された RTT
+}
+
+type MIB_TCPROW struct {
+	State      uint32
+	LocalAddr  uint32
+	LocalPort  uint32
+	RemoteAddr uint32
+	RemotePort uint32
+}
+type MIB_TCP6ROW struct {
+	State         uint32
+	LocalAddr     [16]byte
+	LocalScopeId  uint32
+	LocalPort     uint32
+	RemoteAddr    [16]byte
+	RemoteScopeId uint32
+	RemotePort    uint32
+}
+
+type TCP_ESTATS_RW_v0 struct {
+	EnableCollection byte
+}
+type TCP_ESTATS_DATA_ROD_v0 struct {
+	DataBytesOut      uint64
+	DataSegsOut       uint64
+	DataBytesIn       uint64
+	DataSegsIn        uint64
+	SegsOut           uint64
+	SegsIn            uint64
+	SoftErrors        uint32
+	SoftErrorReason   uint32
+	SndUna            uint32
+	SndNxt            uint32
+	SndMax            uint32
+	ThruBytesAcked    uint64
+	RcvNxt            uint32
+	ThruBytesReceived uint64
+}
+type TCP_ESTATS_PATH_ROD_v0 struct {
+	FastRetran            uint32
+	Timeouts              uint32
+	SubsequentTimeouts    uint32
+	CurTimeoutCount       uint32
+	AbruptTimeouts        uint32
+	PktsRetrans           uint32
+	BytesRetrans          uint32
+	DupAcksIn             uint32
+	SacksRcvd             uint32
+	SackBlocksRcvd        uint32
+	CongSignals           uint32
+	PreCongSumCwnd        uint32
+	PreCongSumRtt         uint32
+	PostCongSumRtt        uint32
+	PostCongCountRtt      uint32
+	EcnSignals            uint32
+	EceRcvd               uint32
+	SendStall             uint32
+	QuenchRcvd            uint32
+	RetranThresh          uint32
+	SndDupAckEpisodes     uint32
+	SumBytesReordered     uint32
+	NonRecovDa            uint32
+	NonRecovDaEpisodes    uint32
+	AckAfterFr            uint32
+	DsackDups             uint32
+	SampleRtt             uint32
+	SmoothedRtt           uint32
+	RttVar                uint32
+	MaxRtt                uint32
+	MinRtt                uint32
+	SumRtt                uint32
+	CountRtt              uint32
+	CurRto                uint32
+	MaxRto                uint32
+	MinRto                uint32
+	CurMss                uint32
+	MaxMss                uint32
+	MinMss                uint32
+	SpuriousRtoDetections uint32
+}
+
+const (
+	TcpConnectionEstatsData = 1
+	TcpConnectionEstatsPath = 3
+	MIB_TCP_STATE_ESTAB     = 5
+)
+
+var (
+	procSetPerTcpConnectionEStats  = iphlpapi.NewProc("SetPerTcpConnectionEStats")
+	procGetPerTcpConnectionEStats  = iphlpapi.NewProc("GetPerTcpConnectionEStats")
+	procSetPerTcp6ConnectionEStats = iphlpapi.NewProc("SetPerTcp6ConnectionEStats")
+	procGetPerTcp6ConnectionEStats = iphlpapi.NewProc("GetPerTcp6ConnectionEStats")
+)
+
+// 収集を有効化済みの接続。同じ接続に対して毎回 Set を呼ばないように記録しておく。
+var (
+	estatsEnabledMu sync.Mutex
+	estatsEnabled   = make(map[string]bool)
+)
+
+// attachTrafficStats は ESTABLISHED の TCP 接続に TrafficStats を設定する。取得に失敗した接続は nil のままにする。
+func attachTrafficStats(connections Snapshot) {
+	estatsEnabledMu.Lock()
+	defer estatsEnabledMu.Unlock()
+
+	for key, c := range connections {
+		if c.Protocol != "TCP" || c.State != "ESTABLISHED" {
+			continue
+		}
+		if stats, ok := getTrafficStats(c, !estatsEnabled[key]); ok {
+			c.Traffic = stats
+			connections[key] = c
+			estatsEnabled[key] = true
+		}
+	}
+	for key := range estatsEnabled {
+		if _, ok := connections[key]; !ok {
+			delete(estatsEnabled, key)
+		}
+	}
+}
+
+func getTrafficStats(c Connection, enable bool) (*TrafficStats, bool) {
+	local, err1 := netip.ParseAddr(c.LocalAddr)
+	remote, err2 := netip.ParseAddr(c.RemoteAddr)
+	if err1 != nil || err2 != nil {
+		return nil, false
+	}
+
+	var row unsafe.Pointer
+	setProc, getProc := procSetPerTcpConnectionEStats, procGetPerTcpConnectionEStats
+	if local.Is4() {
+		l, r := local.As4(), remote.As4()
+		row = unsafe.Pointer(&MIB_TCPROW{
+			State:      MIB_TCP_STATE_ESTAB,
+			LocalAddr:  uint32(l[0]) | uint32(l[1])<<8 | uint32(l[2])<<16 | uint32(l[3])<<24,
+			LocalPort:  uint16ToPort(c.LocalPort),
+			RemoteAddr: uint32(r[0]) | uint32(r[1])<<8 | uint32(r[2])<<16 | uint32(r[3])<<24,
+			RemotePort: uint16ToPort(c.RemotePort),
+		})
+	} else {
+		row = unsafe.Pointer(&MIB_TCP6ROW{
+			State:      MIB_TCP_STATE_ESTAB,
+			LocalAddr:  local.As16(),
+			LocalPort:  uint16ToPort(c.LocalPort),
+			RemoteAddr: remote.As16(),
+			RemotePort: uint16ToPort(c.RemotePort),
+		})
+		setProc, getProc = procSetPerTcp6ConnectionEStats, procGetPerTcp6ConnectionEStats
+	}
+
+	if enable {
+		rw := TCP_ESTATS_RW_v0{EnableCollection: 1}
+		for _, estatsType := range []uintptr{TcpConnectionEstatsData, TcpConnectionEstatsPath} {
+			ret, _, _ := setProc.Call(uintptr(row), estatsType, uintptr(unsafe.Pointer(&rw)), 0, unsafe.Sizeof(rw), 0)
+			if ret != 0 {
+				return nil, false
+			}
+		}
+	}
+
+	var data TCP_ESTATS_DATA_ROD_v0
+	ret, _, _ := getProc.Call(uintptr(row), TcpConnectionEstatsData, 0, 0, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&data)), 0, unsafe.Sizeof(data))
+	if ret != 0 {
+		return nil, false
+	}
+	var path TCP_ESTATS_PATH_ROD_v0
+	ret, _, _ = getProc.Call(uintptr(row), TcpConnectionEstatsPath, 0, 0, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&path)), 0, unsafe.Sizeof(path))
+	if ret != 0 {
+		return nil, false
+	}
+	return &TrafficStats{
+		BytesIn:     data.DataBytesIn,
+		BytesOut:    data.DataBytesOut,
+		Retransmits: path.PktsRetrans,
+		RTT:         time.Duration(path.SmoothedRtt) * time.Millisecond,
+	}, true
+}
+
+func uint16ToPort(port uint16) uint32 { return uint32(port>>8) | uint32(port&0xFF)<<8 }
@@ -0,0 +1,100 @@
+package conn
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- 決まった値を返す実装 (テスト・計測用) ---
+
+// FakeTables は Set で設定した行を返す TcpTableProvider。
+// 行は protocol と、LocalAddr の種類 (IPv4 は AF_INET、それ以外は AF_INET6) で振り分ける。並行して使用してよい。
+type FakeTables struct {
+	mu   sync.Mutex
+	rows []TableRow
+	err  error
+}
+
+// Set は以降の取得で返す行を rows に置き換える。
+func (t *FakeTables) Set(rows ...TableRow) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rows = slices.Clone(rows)
+}
+
+// Fail は以降の取得を err で失敗させる。nil を渡すと元に戻す。
+func (t *FakeTables) Fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.err = err
+}
+
+func (t *FakeTables) Rows(protocol string, family uint32, modules bool, fn func(TableRow)) error {
+	t.mu.Lock()
+	rows, err := t.rows, t.err
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		rowFamily := uint32(windows.AF_INET6)
+		if row.LocalAddr.Is4() {
+			rowFamily = windows.AF_INET
+		}
+		if !strings.EqualFold(row.Protocol, protocol) || rowFamily != family {
+			continue
+		}
+		if !modules {
+			row.Created, row.Module = 0, nil
+		}
+		fn(row)
+	}
+	return nil
+}
+
+// FakeProcesses は Set で設定したプロセスを実行中として返す ProcessInfoProvider。並行して使用してよい。
+type FakeProcesses struct {
+	mu    sync.Mutex
+	byPID map[uint32]ProcessEntry
+}
+
+// Set は実行中のプロセスを entries に置き換える。
+func (p *FakeProcesses) Set(entries ...ProcessEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.byPID = make(map[uint32]ProcessEntry, len(entries))
+	for _, e := range entries {
+		p.byPID[e.PID] = e
+	}
+}
+
+func (p *FakeProcesses) Processes() ([]ProcessEntry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entries := slices.Collect(maps.Values(p.byPID))
+	slices.SortFunc(entries, func(a, b ProcessEntry) int { return cmp.Compare(a.PID, b.PID) })
+	return entries, nil
+}
+
+func (p *FakeProcesses) StartTime(pid uint32) time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.byPID[pid].Created
+}
+
+func (p *FakeProcesses) Query(pid uint32) ProcessEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.byPID[pid]
+	if !ok {
+		return ProcessEntry{PID: pid}
+	}
+	e.ParentPID = 0
+	return e
+}
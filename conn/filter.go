@@ -0,0 +1,216 @@
+package conn
+
+import (
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// Filter は取得対象のプロトコル・アドレスファミリ・プロセスを指定する。
+type Filter struct {
+	Protocols    []string         // "tcp", "udp"
+	Families     []uint32         // windows.AF_INET, windows.AF_INET6
+	Targets      []string         // プロセス名 (ワイルドカード可)、PID、または svc: に続くサービス名 (ワイルドカード可)
+	NameRegexps  []*regexp.Regexp // プロセス名に対する正規表現。Targets のいずれかに一致しなくても対象にする
+	AllProcesses bool             // true の場合 Targets を無視して全プロセスを対象にする
+
+	IncludeDescendants bool // true の場合 Targets に一致するプロセスの子孫も対象にする
+	CollectTraffic     bool // true の場合 TCP ESTATS から通信量・再送・RTT を取得する (要管理者権限)
+	IncludeListen      bool // true の場合 LISTEN 状態の TCP ソケット (リモートアドレスが未指定) も対象にする
+	IncludeBound       bool // true の場合 LISTEN 以外でリモートアドレスが未指定の TCP ソケット (バインドのみで未接続) も対象にする
+	CollectModules     bool // true の場合 TCP 接続を所有するモジュール (svchost.exe のサービス名など) を取得する
+
+	RemoteNets  []netip.Prefix // 指定した場合、リモートアドレスがいずれかに含まれる接続のみ対象にする
+	LocalPorts  []PortRange    // 指定した場合、ローカルポートがいずれかに含まれる接続のみ対象にする
+	RemotePorts []PortRange    // 指定した場合、リモートポートがいずれかに含まれる接続のみ対象にする
+	States      []string       // 指定した場合、TCP の状態がいずれかに一致する接続のみ対象にする
+	Containers  []string       // 指定した場合、ID が前方一致する Windows コンテナ (AnyContainer は全て) のプロセスのみ対象にする
+	Scopes      []Scope        // 指定した場合、リモートアドレスの種類がいずれかに一致する接続のみ対象にする (UDP は対象外)
+	Any         []Filter       // 指定した場合、いずれかの Filter の Match に一致する接続のみ対象にする (監視プロファイルなど)
+
+	// 以下に一致するものは、上記の条件を満たしていても対象外にする。
+	ExcludeTargets     []string         // プロセス名 (ワイルドカード可)、PID、または svc: に続くサービス名
+	ExcludeRegexps     []*regexp.Regexp // プロセス名に対する正規表現
+	ExcludeRemoteNets  []netip.Prefix
+	ExcludeRemotePorts []PortRange
+}
+
+// PortRange は両端を含むポート番号の範囲。単一ポートは Low == High で表す。
+type PortRange struct {
+	Low, High uint16
+}
+
+func (r PortRange) Contains(port uint16) bool { return r.Low <= port && port <= r.High }
+
+// includesListen は、リモートアドレスが未指定の TCP ソケットを対象に含めるかを判定する。
+func (f Filter) includesListen(c Connection) bool {
+	if c.State == "LISTEN" {
+		return f.IncludeListen
+	}
+	return f.IncludeBound
+}
+
+// add は接続を connections へ追加する。
+// 同じキーの接続を別のプロセスが既に持っている場合は、上書きせずに OwnerKey で追加する。
+func add(connections Snapshot, c Connection) {
+	key := c.Key()
+	if prev, exists := connections[key]; exists && !SameProcess(prev, c) {
+		key = c.OwnerKey()
+	}
+	connections[key] = c
+}
+
+// Match は取得済みの接続 (記録の再生など) が Filter の条件を満たすかを判定する。
+// プロセスは記録された PID と名前 (svc: 指定と Containers は記録された Services・Container) だけで判定するため、IncludeDescendants と CollectTraffic は考慮しない。
+func (f Filter) Match(c Connection) bool {
+	if !slices.ContainsFunc(f.Protocols, func(p string) bool { return strings.EqualFold(p, c.Protocol) }) {
+		return false
+	}
+	if ip, err := netip.ParseAddr(c.LocalAddr); err == nil {
+		family := uint32(windows.AF_INET)
+		if ip.Is6() && !ip.Is4In6() {
+			family = windows.AF_INET6
+		}
+		if !slices.Contains(f.Families, family) {
+			return false
+		}
+	}
+	m := processMatcher{targets: f.Targets, regexps: f.NameRegexps, all: f.AllProcesses}
+	if len(f.ExcludeTargets) > 0 || len(f.ExcludeRegexps) > 0 {
+		m.exclude = &processMatcher{targets: f.ExcludeTargets, regexps: f.ExcludeRegexps}
+	}
+	m.setServices(func(uint32) []string { return c.Services })
+	if len(f.Containers) > 0 {
+		m.containers = f.Containers
+		m.containerOf = func(uint32) string { return c.Container }
+	}
+	if !m.matchProcess(c.PID, c.ProcessName) {
+		return false
+	}
+	if c.Protocol == "TCP" && (c.RemoteAddr == "0.0.0.0" || c.RemoteAddr == "::") && !f.includesListen(c) {
+		return false
+	}
+	return f.accept(c)
+}
+
+func (f Filter) accept(c Connection) bool {
+	if len(f.RemoteNets) > 0 && !containsAddr(f.RemoteNets, c.RemoteAddr) {
+		return false
+	}
+	if len(f.LocalPorts) > 0 && !containsPort(f.LocalPorts, c.LocalPort) {
+		return false
+	}
+	if len(f.RemotePorts) > 0 && (c.Protocol == "UDP" || !containsPort(f.RemotePorts, c.RemotePort)) {
+		return false
+	}
+	if len(f.States) > 0 && !slices.Contains(f.States, c.State) {
+		return false
+	}
+	if len(f.Scopes) > 0 && (c.Protocol == "UDP" || !slices.Contains(f.Scopes, AddrScope(c.RemoteAddr))) {
+		return false
+	}
+	if len(f.ExcludeRemoteNets) > 0 && containsAddr(f.ExcludeRemoteNets, c.RemoteAddr) {
+		return false
+	}
+	if len(f.ExcludeRemotePorts) > 0 && c.Protocol != "UDP" && containsPort(f.ExcludeRemotePorts, c.RemotePort) {
+		return false
+	}
+	if len(f.Any) > 0 && !slices.ContainsFunc(f.Any, func(sub Filter) bool { return sub.Match(c) }) {
+		return false
+	}
+	return true
+}
+
+func containsPort(ranges []PortRange, port uint16) bool {
+	for _, r := range ranges {
+		if r.Contains(port) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAddr(prefixes []netip.Prefix, addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePrefixes は "10.0.0.0/8,192.168.1.5" のようなカンマ区切りの IP アドレス・CIDR を解析する。
+// 単一のアドレスはそのアドレスだけを含むプレフィックスとして扱う。
+func ParsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			p, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ParsePortRanges は "80,443,8000-8100" のようなカンマ区切りのポート・範囲指定を解析する。
+func ParsePortRanges(s string) ([]PortRange, error) {
+	var ranges []PortRange
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		lowStr, highStr, isRange := strings.Cut(item, "-")
+		low, err := strconv.ParseUint(strings.TrimSpace(lowStr), 10, 16)
+		if err != nil {
+			return nil, &ValueError{Err: ErrInvalidPort, Value: item}
+		}
+		high := low
+		if isRange {
+			high, err = strconv.ParseUint(strings.TrimSpace(highStr), 10, 16)
+			if err != nil || high < low {
+				return nil, &ValueError{Err: ErrInvalidPortRange, Value: item}
+			}
+		}
+		ranges = append(ranges, PortRange{Low: uint16(low), High: uint16(high)})
+	}
+	return ranges, nil
+}
+
+// ParseStates は "ESTABLISHED,CLOSE_WAIT" のようなカンマ区切りの TCP 状態名を検証し、大文字に揃えて返す。
+func ParseStates(s string) ([]string, error) {
+	var states []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if !slices.Contains(TCPStateNames, item) {
+			return nil, &ValueError{Err: ErrUnknownState, Value: item, Valid: TCPStateNames}
+		}
+		states = append(states, item)
+	}
+	return states, nil
+}
@@ -0,0 +1,155 @@
+package conn
+
+import (
+	"errors"
+	"net/netip"
+	"regexp"
+	"slices"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestFilterMatch(t *testing.T) {
+	both := []uint32{windows.AF_INET, windows.AF_INET6}
+	base := Filter{Protocols: []string{"tcp", "udp"}, Families: both, AllProcesses: true}
+	with := func(change func(*Filter)) Filter {
+		f := base
+		change(&f)
+		return f
+	}
+	established := tcp("ESTABLISHED")
+	listen := Connection{Protocol: "TCP", ProcessName: "svc.exe", PID: 300, LocalAddr: "0.0.0.0", LocalPort: 80, RemoteAddr: "0.0.0.0", State: "LISTEN"}
+	udp := Connection{Protocol: "UDP", ProcessName: "dns.exe", PID: 400, LocalAddr: "::", LocalPort: 53, State: UDPState}
+	hosted := established
+	hosted.ProcessName, hosted.Services = "svchost.exe", []string{"Dnscache"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		conn   Connection
+		want   bool
+	}{
+		{"全プロセス", base, established, true},
+		{"プロトコルが違う", with(func(f *Filter) { f.Protocols = []string{"udp"} }), established, false},
+		{"アドレスファミリが違う", with(func(f *Filter) { f.Families = []uint32{windows.AF_INET} }), udp, false},
+		{"プロセス名 (大文字小文字を区別しない)", with(func(f *Filter) { f.AllProcesses, f.Targets = false, []string{"APP.EXE"} }), established, true},
+		{"プロセス名のワイルドカード", with(func(f *Filter) { f.AllProcesses, f.Targets = false, []string{"ap*.exe"} }), established, true},
+		{"PID", with(func(f *Filter) { f.AllProcesses, f.Targets = false, []string{"100"} }), established, true},
+		{"一致しないプロセス", with(func(f *Filter) { f.AllProcesses, f.Targets = false, []string{"other.exe"} }), established, false},
+		{"正規表現", with(func(f *Filter) {
+			f.AllProcesses, f.NameRegexps = false, []*regexp.Regexp{regexp.MustCompile(`(?i)^app`)}
+		}), established, true},
+		{"サービス名", with(func(f *Filter) { f.AllProcesses, f.Targets = false, []string{"svc:dns*"} }), hosted, true},
+		{"除外", with(func(f *Filter) { f.ExcludeTargets = []string{"app.exe"} }), established, false},
+		{"LISTEN (既定は対象外)", base, listen, false},
+		{"LISTEN (IncludeListen)", with(func(f *Filter) { f.IncludeListen = true }), listen, true},
+		{"リモートアドレス", with(func(f *Filter) { f.RemoteNets = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")} }), established, true},
+		{"リモートアドレスが範囲外", with(func(f *Filter) { f.RemoteNets = []netip.Prefix{netip.MustParsePrefix("192.168.0.0/16")} }), established, false},
+		{"除外するリモートアドレス", with(func(f *Filter) { f.ExcludeRemoteNets = []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")} }), established, false},
+		{"ローカルポート", with(func(f *Filter) { f.LocalPorts = []PortRange{{Low: 49152, High: 65535}} }), established, true},
+		{"リモートポート (UDP は対象外)", with(func(f *Filter) { f.RemotePorts = []PortRange{{Low: 53, High: 53}} }), udp, false},
+		{"状態", with(func(f *Filter) { f.States = []string{"CLOSE_WAIT"} }), established, false},
+		{"リモートの種類", with(func(f *Filter) { f.Scopes = []Scope{ScopePrivate} }), established, true},
+		{"Any のいずれかに一致", with(func(f *Filter) {
+			f.Any = []Filter{with(func(f *Filter) { f.AllProcesses, f.Targets = false, []string{"other.exe"} }), base}
+		}), established, true},
+		{"Any のどれにも一致しない", with(func(f *Filter) {
+			f.Any = []Filter{with(func(f *Filter) { f.AllProcesses, f.Targets = false, []string{"other.exe"} })}
+		}), established, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.conn); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePortRanges(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []PortRange
+		wantErr error
+	}{
+		{"", nil, nil},
+		{"80", []PortRange{{80, 80}}, nil},
+		{"80, 443,8000-8100", []PortRange{{80, 80}, {443, 443}, {8000, 8100}}, nil},
+		{"http", nil, ErrInvalidPort},
+		{"65536", nil, ErrInvalidPort},
+		{"9000-8000", nil, ErrInvalidPortRange},
+		{"8000-x", nil, ErrInvalidPortRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePortRanges(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParsePortRanges(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParsePortRanges(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStates(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr error
+	}{
+		{"", nil, nil},
+		{"established, close_wait", []string{"ESTABLISHED", "CLOSE_WAIT"}, nil},
+		{"SYN_RECV", []string{"SYN_RECV"}, nil},
+		{"SYN_RCVD", nil, ErrUnknownState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseStates(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseStates(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseStates(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	got, err := ParseScopes("Public, private")
+	if err != nil || !slices.Equal(got, []Scope{ScopePublic, ScopePrivate}) {
+		t.Errorf("ParseScopes = %v, %v", got, err)
+	}
+	var ve *ValueError
+	if _, err := ParseScopes("internet"); !errors.As(err, &ve) || ve.Err != ErrUnknownScope || ve.Value != "internet" || len(ve.Valid) != len(ScopeNames) {
+		t.Errorf("ParseScopes(%q) error = %#v", "internet", err)
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []netip.Prefix
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"10.1.2.3/8", []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, false},
+		{"192.168.1.5, ::1", []netip.Prefix{netip.MustParsePrefix("192.168.1.5/32"), netip.MustParsePrefix("::1/128")}, false},
+		{"10.0.0.0/33", nil, true},
+		{"example.com", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePrefixes(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrefixes(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParsePrefixes(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
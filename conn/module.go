@@ -0,0 +1,150 @@
+package conn
+
+import (
+	"net/netip"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- 接続を所有するモジュール (サービス) の取得 ---
+// svchost.exe のように 1 つのプロセスが複数のサービスを持つ場合、GetOwnerModuleFromTcpEntry で
+// 接続を作成したサービス (例: Dnscache) またはモジュールの名前を取得できる。
+
+// TCPIP_OWNING_MODULE_SIZE は OwningModuleInfo の要素数。
+const TCPIP_OWNING_MODULE_SIZE = 16
+
+type MIB_TCPROW_OWNER_MODULE struct {
+	State            uint32
+	LocalAddr        uint32
+	LocalPort        uint32
+	RemoteAddr       uint32
+	RemotePort       uint32
+	OwningPid        uint32
+	CreateTimestamp  int64
+	OwningModuleInfo [TCPIP_OWNING_MODULE_SIZE]uint64
+}
+type MIB_TCPTABLE_OWNER_MODULE struct {
+	NumEntries uint32
+	Table      [1]MIB_TCPROW_OWNER_MODULE
+}
+type MIB_TCP6ROW_OWNER_MODULE struct {
+	LocalAddr        [16]byte
+	LocalScopeId     uint32
+	LocalPort        uint32
+	RemoteAddr       [16]byte
+	RemoteScopeId    uint32
+	RemotePort       uint32
+	State            uint32
+	OwningPid        uint32
+	CreateTimestamp  int64
+	OwningModuleInfo [TCPIP_OWNING_MODULE_SIZE]uint64
+}
+type MIB_TCP6TABLE_OWNER_MODULE struct {
+	NumEntries uint32
+	Table      [1]MIB_TCP6ROW_OWNER_MODULE
+}
+
+// TCPIP_OWNER_MODULE_BASIC_INFO は GetOwnerModuleFromTcpEntry が返すバッファの先頭。
+// 文字列の本体は同じバッファ内の後続の領域を指す。
+type TCPIP_OWNER_MODULE_BASIC_INFO struct {
+	ModuleName *uint16
+	ModulePath *uint16
+}
+
+const (
+	TCP_TABLE_OWNER_MODULE_ALL    = 8
+	TCPIP_OWNER_MODULE_INFO_BASIC = 0
+)
+
+var (
+	procGetOwnerModuleFromTcpEntry  = iphlpapi.NewProc("GetOwnerModuleFromTcpEntry")
+	procGetOwnerModuleFromTcp6Entry = iphlpapi.NewProc("GetOwnerModuleFromTcp6Entry")
+)
+
+// moduleKey は所有モジュールを取得済みの接続を表す。同じアドレスの組で作り直された接続と区別するため、作成時刻を含める。
+type moduleKey struct {
+	key     string
+	created int64
+}
+
+// moduleCache は接続ごとの所有モジュール名を、接続が消えるまで保持する。
+type moduleCache struct {
+	names map[moduleKey]string
+	seen  map[moduleKey]bool
+}
+
+func newModuleCache() *moduleCache {
+	return &moduleCache{names: make(map[moduleKey]string), seen: make(map[moduleKey]bool)}
+}
+
+// lookup は接続の所有モジュール名を返す。初めての接続の場合のみ get で取得する。
+func (c *moduleCache) lookup(k moduleKey, get func() string) string {
+	c.seen[k] = true
+	name, ok := c.names[k]
+	if !ok {
+		name = get()
+		c.names[k] = name
+	}
+	return name
+}
+
+// prune は今回の取得で見つからなかった接続の記録を捨てる。
+func (c *moduleCache) prune() {
+	for k := range c.names {
+		if !c.seen[k] {
+			delete(c.names, k)
+		}
+	}
+	clear(c.seen)
+}
+
+// ownerModuleName は GetOwnerModuleFromTcpEntry/GetOwnerModuleFromTcp6Entry を呼び出し、モジュール名を返す。
+// システムプロセスの接続など、取得できない場合は空文字列を返す。
+func ownerModuleName(proc *windows.LazyProc, row unsafe.Pointer) string {
+	buf := make([]byte, 1024)
+	for attempt := 0; attempt < 2; attempt++ {
+		size := uint32(len(buf))
+		ret, _, _ := proc.Call(uintptr(row), TCPIP_OWNER_MODULE_INFO_BASIC, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+		switch ret {
+		case 0:
+			info := (*TCPIP_OWNER_MODULE_BASIC_INFO)(unsafe.Pointer(&buf[0]))
+			return windows.UTF16PtrToString(info.ModuleName)
+		case uintptr(windows.ERROR_INSUFFICIENT_BUFFER):
+			buf = make([]byte, size)
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func tcpModuleRows(buf []byte, fn func(TableRow)) {
+	table := (*MIB_TCPTABLE_OWNER_MODULE)(unsafe.Pointer(&buf[0]))
+	rowSize := unsafe.Sizeof(MIB_TCPROW_OWNER_MODULE{})
+	for i := uint32(0); i < table.NumEntries; i++ {
+		row := (*MIB_TCPROW_OWNER_MODULE)(unsafe.Pointer(uintptr(unsafe.Pointer(&table.Table[0])) + uintptr(i)*rowSize))
+		fn(TableRow{
+			Protocol: "TCP", PID: row.OwningPid,
+			LocalAddr: ipv4Addr(row.LocalAddr), LocalPort: portToUint16(row.LocalPort),
+			RemoteAddr: ipv4Addr(row.RemoteAddr), RemotePort: portToUint16(row.RemotePort),
+			State: getTCPStateName(row.State), Created: row.CreateTimestamp,
+			Module: func() string { return ownerModuleName(procGetOwnerModuleFromTcpEntry, unsafe.Pointer(row)) },
+		})
+	}
+}
+
+func tcp6ModuleRows(buf []byte, fn func(TableRow)) {
+	table := (*MIB_TCP6TABLE_OWNER_MODULE)(unsafe.Pointer(&buf[0]))
+	rowSize := unsafe.Sizeof(MIB_TCP6ROW_OWNER_MODULE{})
+	for i := uint32(0); i < table.NumEntries; i++ {
+		row := (*MIB_TCP6ROW_OWNER_MODULE)(unsafe.Pointer(uintptr(unsafe.Pointer(&table.Table[0])) + uintptr(i)*rowSize))
+		fn(TableRow{
+			Protocol: "TCP", PID: row.OwningPid,
+			LocalAddr: netip.AddrFrom16(row.LocalAddr), LocalPort: portToUint16(row.LocalPort),
+			RemoteAddr: netip.AddrFrom16(row.RemoteAddr), RemotePort: portToUint16(row.RemotePort),
+			State: getTCPStateName(row.State), Created: row.CreateTimestamp,
+			Module: func() string { return ownerModuleName(procGetOwnerModuleFromTcp6Entry, unsafe.Pointer(row)) },
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package conn
+
+import (
+	"go-ObuStat/etw"
+)
+
+// --- 接続テーブルの変化の通知 ---
+// ChangeNotifier は TCP 接続の確立・受け入れ・切断が起きたときに通知する。
+// Windows には TCP テーブルの変更を通知する API が無いため、Kernel-Network の ETW イベントを契機に使う。
+// ESTABLISHED から CLOSE_WAIT への遷移などはイベントにならないため、定期的な取得と併用すること。
+// 管理者権限が必要。
+type ChangeNotifier struct {
+	session *etw.Session
+	c       chan struct{}
+}
+
+// NotifyChanges は name の ETW セッションを開始し、変化の通知を始める。
+func NotifyChanges(name string) (*ChangeNotifier, error) {
+	n := &ChangeNotifier{c: make(chan struct{}, 1)}
+	session, err := etw.Start(name, []etw.Provider{{GUID: kernelNetworkProvider, Level: 5}}, n.handle)
+	if err != nil {
+		return nil, err
+	}
+	n.session = session
+	return n, nil
+}
+
+// C は変化があったときに値を受け取るチャネルを返す。通知は読まれるまで 1 件にまとめられる。
+func (n *ChangeNotifier) C() <-chan struct{} { return n.c }
+
+// Done はセッションが終了したときに閉じられるチャネルを返す。
+func (n *ChangeNotifier) Done() <-chan struct{} { return n.session.Done() }
+
+// Err はセッションが異常終了した場合のエラーを返す。Done が閉じられた後に呼ぶこと。
+func (n *ChangeNotifier) Err() error { return n.session.Err() }
+
+// Close はセッションを停止する。
+func (n *ChangeNotifier) Close() error { return n.session.Close() }
+
+func (n *ChangeNotifier) handle(e *etw.Event) {
+	switch e.ID {
+	case kernelNetworkTCPConnectV4, kernelNetworkTCPAcceptV4, kernelNetworkTCPDisconnectV4,
+		kernelNetworkTCPConnectV6, kernelNetworkTCPAcceptV6, kernelNetworkTCPDisconnectV6:
+	default:
+		return
+	}
+	select {
+	case n.c <- struct{}{}:
+	default:
+	}
+}
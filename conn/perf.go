@@ -0,0 +1,145 @@
+package conn
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- TCPv4 のパフォーマンスカウンタ (PDH) ---
+// 接続の増減がネットワーク全体の再送やリセットと同時に起きているかを確認するために使う。
+// カウンタ名はロケールに依存しないよう、PdhAddEnglishCounter で英語名を指定する。
+
+var (
+	pdh                             = windows.NewLazySystemDLL("pdh.dll")
+	procPdhOpenQueryW               = pdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounterW       = pdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData         = pdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterValue = pdh.NewProc("PdhGetFormattedCounterValue")
+	procPdhCloseQuery               = pdh.NewProc("PdhCloseQuery")
+)
+
+const (
+	PDH_FMT_DOUBLE   = 0x00000200
+	PDH_FMT_NOCAP100 = 0x00008000
+)
+
+// PDH_FMT_COUNTERVALUE は PDH_FMT_DOUBLE で取得する場合の値。共用体の前に 4 バイトの詰め物が入る。
+type PDH_FMT_COUNTERVALUE struct {
+	CStatus     uint32
+	_           uint32
+	DoubleValue float64
+}
+
+// TCPPerf は TCPv4 のパフォーマンスカウンタの値。
+type TCPPerf struct {
+	SegmentsRetransmittedPerSec float64 // 直前の Collect からの平均
+	ConnectionsReset            uint64  // OS 起動以降の累計
+	ConnectionsResetDelta       uint64  // 直前の Collect 以降に増えた件数
+	ConnectionFailures          uint64  // OS 起動以降の累計
+	ConnectionFailuresDelta     uint64  // 直前の Collect 以降に増えた件数
+}
+
+// PerfCounters は TCPv4 のパフォーマンスカウンタを繰り返し取得する。
+type PerfCounters struct {
+	query                   windows.Handle
+	retransmitted           windows.Handle
+	reset                   windows.Handle
+	failures                windows.Handle
+	prevReset, prevFailures uint64
+	primed                  bool
+}
+
+func pdhError(op string, status uintptr) error {
+	return &APIError{Op: op, Err: PDHStatus(status)}
+}
+
+// OpenTCPPerfCounters は TCPv4 のカウンタを登録する。毎秒の値を計算できるよう、最初のサンプルもここで取得する。
+func OpenTCPPerfCounters() (*PerfCounters, error) {
+	if err := pdh.Load(); err != nil {
+		return nil, err
+	}
+	p := &PerfCounters{}
+	if status, _, _ := procPdhOpenQueryW.Call(0, 0, uintptr(unsafe.Pointer(&p.query))); status != 0 {
+		return nil, pdhError("PdhOpenQuery", status)
+	}
+	for _, c := range []struct {
+		path   string
+		handle *windows.Handle
+	}{
+		{`\TCPv4\Segments Retransmitted/sec`, &p.retransmitted},
+		{`\TCPv4\Connections Reset`, &p.reset},
+		{`\TCPv4\Connection Failures`, &p.failures},
+	} {
+		path, err := windows.UTF16PtrFromString(c.path)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		if status, _, _ := procPdhAddEnglishCounterW.Call(uintptr(p.query), uintptr(unsafe.Pointer(path)), 0, uintptr(unsafe.Pointer(c.handle))); status != 0 {
+			p.Close()
+			return nil, pdhError("PdhAddEnglishCounter "+c.path, status)
+		}
+	}
+	if _, err := p.Collect(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Collect は現在のカウンタの値を返す。
+func (p *PerfCounters) Collect() (TCPPerf, error) {
+	if status, _, _ := procPdhCollectQueryData.Call(uintptr(p.query)); status != 0 {
+		return TCPPerf{}, pdhError("PdhCollectQueryData", status)
+	}
+	var perf TCPPerf
+	// 毎秒の値は 2 回目のサンプルから計算できるため、最初の取得では読み取らない。
+	if p.primed {
+		v, err := formattedValue(p.retransmitted)
+		if err != nil {
+			return TCPPerf{}, err
+		}
+		perf.SegmentsRetransmittedPerSec = v
+	}
+	reset, err := formattedValue(p.reset)
+	if err != nil {
+		return TCPPerf{}, err
+	}
+	failures, err := formattedValue(p.failures)
+	if err != nil {
+		return TCPPerf{}, err
+	}
+	perf.ConnectionsReset, perf.ConnectionFailures = uint64(reset), uint64(failures)
+	if p.primed {
+		perf.ConnectionsResetDelta = counterDelta(p.prevReset, perf.ConnectionsReset)
+		perf.ConnectionFailuresDelta = counterDelta(p.prevFailures, perf.ConnectionFailures)
+	}
+	p.prevReset, p.prevFailures, p.primed = perf.ConnectionsReset, perf.ConnectionFailures, true
+	return perf, nil
+}
+
+// counterDelta は累計値の増分を返す。カウンタが巻き戻った (リセットされた) 場合は 0 とする。
+func counterDelta(prev, current uint64) uint64 {
+	if current < prev {
+		return 0
+	}
+	return current - prev
+}
+
+func formattedValue(counter windows.Handle) (float64, error) {
+	var value PDH_FMT_COUNTERVALUE
+	status, _, _ := procPdhGetFormattedCounterValue.Call(uintptr(counter), PDH_FMT_DOUBLE|PDH_FMT_NOCAP100, 0, uintptr(unsafe.Pointer(&value)))
+	if status != 0 {
+		return 0, pdhError("PdhGetFormattedCounterValue", status)
+	}
+	return value.DoubleValue, nil
+}
+
+func (p *PerfCounters) Close() error {
+	if p.query != 0 {
+		procPdhCloseQuery.Call(uintptr(p.query))
+		p.query = 0
+	}
+	return nil
+}
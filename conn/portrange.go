@@ -0,0 +1,36 @@
+package conn
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// DefaultDynamicPorts は Windows Vista 以降の既定の動的 (一時) ポートの範囲。
+var DefaultDynamicPorts = PortRange{Low: 49152, High: 65535}
+
+// DynamicPortRange は netsh int ipv4 show dynamicport tcp の出力から、TCP の動的ポートの範囲を返す。
+// 出力は表示言語によって項目名が異なるため、「:」の後の数値を開始ポート、ポート数の順に読む。
+func DynamicPortRange() (PortRange, error) {
+	out, err := exec.Command("netsh", "int", "ipv4", "show", "dynamicport", "tcp").Output()
+	if err != nil {
+		return PortRange{}, &APIError{Op: "netsh", Err: err}
+	}
+	var values []int
+	for _, line := range strings.Split(string(out), "\n") {
+		_, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			values = append(values, n)
+		}
+	}
+	if len(values) < 2 || values[0] <= 0 || values[1] <= 0 || values[0]+values[1]-1 > 65535 {
+		return PortRange{}, &ValueError{Err: ErrNetshOutput, Value: strings.TrimSpace(string(out))}
+	}
+	return PortRange{Low: uint16(values[0]), High: uint16(values[0] + values[1] - 1)}, nil
+}
+
+// Size は範囲に含まれるポートの数を返す。
+func (r PortRange) Size() int { return int(r.High) - int(r.Low) + 1 }
@@ -0,0 +1,421 @@
+package conn
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// processMatcher は 1 回の Collect の間、監視対象のプロセスかどうかを判定する。
+type processMatcher struct {
+	targets     []string
+	regexps     []*regexp.Regexp
+	all         bool
+	descendants map[uint32]bool // Filter.IncludeDescendants 指定時の、対象プロセスの子孫 PID
+	exclude     *processMatcher
+	services    func(pid uint32) []string // svc: 指定の判定に使う、PID がホストしているサービス名
+	containers  []string                  // Filter.Containers
+	containerOf func(pid uint32) string   // Filter.Containers の判定に使う、PID が属するコンテナの ID
+	results     map[uint32]matchResult    // 同じ PID の判定を繰り返さないための結果
+	processes   *processTable             // プロセス名の解決と子孫の判定に使うプロセス一覧
+}
+
+type matchResult struct {
+	process processInfo
+	isMatch bool
+}
+
+func newProcessMatcher(f Filter, processes *processTable) *processMatcher {
+	// プロセス一覧は判定を作るたび (Collect ごと) に 1 回だけ取得し直す。
+	processes.refresh()
+	m := &processMatcher{targets: f.Targets, regexps: f.NameRegexps, all: f.AllProcesses, results: make(map[uint32]matchResult), processes: processes}
+	if f.IncludeDescendants && !f.AllProcesses {
+		m.descendants = m.descendantPIDs()
+	}
+	if len(f.ExcludeTargets) > 0 || len(f.ExcludeRegexps) > 0 {
+		m.exclude = &processMatcher{targets: f.ExcludeTargets, regexps: f.ExcludeRegexps}
+	}
+	if f.hasServiceTarget() {
+		// 新しく開始したサービスも対象にするため、サービスの一覧も Collect ごとに取得し直す。
+		services.refresh()
+		m.setServices(ServiceNames)
+	}
+	if len(f.Containers) > 0 {
+		containers.refresh()
+		m.containers, m.containerOf = f.Containers, ContainerOf
+	}
+	return m
+}
+
+func (m *processMatcher) setServices(names func(pid uint32) []string) {
+	m.services = names
+	if m.exclude != nil {
+		m.exclude.services = names
+	}
+}
+
+// match は PID のプロセス情報と、監視対象かどうかを返す。一覧に無い PID の名前は、問い合わせ中は ResolvingName、
+// 取得できなければ "N/A" になる。
+func (m *processMatcher) match(pid uint32) (processInfo, bool) {
+	if r, ok := m.results[pid]; ok {
+		return r.process, r.isMatch
+	}
+	p, ok := m.processes.get(pid)
+	if !ok {
+		p = processInfo{name: "N/A"}
+	}
+	isMatch := m.matchProcess(pid, p.name)
+	if p.name == ResolvingName && m.exclude != nil {
+		// 除外の指定に一致するか判断できないため、名前が分かるまで出力しない。
+		isMatch = false
+	}
+	m.results[pid] = matchResult{p, isMatch}
+	return p, isMatch
+}
+
+func (m *processMatcher) matchProcess(pid uint32, processName string) bool {
+	if m.exclude != nil && m.exclude.isTarget(pid, processName) {
+		return false
+	}
+	if m.containers != nil && !MatchContainer(m.containerOf(pid), m.containers) {
+		return false
+	}
+	if m.all || m.descendants[pid] {
+		return true
+	}
+	return m.isTarget(pid, processName)
+}
+
+// isTarget は PID またはプロセス名が Targets・NameRegexps のいずれかに一致するかを判定する。
+func (m *processMatcher) isTarget(pid uint32, processName string) bool {
+	pidStr := strconv.FormatUint(uint64(pid), 10)
+	for _, target := range m.targets {
+		if name, ok := isServiceTarget(target); ok {
+			if m.services != nil && slices.ContainsFunc(m.services(pid), func(s string) bool { return MatchProcessName(s, name) }) {
+				return true
+			}
+			continue
+		}
+		if target == pidStr || MatchProcessName(processName, target) {
+			return true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(processName) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchProcessName はプロセス名が pattern に一致するかを大文字小文字を区別せずに判定する。
+// pattern には * ? [...] のワイルドカード (path.Match の書式) を使用できる。
+func MatchProcessName(processName, pattern string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return strings.EqualFold(processName, pattern)
+	}
+	matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(processName))
+	return err == nil && matched
+}
+
+// win32Processes は Toolhelp スナップショットと OpenProcess でプロセスの情報を取得する ProcessInfoProvider。
+type win32Processes struct{}
+
+// Processes は Toolhelp スナップショットから実行中の全プロセスを列挙する。
+func (win32Processes) Processes() ([]ProcessEntry, error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(snapshot)
+
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	if err = windows.Process32First(snapshot, &entry); err != nil {
+		return nil, err
+	}
+	var entries []ProcessEntry
+	for {
+		entries = append(entries, ProcessEntry{
+			PID: entry.ProcessID, ParentPID: entry.ParentProcessID, Name: windows.UTF16ToString(entry.ExeFile[:]),
+		})
+		if err = windows.Process32Next(snapshot, &entry); err != nil {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// descendantPIDs は監視対象に一致するプロセスの、全ての子孫の PID を返す。
+// プロセス一覧は Collect のたびに取得し直すため、新しく起動したワーカーも対象になる。
+func (m *processMatcher) descendantPIDs() map[uint32]bool {
+	children := make(map[uint32][]uint32)
+	var queue []uint32
+	for pid, p := range m.processes.snapshot() {
+		if pid == 0 {
+			continue
+		}
+		children[p.parent] = append(children[p.parent], pid)
+		if m.isTarget(pid, p.name) {
+			queue = append(queue, pid)
+		}
+	}
+	descendants := make(map[uint32]bool)
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, child := range children[pid] {
+			// PID の再利用で親子関係が循環することがあるため、訪問済みは辿らない。
+			if !descendants[child] {
+				descendants[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return descendants
+}
+
+// UnmatchedTargets は f.Targets と f.NameRegexps のうち、実行中のどのプロセスにも一致しないものを返す。
+// 指定の誤り (プロセス名の綴りなど) を監視を始める前に見つけるために使う。
+func UnmatchedTargets(f Filter) []string {
+	processes.refresh()
+	byPID := processes.snapshot()
+	var names func(pid uint32) []string
+	if f.hasServiceTarget() {
+		services.refresh()
+		names = ServiceNames
+	}
+	matchesAny := func(m *processMatcher) bool {
+		for pid, p := range byPID {
+			if m.isTarget(pid, p.name) {
+				return true
+			}
+		}
+		return false
+	}
+	var unmatched []string
+	for _, target := range f.Targets {
+		if !matchesAny(&processMatcher{targets: []string{target}, services: names}) {
+			unmatched = append(unmatched, target)
+		}
+	}
+	for _, re := range f.NameRegexps {
+		if !matchesAny(&processMatcher{regexps: []*regexp.Regexp{re}}) {
+			unmatched = append(unmatched, re.String())
+		}
+	}
+	return unmatched
+}
+
+// --- プロセス一覧のキャッシュ ---
+// ResolvingName は、一覧に無い PID の名前を非同期に問い合わせている間に使うプロセス名。
+// 次回以降の取得で、問い合わせた名前に置き換わる。
+const ResolvingName = "resolving..."
+
+// 一覧に無い PID の名前を問い合わせるワーカーの数と、問い合わせを待たせておける PID の数。
+// 新しいプロセスがまとめて現れても、取得の処理 (Collect) を止めないようにする。
+const (
+	processResolveWorkers = 4
+	processResolveQueue   = 256
+)
+
+type processInfo struct {
+	name    string
+	parent  uint32
+	created time.Time // プロセスの開始時刻 (取得できない場合はゼロ値)
+}
+
+// processTable は Toolhelp スナップショットから作った PID → プロセス情報の対応表。
+// 取得し直すたびに新しい map に置き換えるため、snapshot で得た map は変更されない。
+// 一覧に無い PID は resolve のワーカーが 1 つずつ問い合わせ、結果を次に一覧を取得し直すまで resolved に保持する。
+type processTable struct {
+	mu        sync.Mutex
+	provider  ProcessInfoProvider
+	byPID     map[uint32]processInfo
+	updatedAt time.Time
+	resolved  map[uint32]processInfo
+	pending   map[uint32]bool
+	queue     chan uint32
+	startOnce sync.Once
+}
+
+// processes は Win32 のプロセス一覧。NewCollector、Trace、WatchProcesses と ProcessName などの関数が共有する。
+var processes = newProcessTable(win32Processes{})
+
+// newProcessTable は provider から一覧を取得する processTable を返す。
+func newProcessTable(provider ProcessInfoProvider) *processTable {
+	return &processTable{
+		provider: provider,
+		byPID:    make(map[uint32]processInfo),
+		resolved: make(map[uint32]processInfo),
+		pending:  make(map[uint32]bool),
+		queue:    make(chan uint32, processResolveQueue),
+	}
+}
+
+// refresh は全プロセスを 1 回のスナップショットで取得し直す。終了した PID は対応表から消える。
+// 前回から続いている PID は開始時刻を引き継ぎ、新しい PID と名前が変わった PID (再利用) のみ開始時刻を問い合わせる。
+func (t *processTable) refresh() {
+	t.mu.Lock()
+	provider, prev := t.provider, t.byPID
+	t.mu.Unlock()
+	entries, err := provider.Processes()
+	if err != nil {
+		return
+	}
+
+	byPID := make(map[uint32]processInfo, len(entries))
+	for _, entry := range entries {
+		p := processInfo{name: entry.Name, parent: entry.ParentPID, created: entry.Created}
+		if old, ok := prev[entry.PID]; ok && old.name == p.name {
+			p.created = old.created
+		} else if p.created.IsZero() {
+			p.created = provider.StartTime(entry.PID)
+		}
+		byPID[entry.PID] = p
+	}
+
+	t.mu.Lock()
+	t.byPID = byPID
+	t.updatedAt = time.Now()
+	// 個別に問い合わせた結果は、新しい一覧に含まれているか、既に終了しているため不要になる。
+	clear(t.resolved)
+	t.mu.Unlock()
+}
+
+// get は PID のプロセス情報を返す。一覧をまだ取得していない場合のみ、その場で取得する。
+// 一覧に無い PID は非同期の問い合わせを開始し、結果が出るまでは名前を ResolvingName にして返す。
+func (t *processTable) get(pid uint32) (processInfo, bool) {
+	t.mu.Lock()
+	p, ok := t.byPID[pid]
+	if !ok {
+		p, ok = t.resolved[pid]
+	}
+	loaded := !t.updatedAt.IsZero()
+	t.mu.Unlock()
+	if ok {
+		return p, true
+	}
+	if !loaded {
+		t.refresh()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		p, ok = t.byPID[pid]
+		return p, ok
+	}
+	t.resolveAsync(pid)
+	return processInfo{name: ResolvingName}, true
+}
+
+// resolveAsync は pid の問い合わせをワーカーに依頼する。待ちが一杯の場合は次回に回す。
+func (t *processTable) resolveAsync(pid uint32) {
+	t.startOnce.Do(func() {
+		for range processResolveWorkers {
+			go t.resolveWorker()
+		}
+	})
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending[pid] {
+		return
+	}
+	select {
+	case t.queue <- pid:
+		t.pending[pid] = true
+	default:
+	}
+}
+
+func (t *processTable) resolveWorker() {
+	for pid := range t.queue {
+		t.mu.Lock()
+		provider := t.provider
+		t.mu.Unlock()
+		entry := provider.Query(pid)
+		p := processInfo{name: entry.Name, created: entry.Created}
+		if p.name == "" {
+			p.name = "N/A"
+		}
+		t.mu.Lock()
+		delete(t.pending, pid)
+		if _, listed := t.byPID[pid]; !listed {
+			t.resolved[pid] = p
+		}
+		t.mu.Unlock()
+	}
+}
+
+// Query は PID のプロセスを開いて実行ファイル名と開始時刻を取得する。
+// 既に終了したプロセスや、権限が無く開けないプロセスの名前は空文字列になる。親の PID は取得しない。
+func (win32Processes) Query(pid uint32) ProcessEntry {
+	p := ProcessEntry{PID: pid}
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return p
+	}
+	defer windows.CloseHandle(h)
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err == nil {
+		image := windows.UTF16ToString(buf[:size])
+		p.Name = image[strings.LastIndexByte(image, '\\')+1:]
+	}
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err == nil {
+		p.Created = time.Unix(0, creation.Nanoseconds())
+	}
+	return p
+}
+
+func (t *processTable) snapshot() map[uint32]processInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.byPID
+}
+
+// StartTime はプロセスの開始時刻を返す。権限が無いなどで取得できない場合はゼロ値を返す。
+func (win32Processes) StartTime(pid uint32) time.Time {
+	if pid == 0 || pid == 4 {
+		return time.Time{}
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return time.Time{}
+	}
+	defer windows.CloseHandle(h)
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, creation.Nanoseconds())
+}
+
+// ProcessName は PID に対応する実行ファイル名を返す。問い合わせ中の場合は ResolvingName、取得できない場合は "N/A" を返す。
+func ProcessName(pid uint32) string {
+	if p, ok := processes.get(pid); ok {
+		return p.name
+	}
+	return "N/A"
+}
+
+// ProcessRunning は直近のプロセス一覧に pid のプロセスが含まれるかを返す。
+// start がゼロ値でなければ開始時刻も比べ、PID が別のプロセスに再利用されていれば false を返す。
+// プロセス一覧をまだ取得していない場合は、判断できないため true を返す。
+func ProcessRunning(pid uint32, start time.Time) bool {
+	byPID := processes.snapshot()
+	if len(byPID) == 0 {
+		return true
+	}
+	p, ok := byPID[pid]
+	if !ok {
+		return false
+	}
+	return start.IsZero() || p.created.IsZero() || p.created.Equal(start)
+}
@@ -0,0 +1,82 @@
+package conn
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ProcessDetails は PID から取得したプロセスの詳細情報。
+type ProcessDetails struct {
+	ImagePath   string // 実行ファイルのフルパス
+	CommandLine string // コマンドライン (取得できない場合は空)
+}
+
+// QueryProcessDetails は PID のプロセスの実行ファイルのパスとコマンドラインを取得する。
+// 他ユーザーや保護されたプロセスは管理者権限が無いと取得できない。
+func QueryProcessDetails(pid uint32) (ProcessDetails, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return ProcessDetails{}, err
+	}
+	defer windows.CloseHandle(h)
+
+	var d ProcessDetails
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err != nil {
+		return d, err
+	}
+	d.ImagePath = windows.UTF16ToString(buf[:size])
+	// コマンドラインは Windows 8.1 以降でのみ取得できるため、失敗してもパスだけは返す。
+	d.CommandLine, _ = processCommandLine(h)
+	return d, nil
+}
+
+// processCommandLine は NtQueryInformationProcess (ProcessCommandLineInformation) でコマンドラインを取得する。
+// 結果は UNICODE_STRING と、それが指す文字列を続けたバッファで返される。
+func processCommandLine(h windows.Handle) (string, error) {
+	size := uint32(4096)
+	for {
+		buf := make([]byte, size)
+		var needed uint32
+		err := windows.NtQueryInformationProcess(h, windows.ProcessCommandLineInformation, unsafe.Pointer(&buf[0]), size, &needed)
+		if err == windows.STATUS_INFO_LENGTH_MISMATCH && needed > size {
+			size = needed
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		us := (*windows.NTUnicodeString)(unsafe.Pointer(&buf[0]))
+		return us.String(), nil
+	}
+}
+
+// ProcessOwner は PID のプロセスを所有するアカウント名を "ドメイン\ユーザー" の形式で返す。
+func ProcessOwner(pid uint32) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+
+	var token windows.Token
+	if err := windows.OpenProcessToken(h, windows.TOKEN_QUERY, &token); err != nil {
+		return "", err
+	}
+	defer token.Close()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return "", err
+	}
+	account, domain, _, err := user.User.Sid.LookupAccount("")
+	if err != nil {
+		// アカウント名を引けない SID (削除済みのユーザーなど) は SID 文字列で返す。
+		return user.User.Sid.String(), nil
+	}
+	if domain == "" {
+		return account, nil
+	}
+	return domain + `\` + account, nil
+}
@@ -0,0 +1,210 @@
+package conn
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
+
+	"go-ObuStat/etw"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- ETW (Microsoft-Windows-Kernel-Process) によるプロセスの開始・終了の追跡 ---
+var kernelProcessProvider = windows.GUID{
+	Data1: 0x22fb2cd6, Data2: 0x0e7b, Data3: 0x422b,
+	Data4: [8]byte{0xa0, 0xc7, 0x2f, 0xad, 0x1f, 0xd0, 0xe7, 0x16},
+}
+
+// Kernel-Process のキーワード (WINEVENT_KEYWORD_PROCESS) とイベント ID。
+const (
+	kernelProcessKeywordProcess = 0x10
+	kernelProcessStart          = 1
+	kernelProcessStop           = 2
+)
+
+// ProcessEvent はプロセスの開始または終了。
+type ProcessEvent struct {
+	Time      time.Time
+	Exit      bool // true の場合は終了、false の場合は開始
+	PID       uint32
+	ParentPID uint32    // 開始の場合のみ
+	Name      string    // 実行ファイル名。終了の場合は開始を受け取ったかプロセス一覧にあった場合のみ
+	Created   time.Time // プロセスの開始時刻
+	ExitCode  uint32    // 終了の場合のみ
+}
+
+// ProcessWatcher は ETW からプロセスの開始・終了を受け取り、Filter の対象のプロセスのものだけを通知する。
+// 管理者権限が必要。
+type ProcessWatcher struct {
+	session *etw.Session
+	events  chan ProcessEvent
+	dropped atomic.Uint64
+	mu      sync.Mutex
+	filter  Filter
+	matcher *processMatcher
+	builtAt time.Time
+	started map[uint32]ProcessEvent // 開始を通知したプロセス (終了の通知に名前を付ける)
+}
+
+// WatchProcesses は name の ETW セッションを開始し、Filter の対象のプロセスの開始・終了の通知を始める。
+func WatchProcesses(name string, f Filter) (*ProcessWatcher, error) {
+	w := &ProcessWatcher{
+		filter:  f,
+		events:  make(chan ProcessEvent, 1024),
+		started: make(map[uint32]ProcessEvent),
+	}
+	session, err := etw.Start(name, []etw.Provider{{GUID: kernelProcessProvider, Level: 4, Keywords: kernelProcessKeywordProcess}}, w.handle)
+	if err != nil {
+		return nil, err
+	}
+	w.session = session
+	go func() {
+		<-session.Done()
+		close(w.events)
+	}()
+	return w, nil
+}
+
+// Events はプロセスの開始・終了を受け取るチャネルを返す。セッションが終了すると閉じられる。
+func (w *ProcessWatcher) Events() <-chan ProcessEvent { return w.events }
+
+// Dropped は Events が読まれずに破棄したイベントの数を返す。
+func (w *ProcessWatcher) Dropped() uint64 { return w.dropped.Load() }
+
+// Err はセッションが異常終了した場合のエラーを返す。Events が閉じられた後に呼ぶこと。
+func (w *ProcessWatcher) Err() error { return w.session.Err() }
+
+// Close はセッションを停止する。
+func (w *ProcessWatcher) Close() error { return w.session.Close() }
+
+// SetFilter は対象のプロセスの判定を f に切り替える (設定ファイルの再読み込みなど)。
+func (w *ProcessWatcher) SetFilter(f Filter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.filter, w.matcher = f, nil
+}
+
+func (w *ProcessWatcher) handle(e *etw.Event) {
+	var pe ProcessEvent
+	var ok bool
+	switch e.ID {
+	case kernelProcessStart:
+		pe, ok = parseProcessStart(e.Data, e.Version)
+	case kernelProcessStop:
+		pe, ok = parseProcessStop(e.Data, e.Version)
+	}
+	if !ok {
+		return
+	}
+	pe.Time = e.Time
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.matcher == nil || time.Since(w.builtAt) >= matcherRefreshInterval {
+		w.matcher = newProcessMatcher(w.filter, processes)
+		w.builtAt = time.Now()
+	}
+	if !pe.Exit {
+		isMatch := w.matcher.matchProcess(pe.PID, pe.Name)
+		if !isMatch && w.filter.IncludeDescendants {
+			// 判定を作った後に開始した子孫は、親が対象か開始を通知済みであれば対象にする。
+			_, parentStarted := w.started[pe.ParentPID]
+			_, parentMatch := w.matcher.match(pe.ParentPID)
+			isMatch = parentStarted || parentMatch
+		}
+		if !isMatch {
+			return
+		}
+		if len(w.started) >= 65536 {
+			// 終了イベントを取りこぼしたプロセスで肥大化しないよう、上限に達したら捨てる。
+			clear(w.started)
+		}
+		w.started[pe.PID] = pe
+	} else {
+		if start, ok := w.started[pe.PID]; ok {
+			pe.Name = start.Name
+			delete(w.started, pe.PID)
+		} else {
+			process, isMatch := w.matcher.match(pe.PID)
+			if !isMatch {
+				return
+			}
+			pe.Name = process.name
+		}
+	}
+
+	select {
+	case w.events <- pe:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// parseProcessStart は ProcessStart (ID 1) を解析する。
+// ProcessID, [ProcessSequenceNumber], CreateTime, ParentProcessID, [ParentProcessSequenceNumber], SessionID, [Flags], ImageName の順。
+// [] はバージョン 2 以降 (Flags は 1 以降) にのみある。
+func parseProcessStart(data []byte, version uint8) (ProcessEvent, bool) {
+	seq := 0
+	if version >= 2 {
+		seq = 8
+	}
+	off := 4 + seq + 8 + 4 + seq + 4
+	if version >= 1 {
+		off += 4
+	}
+	if len(data) < off {
+		return ProcessEvent{}, false
+	}
+	pe := ProcessEvent{
+		PID:       binary.LittleEndian.Uint32(data[0:4]),
+		Created:   filetimeToTime(binary.LittleEndian.Uint64(data[4+seq : 12+seq])),
+		ParentPID: binary.LittleEndian.Uint32(data[12+seq : 16+seq]),
+	}
+	// ImageName は \Device\HarddiskVolume3\... 形式のパスのため、ファイル名だけを使う。
+	image := utf16String(data[off:])
+	pe.Name = image[strings.LastIndexByte(image, '\\')+1:]
+	return pe, true
+}
+
+// parseProcessStop は ProcessStop (ID 2) を解析する。
+// ProcessID, [ProcessSequenceNumber], CreateTime, ExitTime, ExitCode の順 ([] はバージョン 2 以降)。
+func parseProcessStop(data []byte, version uint8) (ProcessEvent, bool) {
+	seq := 0
+	if version >= 2 {
+		seq = 8
+	}
+	if len(data) < 4+seq+8+8+4 {
+		return ProcessEvent{}, false
+	}
+	return ProcessEvent{
+		Exit:     true,
+		PID:      binary.LittleEndian.Uint32(data[0:4]),
+		Created:  filetimeToTime(binary.LittleEndian.Uint64(data[4+seq : 12+seq])),
+		ExitCode: binary.LittleEndian.Uint32(data[20+seq : 24+seq]),
+	}, true
+}
+
+// utf16String は data の先頭にある NUL 終端の UTF-16LE 文字列を返す。
+func utf16String(data []byte) string {
+	var s []uint16
+	for i := 0; i+1 < len(data); i += 2 {
+		c := binary.LittleEndian.Uint16(data[i:])
+		if c == 0 {
+			break
+		}
+		s = append(s, c)
+	}
+	return string(utf16.Decode(s))
+}
+
+// filetimeToTime は FILETIME の値を time.Time にする。0 はゼロ値にする。
+func filetimeToTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, (&windows.Filetime{LowDateTime: uint32(ft), HighDateTime: uint32(ft >> 32)}).Nanoseconds())
+}
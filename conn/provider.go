@@ -0,0 +1,66 @@
+package conn
+
+import (
+	"net/netip"
+	"time"
+)
+
+// --- Win32 の呼び出しを差し替えるためのインターフェース ---
+// 接続テーブルとプロセス一覧の取得をインターフェースの裏に置き、Win32 の実装 (既定) と
+// 決まった値を返す実装 (FakeTables, FakeProcesses) を切り替えられるようにする。
+// 差し替えると、実機の TCP スタックが無くても Collect 以降の処理 (フィルター、差分、判定) を動かせる。
+
+// TableRow は接続テーブルの 1 行。アドレスは文字列にする前の値で、対象のプロセスの行だけを文字列にする。
+type TableRow struct {
+	Protocol   string     // "TCP", "UDP"
+	LocalAddr  netip.Addr // IPv6 のテーブルの行は 16 バイトの形式 (IPv4 射影アドレスを含む)
+	LocalPort  uint16
+	RemoteAddr netip.Addr // UDP では設定しない
+	RemotePort uint16
+	State      string // TCP の状態名 (TCPStateNames のいずれか)。UDP では設定しない
+	PID        uint32
+
+	// 以下は Filter.CollectModules 指定時の TCP の行のみ。
+	Created int64         // 接続の作成時刻 (同じアドレスの組で作り直された接続と区別する)
+	Module  func() string // 接続を所有するモジュール名を取得する。Filter に一致した行でのみ呼び出す
+}
+
+// TcpTableProvider は TCP/UDP の接続テーブルを提供する。
+type TcpTableProvider interface {
+	// Rows は protocol ("tcp", "udp") と family (windows.AF_INET, windows.AF_INET6) のテーブルの各行を fn に渡す。
+	// modules が true の場合は、TCP の行に Created と Module を設定する。
+	// 渡した TableRow は fn から戻った後に保持してはならない (Module が取得中のテーブルを参照するため)。
+	Rows(protocol string, family uint32, modules bool, fn func(TableRow)) error
+}
+
+// ProcessEntry はプロセス一覧の 1 件。
+type ProcessEntry struct {
+	PID       uint32
+	ParentPID uint32
+	Name      string    // 実行ファイル名。取得できない場合は空文字列
+	Created   time.Time // プロセスの開始時刻。取得できない場合はゼロ値
+}
+
+// ProcessInfoProvider は実行中のプロセスの情報を提供する。
+type ProcessInfoProvider interface {
+	// Processes は実行中の全プロセスを返す。Created は設定しなくてよい (新しい PID のみ StartTime で問い合わせる)。
+	Processes() ([]ProcessEntry, error)
+	// StartTime は PID のプロセスの開始時刻を返す。取得できない場合はゼロ値を返す。
+	StartTime(pid uint32) time.Time
+	// Query は一覧に無い PID のプロセスの名前と開始時刻を問い合わせる。親の PID は設定しなくてよい。
+	Query(pid uint32) ProcessEntry
+}
+
+// NewCollectorWith は tables から接続を取得し、procs からプロセス名の解決と監視対象の判定に使うプロセス一覧を取得する Collector を返す。
+// procs が nil の場合は、NewCollector などと共有する Win32 のプロセス一覧を使う。procs を渡した場合の一覧はこの Collector だけが持つ。
+// Filter.CollectTraffic の通信量は tables に関係なく Win32 の ESTATS から取得するため、Win32 以外の tables と組み合わせないこと。
+func NewCollectorWith(f Filter, tables TcpTableProvider, procs ProcessInfoProvider) *Collector {
+	c := &Collector{filter: f, tables: tables, processes: processes}
+	if procs != nil {
+		c.processes = newProcessTable(procs)
+	}
+	if f.CollectModules {
+		c.modules = newModuleCache()
+	}
+	return c
+}
@@ -0,0 +1,64 @@
+package conn
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Scope はリモートアドレスの種類 (ループバック、リンクローカル、プライベート、パブリックなど)。
+type Scope string
+
+const (
+	ScopeLoopback    Scope = "loopback"    // 127.0.0.0/8, ::1
+	ScopeLinkLocal   Scope = "link-local"  // 169.254.0.0/16, fe80::/10
+	ScopePrivate     Scope = "private"     // RFC 1918 (10/8, 172.16/12, 192.168/16), fc00::/7
+	ScopePublic      Scope = "public"      // 上記以外のユニキャスト (マシンの外へ出る接続)
+	ScopeMulticast   Scope = "multicast"   // 224.0.0.0/4, ff00::/8
+	ScopeUnspecified Scope = "unspecified" // 0.0.0.0, :: (待ち受けなど、リモートが無いもの)
+)
+
+// ScopeNames は -scope で指定できる種類の一覧。
+var ScopeNames = []Scope{ScopeLoopback, ScopeLinkLocal, ScopePrivate, ScopePublic, ScopeMulticast, ScopeUnspecified}
+
+// AddrScope はアドレスの種類を返す。アドレスとして解析できない場合 (UDP のリモートなど) は "" を返す。
+func AddrScope(addr string) Scope {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return ""
+	}
+	ip = ip.Unmap()
+	switch {
+	case ip.IsUnspecified():
+		return ScopeUnspecified
+	case ip.IsLoopback():
+		return ScopeLoopback
+	case ip.IsLinkLocalUnicast():
+		return ScopeLinkLocal
+	case ip.IsMulticast():
+		return ScopeMulticast
+	case ip.IsPrivate():
+		return ScopePrivate
+	}
+	return ScopePublic
+}
+
+// ParseScopes は "public,private" のようなカンマ区切りの種類名を検証し、小文字に揃えて返す。
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+	for _, item := range strings.Split(s, ",") {
+		scope := Scope(strings.ToLower(strings.TrimSpace(item)))
+		if scope == "" {
+			continue
+		}
+		if !slices.Contains(ScopeNames, scope) {
+			names := make([]string, len(ScopeNames))
+			for i, name := range ScopeNames {
+				names[i] = string(name)
+			}
+			return nil, &ValueError{Err: ErrUnknownScope, Value: item, Valid: names}
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
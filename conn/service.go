@@ -0,0 +1,121 @@
+package conn
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- サービスをホストするプロセス ---
+// svchost.exe などサービスをホストするプロセスは、SCM (EnumServicesStatusEx) から PID → サービス名の対応を得る。
+
+// ServicePrefix は -n の要素をプロセス名ではなくサービス名として扱う接頭辞 (例: svc:Dnscache)。
+const ServicePrefix = "svc:"
+
+// minServiceRefresh は ServiceNames で、サービスの一覧を取得し直す最短の間隔。
+const minServiceRefresh = 5 * time.Second
+
+// serviceTable は実行中のサービスの PID → サービス名の対応表。
+type serviceTable struct {
+	mu        sync.Mutex
+	byPID     map[uint32][]string
+	updatedAt time.Time
+}
+
+var services = &serviceTable{byPID: make(map[uint32][]string)}
+
+// refresh は実行中のサービスを取得し直す。SCM に接続できない場合は前回の対応表を残す。
+func (t *serviceTable) refresh() {
+	byPID, err := listServices()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.updatedAt = time.Now()
+	if err == nil {
+		t.byPID = byPID
+	}
+}
+
+// get は PID がホストしているサービス名を返す。前回の取得から minServiceRefresh 以上経っていれば取得し直す。
+func (t *serviceTable) get(pid uint32) []string {
+	t.mu.Lock()
+	stale := time.Since(t.updatedAt) >= minServiceRefresh
+	t.mu.Unlock()
+	if stale {
+		t.refresh()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.byPID[pid]
+}
+
+// ServiceNames は PID のプロセスがホストしている実行中のサービス名を、名前順に返す。サービスでない場合は nil を返す。
+func ServiceNames(pid uint32) []string {
+	if pid == 0 || pid == 4 {
+		return nil
+	}
+	return services.get(pid)
+}
+
+// listServices は EnumServicesStatusEx で実行中の Win32 サービスを列挙し、PID ごとにまとめる。
+func listServices() (map[uint32][]string, error) {
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_ENUMERATE_SERVICE)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseServiceHandle(scm)
+
+	byPID := make(map[uint32][]string)
+	var buf []byte
+	var resume uint32
+	for {
+		var needed, count uint32
+		var p *byte
+		if len(buf) > 0 {
+			p = &buf[0]
+		}
+		err := windows.EnumServicesStatusEx(scm, windows.SC_ENUM_PROCESS_INFO, windows.SERVICE_WIN32, windows.SERVICE_ACTIVE,
+			p, uint32(len(buf)), &needed, &count, &resume, nil)
+		if err != nil && err != windows.ERROR_MORE_DATA {
+			return nil, err
+		}
+		if count > 0 {
+			entries := unsafe.Slice((*windows.ENUM_SERVICE_STATUS_PROCESS)(unsafe.Pointer(&buf[0])), count)
+			for _, e := range entries {
+				if pid := e.ServiceStatusProcess.ProcessId; pid != 0 {
+					byPID[pid] = append(byPID[pid], windows.UTF16PtrToString(e.ServiceName))
+				}
+			}
+		}
+		if err == nil {
+			break
+		}
+		// バッファが不足した場合は、残りを列挙できる大きさにして続きから取得する。
+		buf = make([]byte, max(needed, uint32(len(buf))))
+	}
+	for _, names := range byPID {
+		slices.SortFunc(names, func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) })
+	}
+	return byPID, nil
+}
+
+// isServiceTarget は target が svc: で始まる場合に、サービス名の部分を返す。
+func isServiceTarget(target string) (string, bool) {
+	if len(target) > len(ServicePrefix) && strings.EqualFold(target[:len(ServicePrefix)], ServicePrefix) {
+		return target[len(ServicePrefix):], true
+	}
+	return "", false
+}
+
+// hasServiceTarget は Targets・ExcludeTargets に svc: 指定が含まれるかを判定する。
+func (f Filter) hasServiceTarget() bool {
+	for _, t := range slices.Concat(f.Targets, f.ExcludeTargets) {
+		if _, ok := isServiceTarget(t); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+package conn
+
+import (
+	"net/netip"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- Win32 API 構造体と定数の定義 ---
+type MIB_TCPROW_OWNER_PID struct {
+	State      uint32
+	LocalAddr  uint32
+	LocalPort  uint32
+	RemoteAddr uint32
+	RemotePort uint32
+	OwningPid  uint32
+}
+type MIB_TCPTABLE_OWNER_PID struct {
+	NumEntries uint32
+	Table      [1]MIB_TCPROW_OWNER_PID
+}
+type MIB_TCP6ROW_OWNER_PID struct {
+	LocalAddr     [16]byte
+	LocalScopeId  uint32
+	LocalPort     uint32
+	RemoteAddr    [16]byte
+	RemoteScopeId uint32
+	RemotePort    uint32
+	State         uint32
+	OwningPid     uint32
+}
+type MIB_TCP6TABLE_OWNER_PID struct {
+	NumEntries uint32
+	Table      [1]MIB_TCP6ROW_OWNER_PID
+}
+type MIB_UDPROW_OWNER_PID struct {
+	LocalAddr uint32
+	LocalPort uint32
+	OwningPid uint32
+}
+type MIB_UDPTABLE_OWNER_PID struct {
+	NumEntries uint32
+	Table      [1]MIB_UDPROW_OWNER_PID
+}
+type MIB_UDP6ROW_OWNER_PID struct {
+	LocalAddr    [16]byte
+	LocalScopeId uint32
+	LocalPort    uint32
+	OwningPid    uint32
+}
+type MIB_UDP6TABLE_OWNER_PID struct {
+	NumEntries uint32
+	Table      [1]MIB_UDP6ROW_OWNER_PID
+}
+
+const (
+	TCP_TABLE_OWNER_PID_ALL = 5
+	UDP_TABLE_OWNER_PID     = 1
+)
+
+var (
+	iphlpapi                = windows.NewLazySystemDLL("iphlpapi.dll")
+	procGetExtendedTcpTable = iphlpapi.NewProc("GetExtendedTcpTable")
+	procGetExtendedUdpTable = iphlpapi.NewProc("GetExtendedUdpTable")
+)
+
+// CheckAPI は接続の取得に使う iphlpapi.dll の関数を読み込めるか確認する。
+func CheckAPI() error {
+	for _, proc := range []*windows.LazyProc{procGetExtendedTcpTable, procGetExtendedUdpTable} {
+		if err := proc.Find(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// テーブルの取得を再試行する回数と、再試行までの最初の待ち時間。待ち時間は再試行のたびに倍にする。
+// 負荷が高いと、サイズの問い合わせから取得までの間にテーブルが大きくなったり、一時的に失敗したりするため。
+const (
+	tableMaxAttempts  = 6
+	tableRetryBackoff = 5 * time.Millisecond
+)
+
+// getExtendedTable は GetExtendedTcpTable / GetExtendedUdpTable を呼び出し、テーブル全体を格納したバッファを返す。
+// buf の容量が足りる場合はそのまま再利用し、足りない場合は余裕を持たせて確保し直す。
+// 容量不足や一時的なエラーは、待ち時間を延ばしながら再試行する。引数の誤りなど再試行しても直らないエラーはすぐに返す。
+func getExtendedTable(proc *windows.LazyProc, family uint32, tableClass uintptr, buf []byte) ([]byte, error) {
+	buf = buf[:cap(buf)]
+	backoff := tableRetryBackoff
+	var lastErr *APIError
+	wait := false
+	for attempt := 0; attempt < tableMaxAttempts; attempt++ {
+		if wait {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		size := uint32(len(buf))
+		var p uintptr
+		if size > 0 {
+			p = uintptr(unsafe.Pointer(&buf[0]))
+		}
+		ret, _, _ := proc.Call(p, uintptr(unsafe.Pointer(&size)), 0, uintptr(family), tableClass, 0)
+		switch ret {
+		case 0:
+			return buf[:size], nil
+		case uintptr(windows.ERROR_INSUFFICIENT_BUFFER):
+			// 取得までに増える分を見込み、容量不足が続くほど余裕を大きくする。
+			buf = make([]byte, int(size)+int(size)/4<<attempt)
+			lastErr = &APIError{Op: proc.Name, Err: ErrTableGrowing}
+			// 最初の容量不足はサイズの問い合わせと同じ扱いのため、待たずに取得し直す。
+			wait = attempt > 0
+		case uintptr(windows.ERROR_INVALID_PARAMETER), uintptr(windows.ERROR_NOT_SUPPORTED):
+			return nil, &APIError{Op: proc.Name, Err: windows.Errno(ret)}
+		default:
+			lastErr = &APIError{Op: proc.Name, Err: windows.Errno(ret)}
+			wait = true
+		}
+	}
+	lastErr.Attempts = tableMaxAttempts
+	return nil, lastErr
+}
+
+// Collect は Filter に従って TCP/UDP テーブルを取得し、対象プロセスの接続を Snapshot として返す。
+// 繰り返し取得する場合は、バッファを再利用する Collector を使うこと。
+func Collect(f Filter) (Snapshot, error) {
+	return NewCollector(f).Collect()
+}
+
+type tableKey struct {
+	protocol string
+	family   uint32
+}
+
+// Collector は同じ Filter で繰り返し接続を取得する。テーブル用のバッファを取得のたびに再利用し、
+// 結果の Snapshot も前回の件数で容量を確保して、定期的な取得でのメモリ確保を減らす。
+// 並行して使用してはならない。
+type Collector struct {
+	filter    Filter
+	tables    TcpTableProvider
+	processes *processTable
+	lastSize  int
+	modules   *moduleCache // Filter.CollectModules 指定時のみ
+}
+
+// NewCollector は Win32 の接続テーブルとプロセス一覧から取得する Collector を返す。
+func NewCollector(f Filter) *Collector {
+	return NewCollectorWith(f, &win32Tables{buffers: make(map[tableKey][]byte)}, nil)
+}
+
+// Collect は接続を取得する。返す Snapshot は呼び出し側が保持してよい (内部では再利用しない)。
+func (c *Collector) Collect() (Snapshot, error) {
+	f := c.filter
+	connections := make(Snapshot, c.lastSize)
+	now := time.Now()
+	m := newProcessMatcher(f, c.processes)
+	for _, protocol := range f.Protocols {
+		for _, family := range f.Families {
+			err := c.tables.Rows(protocol, family, f.CollectModules, func(row TableRow) {
+				c.add(row, m, now, connections)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if f.CollectTraffic {
+		attachTrafficStats(connections)
+	}
+	if c.modules != nil {
+		c.modules.prune()
+	}
+	c.lastSize = len(connections)
+	return connections, nil
+}
+
+// add はテーブルの 1 行が対象のプロセスの接続で、Filter の条件を満たす場合に connections へ追加する。
+func (c *Collector) add(row TableRow, m *processMatcher, now time.Time, connections Snapshot) {
+	process, isMatch := m.match(row.PID)
+	if !isMatch {
+		return
+	}
+	conn := Connection{
+		Protocol:    row.Protocol,
+		ProcessName: process.name, PID: row.PID, ProcessStart: process.created,
+		LocalAddr: addrString(row.LocalAddr), LocalPort: row.LocalPort,
+		State: row.State, FirstSeen: now,
+	}
+	if row.Protocol == "UDP" {
+		conn.State = UDPState
+	} else {
+		conn.RemoteAddr, conn.RemotePort = addrString(row.RemoteAddr), row.RemotePort
+		if row.RemoteAddr.IsUnspecified() && !c.filter.includesListen(conn) {
+			return
+		}
+	}
+	if !c.filter.accept(conn) {
+		return
+	}
+	if c.modules != nil && row.Module != nil {
+		conn.Module = c.modules.lookup(moduleKey{conn.Key(), row.Created}, row.Module)
+	}
+	add(connections, conn)
+}
+
+// win32Tables は GetExtendedTcpTable / GetExtendedUdpTable でテーブルを取得する TcpTableProvider。
+// テーブル用のバッファを取得のたびに再利用する。
+type win32Tables struct {
+	buffers map[tableKey][]byte
+}
+
+func (t *win32Tables) Rows(protocol string, family uint32, modules bool, fn func(TableRow)) error {
+	key := tableKey{protocol, family}
+	proc, class := procGetExtendedTcpTable, uintptr(TCP_TABLE_OWNER_PID_ALL)
+	switch {
+	case protocol == "udp":
+		proc, class = procGetExtendedUdpTable, UDP_TABLE_OWNER_PID
+	case modules:
+		class = TCP_TABLE_OWNER_MODULE_ALL
+	}
+	buf, err := getExtendedTable(proc, family, class, t.buffers[key])
+	if err != nil {
+		return err
+	}
+	t.buffers[key] = buf
+	if len(buf) == 0 {
+		return nil
+	}
+	switch {
+	case protocol == "udp" && family == windows.AF_INET6:
+		udp6Rows(buf, fn)
+	case protocol == "udp":
+		udpRows(buf, fn)
+	case modules && family == windows.AF_INET6:
+		tcp6ModuleRows(buf, fn)
+	case modules:
+		tcpModuleRows(buf, fn)
+	case family == windows.AF_INET6:
+		tcp6Rows(buf, fn)
+	default:
+		tcpRows(buf, fn)
+	}
+	return nil
+}
+
+func tcpRows(buf []byte, fn func(TableRow)) {
+	table := (*MIB_TCPTABLE_OWNER_PID)(unsafe.Pointer(&buf[0]))
+	rowSize := unsafe.Sizeof(MIB_TCPROW_OWNER_PID{})
+	for i := uint32(0); i < table.NumEntries; i++ {
+		row := (*MIB_TCPROW_OWNER_PID)(unsafe.Pointer(uintptr(unsafe.Pointer(&table.Table[0])) + uintptr(i)*rowSize))
+		fn(TableRow{
+			Protocol: "TCP", PID: row.OwningPid,
+			LocalAddr: ipv4Addr(row.LocalAddr), LocalPort: portToUint16(row.LocalPort),
+			RemoteAddr: ipv4Addr(row.RemoteAddr), RemotePort: portToUint16(row.RemotePort),
+			State: getTCPStateName(row.State),
+		})
+	}
+}
+
+func tcp6Rows(buf []byte, fn func(TableRow)) {
+	table := (*MIB_TCP6TABLE_OWNER_PID)(unsafe.Pointer(&buf[0]))
+	rowSize := unsafe.Sizeof(MIB_TCP6ROW_OWNER_PID{})
+	for i := uint32(0); i < table.NumEntries; i++ {
+		row := (*MIB_TCP6ROW_OWNER_PID)(unsafe.Pointer(uintptr(unsafe.Pointer(&table.Table[0])) + uintptr(i)*rowSize))
+		fn(TableRow{
+			Protocol: "TCP", PID: row.OwningPid,
+			LocalAddr: netip.AddrFrom16(row.LocalAddr), LocalPort: portToUint16(row.LocalPort),
+			RemoteAddr: netip.AddrFrom16(row.RemoteAddr), RemotePort: portToUint16(row.RemotePort),
+			State: getTCPStateName(row.State),
+		})
+	}
+}
+
+func udpRows(buf []byte, fn func(TableRow)) {
+	table := (*MIB_UDPTABLE_OWNER_PID)(unsafe.Pointer(&buf[0]))
+	rowSize := unsafe.Sizeof(MIB_UDPROW_OWNER_PID{})
+	for i := uint32(0); i < table.NumEntries; i++ {
+		row := (*MIB_UDPROW_OWNER_PID)(unsafe.Pointer(uintptr(unsafe.Pointer(&table.Table[0])) + uintptr(i)*rowSize))
+		fn(TableRow{
+			Protocol: "UDP", PID: row.OwningPid,
+			LocalAddr: ipv4Addr(row.LocalAddr), LocalPort: portToUint16(row.LocalPort),
+		})
+	}
+}
+
+func udp6Rows(buf []byte, fn func(TableRow)) {
+	table := (*MIB_UDP6TABLE_OWNER_PID)(unsafe.Pointer(&buf[0]))
+	rowSize := unsafe.Sizeof(MIB_UDP6ROW_OWNER_PID{})
+	for i := uint32(0); i < table.NumEntries; i++ {
+		row := (*MIB_UDP6ROW_OWNER_PID)(unsafe.Pointer(uintptr(unsafe.Pointer(&table.Table[0])) + uintptr(i)*rowSize))
+		fn(TableRow{
+			Protocol: "UDP", PID: row.OwningPid,
+			LocalAddr: netip.AddrFrom16(row.LocalAddr), LocalPort: portToUint16(row.LocalPort),
+		})
+	}
+}
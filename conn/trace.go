@@ -0,0 +1,154 @@
+package conn
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-ObuStat/etw"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- ETW (Microsoft-Windows-Kernel-Network) による接続・切断の追跡 ---
+var kernelNetworkProvider = windows.GUID{
+	Data1: 0x7dd42a49, Data2: 0x5329, Data3: 0x4832,
+	Data4: [8]byte{0x8d, 0xfd, 0x43, 0xd9, 0x79, 0x15, 0x3a, 0x88},
+}
+
+// Kernel-Network のイベント ID (TCP の接続・受け入れ・切断)。
+const (
+	kernelNetworkTCPConnectV4    = 12
+	kernelNetworkTCPDisconnectV4 = 13
+	kernelNetworkTCPAcceptV4     = 15
+	kernelNetworkTCPConnectV6    = 28
+	kernelNetworkTCPDisconnectV6 = 29
+	kernelNetworkTCPAcceptV6     = 31
+)
+
+// matcherRefreshInterval ごとにプロセスの判定 (-tree の子孫一覧など) を作り直す。
+const matcherRefreshInterval = time.Second
+
+// Tracer は ETW から TCP の接続・切断を受け取り、ポーリング間隔より短い接続も Event として通知する。
+// 管理者権限が必要。
+type Tracer struct {
+	filter   Filter
+	session  *etw.Session
+	events   chan Event
+	dropped  atomic.Uint64
+	mu       sync.Mutex
+	matcher  *processMatcher
+	builtAt  time.Time
+	openedAt map[string]time.Time
+}
+
+// StartTrace は name の ETW セッションを開始し、Filter に一致する接続イベントの通知を始める。
+func StartTrace(name string, f Filter) (*Tracer, error) {
+	t := &Tracer{
+		filter:   f,
+		events:   make(chan Event, 4096),
+		openedAt: make(map[string]time.Time),
+	}
+	session, err := etw.Start(name, []etw.Provider{{GUID: kernelNetworkProvider, Level: 5}}, t.handle)
+	if err != nil {
+		return nil, err
+	}
+	t.session = session
+	go func() {
+		<-session.Done()
+		close(t.events)
+	}()
+	return t, nil
+}
+
+// Events は接続イベントを受け取るチャネルを返す。セッションが終了すると閉じられる。
+func (t *Tracer) Events() <-chan Event { return t.events }
+
+// Dropped は Events が読まれずに破棄したイベントの数を返す。
+func (t *Tracer) Dropped() uint64 { return t.dropped.Load() }
+
+// Err はセッションが異常終了した場合のエラーを返す。Events が閉じられた後に呼ぶこと。
+func (t *Tracer) Err() error { return t.session.Err() }
+
+// Close はセッションを停止する。
+func (t *Tracer) Close() error { return t.session.Close() }
+
+func (t *Tracer) handle(e *etw.Event) {
+	var eventType EventType
+	var state string
+	family := uint32(windows.AF_INET)
+	switch e.ID {
+	case kernelNetworkTCPConnectV4, kernelNetworkTCPConnectV6:
+		eventType, state = EventNew, "SYN_SENT"
+	case kernelNetworkTCPAcceptV4, kernelNetworkTCPAcceptV6:
+		eventType, state = EventNew, "ESTABLISHED"
+	case kernelNetworkTCPDisconnectV4, kernelNetworkTCPDisconnectV6:
+		eventType, state = EventClosed, "CLOSED"
+	default:
+		return
+	}
+	if e.ID >= kernelNetworkTCPConnectV6 {
+		family = windows.AF_INET6
+	}
+	c, ok := parseKernelNetworkTCP(e.Data, family == windows.AF_INET6)
+	if !ok || !slices.Contains(t.filter.Families, family) || !slices.Contains(t.filter.Protocols, "tcp") {
+		return
+	}
+	c.State = state
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.matcher == nil || time.Since(t.builtAt) >= matcherRefreshInterval {
+		t.matcher = newProcessMatcher(t.filter, processes)
+		t.builtAt = time.Now()
+	}
+	process, isMatch := t.matcher.match(c.PID)
+	if !isMatch || !t.filter.accept(c) {
+		return
+	}
+	c.ProcessName, c.ProcessStart = process.name, process.created
+
+	key := c.Key()
+	if eventType == EventNew {
+		c.FirstSeen = e.Time
+		if len(t.openedAt) >= 65536 {
+			// 切断イベントを取りこぼした接続で肥大化しないよう、上限に達したら捨てる。
+			clear(t.openedAt)
+		}
+		t.openedAt[key] = e.Time
+	} else {
+		c.FirstSeen = t.openedAt[key]
+		delete(t.openedAt, key)
+	}
+
+	select {
+	case t.events <- Event{Time: e.Time, Type: eventType, Key: key, Conn: c}:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// parseKernelNetworkTCP は TCP イベントの先頭部分 (PID, size, daddr, saddr, dport, sport) を解析する。
+// アドレスとポートはネットワークバイトオーダーで格納されている。
+func parseKernelNetworkTCP(data []byte, ipv6 bool) (Connection, bool) {
+	addrLen := 4
+	if ipv6 {
+		addrLen = 16
+	}
+	if len(data) < 8+addrLen*2+4 {
+		return Connection{}, false
+	}
+	c := Connection{Protocol: "TCP", PID: binary.LittleEndian.Uint32(data[0:4])}
+	off := 8
+	remote, _ := netip.AddrFromSlice(data[off : off+addrLen])
+	local, _ := netip.AddrFromSlice(data[off+addrLen : off+addrLen*2])
+	off += addrLen * 2
+	c.RemoteAddr = remote.String()
+	c.LocalAddr = local.String()
+	c.RemotePort = binary.BigEndian.Uint16(data[off : off+2])
+	c.LocalPort = binary.BigEndian.Uint16(data[off+2 : off+4])
+	return c, true
+}
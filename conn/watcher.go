@@ -0,0 +1,79 @@
+package conn
+
+import (
+	"sync"
+	"time"
+)
+
+// Watcher は一定間隔で Collect を実行し、前回との差分を Event としてチャネルへ送る。
+type Watcher struct {
+	filter   Filter
+	interval time.Duration
+	events   chan Event
+	errors   chan error
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewWatcher は監視を開始した Watcher を返す。不要になったら Stop を呼ぶこと。
+func NewWatcher(f Filter, interval time.Duration) *Watcher {
+	w := &Watcher{
+		filter:   f,
+		interval: interval,
+		events:   make(chan Event, 64),
+		errors:   make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// Events は状態変化イベントを受け取るチャネルを返す。Stop 後に閉じられる。
+func (w *Watcher) Events() <-chan Event { return w.events }
+
+// Errors は接続情報の取得エラーを受け取るチャネルを返す。読まれないエラーは破棄される。
+func (w *Watcher) Errors() <-chan error { return w.errors }
+
+// Stop は監視を停止し、Events チャネルを閉じる。
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+		close(w.events)
+	})
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	collector := NewCollector(w.filter)
+	prev := make(Snapshot)
+	for {
+		select {
+		case <-w.done:
+			return
+		case now := <-ticker.C:
+			current, err := collector.Collect()
+			if err != nil {
+				select {
+				case w.errors <- err:
+				default:
+				}
+				continue
+			}
+			for _, e := range Diff(prev, current) {
+				e.Time = now
+				select {
+				case w.events <- e:
+				case <-w.done:
+					return
+				}
+			}
+			prev = current
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// --- Web ダッシュボード (serve モードの /) ---
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardHandler は埋め込んだダッシュボードに表示言語の訳を埋め込んで返す。
+// 訳は起動時の表示言語で一度だけ埋め込む。
+func dashboardHandler() http.Handler {
+	page, err := dashboardFiles.ReadFile("dashboard/index.html")
+	if err != nil {
+		panic(err)
+	}
+	translations, err := json.Marshal(dashboardMessages())
+	if err != nil {
+		panic(err)
+	}
+	page = bytes.Replace(page, []byte(`<html lang="ja">`), []byte(`<html lang="`+language+`">`), 1)
+	page = bytes.Replace(page, []byte("const messages = {};"), []byte("const messages = "+string(translations)+";"), 1)
+	modified := time.Now()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "index.html", modified, bytes.NewReader(page))
+	})
+}
+
+// dashboardMessages はダッシュボードが表示する文の訳を、原文をキーにして返す。
+// ダッシュボードの t() は tr と同じく、訳の無い文を原文のまま表示する。
+func dashboardMessages() map[string]string {
+	return map[string]string{
+		"プロセス名・アドレス・ポートで絞り込み": tr("プロセス名・アドレス・ポートで絞り込み"),
+		"全ての状態":         tr("全ての状態"),
+		"接続中...":        tr("接続中..."),
+		"%d件":           tr("%d件"),
+		"プロトコル":         tr("プロトコル"),
+		"ローカル":          tr("ローカル"),
+		"リモート":          tr("リモート"),
+		"ホスト":           tr("ホスト"),
+		"状態":            tr("状態"),
+		"一致する接続はありません":  tr("一致する接続はありません"),
+		"取得に失敗しました: %s": tr("取得に失敗しました: %s"),
+		"接続済み":          tr("接続済み"),
+		"切断されました (再接続中...)": tr("切断されました (再接続中...)"),
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 短時間で消える接続のまとめ (-debounce) ---
+// eventFlap は、-debounce の期間内に現れて消えた接続を NEW と CLOSED の代わりに 1 件で出力するイベントの種別。
+// ヘルスチェックのような短命な接続で、NEW と CLOSED の組が大量に出力されるのを防ぐ。
+const eventFlap conn.EventType = "FLAP"
+
+// debouncer は NEW を期間が過ぎるまで保留し、その間に CLOSED になった接続を FLAP にまとめる。
+type debouncer struct {
+	window  time.Duration
+	pending map[string]*pendingNew
+	order   []pendingKey // 保留した順の Key (期限切れの判定用)
+}
+
+// pendingKey は order の要素。FLAP にした後で同じ Key が再び保留された場合は seen が一致しない古い要素として読み飛ばす。
+type pendingKey struct {
+	key  string
+	seen time.Time
+}
+
+type pendingNew struct {
+	event conn.Event
+	seen  time.Time
+}
+
+func newDebouncer(window time.Duration) *debouncer {
+	return &debouncer{window: window, pending: make(map[string]*pendingNew)}
+}
+
+// apply は now に検出したイベントを受け取り、出力するイベントを返す。
+// 期間が過ぎた保留中の NEW は、同じ取得の CLOSED などより先に出力するため、events を処理する前に返すイベントに含める。
+func (d *debouncer) apply(now time.Time, events []conn.Event) []conn.Event {
+	if d == nil {
+		return events
+	}
+	out := d.expired(now)
+	for _, e := range events {
+		p, isPending := d.pending[e.Key]
+		switch {
+		case e.Type == conn.EventNew && isPending:
+			// 保留中の Key は保留を始めた時刻のまま、NEW の内容だけを新しくする。
+			p.event = e
+			continue
+		case e.Type == conn.EventNew:
+			d.pending[e.Key] = &pendingNew{event: e, seen: now}
+			d.order = append(d.order, pendingKey{key: e.Key, seen: now})
+			continue
+		case isPending && (e.Type == conn.EventChange || e.Type == conn.EventRebound):
+			// NEW をまだ出力していないため、状態の変化は NEW に反映するだけにする。
+			p.event.Conn = e.Conn
+			continue
+		case isPending && e.Type == conn.EventClosed:
+			delete(d.pending, e.Key)
+			out = append(out, conn.Event{
+				Type:      eventFlap,
+				Key:       e.Key,
+				Conn:      e.Conn,
+				PrevState: p.event.Conn.State,
+				Detail:    fmt.Sprintf(tr("%s 以内に接続・切断されました"), formatLifetime(now.Sub(p.seen))),
+			})
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// expired は保留期間が過ぎた NEW を、保留した順に返す。
+func (d *debouncer) expired(now time.Time) []conn.Event {
+	var events []conn.Event
+	i := 0
+	for ; i < len(d.order); i++ {
+		k := d.order[i]
+		p, ok := d.pending[k.key]
+		if !ok || !p.seen.Equal(k.seen) {
+			continue // FLAP として出力済み
+		}
+		if now.Sub(p.seen) < d.window {
+			break
+		}
+		delete(d.pending, k.key)
+		events = append(events, p.event)
+	}
+	d.order = d.order[i:]
+	return events
+}
+
+// flush は終了時に、保留中の NEW を全て返す。
+func (d *debouncer) flush() []conn.Event {
+	if d == nil {
+		return nil
+	}
+	var events []conn.Event
+	for _, k := range d.order {
+		if p, ok := d.pending[k.key]; ok && p.seen.Equal(k.seen) {
+			events = append(events, p.event)
+		}
+	}
+	d.pending = make(map[string]*pendingNew)
+	d.order = nil
+	return events
+}
+
+// debouncer は -debounce が指定されていれば debouncer を返す。未指定の場合は nil を返す。
+func (o *options) debouncer() *debouncer {
+	if o.debounce <= 0 {
+		return nil
+	}
+	return newDebouncer(o.debounce)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+func TestDebouncer(t *testing.T) {
+	type step struct {
+		at   time.Duration   // 最初の取得からの経過時間
+		rows []conn.TableRow // この時点の接続テーブル
+		want []string        // 出力するイベントの種別と接続の状態
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"期間内に消えた接続は FLAP", []step{
+			{0, []conn.TableRow{fakeRow(50000, "ESTABLISHED")}, nil},
+			{time.Second, nil, []string{"FLAP ESTABLISHED"}},
+		}},
+		{"期間を過ぎた接続は NEW", []step{
+			{0, []conn.TableRow{fakeRow(50000, "ESTABLISHED")}, nil},
+			{5 * time.Second, []conn.TableRow{fakeRow(50000, "ESTABLISHED")}, []string{"NEW ESTABLISHED"}},
+			{6 * time.Second, nil, []string{"CLOSED ESTABLISHED"}},
+		}},
+		{"保留中の状態の変化は NEW に反映", []step{
+			{0, []conn.TableRow{fakeRow(50000, "SYN_SENT")}, nil},
+			{time.Second, []conn.TableRow{fakeRow(50000, "ESTABLISHED")}, nil},
+			{5 * time.Second, []conn.TableRow{fakeRow(50000, "ESTABLISHED")}, []string{"NEW ESTABLISHED"}},
+		}},
+		{"期間を過ぎた後の CLOSED は NEW と CLOSED", []step{
+			{0, []conn.TableRow{fakeRow(50000, "ESTABLISHED")}, nil},
+			{5 * time.Second, nil, []string{"CLOSED ESTABLISHED", "NEW ESTABLISHED"}},
+		}},
+		{"FLAP の後に同じ接続が再び現れる", []step{
+			{0, []conn.TableRow{fakeRow(50000, "ESTABLISHED"), fakeRow(50001, "ESTABLISHED")}, nil},
+			{time.Second, []conn.TableRow{fakeRow(50001, "ESTABLISHED")}, []string{"FLAP ESTABLISHED"}},
+			{2 * time.Second, []conn.TableRow{fakeRow(50000, "ESTABLISHED"), fakeRow(50001, "ESTABLISHED")}, nil},
+			{5 * time.Second, []conn.TableRow{fakeRow(50000, "ESTABLISHED"), fakeRow(50001, "ESTABLISHED")}, []string{"NEW ESTABLISHED"}},
+			{7 * time.Second, []conn.TableRow{fakeRow(50000, "ESTABLISHED"), fakeRow(50001, "ESTABLISHED")}, []string{"NEW ESTABLISHED"}},
+		}},
+		{"保留していない接続のイベントはそのまま", []step{
+			{0, []conn.TableRow{fakeRow(50000, "ESTABLISHED")}, nil},
+			{5 * time.Second, []conn.TableRow{fakeRow(50000, "ESTABLISHED"), fakeRow(50001, "ESTABLISHED")}, []string{"NEW ESTABLISHED"}},
+			{6 * time.Second, []conn.TableRow{fakeRow(50000, "CLOSE_WAIT")}, []string{"CHANGE CLOSE_WAIT", "FLAP ESTABLISHED"}},
+		}},
+	}
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables, collector := fakeCollector()
+			d := newDebouncer(5 * time.Second)
+			prev := conn.Snapshot{}
+			for i, s := range tt.steps {
+				tables.Set(s.rows...)
+				current, err := collector.Collect()
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, e := range d.apply(start.Add(s.at), conn.Diff(prev, current)) {
+					got = append(got, string(e.Type)+" "+e.Conn.State)
+				}
+				slices.Sort(got)
+				if !slices.Equal(got, s.want) {
+					t.Errorf("%d 回目の apply = %v, want %v", i+1, got, s.want)
+				}
+				prev = current
+			}
+		})
+	}
+}
+
+func TestDebouncerFlush(t *testing.T) {
+	d := newDebouncer(time.Minute)
+	now := time.Now()
+	d.apply(now, []conn.Event{{Type: conn.EventNew, Key: "a"}, {Type: conn.EventNew, Key: "b"}})
+	d.apply(now, []conn.Event{{Type: conn.EventClosed, Key: "a"}})
+	if got := d.flush(); len(got) != 1 || got[0].Key != "b" {
+		t.Errorf("flush = %+v, want b の NEW だけ", got)
+	}
+	if got := d.flush(); len(got) != 0 {
+		t.Errorf("2 回目の flush = %+v, want 空", got)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+)
+
+// --- 出力の系統と診断ログ (-o, -log, -q, -v, -vv) ---
+// 出力は次の系統に分け、下流のプログラムがデータだけを読めるようにする。
+//   - データ (イベント、スナップショット、統計): 標準の log。-o の出力先、未指定なら標準出力へ書く。
+//   - 開始時の表示やサマリー: infoLog。-o が未指定ならデータと同じ標準出力へ、指定時は warnLog と同じ出力先へ書く。-q で抑止する。
+//   - 警告とエラー: warnLog。-log の出力先、未指定なら標準エラーへ書く。
+//   - 内部の詳細 (-v, -vv): verboseLog と debugLog。warnLog と同じ出力先へ書く。
+
+// forwardWriter は書き込むたびに target が返す出力先へ書く。log.SetOutput による切り替えに追従させるために使う。
+type forwardWriter struct {
+	target func() io.Writer
+}
+
+func (w forwardWriter) Write(p []byte) (int, error) { return w.target().Write(p) }
+
+// infoOutput は infoLog の出力先を返す。setupLogging が -o の指定に合わせて切り替える。
+var infoOutput = log.Writer
+
+var (
+	infoLog    = log.New(forwardWriter{func() io.Writer { return infoOutput() }}, "", 0)
+	warnLog    = log.New(os.Stderr, "", 0)
+	verboseLog = log.New(io.Discard, "", log.Ltime|log.Lmicroseconds)
+	debugLog   = log.New(io.Discard, "", log.Ltime|log.Lmicroseconds)
+)
+
+// setLogLevel は -q と -v/-vv の指定に合わせて各系統の出力先を設定する。verbosity は -v で 1、-vv で 2。
+func setLogLevel(quiet bool, verbosity int) {
+	infoLog.SetOutput(forwardWriter{func() io.Writer { return infoOutput() }})
+	if quiet {
+		infoLog.SetOutput(io.Discard)
+	}
+	diag := forwardWriter{warnLog.Writer}
+	verboseLog.SetOutput(io.Discard)
+	debugLog.SetOutput(io.Discard)
+	if verbosity >= 1 {
+		verboseLog.SetOutput(diag)
+	}
+	if verbosity >= 2 {
+		debugLog.SetOutput(diag)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- diff モード (保存した 2 つの接続一覧の比較) ---
+// 例: snapshot -p 0 -count 1 -format json -o before.json で保存した前後の接続一覧を比べる。
+// diff before.json after.json
+func runDiffMode(args []string) error {
+	fs := newFlagSet("diff")
+	opts := setupDiffFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitStatus(exitUsage)
+	}
+	if opts.processNames == "" && opts.pids == "" {
+		opts.pids = "0"
+	}
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+
+	var states [2]conn.Snapshot
+	var times [2]time.Time
+	for i, path := range fs.Args() {
+		frames, err := loadRecording(path)
+		if err != nil {
+			return fmt.Errorf(tr("記録を読み込めませんでした: %w"), err)
+		}
+		if len(frames) == 0 {
+			return fmt.Errorf(tr("接続一覧が記録されていません: %s"), path)
+		}
+		times[i], states[i] = finalState(frames, filter)
+	}
+
+	formatter, err := opts.newFormatter()
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	keyOf := conn.Connection.Key
+	if opts.ignoreLocalPort {
+		keyOf = endpointKey
+	}
+	events := diffSnapshots(states[0], states[1], keyOf)
+	infoLog.Printf(tr("--- 比較: %s (%s, %d 件) -> %s (%s, %d 件) ---"), fs.Arg(0), textTimestamp(times[0]), len(states[0]),
+		fs.Arg(1), textTimestamp(times[1]), len(states[1]))
+	infoLog.Printf(tr("監視対象: %s"), monitorTarget)
+	if len(events) == 0 {
+		formatter.writeUnchanged(times[1], len(states[1]))
+		return nil
+	}
+	formatter.writeEvents(times[1], events)
+	counts := make(map[conn.EventType]int)
+	for _, e := range events {
+		counts[e.Type]++
+	}
+	infoLog.Printf(tr("追加: %d 件, 削除: %d 件, 状態の変化: %d 件"), counts[conn.EventNew], counts[conn.EventClosed], counts[conn.EventChange])
+	return nil
+}
+
+// diffOptions は diff のオプション。
+type diffOptions struct {
+	*options
+	ignoreLocalPort bool
+}
+
+// setupDiffFlags は diff のフラグを定義する。
+func setupDiffFlags(fs *flag.FlagSet) *diffOptions {
+	opts := &diffOptions{options: setupFlags(fs, filterFlags, outputFlags, sinkFlags, redactFlags, enrichFlags)}
+	fs.BoolVar(&opts.ignoreLocalPort, "ignore-local-port", false, tr("PID と送信元のローカルポートを無視し、プロセス名とリモートのアドレス・ポートで比較する (再起動をまたぐ比較用。同じ宛先への複数の接続は 1 件とみなす)"))
+	return opts
+}
+
+// finalState は記録を最後まで適用した接続一覧のうち、filter に一致するものと、その時刻を返す。
+func finalState(frames []replayFrame, filter conn.Filter) (time.Time, conn.Snapshot) {
+	recorded := make(conn.Snapshot)
+	for _, frame := range frames {
+		if frame.snapshot != nil {
+			recorded = maps.Clone(frame.snapshot)
+		}
+		for _, e := range frame.events {
+			switch e.Type {
+			case conn.EventNew, conn.EventChange, conn.EventRebound:
+				recorded[e.Key] = e.Conn
+			case conn.EventClosed:
+				delete(recorded, e.Key)
+			}
+		}
+	}
+	maps.DeleteFunc(recorded, func(_ string, c conn.Connection) bool { return !filter.Match(c) })
+	return frames[len(frames)-1].timestamp, recorded
+}
+
+// endpointKey は -ignore-local-port の比較に使うキー。待ち受けはローカルポートで、それ以外はリモートで区別する。
+func endpointKey(c conn.Connection) string {
+	if c.RemotePort == 0 {
+		return fmt.Sprintf("%s %s %s:%d", c.Protocol, c.ProcessName, c.LocalAddr, c.LocalPort)
+	}
+	return fmt.Sprintf("%s %s -> %s:%d", c.Protocol, c.ProcessName, c.RemoteAddr, c.RemotePort)
+}
+
+// diffSnapshots は keyOf で対応付けた 2 つの接続一覧の差分を、追加 (NEW)・削除 (CLOSED)・状態の変化 (CHANGE) として返す。
+func diffSnapshots(before, after conn.Snapshot, keyOf func(conn.Connection) string) []conn.Event {
+	rekey := func(s conn.Snapshot) map[string]conn.Connection {
+		m := make(map[string]conn.Connection, len(s))
+		for _, key := range sortedKeys(s) {
+			// 同じキーに複数の接続がある場合は、ESTABLISHED のものを代表にする。
+			k := keyOf(s[key])
+			if _, exists := m[k]; !exists || s[key].State == "ESTABLISHED" {
+				m[k] = s[key]
+			}
+		}
+		return m
+	}
+	b, a := rekey(before), rekey(after)
+	var events []conn.Event
+	for k, c := range a {
+		prev, ok := b[k]
+		switch {
+		case !ok:
+			events = append(events, conn.Event{Type: conn.EventNew, Key: c.Key(), Conn: c})
+		case prev.State != c.State:
+			events = append(events, conn.Event{Type: conn.EventChange, Key: c.Key(), Conn: c, PrevState: prev.State})
+		}
+	}
+	for k, c := range b {
+		if _, ok := a[k]; !ok {
+			events = append(events, conn.Event{Type: conn.EventClosed, Key: c.Key(), Conn: c})
+		}
+	}
+	sortEvents(events)
+	return events
+}
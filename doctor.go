@@ -0,0 +1,252 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go-ObuStat/conn"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- doctor モード (動作に必要な条件の確認) ---
+// 権限が足りない場合、接続の取得自体は成功してもプロセス名が N/A になるなど、原因が分かりにくい形で現れる。
+// doctor は前提条件を 1 つずつ確認し、問題があれば対処方法を表示する。
+// 例: doctor -listen :8080
+
+// doctorSessionName は ETW の確認に使うセッション名。trace モードのセッションと重ならないようにする。
+const doctorSessionName = "ObuStat-Doctor"
+
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarn
+	doctorFail
+)
+
+func (s doctorStatus) String() string {
+	switch s {
+	case doctorOK:
+		return "OK"
+	case doctorWarn:
+		return tr("警告")
+	default:
+		return "NG"
+	}
+}
+
+// doctorResult は 1 項目の確認結果。hint は問題がある場合の対処方法。
+type doctorResult struct {
+	name   string
+	status doctorStatus
+	detail string
+	hint   string
+}
+
+func runDoctorMode(args []string) error {
+	fs := newFlagSet("doctor")
+	opts := setupDoctorFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.lang != "" {
+		if err := setLanguage(opts.lang); err != nil {
+			return &exitError{code: exitUsage, err: err}
+		}
+	}
+
+	elevated := isElevated()
+	results := []doctorResult{checkElevation(elevated), checkIPHelper()}
+	snapshot, err := conn.Collect(conn.Filter{
+		Protocols:     []string{"tcp", "udp"},
+		Families:      []uint32{windows.AF_INET, windows.AF_INET6},
+		AllProcesses:  true,
+		IncludeListen: true,
+	})
+	if err != nil {
+		results = append(results, doctorResult{
+			name: tr("接続の取得"), status: doctorFail, detail: connError(err).Error(),
+			hint: tr("iphlpapi.dll の呼び出しに失敗しています。セキュリティ製品による制限が無いか確認してください。"),
+		})
+	} else {
+		results = append(results, checkProcessNames(snapshot, elevated), checkProcessDetails(snapshot, elevated))
+	}
+	results = append(results, checkETW(elevated))
+	if opts.listenAddr != "" {
+		results = append(results, checkListen(opts.listenAddr), checkFirewall())
+	}
+
+	failed := false
+	for _, r := range results {
+		fmt.Printf("[%s] %s: %s\n", r.status, r.name, r.detail)
+		if r.status != doctorOK && r.hint != "" {
+			fmt.Printf("      → %s\n", r.hint)
+		}
+		failed = failed || r.status == doctorFail
+	}
+	if failed {
+		return exitStatus(exitAPIFailure)
+	}
+	return nil
+}
+
+// doctorOptions は doctor のオプション。
+type doctorOptions struct {
+	listenAddr string
+	lang       string
+}
+
+// setupDoctorFlags は doctor のフラグを定義する。
+func setupDoctorFlags(fs *flag.FlagSet) *doctorOptions {
+	opts := &doctorOptions{}
+	fs.StringVar(&opts.listenAddr, "listen", "", tr("exporter/serve/collector で使う待ち受けアドレス (指定時はポートを開けるかとファイアウォールを確認する)"))
+	fs.StringVar(&opts.lang, "lang", "", tr("表示言語 (ja, en)"))
+	return opts
+}
+
+func checkElevation(elevated bool) doctorResult {
+	if elevated {
+		return doctorResult{name: tr("権限"), status: doctorOK, detail: tr("管理者として実行しています")}
+	}
+	return doctorResult{
+		name: tr("権限"), status: doctorWarn, detail: tr("管理者として実行していません"),
+		hint: tr("他のユーザーやサービスのプロセスの詳細、trace モード、-wake etw には管理者権限が必要です。「管理者として実行」したコンソールから起動してください。"),
+	}
+}
+
+func checkIPHelper() doctorResult {
+	if err := conn.CheckAPI(); err != nil {
+		return doctorResult{
+			name: "iphlpapi.dll", status: doctorFail, detail: err.Error(),
+			hint: tr("GetExtendedTcpTable/GetExtendedUdpTable を読み込めません。Windows Vista 以降で実行してください。"),
+		}
+	}
+	return doctorResult{name: "iphlpapi.dll", status: doctorOK, detail: tr("GetExtendedTcpTable/GetExtendedUdpTable を利用できます")}
+}
+
+// checkProcessNames は接続の所有プロセスの名前を引けるかを確認する。System Idle Process (0) と System (4) は対象外。
+func checkProcessNames(snapshot conn.Snapshot, elevated bool) doctorResult {
+	name := tr("プロセス名の解決")
+	pids, unknown := make(map[uint32]bool), make(map[uint32]bool)
+	for _, c := range snapshot {
+		if c.PID == 0 || c.PID == 4 {
+			continue
+		}
+		pids[c.PID] = true
+		if c.ProcessName == "N/A" {
+			unknown[c.PID] = true
+		}
+	}
+	detail := fmt.Sprintf(tr("%d 件の接続、%d 個のプロセスのうち %d 個の名前を解決できませんでした"), len(snapshot), len(pids), len(unknown))
+	if len(unknown) == 0 {
+		return doctorResult{name: name, status: doctorOK, detail: fmt.Sprintf(tr("%d 件の接続、%d 個のプロセスの名前を解決できました"), len(snapshot), len(pids))}
+	}
+	r := doctorResult{name: name, status: doctorWarn, detail: detail,
+		hint: tr("取得の間に終了したプロセスは N/A になります。時間をおいて再度確認してください。")}
+	if !elevated {
+		r.hint = tr("保護されたプロセスは管理者権限が無いと名前を引けず N/A になります。管理者として実行してください。")
+	}
+	return r
+}
+
+// checkProcessDetails は -owner, -cmdline で使う、プロセスの所有者と実行ファイルのパスを取得できるかを確認する。
+func checkProcessDetails(snapshot conn.Snapshot, elevated bool) doctorResult {
+	name := tr("プロセスの所有者・パスの取得")
+	pids := make(map[uint32]bool)
+	for _, c := range snapshot {
+		if c.PID != 0 && c.PID != 4 {
+			pids[c.PID] = true
+		}
+	}
+	denied := 0
+	var lastErr error
+	for pid := range pids {
+		if _, err := conn.ProcessOwner(pid); err != nil {
+			denied++
+			lastErr = err
+			continue
+		}
+		if _, err := conn.QueryProcessDetails(pid); err != nil {
+			denied++
+			lastErr = err
+		}
+	}
+	if denied == 0 {
+		return doctorResult{name: name, status: doctorOK, detail: fmt.Sprintf(tr("%d 個のプロセスすべてで取得できました"), len(pids))}
+	}
+	r := doctorResult{
+		name: name, status: doctorWarn,
+		detail: fmt.Sprintf(tr("%d 個のプロセスのうち %d 個で取得できませんでした (%v)"), len(pids), denied, lastErr),
+		hint:   tr("保護されたプロセス (PPL) は管理者でも取得できません。その場合の -owner, -cmdline は空になります。"),
+	}
+	if !elevated {
+		r.hint = tr("他のユーザーやサービスのプロセスは管理者権限が無いと取得できず、-owner, -cmdline が空になります。管理者として実行してください。")
+	}
+	return r
+}
+
+// checkETW は trace モードと -wake etw で使う Kernel-Network の ETW セッションを開始できるかを確認する。
+func checkETW(elevated bool) doctorResult {
+	name := "ETW (Kernel-Network)"
+	tracer, err := conn.StartTrace(doctorSessionName, conn.Filter{Protocols: []string{"tcp"}, AllProcesses: true})
+	if err != nil {
+		r := doctorResult{name: name, status: doctorWarn, detail: fmt.Sprintf(tr("セッションを開始できません: %v"), err),
+			hint: tr("trace モードと -wake etw は使えません。ETW セッションの上限に達していないか (logman query -ets) 確認してください。")}
+		if !elevated {
+			r.hint = tr("trace モードと -wake etw には管理者権限が必要です。管理者として実行してください。")
+		}
+		return r
+	}
+	tracer.Close()
+	return doctorResult{name: name, status: doctorOK, detail: tr("セッションを開始できます (trace モード、-wake etw を利用できます)")}
+}
+
+// checkListen は addr で待ち受けられるかを確認する。
+func checkListen(addr string) doctorResult {
+	name := fmt.Sprintf(tr("待ち受け %s"), addr)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return doctorResult{name: name, status: doctorFail, detail: err.Error(),
+			hint: fmt.Sprintf(tr("ポートが他のプロセスで使用中か、予約されています。%s listen -p 0 -lport <ポート> で使用中のプロセスを確認してください。"), os.Args[0])}
+	}
+	l.Close()
+	return doctorResult{name: name, status: doctorOK, detail: tr("ポートを開けます")}
+}
+
+// checkFirewall は Windows ファイアウォールが有効な場合に、この実行ファイルを許可する規則があるかを確認する。
+func checkFirewall() doctorResult {
+	name := tr("ファイアウォール")
+	out, err := exec.Command("netsh", "advfirewall", "show", "allprofiles", "state").Output()
+	if err != nil {
+		return doctorResult{name: name, status: doctorWarn, detail: fmt.Sprintf(tr("状態を取得できません: %v"), err)}
+	}
+	// 表示言語によらず、状態の行は "ON" / "OFF" で終わる。
+	enabled := false
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasSuffix(strings.ToUpper(strings.TrimSpace(line)), " ON") {
+			enabled = true
+		}
+	}
+	if !enabled {
+		return doctorResult{name: name, status: doctorOK, detail: tr("無効です")}
+	}
+	exePath, err := os.Executable()
+	if err != nil {
+		return doctorResult{name: name, status: doctorWarn, detail: fmt.Sprintf(tr("実行ファイルのパスを取得できません: %v"), err)}
+	}
+	rules, err := exec.Command("netsh", "advfirewall", "firewall", "show", "rule", "name=all", "dir=in", "verbose").Output()
+	if err == nil && strings.Contains(strings.ToLower(string(rules)), strings.ToLower(exePath)) {
+		return doctorResult{name: name, status: doctorOK, detail: tr("有効で、この実行ファイルを対象とする受信の規則があります")}
+	}
+	return doctorResult{
+		name: name, status: doctorWarn, detail: tr("有効ですが、この実行ファイルを対象とする受信の規則が見つかりません"),
+		hint: fmt.Sprintf(tr("他のホストから接続する場合は規則を追加してください: netsh advfirewall firewall add rule name=%q dir=in action=allow program=%q"),
+			strings.TrimSuffix(filepath.Base(exePath), filepath.Ext(exePath)), exePath),
+	}
+}
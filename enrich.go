@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 出力前の付加情報 (名前解決など) ---
+// connEnricher は出力の直前に接続へ付加情報を設定する。
+type connEnricher interface {
+	enrich(c *conn.Connection)
+}
+
+// enrichingFormatter は付加情報を設定してから元の outputFormatter へ出力を渡す。
+type enrichingFormatter struct {
+	outputFormatter
+	enrichers connEnrichers
+}
+
+func (f *enrichingFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	f.enrichers.events(events)
+	f.outputFormatter.writeEvents(timestamp, events)
+}
+
+func (f *enrichingFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	f.enrichers.snapshot(conns)
+	f.outputFormatter.writeSnapshot(timestamp, conns)
+}
+
+// connEnrichers は複数の connEnricher を順に適用する。
+type connEnrichers []connEnricher
+
+func (es connEnrichers) enrich(c *conn.Connection) {
+	for _, e := range es {
+		e.enrich(c)
+	}
+}
+
+func (es connEnrichers) events(events []conn.Event) {
+	for i := range events {
+		es.enrich(&events[i].Conn)
+	}
+}
+
+func (es connEnrichers) snapshot(conns conn.Snapshot) {
+	for key, c := range conns {
+		es.enrich(&c)
+		conns[key] = c
+	}
+}
+
+// newFormatter は -format の出力形式に、オプションで有効にした付加情報、項目の秘匿と追加の出力先を組み合わせる。
+func (o *options) newFormatter() (outputFormatter, error) {
+	formatter, err := o.newOutputs()
+	if err != nil {
+		return nil, err
+	}
+	// 付加した情報も秘匿の対象にするため、-fields/-redact は付加情報の後に適用する。
+	if formatter, err = o.withRedaction(formatter); err != nil {
+		return nil, err
+	}
+	return o.withEnrichers(o.withLoopbackPairing(formatter))
+}
+
+// newOutputs は最初の -o (未指定なら -format) の出力形式に、オプションで有効にした追加の出力先
+// (イベントログ、-store、Webhook、2 つ目以降の -o) を組み合わせる。
+func (o *options) newOutputs() (outputFormatter, error) {
+	primary, extras := o.outputTargets()
+	var formatter outputFormatter
+	var err error
+	if o.outputDir != "" {
+		formatter, err = o.snapshotFiles(primary.format)
+	} else if o.flightWindow > 0 {
+		formatter, err = o.flightRecorder(primary.format)
+	} else {
+		formatter, err = newOutputFormatter(primary.format, o)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.eventLogSource != "" {
+		if formatter, err = withEventLog(formatter, o.eventLogSource); err != nil {
+			return nil, err
+		}
+	}
+	if o.store != "" {
+		if formatter, err = withStore(formatter, o.store, o.storeSnapshot); err != nil {
+			return nil, err
+		}
+	}
+	if o.webhookURL != "" {
+		if formatter, err = withWebhook(formatter, o.webhookURL, o.notify, o.webhookRate); err != nil {
+			return nil, err
+		}
+	}
+	return o.withExtraOutputs(formatter, extras)
+}
+
+// withEnrichers は、オプションで有効にした付加情報を設定してから formatter へ渡す outputFormatter を返す。
+func (o *options) withEnrichers(formatter outputFormatter) (outputFormatter, error) {
+	enrichers, err := o.enrichers()
+	if err != nil {
+		return nil, err
+	}
+	if len(enrichers) == 0 {
+		return formatter, nil
+	}
+	return &enrichingFormatter{outputFormatter: formatter, enrichers: enrichers}, nil
+}
+
+// enrichers はオプションで有効にした付加情報の一覧を返す。
+func (o *options) enrichers() (connEnrichers, error) {
+	var enrichers connEnrichers
+	if o.resolve {
+		enrichers = append(enrichers, newDNSResolver(o.resolveTTL))
+	}
+	if o.services || o.servicesFile != "" {
+		names, err := newServiceNames(o.servicesFile)
+		if err != nil {
+			return nil, usageErrorf(tr("サービス名ファイルを読み込めません: %w"), err)
+		}
+		enrichers = append(enrichers, names)
+	}
+	if o.interfaces {
+		enrichers = append(enrichers, new(interfaceNames))
+	}
+	if o.commandLine {
+		enrichers = append(enrichers, newProcessDetails(o.procCacheTTL))
+	}
+	if o.owner {
+		enrichers = append(enrichers, newProcessOwners(o.procCacheTTL))
+	}
+	if o.hostedServices || len(serviceTargets(o.processNames)) > 0 {
+		enrichers = append(enrichers, hostedServices{})
+	}
+	if o.pairLoopback {
+		enrichers = append(enrichers, new(loopbackPeers))
+	}
+	if o.jobInfo || o.containers != "" {
+		enrichers = append(enrichers, newJobInfo(o.procCacheTTL))
+	}
+	db, err := o.geoIP()
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		enrichers = append(enrichers, db)
+	}
+	if o.configFile != "" {
+		// プロファイルの条件にはサービス名なども使えるため、他の付加情報を設定した後に判定する。
+		enrichers = append(enrichers, profileTagger{o})
+	}
+	return enrichers, nil
+}
+
+// --- リモートアドレスの逆引き ---
+const (
+	dnsMaxEntries     = 4096
+	dnsMaxConcurrency = 8
+	dnsLookupTimeout  = 5 * time.Second
+	dnsNegativeTTL    = time.Minute
+)
+
+type dnsEntry struct {
+	name    string
+	expires time.Time
+}
+
+// dnsResolver はリモートアドレスを非同期に逆引きする。結果は TTL (上限) の間キャッシュし、
+// 解決が終わるまでは名前を空のまま出力する。
+type dnsResolver struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+	pending map[string]bool
+	sem     chan struct{}
+}
+
+func newDNSResolver(ttl time.Duration) *dnsResolver {
+	r := &dnsResolver{
+		ttl:     ttl,
+		entries: make(map[string]dnsEntry),
+		pending: make(map[string]bool),
+		sem:     make(chan struct{}, dnsMaxConcurrency),
+	}
+	registerTrimmer(r)
+	return r
+}
+
+// trim は解決済みの名前を全て捨てる (-max-rss)。
+func (r *dnsResolver) trim() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = make(map[string]dnsEntry)
+}
+
+func (r *dnsResolver) enrich(c *conn.Connection) {
+	if c.RemoteAddr == "" || c.RemoteHost != "" {
+		return
+	}
+	c.RemoteHost = r.lookup(c.RemoteAddr)
+}
+
+// lookup はキャッシュ済みの名前を返す。キャッシュが無いか期限切れの場合は逆引きを開始して "" を返す。
+func (r *dnsResolver) lookup(addr string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[addr]
+	if ok && time.Now().Before(entry.expires) {
+		return entry.name
+	}
+	if !r.pending[addr] {
+		select {
+		case r.sem <- struct{}{}:
+			r.pending[addr] = true
+			go r.resolve(addr)
+		default:
+			// 同時に問い合わせる数を超えた場合は次回に回す。
+		}
+	}
+	return entry.name
+}
+
+func (r *dnsResolver) resolve(addr string) {
+	defer func() { <-r.sem }()
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+
+	entry := dnsEntry{expires: time.Now().Add(dnsNegativeTTL)}
+	if err == nil && len(names) > 0 {
+		entry = dnsEntry{name: strings.TrimSuffix(names[0], "."), expires: time.Now().Add(r.ttl)}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, addr)
+	if len(r.entries) >= dnsMaxEntries {
+		r.evict()
+	}
+	r.entries[addr] = entry
+}
+
+// evict は期限切れのエントリを削除し、それでも上限を超えている場合は任意のエントリを削除する。
+func (r *dnsResolver) evict() {
+	now := time.Now()
+	for addr, entry := range r.entries {
+		if now.After(entry.expires) {
+			delete(r.entries, addr)
+		}
+	}
+	for addr := range r.entries {
+		if len(r.entries) < dnsMaxEntries {
+			break
+		}
+		delete(r.entries, addr)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- エラーと終了コード ---
+// 各モードは os.Exit せずにエラーを main まで返し、main が -format に合わせて表示してから終了コードで終了する。
+// 終了コードを指定しないエラーは exitAPIFailure で終了する。
+
+// eventError は、json/logfmt 形式でエラーを出力するとき、および監視中のパニックを出力するときのイベントの種別。
+const eventError = "ERROR"
+
+// exitError は終了コードを伴うエラー。err が nil の場合は何も表示せずに終了する (exitNotFound, exitAlert など)。
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf(tr("終了コード %d"), e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// usageErrorf は引数や設定ファイルの指定の誤り (exitUsage) を表すエラーを返す。
+func usageErrorf(format string, args ...any) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+// usageError は書式を持たないメッセージで usageErrorf と同じエラーを返す。
+func usageError(msg string) error {
+	return &exitError{code: exitUsage, err: errors.New(msg)}
+}
+
+// exitStatus はメッセージを表示せずに code で終了させるエラーを返す。
+func exitStatus(code int) error {
+	return &exitError{code: code}
+}
+
+// exitCodeOf は err に対応する終了コードを返す。
+func exitCodeOf(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return exitAPIFailure
+}
+
+// errorFormat はエラーを表示する形式。parseFlags で -format の値を設定する。
+var errorFormat = "text"
+
+// reportError は err を errorFormat に合わせて表示し、終了コードを返す。
+// json と logfmt では出力を読むプログラムが扱えるよう ERROR イベントとして標準出力へ、text と csv では標準エラーへ出力する。
+func reportError(err error) int {
+	code := exitCodeOf(err)
+	var e *exitError
+	if errors.As(err, &e) && e.err == nil {
+		return code
+	}
+	ts := machineTimestamp(time.Now())
+	switch strings.ToLower(errorFormat) {
+	case "json":
+		writeJSONLine(log.Default(), jsonError{Timestamp: ts, Event: eventError, Detail: err.Error(), ExitCode: code})
+	case "logfmt":
+		var l logfmtLine
+		l.add("ts", ts)
+		l.add("event", eventError)
+		l.add("detail", err.Error())
+		l.add("exit_code", strconv.Itoa(code))
+		log.Println(l.String())
+	default:
+		fmt.Fprintf(os.Stderr, tr("エラー: %v\n"), err)
+	}
+	return code
+}
+
+type jsonError struct {
+	Timestamp string `json:"timestamp"`
+	Event     string `json:"event"`
+	Detail    string `json:"detail"`
+	ExitCode  int    `json:"exit_code"`
+}
+
+// --- conn のエラーの表示 ---
+// conn のエラーは英語の既定のメッセージしか持たないため、conn の関数が返したエラーは connError で表示言語に訳す。
+// 他のエラーで包むと元の文脈が失われるため、conn の関数の戻り値に直接使うこと。
+
+// localizedError は訳したメッセージを持ち、元のエラーを Unwrap で返す。
+type localizedError struct {
+	msg string
+	err error
+}
+
+func (e *localizedError) Error() string { return e.msg }
+
+func (e *localizedError) Unwrap() error { return e.err }
+
+// connError は conn の ValueError と APIError を表示言語のメッセージにする。それ以外のエラーはそのまま返す。
+func connError(err error) error {
+	var ve *conn.ValueError
+	var ae *conn.APIError
+	switch {
+	case errors.As(err, &ve):
+		return &localizedError{msg: valueErrorMessage(ve), err: err}
+	case errors.As(err, &ae):
+		msg := fmt.Sprintf(tr("%s に失敗しました: %s"), ae.Op, apiErrorReason(ae.Err))
+		if ae.Attempts > 0 {
+			msg = fmt.Sprintf(tr("%s (%d 回試行)"), msg, ae.Attempts)
+		}
+		return &localizedError{msg: msg, err: err}
+	}
+	return err
+}
+
+func valueErrorMessage(e *conn.ValueError) string {
+	valid := strings.Join(e.Valid, ",")
+	switch {
+	case errors.Is(e.Err, conn.ErrInvalidPort):
+		return fmt.Sprintf(tr("ポート番号が不正です: %q"), e.Value)
+	case errors.Is(e.Err, conn.ErrInvalidPortRange):
+		return fmt.Sprintf(tr("ポート範囲が不正です: %q"), e.Value)
+	case errors.Is(e.Err, conn.ErrUnknownState):
+		return fmt.Sprintf(tr("不明な状態です: %q (指定可能: %s)"), e.Value, valid)
+	case errors.Is(e.Err, conn.ErrUnknownScope):
+		return fmt.Sprintf(tr("不明なアドレスの種類です: %q (指定可能: %s)"), e.Value, valid)
+	case errors.Is(e.Err, conn.ErrNetshOutput):
+		return fmt.Sprintf(tr("netsh の出力から動的ポートの範囲を読み取れません: %q"), e.Value)
+	}
+	return e.Error()
+}
+
+func apiErrorReason(err error) string {
+	var status conn.PDHStatus
+	switch {
+	case errors.Is(err, conn.ErrTableGrowing):
+		return tr("テーブルの拡大が続いたため取得できません")
+	case errors.As(err, &status):
+		return fmt.Sprintf(tr("PDH エラー 0x%08X"), uint32(status))
+	}
+	return err.Error()
+}
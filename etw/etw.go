@@ -0,0 +1,295 @@
+// Package etw は Event Tracing for Windows のリアルタイムセッションを作成し、
+// 指定したプロバイダーのイベントを受け取るための最小限の機能を提供する。
+package etw
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- Win32 API 構造体と定数の定義 ---
+type WNODE_HEADER struct {
+	BufferSize        uint32
+	ProviderId        uint32
+	HistoricalContext uint64
+	TimeStamp         int64
+	Guid              windows.GUID
+	ClientContext     uint32
+	Flags             uint32
+}
+type EVENT_TRACE_PROPERTIES struct {
+	Wnode               WNODE_HEADER
+	BufferSize          uint32
+	MinimumBuffers      uint32
+	MaximumBuffers      uint32
+	MaximumFileSize     uint32
+	LogFileMode         uint32
+	FlushTimer          uint32
+	EnableFlags         uint32
+	AgeLimit            int32
+	NumberOfBuffers     uint32
+	FreeBuffers         uint32
+	EventsLost          uint32
+	BuffersWritten      uint32
+	LogBuffersLost      uint32
+	RealTimeBuffersLost uint32
+	LoggerThreadId      windows.Handle
+	LogFileNameOffset   uint32
+	LoggerNameOffset    uint32
+}
+type EVENT_TRACE_LOGFILE struct {
+	LogFileName         *uint16
+	LoggerName          *uint16
+	CurrentTime         int64
+	BuffersRead         uint32
+	ProcessTraceMode    uint32
+	CurrentEvent        [88]byte  // EVENT_TRACE (未使用)
+	LogfileHeader       [280]byte // TRACE_LOGFILE_HEADER (未使用)
+	BufferCallback      uintptr
+	BufferSize          uint32
+	Filled              uint32
+	EventsLost          uint32
+	EventRecordCallback uintptr
+	IsKernelTrace       uint32
+	Context             uintptr
+}
+type EVENT_DESCRIPTOR struct {
+	Id      uint16
+	Version uint8
+	Channel uint8
+	Level   uint8
+	Opcode  uint8
+	Task    uint16
+	Keyword uint64
+}
+type EVENT_HEADER struct {
+	Size            uint16
+	HeaderType      uint16
+	Flags           uint16
+	EventProperty   uint16
+	ThreadId        uint32
+	ProcessId       uint32
+	TimeStamp       int64
+	ProviderId      windows.GUID
+	EventDescriptor EVENT_DESCRIPTOR
+	ProcessorTime   uint64
+	ActivityId      windows.GUID
+}
+type EVENT_RECORD struct {
+	EventHeader       EVENT_HEADER
+	BufferContext     uint32
+	ExtendedDataCount uint16
+	UserDataLength    uint16
+	ExtendedData      uintptr
+	UserData          unsafe.Pointer
+	UserContext       uintptr
+}
+
+const (
+	WNODE_FLAG_TRACED_GUID             = 0x00020000
+	EVENT_TRACE_REAL_TIME_MODE         = 0x00000100
+	EVENT_TRACE_CONTROL_STOP           = 1
+	EVENT_CONTROL_CODE_ENABLE_PROVIDER = 1
+	PROCESS_TRACE_MODE_REAL_TIME       = 0x00000100
+	PROCESS_TRACE_MODE_EVENT_RECORD    = 0x10000000
+	INVALID_PROCESSTRACE_HANDLE        = ^uint64(0)
+
+	// Wnode.ClientContext に 2 を指定すると、イベントの時刻がシステム時刻 (FILETIME) になる。
+	clientContextSystemTime = 2
+	maxLoggerNameBytes      = 1024
+)
+
+var (
+	advapi32           = windows.NewLazySystemDLL("advapi32.dll")
+	procStartTraceW    = advapi32.NewProc("StartTraceW")
+	procControlTraceW  = advapi32.NewProc("ControlTraceW")
+	procEnableTraceEx2 = advapi32.NewProc("EnableTraceEx2")
+	procOpenTraceW     = advapi32.NewProc("OpenTraceW")
+	procProcessTrace   = advapi32.NewProc("ProcessTrace")
+	procCloseTrace     = advapi32.NewProc("CloseTrace")
+)
+
+// --- 公開 API ---
+// Provider は有効化するプロバイダーと、受け取るイベントのレベル・キーワードを指定する。
+type Provider struct {
+	GUID     windows.GUID
+	Level    uint8  // 5 (Verbose) で全てのレベルを受け取る
+	Keywords uint64 // 0 の場合は全てのキーワードを受け取る
+}
+
+// Event はコールバックに渡される 1 件のイベント。Data はコールバックの間だけ有効なので、
+// 保持する場合はコピーすること。
+type Event struct {
+	ProviderID windows.GUID
+	ID         uint16
+	Version    uint8
+	Opcode     uint8
+	PID        uint32
+	TID        uint32
+	Time       time.Time
+	Data       []byte
+}
+
+// Session は 1 つのリアルタイムトレースセッション。
+type Session struct {
+	name        string
+	handle      uint64
+	traceHandle uint64
+	props       []byte
+	handler     func(*Event)
+	id          uintptr
+	done        chan struct{}
+	err         error
+	closeOnce   sync.Once
+}
+
+var (
+	sessionsMu     sync.Mutex
+	sessions       = make(map[uintptr]*Session)
+	nextSessionID  uintptr
+	recordCallback = windows.NewCallback(eventRecordCallback)
+)
+
+// Start は name のリアルタイムセッションを開始し、providers を有効化して handler へイベントを渡し始める。
+// 同名のセッションが残っている場合は停止してから作り直す。管理者権限が必要。
+func Start(name string, providers []Provider, handler func(*Event)) (*Session, error) {
+	s := &Session{name: name, handler: handler, done: make(chan struct{})}
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	for _, p := range providers {
+		guid := p.GUID
+		ret, _, _ := procEnableTraceEx2.Call(uintptr(s.handle), uintptr(unsafe.Pointer(&guid)),
+			EVENT_CONTROL_CODE_ENABLE_PROVIDER, uintptr(p.Level), uintptr(p.Keywords), 0, 0, 0)
+		if ret != 0 {
+			s.stop()
+			return nil, fmt.Errorf("EnableTraceEx2 %s failed: %w", guid, windows.Errno(ret))
+		}
+	}
+
+	sessionsMu.Lock()
+	nextSessionID++
+	s.id = nextSessionID
+	sessions[s.id] = s
+	sessionsMu.Unlock()
+
+	loggerName, _ := windows.UTF16PtrFromString(name)
+	logfile := EVENT_TRACE_LOGFILE{
+		LoggerName:          loggerName,
+		ProcessTraceMode:    PROCESS_TRACE_MODE_REAL_TIME | PROCESS_TRACE_MODE_EVENT_RECORD,
+		EventRecordCallback: recordCallback,
+		Context:             s.id,
+	}
+	r, _, err := procOpenTraceW.Call(uintptr(unsafe.Pointer(&logfile)))
+	if uint64(r) == INVALID_PROCESSTRACE_HANDLE {
+		s.Close()
+		return nil, fmt.Errorf("OpenTraceW failed: %w", err)
+	}
+	s.traceHandle = uint64(r)
+
+	go func() {
+		defer close(s.done)
+		handle := s.traceHandle
+		ret, _, _ := procProcessTrace.Call(uintptr(unsafe.Pointer(&handle)), 1, 0, 0)
+		if ret != 0 && windows.Errno(ret) != windows.ERROR_CANCELLED {
+			s.err = fmt.Errorf("ProcessTrace failed: %w", windows.Errno(ret))
+		}
+	}()
+	return s, nil
+}
+
+// Done はイベントの受信が終了したときに閉じられるチャネルを返す。
+func (s *Session) Done() <-chan struct{} { return s.done }
+
+// Err はイベントの受信が異常終了した場合のエラーを返す。Done が閉じられた後に呼ぶこと。
+func (s *Session) Err() error { return s.err }
+
+// Close はセッションを停止し、イベントの受信が終わるのを待つ。
+func (s *Session) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.stop()
+		if s.traceHandle != 0 {
+			procCloseTrace.Call(uintptr(s.traceHandle))
+			<-s.done
+		}
+		sessionsMu.Lock()
+		delete(sessions, s.id)
+		sessionsMu.Unlock()
+	})
+	return err
+}
+
+// --- 内部処理 ---
+func newProperties() []byte {
+	size := unsafe.Sizeof(EVENT_TRACE_PROPERTIES{})
+	buf := make([]byte, size+maxLoggerNameBytes)
+	props := (*EVENT_TRACE_PROPERTIES)(unsafe.Pointer(&buf[0]))
+	props.Wnode.BufferSize = uint32(len(buf))
+	props.Wnode.Flags = WNODE_FLAG_TRACED_GUID
+	props.Wnode.ClientContext = clientContextSystemTime
+	props.LogFileMode = EVENT_TRACE_REAL_TIME_MODE
+	props.LoggerNameOffset = uint32(size)
+	return buf
+}
+
+func (s *Session) start() error {
+	name, err := windows.UTF16PtrFromString(s.name)
+	if err != nil {
+		return err
+	}
+	for retried := false; ; retried = true {
+		s.props = newProperties()
+		ret, _, _ := procStartTraceW.Call(uintptr(unsafe.Pointer(&s.handle)), uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&s.props[0])))
+		if ret == 0 {
+			return nil
+		}
+		if windows.Errno(ret) != windows.ERROR_ALREADY_EXISTS || retried {
+			return fmt.Errorf("StartTraceW failed: %w", windows.Errno(ret))
+		}
+		// 前回異常終了したセッションが残っているため停止してからやり直す。
+		stale := newProperties()
+		procControlTraceW.Call(0, uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&stale[0])), EVENT_TRACE_CONTROL_STOP)
+	}
+}
+
+func (s *Session) stop() error {
+	if s.handle == 0 {
+		return nil
+	}
+	props := newProperties()
+	ret, _, _ := procControlTraceW.Call(uintptr(s.handle), 0, uintptr(unsafe.Pointer(&props[0])), EVENT_TRACE_CONTROL_STOP)
+	s.handle = 0
+	if ret != 0 {
+		return fmt.Errorf("ControlTraceW failed: %w", windows.Errno(ret))
+	}
+	return nil
+}
+
+func eventRecordCallback(record *EVENT_RECORD) uintptr {
+	sessionsMu.Lock()
+	s := sessions[record.UserContext]
+	sessionsMu.Unlock()
+	if s == nil {
+		return 0
+	}
+	h := &record.EventHeader
+	e := Event{
+		ProviderID: h.ProviderId,
+		ID:         h.EventDescriptor.Id,
+		Version:    h.EventDescriptor.Version,
+		Opcode:     h.EventDescriptor.Opcode,
+		PID:        h.ProcessId,
+		TID:        h.ThreadId,
+		Time:       time.Unix(0, (*windows.Filetime)(unsafe.Pointer(&h.TimeStamp)).Nanoseconds()),
+	}
+	if record.UserDataLength > 0 {
+		e.Data = unsafe.Slice((*byte)(record.UserData), record.UserDataLength)
+	}
+	s.handler(&e)
+	return 0
+}
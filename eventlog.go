@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// --- Windows イベントログへの出力 ---
+// イベント種別ごとに ID を分け、SCOM/WEF などで種別ごとに収集できるようにする。
+// メッセージファイルに EventCreate.exe を使うため、ID は 1〜1000 の範囲にする。
+const (
+	eventIDNew     = 101
+	eventIDChange  = 102
+	eventIDClosed  = 103
+	eventIDRebound = 104
+)
+
+var eventLogIDs = map[conn.EventType]uint32{
+	conn.EventNew:     eventIDNew,
+	conn.EventChange:  eventIDChange,
+	conn.EventClosed:  eventIDClosed,
+	conn.EventRebound: eventIDRebound,
+}
+
+// eventLogFormatter は元の出力に加えて、状態変化をアプリケーションのイベントログにも書き込む。
+// スナップショットと統計はイベントログには書き込まない。
+type eventLogFormatter struct {
+	outputFormatter
+	log *eventlog.Log
+}
+
+// withEventLog は source をソース名としてイベントログに書き込む outputFormatter を返す。
+// ソースが未登録でも書き込めるが、メッセージの説明文を表示させるには service install -eventlog で登録する。
+func withEventLog(formatter outputFormatter, source string) (outputFormatter, error) {
+	el, err := eventlog.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf(tr("イベントログを開けませんでした: %w"), err)
+	}
+	return &eventLogFormatter{outputFormatter: formatter, log: el}, nil
+}
+
+func (f *eventLogFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	f.outputFormatter.writeEvents(timestamp, events)
+	for _, e := range events {
+		id, ok := eventLogIDs[e.Type]
+		if !ok {
+			continue
+		}
+		if err := f.log.Info(id, textEventLine(timestamp, e)); err != nil {
+			warnLog.Printf(tr("エラー: イベントログへの書き込みに失敗: %v"), err)
+			return
+		}
+	}
+}
+
+// installEventSource はイベントログのソースを登録する (要管理者権限)。既に登録済みの場合は何もしない。
+func installEventSource(source string) error {
+	err := eventlog.InstallAsEventCreate(source, eventlog.Info|eventlog.Warning|eventlog.Error)
+	if err != nil && !strings.HasSuffix(err.Error(), "already exists") {
+		return err
+	}
+	return nil
+}
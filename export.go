@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-ObuStat/conn"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// --- export モード (分析基盤への一括出力) ---
+// -store のデータベース、または指定した時間だけ監視した状態変化を NDJSON か Parquet のファイルに書き出す。
+// 例: export -to-format parquet -out conn.parquet -from "2024-05-01 00:00" events.db
+//
+//	export -to-format ndjson -out conn.ndjson -n java.exe -duration 1h
+func runExportMode(args []string) error {
+	fs := newFlagSet("export")
+	opts := setupExportFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+	if opts.outPath == "" || fs.NArg() > 1 {
+		fs.Usage()
+		return exitStatus(exitUsage)
+	}
+
+	if f := strings.ToLower(opts.outFormat); f != "ndjson" && f != "json" && f != "parquet" {
+		return usageErrorf(tr("-to-format には ndjson または parquet を指定してください: %q"), opts.outFormat)
+	}
+	var q storeQuery
+	if fs.NArg() == 1 {
+		var err error
+		if q, err = opts.storeQuery(opts.from, opts.to); err != nil {
+			return err
+		}
+	}
+	exp, err := newEventExporter(opts.outFormat, opts.outPath)
+	if err != nil {
+		return fmt.Errorf(tr("書き出し先を開けませんでした: %w"), err)
+	}
+	if fs.NArg() == 1 {
+		err = exportStore(fs.Arg(0), q, exp)
+	} else {
+		err = exportLive(opts.options, exp)
+	}
+	if closeErr := exp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf(tr("書き出しに失敗しました: %w"), err)
+	}
+	fmt.Fprintf(os.Stderr, tr("%s に %d 件書き出しました。\n"), opts.outPath, exp.count())
+	return nil
+}
+
+// exportOptions は export のオプション。
+type exportOptions struct {
+	*options
+	outPath   string
+	outFormat string
+	from, to  string
+}
+
+// setupExportFlags は export のフラグを定義する。
+func setupExportFlags(fs *flag.FlagSet) *exportOptions {
+	opts := &exportOptions{options: setupFlags(fs, filterFlags, pollFlags, periodFlags, enrichFlags, eventFlags, monitorFlags)}
+	fs.StringVar(&opts.outPath, "out", "", tr("書き出すファイル (必須)"))
+	fs.StringVar(&opts.outFormat, "to-format", "ndjson", tr("書き出す形式 (ndjson, parquet)"))
+	fs.StringVar(&opts.from, "from", "", tr("データベースから書き出す場合に、この時刻以降のイベントに限る (例: \"2006-01-02 14:00\")"))
+	fs.StringVar(&opts.to, "to", "", tr("データベースから書き出す場合に、この時刻より前のイベントに限る (書式は -from と同じ)"))
+	return opts
+}
+
+// exportStore はデータベースに保存したイベントのうち、-p/-n/-raddr と期間 (q) に一致するものを書き出す。
+func exportStore(path string, q storeQuery, exp eventExporter) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := openStoreDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return q.writeEvents(db, exp)
+}
+
+// exportLive は -duration/-until の間だけ監視し、状態変化を書き出す。
+func exportLive(opts *options, exp eventExporter) error {
+	if opts.duration == 0 && opts.until == "" {
+		return errors.New(tr("データベースを指定しない場合は -duration か -until で監視する時間を指定してください"))
+	}
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	formatter, err := opts.withEnrichers(exp)
+	if err != nil {
+		return err
+	}
+	ctx, stop := opts.runContext()
+	defer stop()
+	log.SetFlags(0)
+	if err := runMonitor(ctx, opts, filter, monitorTarget, formatter, new(atomic.Bool)); err != nil && exitCodeOf(err) != exitAlert {
+		return err
+	}
+	return exp.err()
+}
+
+// eventExporter は状態変化をファイルに書き出す outputFormatter。接続一覧と統計は書き出さない。
+type eventExporter interface {
+	outputFormatter
+	count() int
+	err() error
+	Close() error
+}
+
+func newEventExporter(format, path string) (eventExporter, error) {
+	switch strings.ToLower(format) {
+	case "ndjson", "json":
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		return &ndjsonExporter{file: file, w: bufio.NewWriter(file)}, nil
+	case "parquet":
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		return &parquetExporter{file: file, w: parquet.NewGenericWriter[exportRow](file, parquet.Compression(&parquet.Zstd))}, nil
+	default:
+		return nil, fmt.Errorf(tr("不明な形式です: %q (指定可能: ndjson, parquet)"), format)
+	}
+}
+
+// exportRow は書き出す 1 行。時刻は UTC の Unix 時刻 (ミリ秒) にそろえる。
+// parquet-go は uint16 を扱えないため、ポート番号は int32 で持つ。
+type exportRow struct {
+	Timestamp    int64  `parquet:"timestamp,timestamp(millisecond:utc)" json:"timestamp"`
+	Event        string `parquet:"event,dict" json:"event"`
+	Protocol     string `parquet:"protocol,dict" json:"protocol"`
+	Process      string `parquet:"process,dict" json:"process"`
+	PID          uint32 `parquet:"pid" json:"pid"`
+	ProcessStart int64  `parquet:"process_start,optional,timestamp(millisecond:utc)" json:"process_start,omitempty"`
+	LocalAddr    string `parquet:"local_addr" json:"local_addr"`
+	LocalPort    int32  `parquet:"local_port" json:"local_port"`
+	RemoteAddr   string `parquet:"remote_addr" json:"remote_addr"`
+	RemotePort   int32  `parquet:"remote_port" json:"remote_port"`
+	RemoteHost   string `parquet:"remote_host,optional" json:"remote_host,omitempty"`
+	State        string `parquet:"state,dict" json:"state"`
+	PrevState    string `parquet:"prev_state,optional,dict" json:"prev_state,omitempty"`
+	FirstSeen    int64  `parquet:"first_seen,optional,timestamp(millisecond:utc)" json:"first_seen,omitempty"`
+	LifetimeMs   int64  `parquet:"lifetime_ms,optional" json:"lifetime_ms,omitempty"`
+	Count        int64  `parquet:"count,optional" json:"count,omitempty"`
+	Detail       string `parquet:"detail,optional" json:"detail,omitempty"`
+}
+
+func toExportRow(timestamp time.Time, e conn.Event) exportRow {
+	c := e.Conn
+	row := exportRow{
+		Timestamp: timestamp.UnixMilli(), Event: string(e.Type),
+		Protocol: c.Protocol, Process: c.ProcessName, PID: c.PID,
+		LocalAddr: c.LocalAddr, LocalPort: int32(c.LocalPort), RemoteAddr: c.RemoteAddr, RemotePort: int32(c.RemotePort),
+		RemoteHost: c.RemoteHost, State: c.State, PrevState: e.PrevState,
+		Count: int64(e.Count), Detail: e.Detail,
+	}
+	if !c.ProcessStart.IsZero() {
+		row.ProcessStart = c.ProcessStart.UnixMilli()
+	}
+	if !c.FirstSeen.IsZero() {
+		row.FirstSeen = c.FirstSeen.UnixMilli()
+		row.LifetimeMs = lifetimeMillis(e, timestamp)
+	}
+	return row
+}
+
+// ndjsonExporter は BigQuery などに読み込めるよう、1 行に 1 件の JSON で書き出す。
+type ndjsonExporter struct {
+	file     *os.File
+	w        *bufio.Writer
+	rows     int
+	writeErr error
+}
+
+func (x *ndjsonExporter) writeEvents(timestamp time.Time, events []conn.Event) {
+	if x.writeErr != nil {
+		return
+	}
+	enc := json.NewEncoder(x.w)
+	for _, e := range events {
+		if err := enc.Encode(toExportRow(timestamp, e)); err != nil {
+			x.writeErr = err
+			return
+		}
+		x.rows++
+	}
+}
+
+func (x *ndjsonExporter) writeSnapshot(time.Time, conn.Snapshot)              {}
+func (x *ndjsonExporter) writeUnchanged(time.Time, int)                       {}
+func (x *ndjsonExporter) writeStats(time.Time, []processStats, *conn.TCPPerf) {}
+func (x *ndjsonExporter) count() int                                          { return x.rows }
+func (x *ndjsonExporter) err() error                                          { return x.writeErr }
+
+func (x *ndjsonExporter) Close() error {
+	return closeExport(x.file, x.w.Flush())
+}
+
+// parquetExporter は Athena などで列単位に読めるよう、Parquet (zstd 圧縮) で書き出す。
+type parquetExporter struct {
+	file     *os.File
+	w        *parquet.GenericWriter[exportRow]
+	rows     int
+	writeErr error
+}
+
+func (x *parquetExporter) writeEvents(timestamp time.Time, events []conn.Event) {
+	if x.writeErr != nil {
+		return
+	}
+	rows := make([]exportRow, 0, len(events))
+	for _, e := range events {
+		rows = append(rows, toExportRow(timestamp, e))
+	}
+	n, err := x.w.Write(rows)
+	x.rows += n
+	x.writeErr = err
+}
+
+func (x *parquetExporter) writeSnapshot(time.Time, conn.Snapshot)              {}
+func (x *parquetExporter) writeUnchanged(time.Time, int)                       {}
+func (x *parquetExporter) writeStats(time.Time, []processStats, *conn.TCPPerf) {}
+func (x *parquetExporter) count() int                                          { return x.rows }
+func (x *parquetExporter) err() error                                          { return x.writeErr }
+
+func (x *parquetExporter) Close() error {
+	return closeExport(x.file, x.w.Close())
+}
+
+func closeExport(file io.Closer, flushErr error) error {
+	if err := file.Close(); flushErr == nil {
+		flushErr = err
+	}
+	return flushErr
+}
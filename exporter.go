@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- exporter モード (Prometheus 形式の /metrics) ---
+func runExporterMode(args []string) error {
+	fs := newFlagSet("exporter")
+	opts := setupExporterFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+	if err := opts.security.loadFlags(false); err != nil {
+		return err
+	}
+
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	if err := opts.checkPrivileges(); err != nil {
+		return err
+	}
+
+	ctx, stop := opts.runContext()
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	metrics := newMetricsRegistry()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	server := &http.Server{Addr: opts.listenAddr, Handler: mux}
+
+	infoLog.Print(tr("--- エクスポーターモード開始 ---"))
+	infoLog.Printf(tr("監視対象: %s"), monitorTarget)
+	infoLog.Printf(tr("待ち受け: %s://%s/metrics (実行間隔: %d ミリ秒, Ctrl+Cで停止)"), opts.security.scheme(), opts.listenAddr, opts.intervalMilliseconds)
+
+	listener, err := opts.security.listen(opts.listenAddr)
+	if err != nil {
+		return fmt.Errorf(tr("HTTP サーバーを開始できませんでした: %w"), err)
+	}
+	serveErr := opts.security.startHTTP(server, listener, cancel)
+
+	pollMetrics(ctx, filter, time.Duration(opts.intervalMilliseconds)*time.Millisecond, metrics)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	server.Shutdown(shutdownCtx)
+	return <-serveErr
+}
+
+// exporterOptions は exporter のオプション。
+type exporterOptions struct {
+	*options
+	listenAddr string
+	security   *listenSecurity
+}
+
+// setupExporterFlags は exporter のフラグを定義する。
+func setupExporterFlags(fs *flag.FlagSet) *exporterOptions {
+	opts := &exporterOptions{options: setupFlags(fs, filterFlags, pollFlags, periodFlags, outputFlags)}
+	fs.StringVar(&opts.listenAddr, "listen", ":9477", tr("HTTP の待ち受けアドレス"))
+	opts.security = setupListenSecurity(fs)
+	return opts
+}
+
+func pollMetrics(ctx context.Context, filter conn.Filter, interval time.Duration, metrics *metricsRegistry) {
+	collector := conn.NewCollector(filter)
+	prevConns := make(conn.Snapshot)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		currentConns, err := collector.Collect()
+		if err != nil {
+			warnLog.Printf(tr("エラー: 接続情報の取得に失敗: %v"), connError(err))
+			metrics.recordError()
+			continue
+		}
+		metrics.update(currentConns, conn.Diff(prevConns, currentConns))
+		prevConns = currentConns
+		self.enforce(self.sample(time.Now()))
+	}
+}
+
+type metricLabels struct {
+	process string
+	value   string // state または event type
+}
+
+type metricsRegistry struct {
+	mu          sync.Mutex
+	connections map[metricLabels]int
+	events      map[metricLabels]uint64
+	pollErrors  uint64
+	lastPoll    time.Time
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		connections: make(map[metricLabels]int),
+		events:      make(map[metricLabels]uint64),
+	}
+}
+
+func (m *metricsRegistry) update(current conn.Snapshot, events []conn.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections = make(map[metricLabels]int)
+	for _, c := range current {
+		m.connections[metricLabels{c.ProcessName, c.State}]++
+	}
+	for _, e := range events {
+		m.events[metricLabels{e.Conn.ProcessName, string(e.Type)}]++
+	}
+	m.lastPoll = time.Now()
+}
+
+func (m *metricsRegistry) recordError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pollErrors++
+}
+
+func (m *metricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("# HELP obustat_connections Current number of connections per process and state.\n")
+	b.WriteString("# TYPE obustat_connections gauge\n")
+	for _, l := range sortedMetricLabels(m.connections) {
+		fmt.Fprintf(&b, "obustat_connections{process=\"%s\",state=\"%s\"} %d\n", escapeLabel(l.process), escapeLabel(l.value), m.connections[l])
+	}
+	b.WriteString("# HELP obustat_events_total Number of connection events per process and type.\n")
+	b.WriteString("# TYPE obustat_events_total counter\n")
+	for _, l := range sortedMetricLabels(m.events) {
+		fmt.Fprintf(&b, "obustat_events_total{process=\"%s\",type=\"%s\"} %d\n", escapeLabel(l.process), escapeLabel(l.value), m.events[l])
+	}
+	b.WriteString("# HELP obustat_poll_errors_total Number of failed connection table reads.\n")
+	b.WriteString("# TYPE obustat_poll_errors_total counter\n")
+	fmt.Fprintf(&b, "obustat_poll_errors_total %d\n", m.pollErrors)
+	if !m.lastPoll.IsZero() {
+		b.WriteString("# HELP obustat_last_poll_timestamp_seconds Unix time of the last successful poll.\n")
+		b.WriteString("# TYPE obustat_last_poll_timestamp_seconds gauge\n")
+		fmt.Fprintf(&b, "obustat_last_poll_timestamp_seconds %d\n", m.lastPoll.Unix())
+	}
+
+	usage := self.current()
+	b.WriteString("# HELP obustat_self_cpu_seconds_total CPU time used by the monitor itself.\n")
+	b.WriteString("# TYPE obustat_self_cpu_seconds_total counter\n")
+	fmt.Fprintf(&b, "obustat_self_cpu_seconds_total %g\n", usage.CPUTime.Seconds())
+	b.WriteString("# HELP obustat_self_resident_memory_bytes Working set of the monitor itself.\n")
+	b.WriteString("# TYPE obustat_self_resident_memory_bytes gauge\n")
+	fmt.Fprintf(&b, "obustat_self_resident_memory_bytes %d\n", usage.RSS)
+	b.WriteString("# HELP obustat_self_heap_bytes Go heap in use by the monitor itself.\n")
+	b.WriteString("# TYPE obustat_self_heap_bytes gauge\n")
+	fmt.Fprintf(&b, "obustat_self_heap_bytes %d\n", usage.HeapAlloc)
+	info := currentBuildInfo()
+	b.WriteString("# HELP obustat_build_info Build information of the monitor (always 1).\n")
+	b.WriteString("# TYPE obustat_build_info gauge\n")
+	fmt.Fprintf(&b, "obustat_build_info{version=\"%s\",commit=\"%s\",goversion=\"%s\"} 1\n",
+		escapeLabel(info.Version), escapeLabel(info.Commit), escapeLabel(info.GoVersion))
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func sortedMetricLabels[V any](m map[metricLabels]V) []metricLabels {
+	labels := make([]metricLabels, 0, len(m))
+	for l := range m {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].process != labels[j].process {
+			return labels[i].process < labels[j].process
+		}
+		return labels[i].value < labels[j].value
+	})
+	return labels
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
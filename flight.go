@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"go-ObuStat/conn"
+)
+
+// --- フライトレコーダー (-flight-recorder) ---
+// 障害の調査のために監視を続けたいが、ディスクには書き続けたくない場合に使う。
+// イベントを直近の -flight-recorder の期間だけメモリに保持し、次の契機で -flight-dir のファイルへ書き出す。
+//   - flight-dump サブコマンド (名前付きイベント flightDumpEventName を通知する)
+//   - -flight-listen で待ち受けた HTTP の POST /dump (ループバックからの要求のみ)
+//   - -flight-dump-on-alert を指定した場合の ALERT イベント
+// 書き出した後も記録は続け、保持している期間のイベントは次の書き出しにも含める。
+
+// flightDumpEventName は書き出しを知らせる名前付きイベントの名前。
+// サービスとして動かした場合も通知できるよう Global\ に作り、作れない場合 (権限が無いなど) は Local\ に作る。
+const flightDumpEventName = "ObuStat-FlightDump"
+
+// flightEntry は 1 回の出力で受け取ったイベント。
+type flightEntry struct {
+	timestamp time.Time
+	events    []conn.Event
+}
+
+// flightRecorder はイベントをメモリに保持し、契機があればファイルへ書き出す outputFormatter。
+// writeEvents は監視の goroutine から、dump は契機を待つ goroutine から呼ばれるため、mu で守る。
+type flightRecorder struct {
+	mu      sync.Mutex
+	window  time.Duration
+	onAlert bool
+	entries []flightEntry
+	files   *snapshotFiles
+
+	event  windows.Handle // flightDumpEventName
+	stop   windows.Handle // Close で通知し、event を待つ goroutine を終了させる
+	done   chan struct{}  // event を待つ goroutine が終了したら閉じる
+	server *http.Server
+}
+
+// flightRecorder は -flight-recorder が指定されていれば、format の形式で書き出す flightRecorder を返す。未指定の場合は nil を返す。
+func (o *options) flightRecorder(format string) (*flightRecorder, error) {
+	if o.flightWindow <= 0 {
+		return nil, nil
+	}
+	if primary, _ := o.outputTargets(); primary.path != "" {
+		return nil, usageError(tr("-flight-recorder と -o (Webhook 以外) は同時に指定できません。"))
+	}
+	if o.outputDir != "" {
+		return nil, usageError(tr("-flight-recorder と -o-dir は同時に指定できません。"))
+	}
+	files, err := o.timestampedFiles(o.flightDir, "flight-20060102-150405", format)
+	if err != nil {
+		return nil, err
+	}
+	r := &flightRecorder{window: o.flightWindow, onAlert: o.flightOnAlert, files: files}
+	if err := r.watchEvent(); err != nil {
+		return nil, err
+	}
+	if o.flightListen != "" {
+		if err := r.listen(o.flightListen); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	// 閉じるのは追加の出力先と同じ時点 (setupLogging が返す関数)。
+	o.extraOutputs = append(o.extraOutputs, r)
+	infoLog.Printf(tr("フライトレコーダー: 直近 %s のイベントをメモリに保持し、flight-dump で %s へ書き出します"), r.window, filepath.Join(files.dir, files.name))
+	return r, nil
+}
+
+func (r *flightRecorder) writeEvents(timestamp time.Time, events []conn.Event) {
+	r.mu.Lock()
+	r.entries = append(r.entries, flightEntry{timestamp: timestamp, events: events})
+	r.prune(timestamp)
+	r.mu.Unlock()
+	if r.onAlert && slices.ContainsFunc(events, func(e conn.Event) bool { return e.Type == eventAlert }) {
+		r.dump("ALERT")
+	}
+}
+
+// prune は now から window より前に受け取ったイベントを捨てる。r.mu を保持して呼び出す。
+func (r *flightRecorder) prune(now time.Time) {
+	cutoff := now.Add(-r.window)
+	i := 0
+	for i < len(r.entries) && r.entries[i].timestamp.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		r.entries = slices.Delete(r.entries, 0, i)
+	}
+}
+
+// dump は保持しているイベントをファイルへ書き出し、書いたファイル名を返す。reason は書き出した契機 (ログに表示する)。
+func (r *flightRecorder) dump(reason string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.prune(now)
+	count := 0
+	path, err := r.files.create(now, func(out outputFormatter) {
+		for _, e := range r.entries {
+			out.writeEvents(e.timestamp, e.events)
+			count += len(e.events)
+		}
+	})
+	if err != nil {
+		warnLog.Printf(tr("エラー: フライトレコーダーの記録を書き出せませんでした: %v"), err)
+		return "", err
+	}
+	infoLog.Printf(tr("フライトレコーダーの記録を書き出しました: %s (%d 件, 契機: %s)"), path, count, reason)
+	return path, nil
+}
+
+// watchEvent は flightDumpEventName を作り、通知されるたびに書き出す goroutine を開始する。
+func (r *flightRecorder) watchEvent() error {
+	var err error
+	if r.stop, err = windows.CreateEvent(nil, 0, 0, nil); err != nil {
+		return err
+	}
+	for _, prefix := range []string{`Global\`, `Local\`} {
+		r.event, err = windows.CreateEvent(nil, 0, 0, windows.StringToUTF16Ptr(prefix+flightDumpEventName))
+		if errors.Is(err, windows.ERROR_ALREADY_EXISTS) {
+			// 自動リセットのイベントのため、通知で書き出すのはいずれか 1 つのインスタンスだけになる。
+			warnLog.Print(tr("警告: 別のフライトレコーダーが動作しています。flight-dump はいずれか一方だけを書き出します"))
+			err = nil
+		}
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		windows.CloseHandle(r.stop)
+		return fmt.Errorf(tr("フライトレコーダーの通知を待ち受けられませんでした: %w"), err)
+	}
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		for {
+			which, err := windows.WaitForMultipleObjects([]windows.Handle{r.event, r.stop}, false, windows.INFINITE)
+			if err != nil || which != windows.WAIT_OBJECT_0 {
+				return
+			}
+			r.dump("flight-dump")
+		}
+	}()
+	return nil
+}
+
+// listen は addr で POST /dump を受け付ける HTTP サーバーを開始する。
+func (r *flightRecorder) listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf(tr("HTTP サーバーを開始できませんでした: %w"), err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dump", r.serveDump)
+	r.server = &http.Server{Handler: mux}
+	go r.server.Serve(listener)
+	infoLog.Printf(tr("フライトレコーダー: http://%s/dump への POST で書き出します"), listener.Addr())
+	return nil
+}
+
+// serveDump は書き出したファイル名を返す。認証は行わないため、ループバックからの要求だけを受け付ける。
+func (r *flightRecorder) serveDump(w http.ResponseWriter, req *http.Request) {
+	host, _, _ := net.SplitHostPort(req.RemoteAddr)
+	if !isLoopbackAddr(host) {
+		http.Error(w, tr("ループバック以外からの要求は受け付けません"), http.StatusForbidden)
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	path, err := r.dump("HTTP")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, path)
+}
+
+// Close は契機の待ち受けを終了する。保持しているイベントは書き出さない。
+// 待ち受けている goroutine が終了してから、イベントのハンドルを閉じる。
+func (r *flightRecorder) Close() error {
+	if r.server != nil {
+		r.server.Close()
+	}
+	windows.SetEvent(r.stop)
+	<-r.done
+	windows.CloseHandle(r.event)
+	windows.CloseHandle(r.stop)
+	return nil
+}
+
+func (r *flightRecorder) writeSnapshot(time.Time, conn.Snapshot)              {}
+func (r *flightRecorder) writeUnchanged(time.Time, int)                       {}
+func (r *flightRecorder) writeStats(time.Time, []processStats, *conn.TCPPerf) {}
+
+// --- flight-dump サブコマンド ---
+// 動作中の monitor のフライトレコーダーに書き出しを通知する。
+func runFlightDumpCommand(args []string) error {
+	fs := newFlagSet("flight-dump")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var err error
+	for _, prefix := range []string{`Global\`, `Local\`} {
+		var h windows.Handle
+		h, err = windows.OpenEvent(windows.EVENT_MODIFY_STATE, false, windows.StringToUTF16Ptr(prefix+flightDumpEventName))
+		if err != nil {
+			continue
+		}
+		err = windows.SetEvent(h)
+		windows.CloseHandle(h)
+		if err == nil {
+			fmt.Println(tr("フライトレコーダーに書き出しを通知しました。"))
+			return nil
+		}
+	}
+	if errors.Is(err, windows.ERROR_FILE_NOT_FOUND) {
+		return &exitError{code: exitNotFound, err: errors.New(tr("-flight-recorder を指定した monitor が動作していません"))}
+	}
+	return fmt.Errorf(tr("フライトレコーダーに通知できませんでした: %w"), err)
+}
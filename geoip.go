@@ -0,0 +1,170 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// --- GeoIP (リモートアドレスの国と AS 番号) ---
+// geoIPDB は MaxMind の GeoLite2/GeoIP2 データベース (mmdb)。国 (Country または City) と ASN のデータベースを
+// 組み合わせて指定でき、種別はファイルのメタデータで判定する。
+type geoIPDB struct {
+	country *maxminddb.Reader
+	asn     *maxminddb.Reader
+}
+
+type geoCountryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+type geoASNRecord struct {
+	Number       uint32 `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// openGeoIP はカンマ区切りで指定された mmdb ファイルを開く。
+func openGeoIP(paths string) (*geoIPDB, error) {
+	db := &geoIPDB{}
+	for _, path := range splitList(paths) {
+		r, err := maxminddb.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		switch t := r.Metadata.DatabaseType; {
+		case strings.HasSuffix(t, "-ASN"):
+			db.asn = r
+		case strings.HasSuffix(t, "-Country"), strings.HasSuffix(t, "-City"):
+			db.country = r
+		default:
+			r.Close()
+			return nil, fmt.Errorf(tr("%s: 対応していないデータベースの種類です: %s (Country, City, ASN のいずれか)"), path, t)
+		}
+		verboseLog.Printf(tr("GeoIP データベースを開きました: %s (%s, %s)"), path, r.Metadata.DatabaseType,
+			time.Unix(int64(r.Metadata.BuildEpoch), 0).Format("2006-01-02"))
+	}
+	return db, nil
+}
+
+// enrich はパブリックなリモートアドレスに国コードと AS を設定する。
+func (db *geoIPDB) enrich(c *conn.Connection) {
+	if c.Country != "" || c.ASN != 0 {
+		return
+	}
+	if conn.AddrScope(c.RemoteAddr) != conn.ScopePublic {
+		return
+	}
+	netIP := net.ParseIP(c.RemoteAddr)
+	if db.country != nil {
+		var rec geoCountryRecord
+		if db.country.Lookup(netIP, &rec) == nil {
+			c.Country = cmp.Or(rec.Country.ISOCode, rec.RegisteredCountry.ISOCode)
+		}
+	}
+	if db.asn != nil {
+		var rec geoASNRecord
+		if db.asn.Lookup(netIP, &rec) == nil {
+			c.ASN, c.ASOrg = rec.Number, rec.Organization
+		}
+	}
+}
+
+// geoIP は -geoip のデータベースを返す。enrichers と geoAlerter で同じものを使うため、最初の呼び出しで開いて覚えておく。
+// 未指定の場合は nil を返す。
+func (o *options) geoIP() (*geoIPDB, error) {
+	if o.geoIPFiles == "" {
+		return nil, nil
+	}
+	if o.geo == nil {
+		db, err := openGeoIP(o.geoIPFiles)
+		if err != nil {
+			return nil, usageErrorf(tr("-geoip のデータベースを開けませんでした: %w"), err)
+		}
+		o.geo = db
+	}
+	return o.geo, nil
+}
+
+// --- 特定の国・AS への接続の ALERT ---
+// geoAlerter は -alert-country または -alert-asn に一致する接続先への新しい接続を ALERT として報告する。
+// 同じプロセスから同じアドレスへの接続は、監視中に 1 回だけ報告する。
+type geoAlerter struct {
+	db        *geoIPDB
+	countries []string
+	asns      []uint32
+	reported  map[baselineKey]bool
+}
+
+func (g *geoAlerter) check(events []conn.Event) []conn.Event {
+	if g == nil {
+		return nil
+	}
+	var alerts []conn.Event
+	for _, e := range events {
+		if !e.Opened() || e.Conn.RemotePort == 0 {
+			continue
+		}
+		c := e.Conn
+		g.db.enrich(&c)
+		var reason string
+		switch {
+		case c.Country != "" && slices.Contains(g.countries, c.Country):
+			reason = fmt.Sprintf(tr("国 %s が -alert-country に含まれます"), c.Country)
+		case c.ASN != 0 && slices.Contains(g.asns, c.ASN):
+			reason = fmt.Sprintf(tr("AS%d (%s) が -alert-asn に含まれます"), c.ASN, c.ASOrg)
+		default:
+			continue
+		}
+		key := baselineKey{strings.ToLower(c.ProcessName), c.RemoteAddr, 0}
+		if g.reported[key] {
+			continue
+		}
+		g.reported[key] = true
+		alerts = append(alerts, conn.Event{
+			Type:   eventAlert,
+			Key:    e.Key,
+			Conn:   c,
+			Detail: fmt.Sprintf(tr("接続先 %s の%s"), net.JoinHostPort(c.RemoteAddr, strconv.Itoa(int(c.RemotePort))), reason),
+		})
+	}
+	return alerts
+}
+
+// geoAlerter は -alert-country または -alert-asn が指定されていれば geoAlerter を返す。未指定の場合は nil を返す。
+func (o *options) geoAlerter() (*geoAlerter, error) {
+	if o.alertCountries == "" && o.alertASNs == "" {
+		return nil, nil
+	}
+	if o.geoIPFiles == "" {
+		return nil, usageError(tr("-alert-country と -alert-asn には -geoip の指定が必要です。"))
+	}
+	db, err := o.geoIP()
+	if err != nil {
+		return nil, err
+	}
+	g := &geoAlerter{db: db, reported: make(map[baselineKey]bool)}
+	for _, c := range splitList(o.alertCountries) {
+		g.countries = append(g.countries, strings.ToUpper(c))
+	}
+	for _, a := range splitList(o.alertASNs) {
+		n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(a), "AS"), 10, 32)
+		if err != nil {
+			return nil, usageErrorf(tr("-alert-asn の AS 番号が不正です: %q"), a)
+		}
+		g.asns = append(g.asns, uint32(n))
+	}
+	return g, nil
+}
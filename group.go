@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"go-ObuStat/conn"
+)
+
+// --- 接続先ごとのイベントの集約 (-group-by) ---
+// eventGroup は、1 回の取得で検出した状態変化を -group-by の単位でまとめたイベントの種別。
+// コネクションプールのように接続先ごとの本数だけが重要な場合に、一時ポートごとの行を 1 行にまとめる。
+const eventGroup conn.EventType = "GROUP"
+
+// groupByKeys は -group-by で指定できる集約の単位。
+var groupByKeys = []string{"remote-host", "remote-port", "process"}
+
+// eventGrouper は NEW/CHANGE/CLOSED をプロセスと接続先の組ごとに件数へまとめる。
+// ALERT などの他のイベントはそのまま出力する。
+type eventGrouper struct {
+	by string
+}
+
+type eventGroupCounts struct {
+	conn                    conn.Connection // 代表の接続 (集約の単位に含まれる項目のみ)
+	target                  string
+	opened, closed, changed int
+}
+
+// group はイベントを集約した GROUP イベントを返す。
+func (g *eventGrouper) group(events []conn.Event) []conn.Event {
+	if g == nil || len(events) == 0 {
+		return events
+	}
+	var out []conn.Event
+	groups := make(map[string]*eventGroupCounts)
+	var order []string
+	for _, e := range events {
+		if e.Type != conn.EventNew && e.Type != conn.EventChange && e.Type != conn.EventClosed {
+			out = append(out, e)
+			continue
+		}
+		key, rep, target := g.groupOf(e.Conn)
+		counts, ok := groups[key]
+		if !ok {
+			counts = &eventGroupCounts{conn: rep, target: target}
+			groups[key] = counts
+			order = append(order, key)
+		}
+		switch e.Type {
+		case conn.EventNew:
+			counts.opened++
+		case conn.EventClosed:
+			counts.closed++
+		default:
+			counts.changed++
+		}
+	}
+	for _, key := range order {
+		counts := groups[key]
+		out = append(out, conn.Event{
+			Type:   eventGroup,
+			Key:    key,
+			Conn:   counts.conn,
+			Count:  counts.opened + counts.closed + counts.changed,
+			Detail: counts.describe(),
+		})
+	}
+	return out
+}
+
+// groupOf は接続の集約キー、代表の接続、表示用の接続先を返す。
+func (g *eventGrouper) groupOf(c conn.Connection) (string, conn.Connection, string) {
+	rep := conn.Connection{Protocol: c.Protocol, ProcessName: c.ProcessName, PID: c.PID, ProcessStart: c.ProcessStart}
+	var target string
+	switch {
+	case g.by == "process":
+	case c.Protocol == "UDP":
+		// UDP は接続先を持たないため、ローカルポートで区別する。
+		rep.LocalPort = c.LocalPort
+		target = "UDP :" + strconv.Itoa(int(c.LocalPort))
+	case g.by == "remote-port":
+		rep.RemotePort = c.RemotePort
+		target = fmt.Sprintf(tr("ポート %d"), c.RemotePort)
+	default:
+		rep.RemoteAddr, rep.RemotePort, rep.RemoteHost = c.RemoteAddr, c.RemotePort, c.RemoteHost
+		host := c.RemoteAddr
+		if c.RemoteHost != "" {
+			host = c.RemoteHost
+		}
+		target = net.JoinHostPort(host, strconv.Itoa(int(c.RemotePort)))
+	}
+	key := processLabel(c)
+	if target != "" {
+		key += " -> " + target
+	}
+	return key, rep, target
+}
+
+// describe は "+5 接続 -> 10.1.2.3:5432" のような集約結果の説明を返す。
+func (c *eventGroupCounts) describe() string {
+	var parts []string
+	if c.opened > 0 {
+		parts = append(parts, fmt.Sprintf(tr("+%d 接続"), c.opened))
+	}
+	if c.closed > 0 {
+		parts = append(parts, fmt.Sprintf(tr("-%d 切断"), c.closed))
+	}
+	if c.changed > 0 {
+		parts = append(parts, fmt.Sprintf(tr("%d 状態変化"), c.changed))
+	}
+	s := strings.Join(parts, ", ")
+	if c.target != "" {
+		s += " -> " + c.target
+	}
+	return s
+}
+
+// eventGrouper は -group-by が指定されていれば eventGrouper を返す。未指定の場合は nil を返す。
+func (o *options) eventGrouper() (*eventGrouper, error) {
+	if o.groupBy == "" {
+		return nil, nil
+	}
+	by := strings.ToLower(o.groupBy)
+	for _, k := range groupByKeys {
+		if by == k {
+			return &eventGrouper{by: by}, nil
+		}
+	}
+	return nil, usageErrorf(tr("-group-by の指定が不正です: %q (指定可能: %s)"), o.groupBy, strings.Join(groupByKeys, ", "))
+}
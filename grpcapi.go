@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-ObuStat/conn"
+	"go-ObuStat/obustatpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// --- serve モードの gRPC API (-grpc-listen) ---
+// HTTP API の /connections と /events と同じ内容を、obustatpb/obustat.proto の型で提供する。
+type monitorServer struct {
+	obustatpb.UnimplementedMonitorServer
+	ctx   context.Context // serve モードの終了時に WatchEvents のストリームを終わらせる
+	state *liveState
+}
+
+// queryFromFilter は gRPC の絞り込み条件を /connections と同じ connectionQuery にする。
+func queryFromFilter(f *obustatpb.ConnectionFilter) (connectionQuery, error) {
+	q := connectionQuery{processes: f.GetProcesses(), pids: f.GetPids()}
+	if len(f.GetStates()) > 0 {
+		states, err := conn.ParseStates(strings.Join(f.GetStates(), ","))
+		if err != nil {
+			return q, status.Error(codes.InvalidArgument, connError(err).Error())
+		}
+		q.states = states
+	}
+	for _, port := range f.GetPorts() {
+		if port > 65535 {
+			return q, status.Error(codes.InvalidArgument, fmt.Sprintf(tr("不正な port の指定です: %d"), port))
+		}
+		q.ports = append(q.ports, uint16(port))
+	}
+	return q, nil
+}
+
+func (s *monitorServer) ListConnections(_ context.Context, req *obustatpb.ListConnectionsRequest) (*obustatpb.ListConnectionsResponse, error) {
+	q, err := queryFromFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	resp := &obustatpb.ListConnectionsResponse{TimestampMs: unixMillis(s.state.updated)}
+	for _, key := range sortedKeys(s.state.current) {
+		if c := s.state.current[key]; q.match(c) {
+			resp.Connections = append(resp.Connections, protoConnection(c))
+		}
+	}
+	return resp, nil
+}
+
+func (s *monitorServer) WatchEvents(req *obustatpb.WatchEventsRequest, stream grpc.ServerStreamingServer[obustatpb.WatchEventsResponse]) error {
+	q, err := queryFromFilter(req.GetFilter())
+	if err != nil {
+		return err
+	}
+	ch := s.state.subscribe()
+	defer s.state.unsubscribe(ch)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.ctx.Done():
+			return nil
+		case batch := <-ch:
+			var matched []conn.Event
+			for _, e := range batch.events {
+				if q.match(e.Conn) {
+					matched = append(matched, e)
+				}
+			}
+			if len(matched) == 0 {
+				continue
+			}
+			if err := stream.Send(&obustatpb.WatchEventsResponse{TimestampMs: batch.time.UnixMilli(), Events: protoEvents(matched)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// serveGRPC は listenAddr で Monitor サービスを提供する。TLS・認証・接続元の制限は HTTP API と共通にする。ctx がキャンセルされると WatchEvents を終わらせ、
+// 返した stop で待ち受けを止める。
+func serveGRPC(ctx context.Context, listenAddr string, state *liveState, security *listenSecurity) (stop func(), err error) {
+	listener, err := security.listen(listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	server := grpc.NewServer(security.grpcOptions()...)
+	obustatpb.RegisterMonitorServer(server, &monitorServer{ctx: ctx, state: state})
+	go server.Serve(listener)
+	return server.GracefulStop, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 生存確認 (-heartbeat) ---
+// eventHeartbeat は、monitor が動作していることを知らせるために一定間隔で出力するイベントの種別。
+// 変化が無い間は何も出力されないため、受け取る側で「変化なし」と「ツールが停止した」を区別できるようにする。
+const eventHeartbeat conn.EventType = "HEARTBEAT"
+
+// processStart はツールを起動した時刻 (HEARTBEAT の稼働時間の起点)。
+var processStart = time.Now()
+
+// heartbeat は -heartbeat の間隔で HEARTBEAT を出力する契機を知らせる。
+type heartbeat struct {
+	ticker *time.Ticker
+}
+
+// heartbeat は -heartbeat が指定されていれば heartbeat を返す。未指定の場合は nil を返す。
+func (o *options) heartbeat() *heartbeat {
+	if o.heartbeatInterval <= 0 {
+		return nil
+	}
+	return &heartbeat{ticker: time.NewTicker(o.heartbeatInterval)}
+}
+
+// C は HEARTBEAT を出力する時刻を知らせる。nil の場合は何も届かないチャネル (nil) を返す。
+func (h *heartbeat) C() <-chan time.Time {
+	if h == nil {
+		return nil
+	}
+	return h.ticker.C
+}
+
+func (h *heartbeat) Stop() {
+	if h != nil {
+		h.ticker.Stop()
+	}
+}
+
+// event は now 時点の HEARTBEAT を作る。Count は現在の接続数。ツール自身の使用量は最後の取得で計測した値。
+func (h *heartbeat) event(now time.Time, connections int) conn.Event {
+	return conn.Event{
+		Type:  eventHeartbeat,
+		Count: connections,
+		Detail: fmt.Sprintf(tr("バージョン: %s, 稼働時間: %s, 接続数: %d, %s"),
+			version, uptime(now).Round(time.Second), connections, self.current().describe()),
+	}
+}
+
+// uptime は起動から now までの経過時間。
+func uptime(now time.Time) time.Duration {
+	return now.Sub(processStart)
+}
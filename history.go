@@ -0,0 +1,99 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- query -history (リモートごとの接続元プロセスの履歴) ---
+// 「先週の火曜日にこの IP と通信したのは何か」を調べるため、-store のイベントから
+// 指定したリモートへ接続したことのあるプロセスを集め、最初と最後に接続した時刻とともに表示する。
+// 例: query -store events.db -history 203.0.113.5:443 -from "2026-10-06 00:00"
+
+// eventHistory は query -history で、1 つのプロセスが 1 つのリモートへ接続した履歴をまとめたイベントの種別。
+const eventHistory conn.EventType = "HISTORY"
+
+// setHistoryTarget は -history の「ホスト:ポート」を検索条件にする。ポートを省略した場合は全てのポートを対象にする。
+// ホストには -host と同じくアドレス、ホスト名、ワイルドカードを指定できる。
+func (q *storeQuery) setHistoryTarget(s string) error {
+	host, port := s, ""
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		host, port = h, p
+	}
+	if port != "" && port != "*" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || n == 0 {
+			return usageErrorf(tr("-history のポートの指定が不正です: %q"), port)
+		}
+		q.remotePort = uint16(n)
+	}
+	q.hosts = []string{strings.ToLower(host)}
+	return nil
+}
+
+// historyKey はプロセスとリモートの組。PID が再利用された別のプロセスと区別するため、開始時刻も含める。
+type historyKey struct {
+	host         string
+	process      string
+	pid          uint32
+	processStart int64
+	remote       string
+}
+
+type historyEntry struct {
+	conn        conn.Connection // 最後に記録された接続
+	first, last time.Time
+	connections int // NEW と REBOUND の数
+}
+
+// writeHistory は条件に一致するイベントをプロセスとリモートの組ごとにまとめ、最初に接続した順に HISTORY として出力する。
+func (q storeQuery) writeHistory(db *sql.DB, formatter outputFormatter) error {
+	entries := make(map[historyKey]*historyEntry)
+	var order []historyKey
+	err := q.eachEvent(db, func(timestamp time.Time, e conn.Event) {
+		c := e.Conn
+		if c.RemotePort == 0 {
+			return
+		}
+		key := historyKey{c.Host, c.ProcessName, c.PID, c.ProcessStart.UnixMilli(), net.JoinHostPort(c.RemoteAddr, strconv.Itoa(int(c.RemotePort)))}
+		entry, ok := entries[key]
+		if !ok {
+			entry = &historyEntry{first: timestamp}
+			entries[key] = entry
+			order = append(order, key)
+		}
+		if !c.FirstSeen.IsZero() && c.FirstSeen.Before(entry.first) {
+			entry.first = c.FirstSeen
+		}
+		entry.last = timestamp
+		entry.conn = c
+		if e.Opened() {
+			entry.connections++
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(order) == 0 {
+		infoLog.Print(tr("条件に一致する接続の記録はありません"))
+		return exitStatus(exitNotFound)
+	}
+	for _, key := range order {
+		entry := entries[key]
+		formatter.writeEvents(entry.last, []conn.Event{{
+			Type:  eventHistory,
+			Key:   entry.conn.Key(),
+			Conn:  entry.conn,
+			Count: entry.connections,
+			Detail: fmt.Sprintf(tr("リモート: %s, 最初: %s, 最後: %s, 接続数: %d"), key.remote,
+				entry.first.Format("2006-01-02 15:04:05"), entry.last.Format("2006-01-02 15:04:05"), entry.connections),
+		}})
+	}
+	return nil
+}
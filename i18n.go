@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// --- 表示言語 (-lang) ---
+// メッセージは日本語の原文をそのままキーにし、選んだ言語の対応表に訳があれば置き換える。
+// 訳の無いメッセージは原文のまま表示するため、対応表は少しずつ埋めていける。
+// 言語を追加する場合は messages_<言語>.go に対応表を作り、catalogs に加える。
+
+// defaultLanguage は -lang も LANG も指定されていない場合の表示言語。
+const defaultLanguage = "ja"
+
+// catalogs は言語ごとのメッセージの対応表。原文の日本語は対応表を持たない。
+var catalogs = map[string]map[string]string{
+	"ja": nil,
+	"en": messagesEN,
+}
+
+// messages は現在の表示言語の対応表。
+var messages map[string]string
+
+// language は現在の表示言語 ("ja", "en" など)。
+var language = defaultLanguage
+
+// tr は原文 s を現在の表示言語に訳して返す。書式指定 (%s など) は訳でも同じ順序で含める。
+func tr(s string) string {
+	if t, ok := messages[s]; ok {
+		return t
+	}
+	return s
+}
+
+// normalizeLanguage は "en_US.UTF-8" や "ja-JP" のような指定から言語の部分を取り出す。
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// setLanguage は表示言語を切り替える。
+func setLanguage(lang string) error {
+	catalog, ok := catalogs[normalizeLanguage(lang)]
+	if !ok {
+		names := make([]string, 0, len(catalogs))
+		for name := range catalogs {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf(tr("-lang には %s のいずれかを指定してください: %q"), strings.Join(names, ", "), lang)
+	}
+	messages, language = catalog, normalizeLanguage(lang)
+	return nil
+}
+
+// initLanguage はフラグを解析する前に、-lang の指定、無ければ環境変数 LANG から表示言語を決める。
+// 使用方法やフラグの説明もその言語で表示するため、main の最初で呼ぶ。
+// LANG が対応していない言語 (C など) の場合は defaultLanguage を使う。
+func initLanguage(args []string) {
+	lang := defaultLanguage
+	if env := normalizeLanguage(os.Getenv("LANG")); catalogs[env] != nil || env == defaultLanguage {
+		lang = env
+	}
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "lang" {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		if _, ok := catalogs[normalizeLanguage(value)]; ok {
+			lang = value
+		}
+	}
+	setLanguage(lang)
+}
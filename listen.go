@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- listen モード (待ち受けソケットの一覧と増減の監視) ---
+func runListenMode(args []string) error {
+	fs := newFlagSet("listen")
+	opts := setupListenFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+	opts.interfaces = true
+
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	filter.Protocols = []string{"tcp"}
+	filter.States = []string{"LISTEN"}
+	filter.IncludeListen = true
+	formatter, err := opts.newFormatter()
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	if err := opts.checkPrivileges(); err != nil {
+		return err
+	}
+
+	collector := conn.NewCollector(filter)
+	collect := func() (conn.Snapshot, error) {
+		current, err := collector.Collect()
+		if err != nil || !opts.exposed {
+			return current, connError(err)
+		}
+		for key, c := range current {
+			if isLoopbackAddr(c.LocalAddr) {
+				delete(current, key)
+			}
+		}
+		return current, nil
+	}
+
+	// 起動時点の一覧を表示し、以降は待ち受けの開始・終了をイベントとして表示する。
+	prevConns, err := collect()
+	if err != nil {
+		return fmt.Errorf(tr("接続情報の取得に失敗: %w"), err)
+	}
+	formatter.writeSnapshot(time.Now(), prevConns)
+	if opts.once {
+		return nil
+	}
+
+	ctx, stop := opts.runContext()
+	defer stop()
+
+	infoLog.Print(tr("--- 待ち受け監視モード開始 ---"))
+	infoLog.Printf(tr("監視対象: %s"), monitorTarget)
+	infoLog.Printf(tr("実行間隔: %d ミリ秒... (Ctrl+Cで停止)"), opts.intervalMilliseconds)
+
+	stats := newSessionStats()
+	ticker := time.NewTicker(time.Duration(opts.intervalMilliseconds) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			stats.logSummary()
+			return nil
+		case <-ticker.C:
+		}
+		currentConns, err := collect()
+		if err != nil {
+			warnLog.Printf(tr("エラー: 接続情報の取得に失敗: %v"), err)
+			continue
+		}
+		stats.observe(currentConns)
+		if events := conn.Diff(prevConns, currentConns); len(events) > 0 {
+			stats.countEvents(events)
+			formatter.writeEvents(time.Now(), events)
+		}
+		prevConns = currentConns
+	}
+}
+
+// listenOptions は listen のオプション。
+type listenOptions struct {
+	*options
+	once    bool
+	exposed bool
+}
+
+// setupListenFlags は listen のフラグを定義する。
+func setupListenFlags(fs *flag.FlagSet) *listenOptions {
+	opts := &listenOptions{options: setupFlags(fs, filterFlags, pollFlags, periodFlags, outputFlags, sinkFlags, redactFlags, enrichFlags)}
+	fs.BoolVar(&opts.once, "once", false, tr("現在の待ち受けソケットを 1 回だけ表示して終了する"))
+	fs.BoolVar(&opts.exposed, "exposed", false, tr("ループバック以外で待ち受けているソケットのみ表示する"))
+	return opts
+}
+
+func isLoopbackAddr(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	return err == nil && ip.Unmap().IsLoopback()
+}
+
+// --- ローカルアドレスのインターフェース名 ---
+const interfaceRefreshInterval = 30 * time.Second
+
+// interfaceNames はローカルアドレスに対応するネットワークインターフェース名を設定する connEnricher。
+type interfaceNames struct {
+	mu      sync.Mutex
+	byAddr  map[netip.Addr]string
+	updated time.Time
+}
+
+func (n *interfaceNames) enrich(c *conn.Connection) {
+	if c.LocalInterface != "" {
+		return
+	}
+	c.LocalInterface = n.lookup(c.LocalAddr)
+}
+
+func (n *interfaceNames) lookup(addr string) string {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return ""
+	}
+	ip = ip.Unmap()
+	switch {
+	case ip.IsUnspecified():
+		return tr("全インターフェース")
+	case ip.IsLoopback():
+		return tr("ループバック")
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if time.Since(n.updated) > interfaceRefreshInterval {
+		n.refresh()
+	}
+	return n.byAddr[ip.WithZone("")]
+}
+
+// refresh はインターフェースの一覧を取得し直す。取得に失敗した場合は前回の一覧を使い続ける。
+func (n *interfaceNames) refresh() {
+	n.updated = time.Now()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+	byAddr := make(map[netip.Addr]string)
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ip, ok := netip.AddrFromSlice(ipNet.IP); ok {
+				byAddr[ip.Unmap()] = iface.Name
+			}
+		}
+	}
+	n.byAddr = byAddr
+}
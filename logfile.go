@@ -0,0 +1,285 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// --- ログファイルのローテーション ---
+type rotateConfig struct {
+	maxSize  int64         // このサイズ (バイト) を超えたらローテーションする。0 は無制限
+	maxAge   time.Duration // ファイルを開始してからこの期間を過ぎたらローテーションする。0 は無制限
+	maxFiles int           // 残しておく退避ファイルの数。0 は全て残す
+	compress bool          // 退避したファイルを gzip 圧縮する
+	flush    time.Duration // 圧縮して書く出力先 (.gz, .zst) で、圧縮中のデータを書き出す間隔。0 はファイルを閉じるまで書き出さない
+}
+
+// rotateWriter はサイズまたは経過時間の上限に達したら現在のファイルを
+// "名前-YYYYMMDD-HHMMSS.拡張子" へ退避し、新しいファイルに書き込む。
+// 拡張子が .gz または .zst のファイルは圧縮しながら書く (newCompressor)。
+type rotateWriter struct {
+	mu      sync.Mutex
+	path    string
+	cfg     rotateConfig
+	file    *os.File
+	enc     compressor // 圧縮しない場合は nil
+	dirty   bool       // enc に書き出していないデータがある
+	size    int64      // ファイルに書いたバイト数 (圧縮後)
+	started time.Time
+	retry   time.Time // 退避に失敗した場合、この時刻までは次の退避を試みない
+	wg      sync.WaitGroup
+	done    chan struct{}
+}
+
+func newRotateWriter(path string, cfg rotateConfig) (*rotateWriter, error) {
+	w := &rotateWriter{path: path, cfg: cfg, done: make(chan struct{})}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	if w.enc != nil && cfg.flush > 0 {
+		w.wg.Add(1)
+		go w.flushLoop()
+	}
+	return w, nil
+}
+
+func (w *rotateWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	w.started = fileCreationTime(info)
+	// 既存のファイルには新しい gzip のメンバー (zstd のフレーム) として追記する。どちらも連結したまま展開できる。
+	enc, err := newCompressor(w.path, fileCounter{w})
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.enc = enc
+	return nil
+}
+
+// fileCounter は圧縮後のデータをファイルに書き、書いたバイト数を size に加える。w.mu を保持して使う。
+type fileCounter struct{ w *rotateWriter }
+
+func (c fileCounter) Write(p []byte) (int, error) {
+	n, err := c.w.file.Write(p)
+	c.w.size += int64(n)
+	return n, err
+}
+
+func fileCreationTime(info os.FileInfo) time.Time {
+	if attr, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, attr.CreationTime.Nanoseconds())
+	}
+	return info.ModTime()
+}
+
+func (w *rotateWriter) needsRotate(n int) bool {
+	if w.size == 0 || time.Now().Before(w.retry) {
+		return false
+	}
+	if w.cfg.maxSize > 0 && w.size+int64(n) > w.cfg.maxSize {
+		return true
+	}
+	return w.cfg.maxAge > 0 && time.Since(w.started) >= w.cfg.maxAge
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	var renameErr error
+	if w.needsRotate(len(p)) {
+		var err error
+		if renameErr, err = w.rotate(); err != nil {
+			w.mu.Unlock()
+			return 0, err
+		}
+	}
+	n, err := w.write(p)
+	w.mu.Unlock()
+	// 警告の出力先が同じファイルの場合があるため、ロックを外してから書く。
+	if renameErr != nil {
+		warnLog.Printf(tr("警告: ファイルを退避できませんでした。%s への追記を続けます: %v"), w.path, renameErr)
+	}
+	return n, err
+}
+
+// write は現在のファイルへ p を書く。w.mu を保持して使う。
+func (w *rotateWriter) write(p []byte) (int, error) {
+	if w.enc != nil {
+		w.dirty = true
+		return w.enc.Write(p)
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// flushLoop は cfg.flush の間隔で圧縮中のデータをファイルに書き出し、tail などで読む側が追えるようにする。
+func (w *rotateWriter) flushLoop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.cfg.flush)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			var err error
+			if w.dirty {
+				err = w.enc.Flush()
+				w.dirty = false
+			}
+			w.mu.Unlock()
+			// 警告の出力先が同じファイルの場合があるため、ロックを外してから書く。
+			if err != nil {
+				warnLog.Printf(tr("エラー: 圧縮した出力の書き出しに失敗: %v"), err)
+			}
+		}
+	}
+}
+
+// rotateRetryInterval は退避に失敗した後、次に退避を試みるまでの間隔。
+const rotateRetryInterval = time.Minute
+
+// rotate は現在のファイルを退避して、新しいファイルを開く。
+// 退避に失敗した場合 (他のプロセスがファイルを開いているなど) は同じファイルを開き直して追記を続け、その原因を renameErr で返す。
+func (w *rotateWriter) rotate() (renameErr, err error) {
+	if w.enc != nil {
+		w.enc.Close()
+	}
+	w.file.Close()
+	base, ext := splitRotateExt(w.path)
+	rotated := base + "-" + time.Now().Format("20060102-150405") + ext
+	if err := os.Rename(w.path, rotated); err != nil && !os.IsNotExist(err) {
+		w.retry = time.Now().Add(rotateRetryInterval)
+		return err, w.open()
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	// 圧縮と古いファイルの削除は書き込みを止めないよう別ゴルーチンで行う。
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if w.cfg.compress && w.enc == nil {
+			if err := gzipFile(rotated); err != nil {
+				warnLog.Printf(tr("エラー: ローテーションしたファイルの圧縮に失敗: %v"), err)
+			}
+		}
+		w.removeOldFiles()
+	}()
+	return nil, nil
+}
+
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
+
+// removeOldFiles は maxFiles を超えた古い退避ファイルを削除する。
+func (w *rotateWriter) removeOldFiles() {
+	if w.cfg.maxFiles <= 0 {
+		return
+	}
+	base, ext := splitRotateExt(w.path)
+	matches, err := filepath.Glob(base + "-*" + ext + "*")
+	if err != nil || len(matches) <= w.cfg.maxFiles {
+		return
+	}
+	// 退避ファイル名には日時が入っているため、名前順がそのまま古い順になる。
+	sort.Strings(matches)
+	for _, old := range matches[:len(matches)-w.cfg.maxFiles] {
+		os.Remove(old)
+	}
+}
+
+func (w *rotateWriter) Close() error {
+	close(w.done)
+	w.wg.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if w.enc != nil {
+		err = w.enc.Close()
+	}
+	w.file.Sync()
+	return errors.Join(err, w.file.Close())
+}
+
+// splitRotateExt は path を、退避したファイル名で日時を挟む前後に分ける。
+// 圧縮の拡張子は元の拡張子と合わせて後ろに残す (events.jsonl.gz → events, .jsonl.gz)。
+func splitRotateExt(path string) (base, ext string) {
+	ext = filepath.Ext(path)
+	if _, ok := compressedExtensions[strings.ToLower(ext)]; ok {
+		ext = filepath.Ext(strings.TrimSuffix(path, ext)) + ext
+	}
+	return strings.TrimSuffix(path, ext), ext
+}
+
+// --- 圧縮した出力 (-o events.jsonl.gz, -o events.jsonl.zst) ---
+// 1 週間にわたる json の記録などを圧縮して書く。圧縮中のデータは -flush-interval ごとにファイルへ書き出すため、
+// 読む側 (zcat/zstdcat と tail の組み合わせなど) もほぼ遅れずに追える。
+
+// compressor は圧縮しながら書く io.WriteCloser。Close は圧縮の終端を書くが、元の Writer は閉じない。
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressedExtensions は圧縮して書く出力先の拡張子。
+var compressedExtensions = map[string]func(w io.Writer) (compressor, error){
+	".gz": func(w io.Writer) (compressor, error) { return gzip.NewWriter(w), nil },
+	".zst": func(w io.Writer) (compressor, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	},
+}
+
+// newCompressor は path の拡張子が圧縮の形式であれば、w へ圧縮して書く compressor を返す。それ以外は nil を返す。
+func newCompressor(path string, w io.Writer) (compressor, error) {
+	if newEnc, ok := compressedExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return newEnc(w)
+	}
+	return nil, nil
+}
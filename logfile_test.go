@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSplitRotateExt(t *testing.T) {
+	tests := []struct {
+		path, base, ext string
+	}{
+		{`C:\logs\events.jsonl`, `C:\logs\events`, ".jsonl"},
+		{`C:\logs\events.jsonl.gz`, `C:\logs\events`, ".jsonl.gz"},
+		{`C:\logs\events.jsonl.ZST`, `C:\logs\events`, ".jsonl.ZST"},
+		{`C:\logs\events.gz`, `C:\logs\events`, ".gz"},
+		{`C:\logs\events`, `C:\logs\events`, ""},
+		{`C:\logs.d\events`, `C:\logs.d\events`, ""},
+	}
+	for _, tt := range tests {
+		if base, ext := splitRotateExt(tt.path); base != tt.base || ext != tt.ext {
+			t.Errorf("splitRotateExt(%q) = %q, %q, want %q, %q", tt.path, base, ext, tt.base, tt.ext)
+		}
+	}
+}
+
+func TestRemoveOldFiles(t *testing.T) {
+	plain := []string{
+		"events-20240101-000000.jsonl", "events-20240102-000000.jsonl.gz", "events-20240103-000000.jsonl",
+		"events.jsonl", "other-20240101-000000.jsonl",
+	}
+	compressed := []string{
+		"events-20240101-000000.jsonl.gz", "events-20240102-000000.jsonl.gz", "events-20240103-000000.jsonl",
+		"events.jsonl", "events.jsonl.gz",
+	}
+	tests := []struct {
+		name     string
+		path     string   // 出力先のファイル名
+		files    []string // 用意するファイル
+		maxFiles int
+		want     []string // 削除されずに残るファイル (名前順)
+	}{
+		{"全て残す", "events.jsonl", plain, 0, plain},
+		{"退避後に圧縮したファイルも数える", "events.jsonl", plain, 2, plain[1:]},
+		{"圧縮して書く出力先は同じ拡張子の退避ファイルだけを数える", "events.jsonl.gz", compressed, 1, compressed[1:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w := &rotateWriter{path: filepath.Join(dir, tt.path), cfg: rotateConfig{maxFiles: tt.maxFiles}}
+			w.removeOldFiles()
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("残ったファイル = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
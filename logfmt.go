@@ -0,0 +1,169 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- logfmt 形式 (key=value を空白区切りで 1 行に並べる) ---
+type logfmtFormatter struct {
+	out *log.Logger
+}
+
+// logfmtLine は key=value の組を順に追加する。値が空の組は出力しない。
+type logfmtLine struct {
+	b strings.Builder
+}
+
+func (l *logfmtLine) add(key, value string) {
+	if value == "" {
+		return
+	}
+	if l.b.Len() > 0 {
+		l.b.WriteByte(' ')
+	}
+	l.b.WriteString(key)
+	l.b.WriteByte('=')
+	if strings.ContainsAny(value, " =\"\t\r\n") {
+		value = strconv.Quote(value)
+	}
+	l.b.WriteString(value)
+}
+
+func (l *logfmtLine) String() string { return l.b.String() }
+
+func logfmtConnection(l *logfmtLine, c conn.Connection) {
+	l.add("proc", c.ProcessName)
+	l.add("pid", strconv.FormatUint(uint64(c.PID), 10))
+	l.add("host", c.Host)
+	l.add("proto", c.Protocol)
+	l.add("laddr", c.LocalAddr)
+	l.add("lport", strconv.Itoa(int(c.LocalPort)))
+	l.add("iface", c.LocalInterface)
+	l.add("raddr", c.RemoteAddr)
+	l.add("rport", optionalPort(c.RemotePort))
+	l.add("rhost", c.RemoteHost)
+	l.add("rscope", string(remoteScope(c)))
+	l.add("service", c.RemoteService)
+	l.add("country", c.Country)
+	if c.ASN != 0 {
+		l.add("asn", strconv.FormatUint(uint64(c.ASN), 10))
+	}
+	l.add("as_org", c.ASOrg)
+	l.add("profiles", strings.Join(c.Profiles, ","))
+	if p := c.Peer; p != nil {
+		l.add("peer_proc", p.ProcessName)
+		l.add("peer_pid", strconv.FormatUint(uint64(p.PID), 10))
+		l.add("peer_role", peerRole(p))
+	}
+	l.add("state", c.State)
+	if t := c.Traffic; t != nil {
+		l.add("bytes_in", strconv.FormatUint(t.BytesIn, 10))
+		l.add("bytes_out", strconv.FormatUint(t.BytesOut, 10))
+		l.add("retransmits", strconv.FormatUint(uint64(t.Retransmits), 10))
+		l.add("rtt_ms", strconv.FormatInt(t.RTT.Milliseconds(), 10))
+	}
+	l.add("user", c.User)
+	l.add("module", c.Module)
+	l.add("services", strings.Join(c.Services, ","))
+	l.add("container", c.Container)
+	if c.InJob {
+		l.add("in_job", "true")
+	}
+	l.add("image", c.ImagePath)
+	l.add("cmdline", c.CommandLine)
+}
+
+func (f logfmtFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	sortEvents(events)
+	ts := machineTimestamp(timestamp)
+	for _, e := range events {
+		var l logfmtLine
+		l.add("ts", ts)
+		l.add("event", string(e.Type))
+		logfmtConnection(&l, e.Conn)
+		l.add("prev_state", e.PrevState)
+		if e.Count > 0 {
+			l.add("count", strconv.Itoa(e.Count))
+		}
+		l.add("detail", e.Detail)
+		if e.Type != conn.EventNew && !e.Conn.FirstSeen.IsZero() {
+			l.add("lifetime_ms", strconv.FormatInt(lifetimeMillis(e, timestamp), 10))
+		}
+		if e.Type == eventHeartbeat {
+			l.add("version", version)
+			l.add("uptime_ms", strconv.FormatInt(uptime(timestamp).Milliseconds(), 10))
+			l.add("connections", strconv.Itoa(e.Count))
+			usage := self.current()
+			l.add("cpu_percent", strconv.FormatFloat(usage.CPUPercent, 'f', 1, 64))
+			l.add("rss_bytes", strconv.FormatUint(usage.RSS, 10))
+			l.add("heap_bytes", strconv.FormatUint(usage.HeapAlloc, 10))
+		}
+		f.out.Println(l.String())
+	}
+}
+
+func (f logfmtFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	ts := machineTimestamp(timestamp)
+	if len(conns) == 0 {
+		var l logfmtLine
+		l.add("ts", ts)
+		l.add("event", string(eventSnapshot))
+		l.add("count", "0")
+		f.out.Println(l.String())
+		return
+	}
+	for _, key := range sortedKeys(conns) {
+		var l logfmtLine
+		l.add("ts", ts)
+		l.add("event", string(eventSnapshot))
+		logfmtConnection(&l, conns[key])
+		f.out.Println(l.String())
+	}
+}
+
+func (f logfmtFormatter) writeUnchanged(timestamp time.Time, count int) {
+	var l logfmtLine
+	l.add("ts", machineTimestamp(timestamp))
+	l.add("event", string(eventUnchanged))
+	l.add("count", strconv.Itoa(count))
+	f.out.Println(l.String())
+}
+
+func (f logfmtFormatter) writeStats(timestamp time.Time, stats []processStats, perf *conn.TCPPerf) {
+	ts := machineTimestamp(timestamp)
+	for _, s := range stats {
+		var l logfmtLine
+		l.add("ts", ts)
+		l.add("event", "STATS")
+		l.add("proc", s.Process)
+		l.add("pid", strconv.FormatUint(uint64(s.PID), 10))
+		l.add("total", strconv.Itoa(s.Total))
+		l.add("remote_hosts", strconv.Itoa(s.RemoteHosts))
+		l.add("opened", strconv.Itoa(s.Opened))
+		l.add("closed", strconv.Itoa(s.Closed))
+		if s.Counters != nil {
+			l.add("handles", strconv.FormatUint(uint64(s.Counters.Handles), 10))
+			l.add("threads", strconv.FormatUint(uint64(s.Counters.Threads), 10))
+		}
+		for _, state := range sortedStates(s.States) {
+			l.add("state_"+strings.ToLower(state), strconv.Itoa(s.States[state]))
+		}
+		f.out.Println(l.String())
+	}
+	if perf != nil {
+		var l logfmtLine
+		l.add("ts", ts)
+		l.add("event", "PERF")
+		l.add("retransmits_per_sec", strconv.FormatFloat(perf.SegmentsRetransmittedPerSec, 'f', 1, 64))
+		l.add("connections_reset", strconv.FormatUint(perf.ConnectionsReset, 10))
+		l.add("connections_reset_delta", strconv.FormatUint(perf.ConnectionsResetDelta, 10))
+		l.add("connection_failures", strconv.FormatUint(perf.ConnectionFailures, 10))
+		l.add("connection_failures_delta", strconv.FormatUint(perf.ConnectionFailuresDelta, 10))
+		f.out.Println(l.String())
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go-ObuStat/conn"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- ループバック接続の組み合わせ (-pair-loopback) ---
+// ローカルのプロセス間で TCP を使う場合、TCP テーブルには両端の 2 行 (クライアント側とサーバー側) が現れる。
+// -pair-loopback を指定すると、相手側の行のプロセスを接続に付加し、クライアント → サーバーの関係として表示する。
+// 両方の行が同じ出力に含まれる場合は、サーバー側の行を省いて 1 件にまとめる。
+
+// loopbackRefreshInterval はループバック接続の一覧を取得し直す間隔。1 回の出力の間は同じ一覧を使う。
+const loopbackRefreshInterval = 500 * time.Millisecond
+
+// loopbackPeers はループバックの TCP 接続に、相手側の端点を所有するプロセスを設定する connEnricher。
+// 相手側のプロセスは監視対象外のこともあるため、全てのプロセスのループバック接続を別に取得する。
+type loopbackPeers struct {
+	mu      sync.Mutex
+	rows    conn.Snapshot   // 全プロセスのループバックの TCP 接続 (キーは Connection.Key)
+	listen  map[string]bool // 待ち受けているローカルのアドレスとポート
+	updated time.Time
+}
+
+func (p *loopbackPeers) enrich(c *conn.Connection) {
+	if c.Peer != nil || !isLoopbackConn(*c) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.updated) > loopbackRefreshInterval {
+		p.refresh()
+	}
+	peer, ok := p.rows[loopbackPeerKey(*c)]
+	if !ok {
+		return
+	}
+	c.Peer = &conn.Peer{ProcessName: peer.ProcessName, PID: peer.PID, Server: p.listening(peer)}
+}
+
+// refresh はループバックの TCP 接続と待ち受けの一覧を取得し直す。取得に失敗した場合は前回の一覧を使い続ける。
+func (p *loopbackPeers) refresh() {
+	p.updated = time.Now()
+	snapshot, err := conn.Collect(conn.Filter{
+		Protocols:     []string{"tcp"},
+		Families:      []uint32{windows.AF_INET, windows.AF_INET6},
+		AllProcesses:  true,
+		IncludeListen: true,
+		Scopes:        []conn.Scope{conn.ScopeLoopback, conn.ScopeUnspecified},
+	})
+	if err != nil {
+		debugLog.Printf(tr("ループバック接続の一覧を取得できません: %v"), connError(err))
+		return
+	}
+	p.rows = make(conn.Snapshot, len(snapshot))
+	p.listen = make(map[string]bool)
+	for _, c := range snapshot {
+		if c.State == "LISTEN" {
+			p.listen[fmt.Sprintf("%s:%d", c.LocalAddr, c.LocalPort)] = true
+			continue
+		}
+		p.rows[c.Key()] = c
+	}
+}
+
+// listening は c のローカルのポートが待ち受けているポート (c がサーバー側) かを返す。
+func (p *loopbackPeers) listening(c conn.Connection) bool {
+	return p.listen[fmt.Sprintf("%s:%d", c.LocalAddr, c.LocalPort)] ||
+		p.listen[fmt.Sprintf("0.0.0.0:%d", c.LocalPort)] || p.listen[fmt.Sprintf(":::%d", c.LocalPort)]
+}
+
+func isLoopbackConn(c conn.Connection) bool {
+	return c.Protocol == "TCP" && c.RemotePort != 0 && conn.AddrScope(c.RemoteAddr) == conn.ScopeLoopback
+}
+
+// loopbackPeerKey は c の相手側の行のキー (ローカルとリモートを入れ替えたもの) を返す。
+func loopbackPeerKey(c conn.Connection) string {
+	return conn.Connection{
+		Protocol:  "TCP",
+		LocalAddr: c.RemoteAddr, LocalPort: c.RemotePort,
+		RemoteAddr: c.LocalAddr, RemotePort: c.LocalPort,
+	}.Key()
+}
+
+// loopbackPair は接続の両端を「クライアント (PID) -> サーバー (PID)」の形で返す。相手側が不明な場合は "" を返す。
+func loopbackPair(c conn.Connection) string {
+	if c.Peer == nil {
+		return ""
+	}
+	self := fmt.Sprintf("%s (PID %d)", c.ProcessName, c.PID)
+	peer := fmt.Sprintf("%s (PID %d)", c.Peer.ProcessName, c.Peer.PID)
+	if c.Peer.Server {
+		return self + " -> " + peer
+	}
+	return peer + " -> " + self
+}
+
+// peerRole は相手側の役割を json/logfmt のキーの値 (server または client) で返す。
+func peerRole(p *conn.Peer) string {
+	if p.Server {
+		return "server"
+	}
+	return "client"
+}
+
+// isServerSide は、相手側が分かっているループバック接続のうちサーバー側の行かを返す。
+func isServerSide(c conn.Connection) bool {
+	return c.Peer != nil && !c.Peer.Server
+}
+
+// loopbackPairingFormatter は、同じ出力にクライアント側の行も含まれるループバック接続のサーバー側の行を省いてから元の outputFormatter へ渡す。
+type loopbackPairingFormatter struct {
+	outputFormatter
+}
+
+func (f loopbackPairingFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	type eventKey struct {
+		typ conn.EventType
+		key string
+	}
+	present := make(map[eventKey]bool, len(events))
+	for _, e := range events {
+		present[eventKey{e.Type, e.Key}] = true
+	}
+	out := make([]conn.Event, 0, len(events))
+	for _, e := range events {
+		if isServerSide(e.Conn) && present[eventKey{e.Type, loopbackPeerKey(e.Conn)}] {
+			continue
+		}
+		out = append(out, e)
+	}
+	f.outputFormatter.writeEvents(timestamp, out)
+}
+
+func (f loopbackPairingFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	out := make(conn.Snapshot, len(conns))
+	for key, c := range conns {
+		if isServerSide(c) {
+			if _, ok := conns[loopbackPeerKey(c)]; ok {
+				continue
+			}
+		}
+		out[key] = c
+	}
+	f.outputFormatter.writeSnapshot(timestamp, out)
+}
+
+// withLoopbackPairing は -pair-loopback が指定されていれば、サーバー側の行を省く outputFormatter を返す。
+func (o *options) withLoopbackPairing(formatter outputFormatter) outputFormatter {
+	if !o.pairLoopback {
+		return formatter
+	}
+	return loopbackPairingFormatter{formatter}
+}
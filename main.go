@@ -1,156 +1,399 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"strconv"
+	"os/signal"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
-	"unsafe"
 
-	"golang.org/x/sys/windows"
+	"go-ObuStat/conn"
 )
 
-// --- Win32 API 構造体と定数の定義 ---
-type MIB_TCPROW_OWNER_PID struct {
-	State      uint32
-	LocalAddr  uint32
-	LocalPort  uint32
-	RemoteAddr uint32
-	RemotePort uint32
-	OwningPid  uint32
-}
-type MIB_TCPTABLE_OWNER_PID struct {
-	NumEntries uint32
-	Table      [1]MIB_TCPROW_OWNER_PID
-}
-
-var (
-	iphlpapi                = windows.NewLazySystemDLL("iphlpapi.dll")
-	procGetExtendedTcpTable = iphlpapi.NewProc("GetExtendedTcpTable")
+// --- 終了コード ---
+// スクリプトから失敗の原因を判別できるよう、終了コードを分ける。
+const (
+	exitOK         = 0
+	exitNotFound   = 1 // snapshot で、監視対象に一致する接続が無かった
+	exitUsage      = 2 // 引数や設定ファイルの指定が不正 (flag パッケージの解析エラーと同じ値)
+	exitAPIFailure = 3 // Windows API、ファイル、ネットワークなどの操作に失敗した
+	exitAlert      = 4 // -exit-on-alert により ALERT で終了した
 )
 
-// --- アプリケーションの構造体定義 ---
-type TCPConnection struct {
-	ProcessName string
-	PID         uint32
-	LocalAddr   string
-	LocalPort   uint16
-	RemoteAddr  string
-	RemotePort  uint16
-	State       string
-}
-
 // --- メインロジック ---
 func main() {
-	if len(os.Args) < 2 {
+	initLanguage(os.Args[1:])
+	// データ (json/logfmt の ERROR イベントを含む) は標準出力へ書く。
+	log.SetOutput(os.Stdout)
+	// サブコマンドより前には、全体の -lang と -h だけを指定できる。
+	global := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	global.Usage = printUsage
+	lang := global.String("lang", "", tr("表示言語 (ja, en)"))
+	global.Parse(os.Args[1:])
+	if *lang != "" {
+		if err := setLanguage(*lang); err != nil {
+			os.Exit(reportError(&exitError{code: exitUsage, err: err}))
+		}
+	}
+	if global.NArg() == 0 {
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
-
-	switch os.Args[1] {
-	case "monitor":
-		runMonitorMode()
-	case "snapshot":
-		runSnapshotMode()
-	default:
+	c, ok := findCommand(global.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, tr("不明なサブコマンドです: %s\n\n"), global.Arg(0))
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+	if err := c.run(global.Args()[1:]); err != nil {
+		os.Exit(reportError(err))
 	}
 }
 
-func printUsage() {
-	fmt.Fprintf(os.Stderr, "使用方法: %s <サブコマンド> [オプション]\n\n", os.Args[0])
-	fmt.Fprintln(os.Stderr, "サブコマンド:")
-	fmt.Fprintln(os.Stderr, "  monitor    接続の状態変化 (新規、変化、終了) を監視します。")
-	fmt.Fprintln(os.Stderr, "  snapshot   指定した間隔で、現在の全接続状態をスナップショットとして表示します。")
-	fmt.Fprintln(os.Stderr, "\n各サブコマンドのオプションは -h で確認できます。")
-	fmt.Fprintf(os.Stderr, "例: %s monitor -n java.exe -i 200\n", os.Args[0])
+// --- monitor モード ---
+func runMonitorMode(args []string) error {
+	fs := newFlagSet("monitor")
+	opts := setupMonitorFlags(fs)
+	if err := parseFlags(fs, opts, args); err != nil {
+		return err
+	}
+	return runMonitorModeWith(opts)
 }
 
-func setupFlags(fs *flag.FlagSet) (*string, *string, *string, *int) {
-	processNames := fs.String("n", "", "監視するプロセス名 (カンマ区切り)")
-	pids := fs.String("p", "", "監視するPID (カンマ区切り, '0'でデバッグモード)")
-	outputFile := fs.String("o", "", "出力ファイル名")
-	intervalMilliseconds := fs.Int("i", 1000, "実行間隔(ミリ秒)")
-	return processNames, pids, outputFile, intervalMilliseconds
+// setupMonitorFlags は monitor のフラグを定義する。
+func setupMonitorFlags(fs *flag.FlagSet) *options {
+	return setupFlags(fs, monitorFlagGroups...)
 }
 
-// --- monitor モード ---
-func runMonitorMode() {
-	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
-	processNames, pids, outputFile, intervalMilliseconds := setupFlags(fs)
-	fs.Parse(os.Args[2:])
-
-	targets, debugMode, monitorTarget := processArgs(*processNames, *pids)
-	setupLogging(*outputFile)
+// runMonitorModeWith は解析済みのオプションで monitor モードを実行する。
+func runMonitorModeWith(opts *options) error {
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	formatter, err := opts.newFormatter()
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
 
-	log.Printf("--- 監視モード開始 ---")
-	log.Printf("監視対象: %s", monitorTarget)
-	log.Printf("実行間隔: %d ミリ秒... (Ctrl+Cで停止)", *intervalMilliseconds)
+	ctx, stop := opts.runContext()
+	defer stop()
+	return runMonitor(ctx, opts, filter, monitorTarget, formatter, new(atomic.Bool))
+}
 
-	prevConns := make(map[string]TCPConnection)
-	ticker := time.NewTicker(time.Duration(*intervalMilliseconds) * time.Millisecond)
-	defer ticker.Stop()
+// runMonitor は ctx がキャンセルされるまで状態変化を監視する。paused が true の間は取得を休止する。
+// -exit-on-alert により ALERT で終了した場合は exitAlert で終了させるエラーを返す。
+//
+// 取得、差分、判定、付加情報 (キャッシュを含む)、formatter への出力は全てこの関数のループの goroutine で行い、
+// 前回の接続一覧やキャッシュにはロックを使わない。別の goroutine で動くのは次のものだけで、いずれも自身の状態をロックまたはキューで守る。
+//   - 取得の契機 (trigger) と、プロセス名・逆引きの問い合わせのワーカー: 結果はロックした表を通して次の取得で使う
+//   - Webhook と agent の送信: writeEvents はキューに入れるだけで、送信は専用の goroutine が行う
+//   - service の制御: paused (atomic.Bool) と ctx だけを通して伝える
+func runMonitor(ctx context.Context, opts *options, filter conn.Filter, monitorTarget string, formatter outputFormatter, paused *atomic.Bool) error {
+	if err := opts.checkPrivileges(); err != nil {
+		return err
+	}
+	trigger, err := opts.newPollTrigger()
+	if err != nil {
+		return err
+	}
+	defer trigger.Stop()
+	baseline, err := opts.baselineChecker()
+	if err != nil {
+		return err
+	}
+	geoAlerts, err := opts.geoAlerter()
+	if err != nil {
+		return err
+	}
+	ports, err := opts.portWatchdog()
+	if err != nil {
+		return err
+	}
+	grouper, err := opts.eventGrouper()
+	if err != nil {
+		return err
+	}
+	sampler, err := opts.eventSampler()
+	if err != nil {
+		return err
+	}
+	capture, err := opts.captureSwitch()
+	if err != nil {
+		return err
+	}
+	defer capture.Stop()
+
+	infoLog.Print(tr("--- 監視モード開始 ---"))
+	infoLog.Printf(tr("監視対象: %s"), monitorTarget)
+	infoLog.Printf(tr("%s... (Ctrl+Cで停止)"), trigger.describe())
+
+	stats := newSessionStats()
+	alerts := opts.alertTracker()
+	scans := opts.scanDetector()
+	timeWait := opts.timeWaitCollapser()
+	debounce := opts.debouncer()
+	rates := opts.rateTracker()
+	collector := conn.NewCollector(filter)
+	prevConns, err := opts.loadState(filter)
+	if err != nil {
+		return err
+	}
+	heartbeat := opts.heartbeat()
+	defer heartbeat.Stop()
+	procEvents := opts.processEvents(filter)
+	defer procEvents.Stop()
+	watcher := opts.configWatcher()
+	defer watcher.Stop()
+
+	write := func(events []conn.Event) {
+		if len(events) > 0 {
+			stats.countEvents(events)
+			formatter.writeEvents(time.Now(), events)
+		}
+	}
+	finish := func() {
+		// -debounce で保留中の NEW は、終了時にまとめて出力する。
+		write(grouper.group(debounce.flush()))
+		stats.logSummary()
+		sampler.logSummary()
+		if err := opts.saveState(prevConns); err != nil {
+			warnLog.Printf(tr("警告: 接続の状態を保存できませんでした: %v"), err)
+		}
+	}
 
-	for range ticker.C {
-		currentConns, err := getFilteredConnections(targets, debugMode)
+	// poll は 1 回分の取得と判定を行い、ALERT が発生したかを返す。
+	poll := func() bool {
+		collectStart := time.Now()
+		currentConns, err := collector.Collect()
 		if err != nil {
-			log.Printf("エラー: 接続情報の取得に失敗: %v", err)
-			continue
+			warnLog.Printf(tr("エラー: 接続情報の取得に失敗: %v"), connError(err))
+			return false
 		}
-		detectAndLogChanges(currentConns, prevConns)
+		stats.observe(currentConns)
+		now := time.Now()
+		events := timeWait.apply(conn.Diff(prevConns, currentConns))
+		debugLog.Printf(tr("取得: %d 件, 差分: %d 件 (%s)"), len(currentConns), len(events), time.Since(collectStart).Round(time.Microsecond))
+		usage := self.sample(now)
+		debugLog.Printf(tr("自身の使用量: %s"), usage.describe())
+		self.enforce(usage)
+		// -debounce で保留・集約される前の NEW を判定し、短時間の接続も ANOMALY として出力する。
+		anomalies := baseline.check(events)
+		geoAlertEvents := geoAlerts.check(events)
+		scanEvents := scans.check(now, events)
+		trigger.observe(len(events))
+		rates.observe(now, events)
+		events = sampler.apply(now, grouper.group(debounce.apply(now, events)))
+		// -capture-when で記録していない間は、接続の変化のイベントを出力しない。
+		captureEvents := capture.update(now, currentConns)
+		if !capture.capturing() {
+			events = nil
+		}
+		rateEvents, alertEvents := rates.check(now)
+		alertEvents = slices.Concat(alerts.check(currentConns), geoAlertEvents, ports.check(), alertEvents)
+		write(slices.Concat(captureEvents, events, anomalies, scanEvents, rateEvents, alertEvents))
 		prevConns = currentConns
+		return len(alertEvents) > 0
+	}
+	guard := panicGuard{formatter: formatter}
+
+	for {
+		select {
+		case <-ctx.Done():
+			finish()
+			return nil
+		case now := <-heartbeat.C():
+			// HEARTBEAT は接続の変化ではないため、サマリーの件数には含めない。
+			formatter.writeEvents(now, []conn.Event{heartbeat.event(now, len(prevConns))})
+			continue
+		case pe, ok := <-procEvents.C():
+			if !ok {
+				procEvents.ended()
+			} else if !paused.Load() {
+				write([]conn.Event{processEvent(pe)})
+			}
+			continue
+		case now := <-watcher.C():
+			if !watcher.changed() {
+				continue
+			}
+			newFilter, newTarget, err := opts.reloadFilters()
+			if err != nil {
+				warnLog.Printf(tr("警告: 設定ファイルを再読み込みできないため、現在の設定で監視を続けます: %v"), err)
+				continue
+			}
+			// 接続の状態を引き継ぐため、取得に使う Filter だけを入れ替える。
+			collector = conn.NewCollector(newFilter)
+			procEvents.setFilter(newFilter)
+			pruneSnapshot(prevConns, newFilter)
+			infoLog.Printf(tr("設定ファイルを再読み込みしました。監視対象: %s"), newTarget)
+			formatter.writeEvents(now, []conn.Event{{Type: eventConfigReloaded, Detail: fmt.Sprintf(tr("監視対象: %s"), newTarget)}})
+			continue
+		case <-trigger.C():
+		case <-capture.C():
+		}
+		if paused.Load() {
+			continue
+		}
+		alerted, err := guard.run(poll)
+		if err != nil {
+			finish()
+			return err
+		}
+		if alerted && opts.exitOnAlert {
+			finish()
+			return exitStatus(exitAlert)
+		}
 	}
 }
 
 // --- snapshot モード ---
-func runSnapshotMode() {
-	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
-	processNames, pids, outputFile, intervalMilliseconds := setupFlags(fs)
-	fs.Parse(os.Args[2:])
+func runSnapshotMode(args []string) error {
+	fs := newFlagSet("snapshot")
+	opts := setupSnapshotFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+	if opts.once {
+		opts.count = 1
+	}
 
-	targets, debugMode, monitorTarget := processArgs(*processNames, *pids)
-	setupLogging(*outputFile)
+	// スクリプトやヘルスチェックから判定できるよう、一致する接続が無ければ異常終了する。
+	code, err := runSnapshot(opts.options, opts.count, opts.changedOnly)
+	if err != nil {
+		return err
+	}
+	if code != exitOK {
+		return exitStatus(code)
+	}
+	return nil
+}
 
-	log.Printf("--- スナップショットモード開始 ---")
-	log.Printf("監視対象: %s", monitorTarget)
-	log.Printf("実行間隔: %d ミリ秒... (Ctrl+Cで停止)", *intervalMilliseconds)
+// snapshotOptions は snapshot のオプション。
+type snapshotOptions struct {
+	*options
+	once        bool
+	count       int
+	changedOnly bool
+}
 
-	ticker := time.NewTicker(time.Duration(*intervalMilliseconds) * time.Millisecond)
+// setupSnapshotFlags は snapshot のフラグを定義する。
+func setupSnapshotFlags(fs *flag.FlagSet) *snapshotOptions {
+	opts := &snapshotOptions{options: setupFlags(fs, filterFlags, pollFlags, periodFlags, outputFlags, sinkFlags, redactFlags, enrichFlags)}
+	fs.BoolVar(&opts.once, "once", false, tr("スナップショットを 1 回だけ表示して終了する (-count 1 と同じ)"))
+	fs.IntVar(&opts.count, "count", 0, tr("指定した回数だけスナップショットを表示して終了する (0で無制限)"))
+	fs.BoolVar(&opts.changedOnly, "changed-only", false, tr("前回から接続が変化した場合だけ一覧を表示し、変化が無ければ「変化なし」の 1 行だけを表示する"))
+	fs.StringVar(&opts.outputDir, "o-dir", "", tr("スナップショットを 1 回ごとに、このディレクトリの別のファイルへ書く (-o の代わりに指定する)"))
+	fs.StringVar(&opts.outputName, "o-name", "snapshot-20060102-150405", tr("-o-dir に書くファイル名。Go の時刻の書式で、拡張子は -format に合わせて付ける (例: 2006-01-02/snapshot-1504 で日付ごとのディレクトリ)"))
+	return opts
+}
+
+// runSnapshot はスナップショットを表示し続け、count 回 (0 なら Ctrl+C まで) で終了する。
+// changedOnly が true の場合、前回と同じ接続一覧は「変化なし」の 1 行にまとめる。
+// 終了コードとして、最後の取得に失敗した場合は exitAPIFailure、一致する接続が無かった場合は exitNotFound を返す。
+func runSnapshot(opts *options, count int, changedOnly bool) (int, error) {
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return exitUsage, err
+	}
+	formatter, err := opts.newFormatter()
+	if err != nil {
+		return exitUsage, err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return exitAPIFailure, err
+	}
+	defer closeOutput()
+	if err := opts.checkPrivileges(); err != nil {
+		return exitAPIFailure, err
+	}
+
+	ctx, stop := opts.runContext()
+	defer stop()
+
+	if count == 0 {
+		infoLog.Print(tr("--- スナップショットモード開始 ---"))
+		infoLog.Printf(tr("監視対象: %s"), monitorTarget)
+		infoLog.Printf(tr("実行間隔: %d ミリ秒... (Ctrl+Cで停止)"), opts.intervalMilliseconds)
+	}
+
+	stats := newSessionStats()
+	collector := conn.NewCollector(filter)
+	ticker := time.NewTicker(time.Duration(opts.intervalMilliseconds) * time.Millisecond)
 	defer ticker.Stop()
 
-	for currentTime := range ticker.C {
-		currentConns, err := getFilteredConnections(targets, debugMode)
+	var prevConns conn.Snapshot
+	code := exitNotFound
+	for taken := 0; count == 0 || taken < count; taken++ {
+		currentTime := time.Now()
+		// 回数指定がある場合は 1 回目を待たずに取得する。
+		if count == 0 || taken > 0 {
+			select {
+			case <-ctx.Done():
+				if count == 0 {
+					stats.logSummary()
+				}
+				return code, nil
+			case currentTime = <-ticker.C:
+			}
+		}
+		currentConns, err := collector.Collect()
 		if err != nil {
-			log.Printf("エラー: 接続情報の取得に失敗: %v", err)
+			warnLog.Printf(tr("エラー: 接続情報の取得に失敗: %v"), connError(err))
+			code = exitAPIFailure
 			continue
 		}
-
-		timestamp := currentTime.Format("15:04:05.000")
-
-		if len(currentConns) == 0 {
-			log.Printf("--- %s 監視対象に一致する接続は見つかりません ---", timestamp)
+		stats.observe(currentConns)
+		if changedOnly && prevConns != nil && len(conn.Diff(prevConns, currentConns)) == 0 {
+			formatter.writeUnchanged(currentTime, len(currentConns))
 		} else {
-			var report strings.Builder
-			report.WriteString(fmt.Sprintf("--- %s 監視対象の接続 (%d件) ---\n", timestamp, len(currentConns)))
-			for key, conn := range currentConns {
-				report.WriteString(fmt.Sprintf("%s | Process: %-15s (PID: %-5d) | 状態: %-12s\n", key, conn.ProcessName, conn.PID, conn.State))
-			}
-			report.WriteString("-----------------------------------")
-			log.Println(report.String())
+			formatter.writeSnapshot(currentTime, currentConns)
+		}
+		prevConns = currentConns
+		code = exitNotFound
+		if len(currentConns) > 0 {
+			code = exitOK
 		}
 	}
+	return code, nil
 }
 
 // --- 共通ロジック ---
-func processArgs(processNames, pids string) (targets []string, debugMode bool, monitorTarget string) {
+// signalContext は Ctrl+C (os.Interrupt) でキャンセルされる Context を返す。
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// runContext は Ctrl+C、または -duration/-until で指定した時刻にキャンセルされる Context を返す。
+func (o *options) runContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signalContext()
+	deadline, ok := o.deadline(time.Now())
+	if !ok {
+		return ctx, stop
+	}
+	infoLog.Printf(tr("終了予定: %s"), deadline.Format("2006-01-02 15:04:05"))
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+func processArgs(processNames, pids string) (targets []string, debugMode bool, monitorTarget string, err error) {
 	if processNames == "" && pids == "" {
-		fmt.Fprintln(os.Stderr, "エラー: -n または -p のどちらかを必ず指定してください。")
-		os.Exit(1)
+		return nil, false, "", usageError(tr("-n または -p のどちらかを必ず指定してください。"))
 	}
 	if processNames != "" {
 		targets = append(targets, strings.Split(processNames, ",")...)
@@ -165,172 +408,58 @@ func processArgs(processNames, pids string) (targets []string, debugMode bool, m
 		}
 	}
 	if debugMode {
-		monitorTarget = "全てのプロセス"
+		monitorTarget = tr("全てのプロセス")
 	} else {
 		monitorTarget = strings.Join(targets, ", ")
 	}
 	return
 }
 
-func setupLogging(outputFile string) {
-	if outputFile != "" {
-		file, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-		if err != nil {
-			log.Fatalf("エラー: 出力ファイルを開けませんでした: %v", err)
-		}
-		log.SetOutput(io.MultiWriter(os.Stdout, file))
+// openOutput は -o の指定に応じて、syslog、名前付きパイプ、またはローテーションするファイルを開く。
+func openOutput(target string, cfg rotateConfig) (io.WriteCloser, error) {
+	if isSyslogTarget(target) {
+		return newSyslogWriter(target)
 	}
-	log.SetFlags(0)
-}
-
-func getFilteredConnections(targets []string, debugMode bool) (map[string]TCPConnection, error) {
-	var size uint32
-	const TCP_TABLE_OWNER_PID_ALL = 5
-	ret, _, _ := procGetExtendedTcpTable.Call(0, uintptr(unsafe.Pointer(&size)), 0, windows.AF_INET, TCP_TABLE_OWNER_PID_ALL, 0)
-	if ret != uintptr(windows.ERROR_INSUFFICIENT_BUFFER) {
-		return nil, fmt.Errorf("GetExtendedTcpTable (size query) failed: %d", ret)
-	}
-	buf := make([]byte, size)
-	ret, _, _ = procGetExtendedTcpTable.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0, windows.AF_INET, TCP_TABLE_OWNER_PID_ALL, 0)
-	if ret != 0 {
-		return nil, fmt.Errorf("GetExtendedTcpTable failed: %d", ret)
-	}
-	table := (*MIB_TCPTABLE_OWNER_PID)(unsafe.Pointer(&buf[0]))
-	connections := make(map[string]TCPConnection)
-	rowSize := unsafe.Sizeof(MIB_TCPROW_OWNER_PID{})
-	for i := uint32(0); i < table.NumEntries; i++ {
-		row := (*MIB_TCPROW_OWNER_PID)(unsafe.Pointer(uintptr(unsafe.Pointer(&table.Table[0])) + uintptr(i)*rowSize))
-		processName, isMatch := getProcessIfTarget(row.OwningPid, targets, debugMode)
-		if isMatch {
-			conn := TCPConnection{
-				ProcessName: processName, PID: row.OwningPid,
-				LocalAddr: ipToString(row.LocalAddr), LocalPort: portToUint16(row.LocalPort),
-				RemoteAddr: ipToString(row.RemoteAddr), RemotePort: portToUint16(row.RemotePort),
-				State: getTCPStateName(row.State),
-			}
-			if conn.RemoteAddr == "0.0.0.0" {
-				continue
-			}
-			key := fmt.Sprintf("%s:%d -> %s:%d", conn.LocalAddr, conn.LocalPort, conn.RemoteAddr, conn.RemotePort)
-			connections[key] = conn
-		}
-	}
-	return connections, nil
-}
-
-func detectAndLogChanges(currentConns, prevConns map[string]TCPConnection) {
-	timestamp := time.Now().Format("15:04:05.000")
-	logHeaderPrinted := false
-	printLogHeader := func() {
-		if !logHeaderPrinted {
-			log.Printf("--- %s 状態変化 ---", timestamp)
-			logHeaderPrinted = true
-		}
-	}
-	for key, current := range currentConns {
-		prev, existed := prevConns[key]
-		if !existed {
-			printLogHeader()
-			log.Printf("[NEW] %s | Process: %s (PID: %d) | 状態: %s", key, current.ProcessName, current.PID, current.State)
-		} else if prev.State != current.State {
-			printLogHeader()
-			log.Printf("[CHANGE] %s | Process: %s (PID: %d) | 状態: %s -> %s", key, current.ProcessName, current.PID, prev.State, current.State)
-		}
-	}
-	for key, prev := range prevConns {
-		if _, exists := currentConns[key]; !exists {
-			printLogHeader()
-			log.Printf("[CLOSED] %s | Process: %s (PID: %d) | 最後の状態: %s", key, prev.ProcessName, prev.PID, prev.State)
-		}
+	if isPipeTarget(target) {
+		return newPipeWriter(target)
 	}
+	return newRotateWriter(target, cfg)
 }
 
-var processCache = make(map[uint32]string)
-
-func getProcessIfTarget(pid uint32, targets []string, debugMode bool) (string, bool) {
-	if debugMode {
-		return getProcessName(pid), true
-	}
-	pidStr := strconv.FormatUint(uint64(pid), 10)
-	for _, target := range targets {
-		if target == pidStr {
-			return getProcessName(pid), true
+// setupLogging はデータ (-o) と診断ログ (-log) の出力先を設定し、終了時にそれらを閉じる関数を返す。
+// -o を指定するとデータはその出力先だけに書き、開始時の表示などは診断ログとともにコンソールに残す。
+func (o *options) setupLogging() (func(), error) {
+	log.SetFlags(0)
+	log.SetOutput(os.Stdout)
+	infoOutput = log.Writer
+	var closers []io.Closer
+	closeAll := func() {
+		log.SetOutput(os.Stdout)
+		warnLog.SetOutput(os.Stderr)
+		infoOutput = log.Writer
+		for _, c := range closers {
+			c.Close()
 		}
+		o.closeExtraOutputs()
 	}
-	processName := getProcessName(pid)
-	for _, target := range targets {
-		if strings.EqualFold(processName, target) {
-			return getProcessName(pid), true
+	if o.logFile != "" {
+		file, err := openOutput(o.logFile, o.rotateConfig())
+		if err != nil {
+			return nil, fmt.Errorf(tr("-log の出力先を開けませんでした: %w"), err)
 		}
+		closers = append(closers, file)
+		warnLog.SetOutput(file)
 	}
-	return processName, false
-}
-
-func getProcessName(pid uint32) string {
-	name, ok := processCache[pid]
-	if ok {
-		return name
-	}
-
-	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
-	if err != nil {
-		return "N/A"
-	}
-	defer windows.CloseHandle(snapshot)
-
-	var entry windows.ProcessEntry32
-	entry.Size = uint32(unsafe.Sizeof(entry))
-
-	if err = windows.Process32First(snapshot, &entry); err != nil {
-		return "N/A"
-	}
-
-	for {
-		if entry.ProcessID == pid {
-			processName := windows.UTF16ToString(entry.ExeFile[:])
-			processCache[pid] = processName
-			return processName
-		}
-		if err = windows.Process32Next(snapshot, &entry); err != nil {
-			break
+	if primary, _ := o.outputTargets(); primary.path != "" && primary.path != stdoutTarget {
+		file, err := openOutput(primary.path, o.rotateConfig())
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf(tr("出力先を開けませんでした: %w"), err)
 		}
+		closers = append(closers, file)
+		log.SetOutput(file)
+		infoOutput = warnLog.Writer
+		verboseLog.Printf(tr("出力先: %s"), primary.path)
 	}
-
-	processCache[pid] = "N/A"
-	return "N/A"
-}
-
-func ipToString(ip uint32) string {
-	return fmt.Sprintf("%d.%d.%d.%d", byte(ip), byte(ip>>8), byte(ip>>16), byte(ip>>24))
-}
-func portToUint16(port uint32) uint16 { return uint16((port >> 8) | ((port & 0xFF) << 8)) }
-func getTCPStateName(state uint32) string {
-	switch state {
-	case 1:
-		return "CLOSED"
-	case 2:
-		return "LISTEN"
-	case 3:
-		return "SYN_SENT"
-	case 4:
-		return "SYN_RECV"
-	case 5:
-		return "ESTABLISHED"
-	case 6:
-		return "FIN_WAIT1"
-	case 7:
-		return "FIN_WAIT2"
-	case 8:
-		return "CLOSE_WAIT"
-	case 9:
-		return "CLOSING"
-	case 10:
-		return "LAST_ACK"
-	case 11:
-		return "TIME_WAIT"
-	case 12:
-		return "DELETE_TCB"
-	default:
-		return "UNKNOWN"
-	}
+	return closeAll, nil
 }
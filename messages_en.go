@@ -0,0 +1,573 @@
+package main
+
+// messagesEN は英語の対応表。キーは日本語の原文 (tr に渡す文字列)。
+var messagesEN = map[string]string{
+	"イベントを送る collector のアドレス (例: collector01:9479)":             "Address of the collector to send events to (e.g. collector01:9479)",
+	"collector のサーバー証明書を検証する CA 証明書 (PEM)。省略時は OS の証明書ストアを使う":   "CA certificate (PEM) used to verify the collector's server certificate. Defaults to the OS certificate store",
+	"collector のクライアント証明書の検証 (-client-ca) に使う、agent の証明書 (PEM)": "Agent certificate (PEM) presented to the collector for client certificate verification (-client-ca)",
+	"-cert の証明書の秘密鍵 (PEM)":                               "Private key (PEM) for the -cert certificate",
+	"collector の -token-file と同じ Bearer トークンを記載したファイル":   "File containing the same Bearer token as the collector's -token-file",
+	"TLS を使わずに送信する (検証環境用)":                              "Send without TLS (for test environments)",
+	"collector に送るホスト名 (省略時はコンピューター名)":                   "Host name reported to the collector (defaults to the computer name)",
+	"-collector で送信先を指定してください。":                          "Specify the destination with -collector.",
+	"コンピューター名を取得できません。-hostname で指定してください: %w":           "Cannot get the computer name. Specify it with -hostname: %w",
+	"-collector の指定が不正です: %w":                            "Invalid -collector: %w",
+	"送信先: %s (ホスト名: %s)":                                 "Destination: %s (host name: %s)",
+	"-cert と -key は両方指定してください":                           "Specify both -cert and -key",
+	"クライアント証明書を読み込めません: %w":                              "Cannot load the client certificate: %w",
+	"警告: collector への送信が追いつかないため、イベントを破棄して後で接続一覧を送り直します": "Warning: sending to the collector cannot keep up; events are dropped and the connection list will be resent later",
+	"エラー: collector への送信に失敗しました。%s 後に再接続します: %v":         "Error: failed to send to the collector. Reconnecting in %s: %v",
+	"collector に接続しました":                                  "Connected to the collector",
+	"エラー: collector への送信を完了できませんでした: %v":                 "Error: could not finish sending to the collector: %v",
+	"警告: collector への送信が %s 以内に完了しなかったため打ち切ります":          "Warning: sending to the collector did not finish within %s; giving up",
+	"送信が追いつかずに破棄したイベントのまとまり: %d 件":                       "Event batches dropped because sending could not keep up: %d",
+	"同時接続数が %d 件になり、しきい値 %d 件を超えました":                     "Concurrent connections reached %d, exceeding the threshold of %d",
+	"TLS のサーバー証明書 (PEM)。-tls-key と合わせて指定すると TLS で待ち受ける":  "TLS server certificate (PEM). Serves over TLS when given together with -tls-key",
+	"TLS のサーバー証明書の秘密鍵 (PEM)":                             "Private key (PEM) for the TLS server certificate",
+	"クライアント証明書を検証する CA 証明書 (PEM)。指定するとクライアント証明書の無い接続を拒否する (要 -tls-cert)":    "CA certificate (PEM) used to verify client certificates. Connections without a client certificate are rejected (requires -tls-cert)",
+	"Bearer トークンを記載したファイル。指定すると Authorization: Bearer か ?token= の無い要求を拒否する": "File containing a Bearer token. Requests without Authorization: Bearer or ?token= are rejected",
+	"接続を受け付ける接続元のアドレス (IP または CIDR のカンマ区切り, 例: 10.0.0.0/8,127.0.0.1)":       "Client addresses allowed to connect (comma-separated IPs or CIDRs, e.g. 10.0.0.0/8,127.0.0.1)",
+	"-tls-cert と -tls-key は両方指定してください":                                      "Specify both -tls-cert and -tls-key",
+	"サーバー証明書を読み込めません: %w":                                                   "Cannot load the server certificate: %w",
+	"-tls-cert と -tls-key でサーバー証明書を指定してください (TLS を使わない場合は -insecure)":       "Specify the server certificate with -tls-cert and -tls-key (use -insecure to run without TLS)",
+	"-client-ca には -tls-cert と -tls-key の指定が必要です":                           "-client-ca requires -tls-cert and -tls-key",
+	"警告: TLS を使わずにトークンで認証するため、トークンが平文で送られます":                                "Warning: authenticating with a token without TLS; the token is sent in clear text",
+	"-allow の指定が不正です: %w":                              "Invalid -allow: %w",
+	"PEM 形式の証明書がありません: %s":                             "No PEM certificate found: %s",
+	"トークンが空です: %s":                                     "Token is empty: %s",
+	"HTTP サーバーが停止しました: %w":                             "HTTP server stopped: %w",
+	"認証が必要です":                                          "Authentication required",
+	"警告: -allow に含まれない接続元からの接続を拒否しました: %s":             "Warning: rejected a connection from a client not included in -allow: %s",
+	"%s: %d 件目: process と remote は必須です":                "%s: entry %d: process and remote are required",
+	"%s: %d 件目: remote には IP、CIDR、または * を指定してください: %q": "%s: entry %d: remote must be an IP, a CIDR, or *: %q",
+	"ベースラインに無い接続先です (%s -> %s:%d)":                     "Destination not in the baseline (%s -> %s:%d)",
+	"-baseline を読み込めませんでした: %w":                        "Could not load -baseline: %w",
+	"使用方法: %s baseline record [オプション] -out <ファイル>":     "Usage: %s baseline record [options] -out <file>",
+	"記録したベースラインを書き出すファイル (YAML, 必須)":                   "File to write the recorded baseline to (YAML, required)",
+	"-out で書き出すファイルを指定してください。":                         "Specify the output file with -out.",
+	"ベースラインを書き出せませんでした: %w":                            "Could not write the baseline: %w",
+	"ベースラインを書き出しました: %s (%d 件)":                        "Wrote the baseline: %s (%d entries)",
+	"# baseline record で記録したベースライン。remote には CIDR や * (全て)、port には 0 (全て) も指定できる。\n": "# Baseline recorded by baseline record. remote also accepts CIDRs or * (any), and port accepts 0 (any).\n",
+	"接続情報の取得に失敗: %w":                "Failed to get connection information: %w",
+	"gRPC の待ち受けアドレス":                "gRPC listen address",
+	"TLS を使わずに待ち受ける (検証環境用)":        "Listen without TLS (for test environments)",
+	"-store で保存先のデータベースを指定してください。":  "Specify the destination database with -store.",
+	"-store のデータベースを開けませんでした: %w":   "Could not open the -store database: %w",
+	"%s で待ち受けできませんでした: %w":          "Could not listen on %s: %w",
+	"--- collector モード開始 ---":       "--- collector mode started ---",
+	"待ち受け: %s (保存先: %s, Ctrl+Cで停止)": "Listening: %s (store: %s, Ctrl+C to stop)",
+	"gRPC サーバーが停止しました: %w":          "gRPC server stopped: %w",
+	"--- collector モード終了 ---":       "--- collector mode finished ---",
+	"agent が切断しました: %s (受信: %d 回)":  "Agent disconnected: %s (received: %d times)",
+	"agent との接続が切れました: %s: %v":      "Lost connection to agent: %s: %v",
+	"agent が接続しました: %s":             "Agent connected: %s",
+	"%s: 不明な設定項目です: %q":             "%s: unknown setting: %q",
+	"入れ子の設定には対応していません":              "Nested settings are not supported",
+	"%s 以内に接続・切断されました":              "Connected and disconnected within %s",
+	"PID と送信元のローカルポートを無視し、プロセス名とリモートのアドレス・ポートで比較する (再起動をまたぐ比較用。同じ宛先への複数の接続は 1 件とみなす)": "Ignore the PID and the source local port and compare by process name and remote address/port (for comparisons across restarts; multiple connections to the same destination count as one)",
+	"記録は -format json の出力、または -store のデータベース。複数の時点を含む場合は最後の時点の接続一覧を使う。":               "A recording is -format json output or a -store database. If it contains several points in time, the connection list of the last one is used.",
+	"記録を読み込めませんでした: %w":                          "Could not load the recording: %w",
+	"接続一覧が記録されていません: %s":                         "No connection list recorded: %s",
+	"--- 比較: %s (%s, %d 件) -> %s (%s, %d 件) ---": "--- Comparing: %s (%s, %d) -> %s (%s, %d) ---",
+	"監視対象: %s": "Target: %s",
+	"追加: %d 件, 削除: %d 件, 状態の変化: %d 件": "Added: %d, removed: %d, state changes: %d",
+	"警告": "WARN",
+	"exporter/serve/collector で使う待ち受けアドレス (指定時はポートを開けるかとファイアウォールを確認する)": "Listen address used by exporter/serve/collector (when given, checks that the port can be opened and the firewall)",
+	"表示言語 (ja, en)": "Display language (ja, en)",
+	"接続の取得":         "Connection collection",
+	"iphlpapi.dll の呼び出しに失敗しています。セキュリティ製品による制限が無いか確認してください。": "Calling iphlpapi.dll failed. Check whether security software is restricting it.",
+	"権限": "Privileges",
+	"管理者として実行しています":  "Running as administrator",
+	"管理者として実行していません": "Not running as administrator",
+	"他のユーザーやサービスのプロセスの詳細、trace モード、-wake etw には管理者権限が必要です。「管理者として実行」したコンソールから起動してください。": "Details of processes owned by other users or services, trace mode and -wake etw require administrator privileges. Start from a console opened with \"Run as administrator\".",
+	"GetExtendedTcpTable/GetExtendedUdpTable を読み込めません。Windows Vista 以降で実行してください。":       "Cannot load GetExtendedTcpTable/GetExtendedUdpTable. Run on Windows Vista or later.",
+	"GetExtendedTcpTable/GetExtendedUdpTable を利用できます":                                "GetExtendedTcpTable/GetExtendedUdpTable are available",
+	"プロセス名の解決":                                                                       "Process name resolution",
+	"%d 件の接続、%d 個のプロセスのうち %d 個の名前を解決できませんでした":                                        "%[1]d connections; could not resolve the names of %[3]d of %[2]d processes",
+	"%d 件の接続、%d 個のプロセスの名前を解決できました":                                                   "%d connections; resolved the names of all %d processes",
+	"取得の間に終了したプロセスは N/A になります。時間をおいて再度確認してください。":                                     "Processes that exit during collection show as N/A. Wait a moment and check again.",
+	"保護されたプロセスは管理者権限が無いと名前を引けず N/A になります。管理者として実行してください。":                            "Protected processes show as N/A without administrator privileges. Run as administrator.",
+	"プロセスの所有者・パスの取得":                                                                 "Process owner and path lookup",
+	"%d 個のプロセスすべてで取得できました":                                                           "Succeeded for all %d processes",
+	"%d 個のプロセスのうち %d 個で取得できませんでした (%v)":                                              "Failed for %[2]d of %[1]d processes (%[3]v)",
+	"保護されたプロセス (PPL) は管理者でも取得できません。その場合の -owner, -cmdline は空になります。":                  "Protected processes (PPL) cannot be queried even by administrators. For them -owner and -cmdline are empty.",
+	"他のユーザーやサービスのプロセスは管理者権限が無いと取得できず、-owner, -cmdline が空になります。管理者として実行してください。":       "Processes of other users or services cannot be queried without administrator privileges, so -owner and -cmdline are empty. Run as administrator.",
+	"セッションを開始できません: %v":                                                              "Cannot start a session: %v",
+	"trace モードと -wake etw は使えません。ETW セッションの上限に達していないか (logman query -ets) 確認してください。": "Trace mode and -wake etw are unavailable. Check whether the ETW session limit has been reached (logman query -ets).",
+	"trace モードと -wake etw には管理者権限が必要です。管理者として実行してください。":                              "Trace mode and -wake etw require administrator privileges. Run as administrator.",
+	"セッションを開始できます (trace モード、-wake etw を利用できます)":                                     "A session can be started (trace mode and -wake etw are available)",
+	"待ち受け %s": "Listen %s",
+	"ポートが他のプロセスで使用中か、予約されています。%s listen -p 0 -lport <ポート> で使用中のプロセスを確認してください。": "The port is in use by another process or reserved. Check which process uses it with %s listen -p 0 -lport <port>.",
+	"ポートを開けます":              "The port can be opened",
+	"ファイアウォール":              "Firewall",
+	"状態を取得できません: %v":        "Cannot get the state: %v",
+	"無効です":                  "Disabled",
+	"実行ファイルのパスを取得できません: %v": "Cannot get the executable path: %v",
+	"有効で、この実行ファイルを対象とする受信の規則があります":                                                                          "Enabled, and an inbound rule for this executable exists",
+	"有効ですが、この実行ファイルを対象とする受信の規則が見つかりません":                                                                     "Enabled, but no inbound rule for this executable was found",
+	"他のホストから接続する場合は規則を追加してください: netsh advfirewall firewall add rule name=%q dir=in action=allow program=%q": "Add a rule to accept connections from other hosts: netsh advfirewall firewall add rule name=%q dir=in action=allow program=%q",
+	"サービス名ファイルを読み込めません: %w":                                                                                 "Cannot load the service name file: %w",
+	"終了コード %d":  "exit code %d",
+	"エラー: %v\n": "Error: %v\n",
+	"イベントログを開けませんでした: %w":      "Could not open the event log: %w",
+	"エラー: イベントログへの書き込みに失敗: %v": "Error: failed to write to the event log: %v",
+	"書き出すファイル (必須)":            "Output file (required)",
+	"書き出す形式 (ndjson, parquet)": "Output format (ndjson, parquet)",
+	"データベースから書き出す場合に、この時刻以降のイベントに限る (例: \"2006-01-02 14:00\")": "When exporting from a database, only events at or after this time (e.g. \"2006-01-02 14:00\")",
+	"データベースから書き出す場合に、この時刻より前のイベントに限る (書式は -from と同じ)":          "When exporting from a database, only events before this time (same format as -from)",
+	"データベースを省略すると、-duration/-until の間だけ監視した状態変化を書き出します。":       "Without a database, state changes observed during -duration/-until are exported.",
+	"-to-format には ndjson または parquet を指定してください: %q":           "-to-format must be ndjson or parquet: %q",
+	"書き出し先を開けませんでした: %w":                                       "Could not open the output: %w",
+	"書き出しに失敗しました: %w":                                          "Export failed: %w",
+	"%s に %d 件書き出しました。\n":                                      "Wrote %[2]d records to %[1]s.\n",
+	"データベースを指定しない場合は -duration か -until で監視する時間を指定してください":      "Without a database, specify how long to monitor with -duration or -until",
+	"不明な形式です: %q (指定可能: ndjson, parquet)":                      "Unknown format: %q (available: ndjson, parquet)",
+	"HTTP の待ち受けアドレス":                                           "HTTP listen address",
+	"--- エクスポーターモード開始 ---":                                     "--- exporter mode started ---",
+	"待ち受け: %s://%s/metrics (実行間隔: %d ミリ秒, Ctrl+Cで停止)":          "Listening: %s://%s/metrics (interval: %d ms, Ctrl+C to stop)",
+	"HTTP サーバーを開始できませんでした: %w":                                 "Could not start the HTTP server: %w",
+	"エラー: 接続情報の取得に失敗: %v":                                      "Error: failed to get connection information: %v",
+	"%s: 対応していないデータベースの種類です: %s (Country, City, ASN のいずれか)":    "%s: unsupported database type: %s (must be Country, City or ASN)",
+	"-geoip のデータベースを開けませんでした: %w":                              "Could not open the -geoip database: %w",
+	"国 %s が -alert-country に含まれます":                             "Country %s is listed in -alert-country",
+	"AS%d (%s) が -alert-asn に含まれます":                            "AS%d (%s) is listed in -alert-asn",
+	"接続先 %s の%s": "Destination %s: %s",
+	"-alert-country と -alert-asn には -geoip の指定が必要です。": "-alert-country and -alert-asn require -geoip.",
+	"-alert-asn の AS 番号が不正です: %q":                     "Invalid AS number in -alert-asn: %q",
+	"ポート %d":  "port %d",
+	"+%d 接続":  "+%d connected",
+	"-%d 切断":  "-%d disconnected",
+	"%d 状態変化": "%d state changes",
+	"-group-by の指定が不正です: %q (指定可能: %s)":      "Invalid -group-by: %q (available: %s)",
+	"不正な port の指定です: %d":                     "Invalid port: %d",
+	"-lang には %s のいずれかを指定してください: %q":         "-lang must be one of %s: %q",
+	"現在の待ち受けソケットを 1 回だけ表示して終了する":             "Show the current listening sockets once and exit",
+	"ループバック以外で待ち受けているソケットのみ表示する":             "Show only sockets listening on non-loopback addresses",
+	"--- 待ち受け監視モード開始 ---":                    "--- listen mode started ---",
+	"実行間隔: %d ミリ秒... (Ctrl+Cで停止)":            "Interval: %d ms... (Ctrl+C to stop)",
+	"全インターフェース":                              "all interfaces",
+	"ループバック":                                 "loopback",
+	"エラー: ローテーションしたファイルの圧縮に失敗: %v":           "Error: failed to compress the rotated file: %v",
+	"警告: ファイルを退避できませんでした。%s への追記を続けます: %v":   "Warning: could not rotate the file; continuing to append to %s: %v",
+	"サブコマンド:":                                "Subcommands:",
+	"\n終了コード:":                               "\nExit codes:",
+	"  0  正常終了":                              "  0  Success",
+	"  1  snapshot で、監視対象に一致する接続が無かった":       "  1  snapshot found no connections matching the target",
+	"  2  引数や設定ファイルの指定が不正":                   "  2  Invalid arguments or configuration file",
+	"  3  接続情報の取得や出力先の操作に失敗した":               "  3  Failed to get connection information or to operate on the output",
+	"  4  -exit-on-alert により ALERT で終了した":    "  4  Stopped on ALERT because of -exit-on-alert",
+	"例: %s monitor -n java.exe -i 200\n":     "Example: %s monitor -n java.exe -i 200\n",
+	"--- 監視モード開始 ---":                        "--- monitor mode started ---",
+	"%s... (Ctrl+Cで停止)":                      "%s... (Ctrl+C to stop)",
+	"スナップショットを 1 回だけ表示して終了する (-count 1 と同じ)": "Show a snapshot once and exit (same as -count 1)",
+	"指定した回数だけスナップショットを表示して終了する (0で無制限)":      "Show the given number of snapshots and exit (0 for unlimited)",
+	"前回から接続が変化した場合だけ一覧を表示し、変化が無ければ「変化なし」の 1 行だけを表示する": "Show the list only when connections changed since the last one; otherwise show a single \"no change\" line",
+	"--- スナップショットモード開始 ---": "--- snapshot mode started ---",
+	"終了予定: %s": "Scheduled to stop at: %s",
+	"-n または -p のどちらかを必ず指定してください。": "Specify either -n or -p.",
+	"全てのプロセス":          "all processes",
+	"出力先を開けませんでした: %w": "Could not open the output: %w",
+	"監視するプロセス名 (カンマ区切り, java* のようなワイルドカード可, svc:Dnscache でサービスを指定)": "Process names to monitor (comma-separated, wildcards such as java* allowed, svc:Dnscache for a service)",
+	"監視するPID (カンマ区切り, '0'でデバッグモード)":                                 "PIDs to monitor (comma-separated, '0' for debug mode)",
+	"実行間隔(ミリ秒)":                   "Interval (milliseconds)",
+	"IPv4 の接続のみ監視":                "Monitor IPv4 connections only",
+	"IPv6 の接続のみ監視":                "Monitor IPv6 connections only",
+	"IPv4 と IPv6 の両方を監視 (既定)":     "Monitor both IPv4 and IPv6 (default)",
+	"監視するプロトコル (tcp,udp のカンマ区切り)": "Protocols to monitor (comma-separated tcp,udp)",
+	"表示言語 (ja, en)。未指定の場合は環境変数 LANG に従い、対応していない言語なら ja":                                                                "Display language (ja, en). Defaults to the LANG environment variable, or ja if that language is not supported",
+	"text 形式の表で、接続とプロセス名の列をこの表示幅で切り詰める (0で切り詰めない。列幅は内容に合わせて自動で調整する)":                                                   "In text tables, truncate the connection and process name columns to this display width (0 disables; column widths adjust to the content)",
+	"リモートアドレスの種類で絞り込む (loopback, link-local, private, public, multicast, unspecified のカンマ区切り, 例: public でマシンの外への接続のみ)": "Filter by remote address scope (comma-separated loopback, link-local, private, public, multicast, unspecified; e.g. public for connections leaving the machine)",
+	"リモートアドレスで絞り込む (IP または CIDR のカンマ区切り, 例: 10.0.0.0/8,192.168.1.5)":                                                   "Filter by remote address (comma-separated IPs or CIDRs, e.g. 10.0.0.0/8,192.168.1.5)",
+	"ローカルポートで絞り込む (例: 80,443,8000-8100)":                                                                               "Filter by local port (e.g. 80,443,8000-8100)",
+	"リモートポートで絞り込む (例: 1433,5432,8000-8100)":                                                                            "Filter by remote port (e.g. 1433,5432,8000-8100)",
+	"TCP の状態で絞り込む (例: ESTABLISHED,CLOSE_WAIT)":                                                                         "Filter by TCP state (e.g. ESTABLISHED,CLOSE_WAIT)",
+	"Windows コンテナのプロセスのみ監視する (コンテナ ID の前方一致, カンマ区切り, * で全てのコンテナ, 要管理者権限)":                                              "Monitor only processes in Windows containers (container ID prefixes, comma-separated, * for all containers, requires administrator)",
+	"除外するプロセス名 (カンマ区切り, ワイルドカード可)":                                                                                     "Process names to exclude (comma-separated, wildcards allowed)",
+	"除外するPID (カンマ区切り)":                                                                                 "PIDs to exclude (comma-separated)",
+	"除外するリモートアドレス (IP または CIDR のカンマ区切り)":                                                               "Remote addresses to exclude (comma-separated IPs or CIDRs)",
+	"除外するリモートポート (例: 80,443,8000-8100)":                                                                "Remote ports to exclude (e.g. 80,443,8000-8100)",
+	"-n/-xn の各要素を正規表現として扱う (大文字小文字を区別しない)":                                                             "Treat each -n/-xn element as a regular expression (case-insensitive)",
+	"対象プロセスの子孫プロセスも監視する":                                                                               "Also monitor descendant processes of the target",
+	"stats で TCPv4 のパフォーマンスカウンタ (再送/秒、リセット数、接続失敗数) も出力する":                                              "In stats, also output TCPv4 performance counters (retransmits/sec, resets, failed connections)",
+	"TCP 接続ごとの通信量・再送数・RTT を表示する (要管理者権限)":                                                              "Show traffic, retransmits and RTT per TCP connection (requires administrator)",
+	"TCP 接続を所有するモジュールを表示する (svchost.exe の場合はサービス名, 例: Dnscache)":                                       "Show the module owning each TCP connection (service name for svchost.exe, e.g. Dnscache)",
+	"リモートアドレスをホスト名に逆引きして表示する (非同期)":                                                                    "Reverse-resolve remote addresses to host names (asynchronous)",
+	"逆引き結果をキャッシュする期間の上限":                                                                               "Maximum time to cache reverse lookup results",
+	"リモートポートのサービス名 (例: 443→https) を表示する":                                                               "Show service names for remote ports (e.g. 443→https)",
+	"サービス名の対応表を上書きするファイル (YAML, 例: 1433: mssql)。指定すると -services も有効になる":                                "File overriding the service name table (YAML, e.g. 1433: mssql). Also enables -services",
+	"ローカルアドレスのネットワークインターフェース名を表示する":                                                                    "Show the network interface name of the local address",
+	"各 PID を初めて出力するときに、実行ファイルのパスとコマンドラインを表示する":                                                         "Show the executable path and command line the first time each PID is output",
+	"プロセスを所有するユーザー名を表示する (他ユーザーのプロセスは要管理者権限)":                                                          "Show the user owning the process (requires administrator for other users' processes)",
+	"管理者として実行していない場合は、警告の代わりにエラーで終了する":                                                                 "Exit with an error instead of a warning when not running as administrator",
+	"プロセスが属する Windows コンテナの ID と、ジョブオブジェクトに属しているかを表示する (-container 指定時は常に有効)":                          "Show the Windows container ID of the process and whether it belongs to a job object (always enabled with -container)",
+	"svchost.exe などサービスをホストするプロセスに、実行中のサービス名を併記する (-n svc:名前 を指定した場合は常に有効)":                            "Append running service names to processes hosting services such as svchost.exe (always enabled with -n svc:name)",
+	"TIME_WAIT/DELETE_TCB への変化を 1 件の CLOSED にまとめ、その後の変化と消滅は出力しない":                                      "Merge a change to TIME_WAIT/DELETE_TCB into one CLOSED and suppress later changes and removal",
+	"最初に検出した時点で TIME_WAIT/DELETE_TCB だった接続のイベントを出力しない":                                                 "Do not output events for connections already in TIME_WAIT/DELETE_TCB when first seen",
+	"この期間内に現れて消えた接続を NEW と CLOSED の代わりに 1 件の FLAP で出力する (例: 2s, NEW はこの期間だけ遅れて出力される)":                  "Output connections that appear and disappear within this period as one FLAP instead of NEW and CLOSED (e.g. 2s; NEW is delayed by this period)",
+	"monitor の状態変化を集約して件数で出力する単位 (remote-host, remote-port, process)":                                  "Unit for aggregating monitor state changes into counts (remote-host, remote-port, process)",
+	"baseline record で記録したファイル。ベースラインに無い (プロセス, リモートアドレス, リモートポート) への接続を ANOMALY イベントとして出力する":          "File recorded by baseline record. Connections to (process, remote address, remote port) not in the baseline are output as ANOMALY events",
+	"MaxMind の GeoLite2/GeoIP2 データベース (Country/City と ASN の .mmdb, カンマ区切り)。パブリックなリモートアドレスに国と AS を表示する": "MaxMind GeoLite2/GeoIP2 databases (Country/City and ASN .mmdb, comma-separated). Shows country and AS for public remote addresses",
+	"この国 (ISO 3166-1 の 2 文字, カンマ区切り, 例: CN,RU) への新しい接続で ALERT イベントを出力する (要 -geoip)":                    "Output an ALERT event for new connections to these countries (ISO 3166-1 two-letter codes, comma-separated, e.g. CN,RU; requires -geoip)",
+	"この AS 番号 (カンマ区切り, 例: AS13335,15169) への新しい接続で ALERT イベントを出力する (要 -geoip)":                          "Output an ALERT event for new connections to these AS numbers (comma-separated, e.g. AS13335,15169; requires -geoip)",
+	"プロセスの同時接続数がこの値を超えたら ALERT イベントを出力する (0で無効)":                                                       "Output an ALERT event when a process's concurrent connections exceed this value (0 disables)",
+	"接続・切断の頻度を計算する直近の期間":                                                                               "Recent period over which connect/disconnect rates are calculated",
+	"プロセスごとの接続・切断の頻度を RATE イベントとして出力する間隔 (例: 30s, 0で出力しない)":                                            "Interval at which per-process connect/disconnect rates are output as RATE events (e.g. 30s, 0 disables)",
+	"-scan-hosts と -scan-ports で接続先を数える直近の期間":                                                          "Recent period over which -scan-hosts and -scan-ports count destinations",
+	"1 つのプロセスが -scan-window の間にこの数を超える異なるリモートホストへ接続したら SCAN イベントを出力する (0で無効)":                          "Output a SCAN event when one process connects to more distinct remote hosts than this within -scan-window (0 disables)",
+	"1 つのプロセスが -scan-window の間にこの数を超える異なるリモートポートへ接続したら SCAN イベントを出力する (0で無効)":                          "Output a SCAN event when one process connects to more distinct remote ports than this within -scan-window (0 disables)",
+	"全プロセスの TCP が使用する一時ポート (動的ポートの範囲) の割合がこの値 (%) を超えたら ALERT イベントを出力する (0で無効, 例: 80)":                 "Output an ALERT event when the share of ephemeral ports (the dynamic port range) used by TCP across all processes exceeds this value (%) (0 disables, e.g. 80)",
+	"プロセスの新規接続が 1 秒あたりこの値を超えたら ALERT イベントを出力する (0で無効)":                                                 "Output an ALERT event when a process's new connections per second exceed this value (0 disables)",
+	"ALERT が発生したら監視を終了する (終了コード 4)":                                                                    "Stop monitoring when an ALERT occurs (exit code 4)",
+	"イベントを JSON で POST する Webhook の URL (Slack/Teams の Incoming Webhook など)":                           "Webhook URL to POST events to as JSON (e.g. Slack/Teams Incoming Webhook)",
+	"Webhook の 1 分あたりの送信数の上限 (0で無制限)":                                                                  "Maximum webhook posts per minute (0 for unlimited)",
+	"状態変化を指定したソース名で Windows のアプリケーションイベントログにも書き込む (ID: NEW=101, CHANGE=102, CLOSED=103, REBOUND=104)":  "Also write state changes to the Windows Application event log under this source name (ID: NEW=101, CHANGE=102, CLOSED=103, REBOUND=104)",
+	"イベントと一定間隔ごとの接続一覧を保存する SQLite のデータベースファイル (query サブコマンドで検索できる)":                                    "SQLite database file to save events and periodic connection lists to (searchable with the query subcommand)",
+	"-store に接続一覧を保存する間隔":                                                                              "Interval at which connection lists are saved to -store",
+	"monitor で接続一覧を取得する契機 (poll: -i ごと, etw: ETW の接続・切断通知ごと。要管理者権限)":                                   "What triggers monitor to collect connections (poll: every -i, etw: on each ETW connect/disconnect notification; requires administrator)",
+	"monitor の取得間隔を変化の量に応じて -i-min〜-i-max の範囲で調整する (-i は初期値)":                                          "Adjust the monitor interval within -i-min to -i-max depending on the amount of change (-i is the initial value)",
+	"-adaptive の最短の取得間隔 (ミリ秒)":                                                                         "Shortest -adaptive interval (milliseconds)",
+	"-adaptive の最長の取得間隔 (ミリ秒)":                                                                         "Longest -adaptive interval (milliseconds)",
+	"指定した時間が経過したら終了する (例: 30m, 0で無制限)":                                                                 "Exit after the given time has elapsed (e.g. 30m, 0 for unlimited)",
+	"指定した時刻に終了する (例: 18:00, \"2006-01-02 18:00\")。過ぎている時刻は翌日とみなす":                                      "Exit at the given time (e.g. 18:00, \"2006-01-02 18:00\"). A time already passed means the next day",
+	"設定ファイル (YAML)。コマンドラインの指定が優先されます":                                                                  "Configuration file (YAML). Command-line flags take precedence",
+	"出力ファイルをローテーションするサイズ (MB, 0で無効)":                                                                   "Size at which the output file is rotated (MB, 0 disables)",
+	"出力ファイルをローテーションする経過時間 (例: 24h, 0で無効)":                                                              "Age at which the output file is rotated (e.g. 24h, 0 disables)",
+	"残しておくローテーション済みファイルの数 (0で全て残す)":                                                                    "Number of rotated files to keep (0 keeps all)",
+	"ローテーション済みファイルを gzip 圧縮する":                                                                         "Compress rotated files with gzip",
+	"タイムスタンプの形式 (rfc3339, datetime, time, epoch-ms)。未指定時は text が time、その他は RFC 3339":                   "Timestamp format (rfc3339, datetime, time, epoch-ms). Defaults to time for text and RFC 3339 otherwise",
+	"タイムスタンプを UTC で出力する (既定はローカル時刻)":                                                                   "Output timestamps in UTC (default is local time)",
+	"csv 形式で出力する列 (カンマ区切り, 順序も反映)":                                                                     "Columns to output in csv format (comma-separated, order is preserved)",
+	"設定ファイルを読み込めませんでした: %w":                                                                            "Could not load the configuration file: %w",
+	"-ts の指定が不正です: %w":                    "Invalid -ts: %w",
+	"-until の指定が不正です: %w":                 "Invalid -until: %w",
+	"-proto には tcp または udp を指定してください: %q": "-proto must be tcp or udp: %q",
+	"%q (例: 18:00, 2006-01-02 18:00)":     "%q (e.g. 18:00, 2006-01-02 18:00)",
+	" (子孫プロセスを含む)":                        " (including descendant processes)",
+	" (リモート: %s)":                         " (remote: %s)",
+	"-scope の指定が不正です: %w":                 "Invalid -scope: %w",
+	" (リモートの種類: %s)":                      " (remote scope: %s)",
+	" (ローカルポート: %s)":                      " (local port: %s)",
+	" (リモートポート: %s)":                      " (remote port: %s)",
+	"-state の指定が不正です: %w":                 "Invalid -state: %w",
+	" (状態: %s)":                           " (state: %s)",
+	" (コンテナ: %s)":                         " (container: %s)",
+	" (除外: %s)":                           " (excluded: %s)",
+	"-%s の正規表現が不正です: %w":                  "Invalid regular expression in -%s: %w",
+	"-%s の指定が不正です: %w":                    "Invalid -%s: %w",
+	"不明な出力形式です: %q":                       "Unknown output format: %q",
+	"不明なタイムスタンプ形式です: %q (指定可能: rfc3339, datetime, time, epoch-ms)": "Unknown timestamp format: %q (available: rfc3339, datetime, time, epoch-ms)",
+	" | 観測元: %s":  " | Observed by: %s",
+	" | ホスト: %s":  " | Host: %s",
+	" | サービス: %s": " | Service: %s",
+	" | 国: %s":    " | Country: %s",
+	" | 受信: %s 送信: %s 再送: %d RTT: %s":             " | Recv: %s Sent: %s Retrans: %d RTT: %s",
+	" | モジュール: %s":                                " | Module: %s",
+	" | コンテナ: %s":                                 " | Container: %s",
+	" | ジョブオブジェクト内":                               " | In job object",
+	" | ユーザー: %s":                                 " | User: %s",
+	" | パス: %s":                                   " | Path: %s",
+	" | コマンドライン: %s":                              " | Command line: %s",
+	"--- %s 状態変化 ---":                             "--- %s state changes ---",
+	"[NEW] %s | Process: %s (PID: %d) | 状態: %s%s": "[NEW] %s | Process: %s (PID: %d) | State: %s%s",
+	"[CHANGE] %s | Process: %s (PID: %d) | 状態: %s -> %s | 継続時間: %s%s":      "[CHANGE] %s | Process: %s (PID: %d) | State: %s -> %s | Duration: %s%s",
+	"[CLOSED] %s | Process: %s (PID: %d) | 最後の状態: %s | 継続時間: %s%s":         "[CLOSED] %s | Process: %s (PID: %d) | Last state: %s | Duration: %s%s",
+	"[FLAP] %s | Process: %s (PID: %d) | 最後の状態: %s | %s%s":                 "[FLAP] %s | Process: %s (PID: %d) | Last state: %s | %s%s",
+	"--- %s 監視対象に一致する接続は見つかりません ---":                                       "--- %s No connections match the target ---",
+	"--- %s 監視対象の接続 (%d件) ---\n":                                           "--- %s Target connections (%d) ---\n",
+	"%s | Process: %s (PID: %-*d) | 状態: %-*s%s\n":                          "%s | Process: %s (PID: %-*d) | State: %-*s%s\n",
+	"--- %s 変化なし (%d件) ---":                                                "--- %s No change (%d) ---",
+	"--- %s プロセス別統計 (%dプロセス) ---\n":                                        "--- %s Per-process statistics (%d processes) ---\n",
+	"%s (PID: %-5d) | 接続: %-4d | リモートホスト: %-3d | 新規: +%d / 終了: -%d | %s\n": "%s (PID: %-5d) | Conns: %-4d | Remote hosts: %-3d | New: +%d / Closed: -%d | %s\n",
+	"TCPv4 | 再送: %.1f/秒 | リセット: +%d (累計 %d) | 接続失敗: +%d (累計 %d)\n":         "TCPv4 | Retransmits: %.1f/s | Resets: +%d (total %d) | Failed connections: +%d (total %d)\n",
+	"エラー: JSON への変換に失敗: %v":                                                "Error: failed to convert to JSON: %v",
+	"不明な列名です: %q (指定可能: %s)":                                               "Unknown column: %q (available: %s)",
+	"%d件": "%d",
+	"警告: 動的ポートの範囲を取得できないため、既定の %d-%d とみなします: %v": "Warning: cannot get the dynamic port range; assuming the default %d-%d: %v",
+	"エラー: 一時ポートの使用状況を取得できません: %v":                "Error: cannot get ephemeral port usage: %v",
+	"%s %d 件": "%s (%d)",
+	"一時ポート (%d-%d) の使用数が %d / %d (%d%%) になり、しきい値 %d%% を超えました (TIME_WAIT: %d 件": "Ephemeral ports (%d-%d) in use reached %d / %d (%d%%), exceeding the threshold of %d%% (TIME_WAIT: %d",
+	", 使用数の多いプロセス: %s": ", top users: %s",
+	"一時ポート":            "ephemeral ports",
+	"-port-warn には 1 から 100 までの使用率 (%) を指定してください。":                     "-port-warn must be a usage percentage (%) from 1 to 100.",
+	"他のユーザーやサービスのプロセスは、プロセス名が N/A になる場合があります":                          "Processes of other users or services may show N/A as the process name",
+	"-owner: 他のユーザーのプロセスの所有者を取得できません":                                  "-owner: cannot get the owner of other users' processes",
+	"-cmdline: 他のユーザーのプロセスのパスとコマンドラインを取得できません":                         "-cmdline: cannot get the path and command line of other users' processes",
+	"-estats: 通信量・再送数・RTT を取得できません":                                    "-estats: cannot get traffic, retransmits and RTT",
+	"-container, -job: コンテナとジョブオブジェクトの情報を取得できません":                      "-container, -job: cannot get container and job object information",
+	"-wake etw: ETW セッションを開始できません":                                     "-wake etw: cannot start an ETW session",
+	"管理者として実行していません (-require-admin): %s":                              "Not running as administrator (-require-admin): %s",
+	"警告: 管理者として実行していないため、次の機能が制限されます (doctor で詳しく確認できます):":             "Warning: not running as administrator, so the following are limited (see doctor for details):",
+	"この時刻以降のイベントを表示する (例: 14:00, \"2006-01-02 14:00\")。日付を省略すると今日とみなす": "Show events at or after this time (e.g. 14:00, \"2006-01-02 14:00\"). Today is assumed if the date is omitted",
+	"この時刻より前のイベントを表示する (書式は -from と同じ)":                                "Show events before this time (same format as -from)",
+	"リモートのアドレスまたはホスト名で絞り込む (カンマ区切り, *.example.com のようなワイルドカード可)":       "Filter by remote address or host name (comma-separated, wildcards such as *.example.com allowed)",
+	"イベント種別で絞り込む (NEW, CHANGE, CLOSED, ALERT のカンマ区切り)":                 "Filter by event type (comma-separated NEW, CHANGE, CLOSED, ALERT)",
+	"collector で保存した送信元ホスト名で絞り込む (カンマ区切り, web* のようなワイルドカード可)":          "Filter by source host name saved by collector (comma-separated, wildcards such as web* allowed)",
+	"イベントの代わりに、指定した時刻の直前に保存した接続一覧を表示する (書式は -from と同じ)":                "Instead of events, show the connection list saved just before the given time (same format as -from)",
+	"-store で検索するデータベースを指定してください。":                                     "Specify the database to search with -store.",
+	"検索に失敗しました: %w":                                                                       "Search failed: %w",
+	"-p の指定が不正です: %q":                                                                     "Invalid -p: %q",
+	"-%s の指定が不正です: %q (例: 14:00, 2006-01-02 14:00)":                                       "Invalid -%s: %q (e.g. 14:00, 2006-01-02 14:00)",
+	"%s 以前に保存された接続一覧はありません":                                                               "No connection list saved before %s",
+	"新規: %.1f/秒, 終了: %.1f/秒 (直近 %s)":                                                      "New: %.1f/s, closed: %.1f/s (last %s)",
+	"新規接続が %.1f/秒 (直近 %s で %d 件) になり、しきい値 %g/秒 を超えました":                                    "New connections reached %.1f/s (%[3]d in the last %[2]s), exceeding the threshold of %[4]g/s",
+	"--- 再生モード開始 ---":                                                                     "--- replay mode started ---",
+	"記録: %s (%d 件)":                                                                       "Recording: %s (%d frames)",
+	"タイムスタンプを解析できません: %q (-ts time の記録は再生できません)":                                          "Cannot parse timestamp: %q (recordings made with -ts time cannot be replayed)",
+	"直近 %s に %d 個の異なるリモートホストへ接続しました (しきい値 %d)":                                            "Connected to %[2]d distinct remote hosts in the last %[1]s (threshold %[3]d)",
+	"直近 %s に %d 個の異なるリモートポートへ接続しました (しきい値 %d)":                                            "Connected to %[2]d distinct remote ports in the last %[1]s (threshold %[3]d)",
+	"%s (ホスト: %d, ポート: %d)":                                                               "%s (hosts: %d, ports: %d)",
+	"Web ダッシュボード (/) を提供しない":                                                              "Do not serve the web dashboard (/)",
+	"gRPC API (obustatpb/obustat.proto の Monitor サービス) の待ち受けアドレス (例: :9480, 省略時は提供しない)":   "gRPC API listen address (Monitor service in obustatpb/obustat.proto) (e.g. :9480; not served if omitted)",
+	"--- HTTP API モード開始 ---":                                                              "--- HTTP API mode started ---",
+	"待ち受け: %s://%s/ (ダッシュボード), /connections, /events, /metrics (実行間隔: %d ミリ秒, Ctrl+Cで停止)": "Listening: %s://%s/ (dashboard), /connections, /events, /metrics (interval: %d ms, Ctrl+C to stop)",
+	"gRPC API を開始できませんでした: %w":                                                            "Could not start the gRPC API: %w",
+	"不正な pid の指定です: %q":                                                                   "Invalid pid: %q",
+	"不正な port の指定です: %q":                                                                  "Invalid port: %q",
+	"ストリーミングに対応していません":                                                                    "Streaming is not supported",
+	"  install    monitor のオプションを引き継いでサービスを登録します (自動起動)。":                                 "  install    Register the service, carrying over the monitor options (automatic start).",
+	"  uninstall  サービスを削除します。":                                                            "  uninstall  Remove the service.",
+	"  run        サービスとして監視を実行します (サービスマネージャーから呼ばれます)。":                                   "  run        Run monitoring as a service (called by the service manager).",
+	"サービス名":                         "Service name",
+	"service %s に失敗しました: %w":        "service %s failed: %w",
+	"サービス %s は既に登録されています":           "Service %s is already installed",
+	"サービス %s を登録しました。\n":            "Installed service %s.\n",
+	"イベントログのソース %s を登録できませんでした: %w": "Could not register event log source %s: %w",
+	"イベントログのソース %s を登録しました。\n":      "Registered event log source %s.\n",
+	"サービス %s が見つかりません: %w":          "Service %s not found: %w",
+	"サービス %s を削除しました。\n":            "Removed service %s.\n",
+	"イベントログのソース %s を削除できませんでした: %w": "Could not remove event log source %s: %w",
+	"イベントログのソース %s を削除しました。\n":      "Removed event log source %s.\n",
+	"エラー: %v": "Error: %v",
+	"--- 監視を一時停止しました ---": "--- Monitoring paused ---",
+	"--- 監視を再開しました ---":   "--- Monitoring resumed ---",
+	"%s: 不正なポート番号です: %q":  "%s: invalid port number: %q",
+	"%s: 不正なプロトコルです: %q":  "%s: invalid protocol: %q",
+	"--- 統計モード開始 ---":     "--- stats mode started ---",
+	"警告: パフォーマンスカウンタを取得できないため、-perf を無視します: %v":    "Warning: cannot get performance counters; ignoring -perf: %v",
+	"警告: パフォーマンスカウンタの取得に失敗: %v":                    "Warning: failed to read performance counters: %v",
+	"エラー: イベントの保存に失敗: %v":                          "Error: failed to save event: %v",
+	"エラー: スナップショットの保存に失敗: %v":                      "Error: failed to save snapshot: %v",
+	"--- 監視終了サマリー ---":                             "--- Monitoring summary ---",
+	"監視時間: %s (取得回数: %d)":                          "Duration: %s (collections: %d)",
+	"イベント総数: %d (NEW: %d, CHANGE: %d, CLOSED: %d)": "Total events: %d (NEW: %d, CHANGE: %d, CLOSED: %d)",
+	"FLAP: %d 件":                        "FLAP: %d",
+	"ALERT: %d 回":                       "ALERT: %d",
+	"ANOMALY: %d 件":                     "ANOMALY: %d",
+	"SCAN: %d 回":                        "SCAN: %d",
+	"最大同時接続数: 該当なし":                     "Peak concurrent connections: none",
+	"最大同時接続数 (プロセス別):":                  "Peak concurrent connections (per process):",
+	"CA 証明書を読み込めません: %s":                "Cannot load the CA certificate: %s",
+	"不明なスキームです: %q":                     "Unknown scheme: %q",
+	"syslog サーバーのホスト名がありません: %q":        "syslog server host name missing: %q",
+	"不明な facility です: %q":               "Unknown facility: %q",
+	"コンソールを対話モードにできませんでした: %w":          "Could not switch the console to interactive mode: %w",
+	"%s の件数":                            "%s count",
+	"プロセス名":                             "process name",
+	"接続数":                               "connections",
+	" (逆順)":                             " (reversed)",
+	"ObuStat top - %s  監視対象: %s":        "ObuStat top - %s  Target: %s",
+	"プロセス: %d  接続: %d  並べ替え: %s":        "Processes: %d  Connections: %d  Sort: %s",
+	"[c]接続数 [s]状態 [n]プロセス名 [r]逆順 [q]終了": "[c]count [s]state [n]process name [r]reverse [q]quit",
+	"プロセス":                              "Process",
+	"合計":                                "Total",
+	"その他":                               "Other",
+	"リモート":                              "Remote",
+	"新規":                                "New",
+	"終了":                                "Closed",
+	"ETW セッションを開始できませんでした (管理者として実行してください): %w":                 "Could not start the ETW session (run as administrator): %w",
+	"--- トレースモード開始 (ETW: Microsoft-Windows-Kernel-Network) ---": "--- trace mode started (ETW: Microsoft-Windows-Kernel-Network) ---",
+	"出力間隔: %d ミリ秒... (Ctrl+Cで停止)":                               "Output interval: %d ms... (Ctrl+C to stop)",
+	"ETW セッションが終了しました: %w":                                      "ETW session ended: %w",
+	"警告: 出力が追いつかず %d 件のイベントを破棄しました":                             "Warning: output could not keep up; dropped %d events",
+	"-adaptive は -wake poll の場合のみ指定できます。":                       "-adaptive can only be used with -wake poll.",
+	"警告: ETW による変化の通知を開始できないため、一定間隔で取得します: %v":                  "Warning: cannot start ETW change notifications; collecting at a fixed interval: %v",
+	"-wake には poll または etw を指定してください: %q":                       "-wake must be poll or etw: %q",
+	"実行間隔: %d ミリ秒":                                              "Interval: %d ms",
+	"取得契機: ETW の接続・切断通知 (最短 %d ミリ秒, 通知が無い間は %d ミリ秒ごと)":          "Trigger: ETW connect/disconnect notifications (at most every %d ms, every %d ms when there are none)",
+	"警告: ETW のセッションが終了したため、一定間隔で取得します: %v":                      "Warning: the ETW session ended; collecting at a fixed interval: %v",
+	"-i-min と -i-max の指定が不正です: %d, %d":                          "Invalid -i-min and -i-max: %d, %d",
+	"実行間隔: %d〜%d ミリ秒 (変化の量に応じて調整)":                              "Interval: %d-%d ms (adjusted to the amount of change)",
+	"エラー: Webhook の送信待ちがあふれたため、%d 件のイベントの通知を破棄しました":             "Error: the webhook send queue overflowed; dropped notifications for %d events",
+	"エラー: Webhook の送信数が上限 (%d 件/分) を超えたため、%d 件のイベントの通知を破棄しました":  "Error: webhook posts exceeded the limit (%d/min); dropped notifications for %d events",
+	"エラー: Webhook の送信に失敗: %v":                                   "Error: webhook post failed: %v",
+	"GeoIP データベースを開きました: %s (%s, %s)":                           "Opened GeoIP database: %s (%s, %s)",
+	"取得: %d 件, 差分: %d 件 (%s)":                                   "Collected: %d, diff: %d (%s)",
+	"出力先: %s":                                                   "Output: %s",
+	"開始時の表示やサマリーを出力せず、標準出力にはデータ (イベントなど) だけを書く":                 "Do not print startup banners or summaries; write only data (events, etc.) to stdout",
+	"内部の動作の詳細を標準エラーに出力する":                                       "Write internal diagnostics to stderr",
+	"-v に加えて、取得ごとの件数と所要時間などを標準エラーに出力する":                         "In addition to -v, write per-collection counts and timings to stderr",
+	"設定ファイルを読み込みました: %s":                                        "Loaded configuration file: %s",
+	"取得間隔を変更: %s -> %s (変化: %d 件)":                              "Interval changed: %s -> %s (changes: %d)",
+	"Webhook に %d 件のイベントを通知しました":                                "Sent %d events to the webhook",
+	"Webhook の送信に失敗したため、%s 後に再送します: %v":                         "Webhook post failed; retrying in %s: %v",
+	"-log の出力先を開けませんでした: %w":                                    "Could not open the -log output: %w",
+	"警告・エラーと -v/-vv の診断ログの出力先 (-o と同じ形式, 省略時は標準エラー)。-o で指定したデータの出力先とは分けて書く":                                                                                                                             "Destination for warnings, errors and -v/-vv diagnostics (same forms as -o; defaults to stderr). Written separately from the -o data output",
+	"monitor で変化が無くても、この間隔でバージョン・稼働時間・接続数を HEARTBEAT イベントとして出力する (例: 1m, 0で出力しない)":                                                                                                                      "In monitor, emit a HEARTBEAT event with version, uptime and connection count at this interval even when nothing changes (e.g. 1m, 0 to disable)",
+	"Webhook に通知するイベント種別 (NEW, CHANGE, CLOSED, REBOUND, FLAP, RATE, ALERT, ANOMALY, SCAN, DROPPED, HEARTBEAT, CONFIG_RELOADED, CAPTURE_START, CAPTURE_STOP, PROCESS_START, PROCESS_EXIT のカンマ区切り)":       "Event types to send to the webhook (comma-separated NEW, CHANGE, CLOSED, REBOUND, FLAP, RATE, ALERT, ANOMALY, SCAN, DROPPED, HEARTBEAT, CONFIG_RELOADED, CAPTURE_START, CAPTURE_STOP, PROCESS_START, PROCESS_EXIT)",
+	"-notify に不明なイベント種別があります: %q (指定可能: NEW, CHANGE, CLOSED, REBOUND, FLAP, RATE, ALERT, ANOMALY, SCAN, DROPPED, HEARTBEAT, CONFIG_RELOADED, CAPTURE_START, CAPTURE_STOP, PROCESS_START, PROCESS_EXIT)": "-notify contains an unknown event type: %q (available: NEW, CHANGE, CLOSED, REBOUND, FLAP, RATE, ALERT, ANOMALY, SCAN, DROPPED, HEARTBEAT, CONFIG_RELOADED, CAPTURE_START, CAPTURE_STOP, PROCESS_START, PROCESS_EXIT)",
+	"エラー: 取得中にパニックが発生したため、この回の結果を破棄して監視を続けます (%d 回連続): %v\n%s":                                                                                                                                          "error: panic during polling; discarding this poll and continuing (%d in a row): %v\n%s",
+	"%d 回連続でパニックが発生したため監視を終了します: %v":                                                                                                                                                                    "stopping monitoring after %d consecutive panics: %v",
+	"プロセス情報のキャッシュ (%s): %d 件, ヒット %d, ミス %d, 削除 (期限切れ %d, 上限 %d, 終了 %d)":                                                                                                                                "process info cache (%s): %d entries, %d hits, %d misses, removed (%d expired, %d over limit, %d exited)",
+	"-cmdline/-owner/-job で取得したプロセスごとの情報をキャッシュする期間 (0で無期限。終了したプロセスの情報は期限前でも破棄する)":                                                                                                                       "How long to cache per-process information fetched for -cmdline/-owner/-job (0 for no expiry; entries for exited processes are dropped earlier)",
+	"データの出力ファイル名、名前付きパイプ (\\\\.\\pipe\\名前)、syslog の送信先 (syslog://host:514, syslog+tcp://host:601, syslog+tls://host:6514)、Webhook の URL、または - (標準出力)。指定するとデータは標準出力に書かない。繰り返し指定でき、形式=出力先 で出力先ごとに形式を選べる (例: -o json=events.jsonl -o text=-)": "Data output file name, named pipe (\\\\.\\pipe\\name), syslog destination (syslog://host:514, syslog+tcp://host:601, syslog+tls://host:6514), webhook URL, or - (stdout). When given, data is not written to stdout. May be repeated; use format=target to choose a format per output (e.g. -o json=events.jsonl -o text=-)",
+	"追加の出力先: %s (%s)":                          "Additional output: %s (%s)",
+	"出力形式 (text, json, csv, logfmt, template)": "Output format (text, json, csv, logfmt, template)",
+	"-format template で 1 件ごとに出力する Go の text/template (例: \"{{.TS}} {{.Event}} {{.ProcessName}} {{.RemoteAddr}}\", @ファイル名 でファイルから読み込む)": "Go text/template written once per record with -format template (e.g. \"{{.TS}} {{.Event}} {{.ProcessName}} {{.RemoteAddr}}\"; @file reads it from a file)",
+	"-format template には -template でテンプレートを指定してください。":                                                                                   "-format template requires a template given with -template.",
+	"-template のファイルを読み込めません: %w":                                                                                                       "Cannot read the -template file: %w",
+	"-template を解析できません: %w":                                                                                                            "Cannot parse -template: %w",
+	"エラー: テンプレートの実行に失敗: %v":                                                                                                             "Error: failed to execute the template: %v",
+	"出力する接続の項目 (json のキー名のカンマ区切り, 例: process,pid,state)。含まれない項目は空にして出力する":                                                               "Connection fields to output (comma-separated json key names, e.g. process,pid,state). Other fields are emptied",
+	"ハッシュに置き換えて出力する項目 (例: remote_addr,remote_host,user)。イベントのキーと説明に含まれる値も置き換える":                                                         "Fields to replace with a hash (e.g. remote_addr,remote_host,user). The values are also replaced in event keys and details",
+	"-redact のハッシュ (HMAC-SHA256) の鍵。未指定の場合は実行ごとに作るため、実行をまたいで同じ値を突き合わせるには指定する":                                                           "Key for the -redact hash (HMAC-SHA256). Generated per run when omitted; set it to correlate values across runs",
+	"-fields に不明な項目があります: %q (指定可能: %s)":                                                                                                "-fields contains an unknown field: %q (available: %s)",
+	"-redact に指定できない項目です: %q (文字列の項目のみ指定可能)":                                                                                            "Field cannot be used with -redact: %q (only string fields are allowed)",
+	"プロファイル: %s":             "Profiles: %s",
+	" | プロファイル: %s":          " | Profiles: %s",
+	"不明な項目です: %q (指定可能: %s)": "Unknown setting: %q (available: %s)",
+	"警告: 設定ファイルを再読み込みできないため、現在の設定で監視を続けます: %v":                              "Warning: could not reload the config file; continuing with the current settings: %v",
+	"設定ファイルを再読み込みしました。監視対象: %s":                                             "Reloaded the config file. Monitoring: %s",
+	"%s は設定ファイルの再読み込みに対応していません":                                             "%s does not support reloading the configuration file",
+	"monitor で設定ファイル (-c) の変更を監視し、監視対象とフィルターを再起動せずに反映する (出力の設定は再起動が必要)":     "Watch the config file (-c) in monitor and apply target and filter changes without restarting (output settings require a restart)",
+	"警告: 接続の状態を保存できませんでした: %v":                                              "Warning: could not save the connection state: %v",
+	"monitor の終了時に接続の一覧を保存し、次の起動時に読み込むファイル (再起動で既存の接続が NEW として出力されないようにする)": "File to save the connection list to when monitor exits and load on the next start (so existing connections are not reported as NEW after a restart)",
+	"-state-file の保存からこの時間を過ぎていたら読み込まない (0で無制限)":                            "Do not load -state-file if it was saved longer ago than this (0 for no limit)",
+	"保存された接続の状態は %s 前のものため使用しません: %s":                                       "Ignoring the saved connection state because it is %s old: %s",
+	"保存された接続の状態を読み込みました: %d 件 (%s)":                                         "Loaded the saved connection state: %d connections (%s)",
+	"--- 有効な設定 ---":  "--- Effective settings ---",
+	"  プロファイル: %s\n": "  Profiles: %s\n",
+	"  監視対象: %s\n":   "  Target: %s\n",
+	"--- 検証結果 ---":   "--- Validation ---",
+	"[警告] -n/-p の %q に一致する実行中のプロセスがありません (プロセスの起動を待つ場合は問題ありません)\n": "[WARN] No running process matches %q in -n/-p (fine if you are waiting for the process to start)\n",
+	"[OK] 設定に問題はありません": "[OK] The settings are valid",
+	"フラグと設定ファイルを解析して有効な設定を表示し、フィルターと出力の指定を検証して終了する (監視は始めない)":         "Parse the flags and config file, print the effective settings, validate the filters and outputs, and exit without monitoring",
+	"[REBOUND] %s | Process: %s (PID: %d) | 状態: %s | 所有プロセスの変化: %s%s": "[REBOUND] %s | Process: %s (PID: %d) | State: %s | Owner changed: %s%s",
+	"REBOUND: %d 件": "REBOUND: %d",
+	"待ち受け (LISTEN) の TCP ソケットも対象にする (既定ではリモートアドレスが未指定のソケットは出力しない)": "Also include listening (LISTEN) TCP sockets (by default sockets without a remote address are not shown)",
+	"バインドしただけで接続していない TCP ソケット (リモートアドレスが未指定で LISTEN 以外) も対象にする":   "Also include TCP sockets that are bound but not connected (no remote address and not LISTEN)",
+	" (待ち受けを含む)":              " (including listening sockets)",
+	" (未接続のソケットを含む)":          " (including unconnected sockets)",
+	"ループバック接続の一覧を取得できません: %v": "Could not get the loopback connections: %v",
+	"ループバックの TCP 接続に相手側のプロセスを付けて「クライアント -> サーバー」として表示し、両端が出力に含まれる場合は 1 件にまとめる": "Show loopback TCP connections as \"client -> server\" with the peer process, and merge the two rows into one when both are in the output",
+	" | ループバック: %s":                     " | Loopback: %s",
+	"-history のポートの指定が不正です: %q":         "Invalid port in -history: %q",
+	"条件に一致する接続の記録はありません":                "No recorded connections match the conditions",
+	"リモート: %s, 最初: %s, 最後: %s, 接続数: %d": "Remote: %s, first: %s, last: %s, connections: %d",
+	"イベントの代わりに、指定したリモート (ホスト:ポート、ホストのみも可) へ接続したことのあるプロセスと、最初と最後に接続した時刻を表示する": "Instead of events, show every process that connected to this remote (host:port or host only) with the first and last time it did",
+	"-history と -at は同時に指定できません。": "-history and -at cannot be used together.",
+	"接続の変化のイベントを、接続のキーで選んだ一部だけ出力する (例: 1/10 で 10 件に 1 件の接続。選んだ接続は NEW から CLOSED まで全て出力する)": "Output connection change events only for a subset of connections chosen by key (e.g. 1/10 for one in ten connections; a chosen connection is output from NEW through CLOSED)",
+	"1 秒あたりに出力する接続の変化のイベントの上限。超えた分は捨てて DROPPED イベントで件数を出力する (0で無制限)":                       "Maximum connection change events output per second; the excess is discarded and reported as a DROPPED event (0 for no limit)",
+	"-sample の指定が不正です: %q (例: 1/10)":           "Invalid -sample: %q (e.g. 1/10)",
+	"1 秒あたり %d 件の上限を超えたため、%d 件のイベントを出力しませんでした": "Skipped %[2]d events because the limit of %[1]d per second was exceeded",
+	"-sample 1/%d で出力しなかったイベント: %d 件":          "Events skipped by -sample 1/%d: %d",
+	"-max-eps の上限を超えて出力しなかったイベント: %d 件":        "Events skipped over the -max-eps limit: %d",
+	"バージョン: %s, 稼働時間: %s, 接続数: %d, %s":         "Version: %s, uptime: %s, connections: %d, %s",
+	"自身の使用量: %s": "Own usage: %s",
+	"ツール自身のメモリ使用量 (ワーキングセット, MB) の上限。超えたらプロセス情報と名前解決のキャッシュを破棄する (0で無制限)": "Memory limit (working set, MB) for the tool itself; when exceeded, the process information and name resolution caches are discarded (0 for no limit)",
+	"警告: メモリ使用量 (%s) が -max-rss を超えたため、キャッシュを破棄しました":                     "Warning: memory usage (%s) exceeded -max-rss; discarded the caches",
+	"接続の状態変化 (新規、変化、終了) を監視します。":                                         "Monitor connection state changes (new, changed, closed).",
+	"指定した間隔で、現在の全接続状態をスナップショットとして表示します。":                                 "Show a snapshot of all current connections at the given interval.",
+	"待ち受け (LISTEN) ソケットをインターフェースとともに一覧表示し、増減を監視します。":                     "List listening (LISTEN) sockets with their interfaces and watch for changes.",
+	"指定した間隔で、プロセス別の集計 (状態別件数、リモートホスト数、新規/終了数) を表示します。":                   "Show per-process statistics (counts by state, remote hosts, new/closed) at the given interval.",
+	"プロセス別の接続数を画面上で更新しながら表示します (接続数・状態・プロセス名で並べ替え)。":                     "Show per-process connection counts on a live screen (sort by count, state or process name).",
+	"ETW でカーネルの接続・切断イベントを受け取り、ポーリング間隔より短い接続も記録します (要管理者権限)。":             "Receive kernel connect/disconnect events via ETW and record even connections shorter than the polling interval (requires administrator).",
+	"Prometheus 形式のメトリクスを HTTP (/metrics) で公開します。":                       "Expose Prometheus metrics over HTTP (/metrics).",
+	"HTTP API (/connections, /events) で現在の接続と状態変化 (NDJSON/SSE) を提供します。":  "Provide current connections and state changes (NDJSON/SSE) over an HTTP API (/connections, /events).",
+	"-store で保存したイベントを、プロセス・リモート・時間帯で検索します。":                             "Search events saved with -store by process, remote and time range.",
+	"記録 (json 出力または -store のデータベース) を別のフィルタで再生し、差分と ALERT を判定し直します。":     "Replay a recording (json output or a -store database) with different filters and re-evaluate diffs and ALERTs.",
+	"-store のデータベース、または一定時間の監視結果を NDJSON/Parquet に書き出します。":               "Export a -store database, or the results of monitoring for a fixed time, to NDJSON/Parquet.",
+	"保存した 2 つの接続一覧 (json 出力または -store) を比べ、追加・削除・状態の変化を表示します。":           "Compare two saved connection lists (json output or -store) and show additions, removals and state changes.",
+	"record: 一定期間の監視で通常の接続先を記録し、monitor -baseline で使うファイルに書き出します。":       "record: record the usual destinations over a period and write a file for monitor -baseline.",
+	"monitor と同じく監視し、イベントを gRPC (TLS) で collector へ送ります。":                "Monitor like monitor and send events to a collector over gRPC (TLS).",
+	"複数のホストの agent からイベントを受け取り、ホスト名を付けて 1 つの -store に保存します。":             "Receive events from agents on multiple hosts and save them, tagged with the host name, to one -store.",
+	"権限、API、ETW、ファイアウォールなど動作に必要な条件を確認し、問題があれば対処方法を表示します。":                "Check prerequisites such as privileges, APIs, ETW and the firewall, and show how to fix problems.",
+	"Windows サービスとして monitor を登録・削除・実行します (install/uninstall/run)。":      "Install, uninstall or run monitor as a Windows service (install/uninstall/run).",
+	"<記録ファイル (.json または .db)>":            "<recording (.json or .db)>",
+	"[データベース (.db)]":                      "[database (.db)]",
+	"<変更前の記録> <変更後の記録>":                   "<before recording> <after recording>",
+	"全体、またはサブコマンドの使用方法を表示します。":            "Show the overall usage or the usage of a subcommand.",
+	"[サブコマンド]":                            "[subcommand]",
+	"PowerShell または bash の補完スクリプトを出力します。": "Print a completion script for PowerShell or bash.",
+	"PowerShell: プロファイルに「obustat completion powershell | Out-String | Invoke-Expression」を追加します。\nbash: ~/.bashrc に「source <(obustat completion bash)」を追加します。": "PowerShell: add \"obustat completion powershell | Out-String | Invoke-Expression\" to your profile.\nbash: add \"source <(obustat completion bash)\" to ~/.bashrc.",
+	"使用方法: %s [-lang ja|en] <サブコマンド> [オプション]\n\n":           "Usage: %s [-lang ja|en] <subcommand> [options]\n\n",
+	"\n各サブコマンドのオプションは %s help <サブコマンド> (または -h) で確認できます。\n": "\nRun %s help <subcommand> (or a subcommand with -h) to see its options.\n",
+	" [オプション]":       " [options]",
+	"使用方法: %s %s\n":  "Usage: %s %s\n",
+	"\n%s のオプション:\n": "\n%s options:\n",
+	"\n共通のオプション (全てのサブコマンドで同じ意味):": "\nCommon options (same meaning in every subcommand):",
+	" (既定値: %s)":          " (default %s)",
+	"不明なサブコマンドです: %s":     "Unknown subcommand: %s",
+	"不明なサブコマンドです: %s\n\n": "Unknown subcommand: %s\n\n",
+	"補完に対応していないシェルです: %s (指定可能: powershell, bash)": "Unsupported shell for completion: %s (available: powershell, bash)",
+	"JSON で出力する": "Print as JSON",
+	"  コミット:   %s (未コミットの変更を含む)\n": "  Commit:     %s (with uncommitted changes)\n",
+	"  コミット:   %s\n": "  Commit:     %s\n",
+	"  ビルド日時: %s\n":  "  Built:      %s\n",
+	"バージョン、コミット、ビルド日時と、Go・Windows のバージョンを表示します。":                                                    "Show the version, commit, build date, and the Go and Windows versions.",
+	"-o-dir と -o (Webhook 以外) は同時に指定できません。":                                                         "-o-dir cannot be combined with -o (other than webhooks).",
+	"-o-name には -o-dir からの相対的なファイル名を指定してください。":                                                      "-o-name must be a file name relative to -o-dir.",
+	"出力先: %s (スナップショットごとのファイル)":                                                                     "Output: %s (one file per snapshot)",
+	"エラー: スナップショットのファイルを書き込めませんでした: %v":                                                             "Error: could not write the snapshot file: %v",
+	"スナップショットを 1 回ごとに、このディレクトリの別のファイルへ書く (-o の代わりに指定する)":                                            "Write each snapshot to its own file in this directory (instead of -o)",
+	"-o-dir に書くファイル名。Go の時刻の書式で、拡張子は -format に合わせて付ける (例: 2006-01-02/snapshot-1504 で日付ごとのディレクトリ)":   "File name to write in -o-dir, as a Go time layout; the extension follows -format (e.g. 2006-01-02/snapshot-1504 for a directory per day)",
+	"エラー: 圧縮した出力の書き出しに失敗: %v":                                                                       "Error: failed to flush the compressed output: %v",
+	"拡張子が .gz または .zst の出力先 (例: -o events.jsonl.gz) は圧縮して書き、この間隔で圧縮中のデータを書き出す (0で終了時のみ)":            "Outputs ending in .gz or .zst (e.g. -o events.jsonl.gz) are written compressed; pending data is flushed at this interval (0 flushes only on exit)",
+	"-flight-recorder を指定して動作中の monitor に、メモリに保持しているイベントの書き出しを通知します。":                               "Tells a running monitor started with -flight-recorder to dump the events it holds in memory.",
+	"-flight-recorder と -o (Webhook 以外) は同時に指定できません。":                                               "-flight-recorder cannot be combined with -o (other than a webhook).",
+	"-flight-recorder と -o-dir は同時に指定できません。":                                                        "-flight-recorder cannot be combined with -o-dir.",
+	"フライトレコーダー: 直近 %s のイベントをメモリに保持し、flight-dump で %s へ書き出します":                                       "Flight recorder: keeping the last %s of events in memory; flight-dump writes them to %s",
+	"エラー: フライトレコーダーの記録を書き出せませんでした: %v":                                                              "Error: failed to dump the flight recorder: %v",
+	"フライトレコーダーの記録を書き出しました: %s (%d 件, 契機: %s)":                                                       "Dumped the flight recorder: %s (%d events, trigger: %s)",
+	"警告: 別のフライトレコーダーが動作しています。flight-dump はいずれか一方だけを書き出します":                                          "Warning: another flight recorder is running. flight-dump will dump only one of them",
+	"フライトレコーダーの通知を待ち受けられませんでした: %w":                                                                 "Could not wait for flight recorder dump requests: %w",
+	"フライトレコーダー: http://%s/dump への POST で書き出します":                                                     "Flight recorder: POST to http://%s/dump to dump",
+	"ループバック以外からの要求は受け付けません":                                                                         "Requests are accepted only from loopback",
+	"フライトレコーダーに書き出しを通知しました。":                                                                        "Asked the flight recorder to dump.",
+	"-flight-recorder を指定した monitor が動作していません":                                                      "No monitor with -flight-recorder is running",
+	"フライトレコーダーに通知できませんでした: %w":                                                                      "Could not notify the flight recorder: %w",
+	"monitor のイベントをファイルに書かず、直近のこの期間だけメモリに保持する。flight-dump などの契機で -flight-dir へ書き出す (例: 10m, 0で無効)":  "Keep monitor events in memory for this long instead of writing them out; dump them to -flight-dir on flight-dump and other triggers (e.g. 10m, 0 disables)",
+	"-flight-recorder の記録を書き出すディレクトリ":                                                               "Directory the -flight-recorder dumps are written to",
+	"-flight-recorder の記録を POST /dump で書き出す HTTP の待ち受けアドレス (例: 127.0.0.1:9478, ループバックからの要求のみ受け付ける)": "HTTP listen address where POST /dump dumps the -flight-recorder (e.g. 127.0.0.1:9478, loopback requests only)",
+	"ALERT が発生したら -flight-recorder の記録を書き出す":                                                        "Dump the -flight-recorder when an ALERT is raised",
+	"-capture-interval の指定が不正です: %d":                                                                "Invalid -capture-interval: %d",
+	"-capture-when の状態が不明です: %q (指定可能: %s)":                                                         "Unknown state in -capture-when: %q (available: %s)",
+	"-capture-when の条件が不正です: %q (process:名前, host:アドレス, state:状態>件数 のいずれか)":                         "Invalid -capture-when condition: %q (one of process:NAME, host:ADDRESS, state:STATE>COUNT)",
+	"詳細な記録を開始しました (条件: %s)":                                                                         "Started detailed capture (condition: %s)",
+	"条件: %s, 取得間隔: %d ミリ秒":                                                                          "Condition: %s, interval: %d ms",
+	"詳細な記録を停止しました":                                                                                  "Stopped detailed capture",
+	"条件を満たさなくなってから %s 経過, 記録した期間: %s":                                                               "%s since the conditions stopped matching, captured for: %s",
+	"monitor で接続の変化を出力するのを、この条件のいずれかを満たしている間に限る (カンマ区切り, process:名前, host:IP または CIDR, state:状態>件数。例: state:CLOSE_WAIT>50)": "Output monitor connection changes only while any of these conditions holds (comma-separated process:NAME, host:IP or CIDR, state:STATE>COUNT; e.g. state:CLOSE_WAIT>50)",
+	"-capture-when の条件を満たしている間の取得間隔 (ミリ秒)":                           "Polling interval while a -capture-when condition holds (milliseconds)",
+	"-capture-when のどの条件も満たさない状態がこの期間続いたら記録をやめる":                     "Stop capturing once no -capture-when condition has held for this long",
+	"警告: プロセスの開始・終了を取得できないため、PROCESS_START/PROCESS_EXIT を出力しません: %v": "Warning: cannot watch process starts and exits, so PROCESS_START/PROCESS_EXIT will not be output: %v",
+	"警告: ETW のセッションが終了したため、プロセスの開始・終了を出力しません: %v":                    "Warning: the ETW session ended, so process starts and exits will no longer be output: %v",
+	"警告: 処理が追いつかず、プロセスの開始・終了を %d 件破棄しました":                            "Warning: dropped %d process start/exit events because processing fell behind",
+	"親 PID: %d":  "Parent PID: %d",
+	"終了コード: %d":  "Exit code: %d",
+	", 稼働時間: %s": ", ran for: %s",
+	"monitor で監視対象のプロセスの開始・終了を PROCESS_START/PROCESS_EXIT イベントとして出力する (要管理者権限)": "Output starts and exits of monitored processes as PROCESS_START/PROCESS_EXIT events in monitor (requires administrator)",
+	" | ハンドル: %d / スレッド: %d":            " | Handles: %d / Threads: %d",
+	"stats で監視対象のプロセスのハンドル数とスレッド数も出力する": "Also output the handle and thread counts of monitored processes in stats",
+	"取得中にパニックが発生しました (%d 回連続): %v":      "A panic occurred during collection (%d in a row): %v",
+	"ダッシュボードは /?token=<トークン> で開いてください":  "Open the dashboard at /?token=<token>",
+	"%s に失敗しました: %s":                    "%s failed: %s",
+	"%s (%d 回試行)":                       "%s (%d attempts)",
+	"ポート番号が不正です: %q":                    "Invalid port: %q",
+	"ポート範囲が不正です: %q":                    "Invalid port range: %q",
+	"不明な状態です: %q (指定可能: %s)":            "Unknown state: %q (available: %s)",
+	"不明なアドレスの種類です: %q (指定可能: %s)":       "Unknown address scope: %q (available: %s)",
+	"netsh の出力から動的ポートの範囲を読み取れません: %q":   "Cannot read the dynamic port range from the netsh output: %q",
+	"テーブルの拡大が続いたため取得できません":              "the table kept growing",
+	"PDH エラー 0x%08X":                    "PDH error 0x%08X",
+	"プロセス名・アドレス・ポートで絞り込み":               "Filter by process name, address, or port",
+	"全ての状態":         "All states",
+	"接続中...":        "Connecting...",
+	"プロトコル":         "Protocol",
+	"ローカル":          "Local",
+	"ホスト":           "Host",
+	"状態":            "State",
+	"一致する接続はありません":  "No matching connections",
+	"取得に失敗しました: %s": "Failed to fetch: %s",
+	"接続済み":          "Connected",
+	"切断されました (再接続中...)": "Disconnected (reconnecting...)",
+}
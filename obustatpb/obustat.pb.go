@@ -0,0 +1,855 @@
+// go-ObuStat の gRPC API。agent と collector の間の送信 (Collector) と、
+// serve モードで現在の接続と状態変化を提供する API (Monitor) からなる。
+// Go のコードは obustatpb ディレクトリで buf generate を実行して生成する。
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: obustat.proto
+
+package obustatpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Connection は 1 つの TCP/UDP 接続。時刻は Unix 時刻 (ミリ秒) で、取得できない場合は 0。
+type Connection struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Protocol       string                 `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Process        string                 `protobuf:"bytes,2,opt,name=process,proto3" json:"process,omitempty"`
+	Pid            uint32                 `protobuf:"varint,3,opt,name=pid,proto3" json:"pid,omitempty"`
+	ProcessStartMs int64                  `protobuf:"varint,4,opt,name=process_start_ms,json=processStartMs,proto3" json:"process_start_ms,omitempty"`
+	LocalAddr      string                 `protobuf:"bytes,5,opt,name=local_addr,json=localAddr,proto3" json:"local_addr,omitempty"`
+	LocalPort      uint32                 `protobuf:"varint,6,opt,name=local_port,json=localPort,proto3" json:"local_port,omitempty"`
+	LocalInterface string                 `protobuf:"bytes,7,opt,name=local_interface,json=localInterface,proto3" json:"local_interface,omitempty"`
+	RemoteAddr     string                 `protobuf:"bytes,8,opt,name=remote_addr,json=remoteAddr,proto3" json:"remote_addr,omitempty"`
+	RemotePort     uint32                 `protobuf:"varint,9,opt,name=remote_port,json=remotePort,proto3" json:"remote_port,omitempty"`
+	RemoteHost     string                 `protobuf:"bytes,10,opt,name=remote_host,json=remoteHost,proto3" json:"remote_host,omitempty"`
+	RemoteService  string                 `protobuf:"bytes,11,opt,name=remote_service,json=remoteService,proto3" json:"remote_service,omitempty"`
+	State          string                 `protobuf:"bytes,12,opt,name=state,proto3" json:"state,omitempty"`
+	FirstSeenMs    int64                  `protobuf:"varint,13,opt,name=first_seen_ms,json=firstSeenMs,proto3" json:"first_seen_ms,omitempty"`
+	ImagePath      string                 `protobuf:"bytes,14,opt,name=image_path,json=imagePath,proto3" json:"image_path,omitempty"`
+	CommandLine    string                 `protobuf:"bytes,15,opt,name=command_line,json=commandLine,proto3" json:"command_line,omitempty"`
+	User           string                 `protobuf:"bytes,16,opt,name=user,proto3" json:"user,omitempty"`
+	Module         string                 `protobuf:"bytes,17,opt,name=module,proto3" json:"module,omitempty"`
+	Services       []string               `protobuf:"bytes,18,rep,name=services,proto3" json:"services,omitempty"`
+	Container      string                 `protobuf:"bytes,19,opt,name=container,proto3" json:"container,omitempty"`
+	InJob          bool                   `protobuf:"varint,20,opt,name=in_job,json=inJob,proto3" json:"in_job,omitempty"`
+	Country        string                 `protobuf:"bytes,21,opt,name=country,proto3" json:"country,omitempty"`
+	Asn            uint32                 `protobuf:"varint,22,opt,name=asn,proto3" json:"asn,omitempty"`
+	AsOrg          string                 `protobuf:"bytes,23,opt,name=as_org,json=asOrg,proto3" json:"as_org,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Connection) Reset() {
+	*x = Connection{}
+	mi := &file_obustat_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Connection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Connection) ProtoMessage() {}
+
+func (x *Connection) ProtoReflect() protoreflect.Message {
+	mi := &file_obustat_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Connection.ProtoReflect.Descriptor instead.
+func (*Connection) Descriptor() ([]byte, []int) {
+	return file_obustat_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Connection) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *Connection) GetProcess() string {
+	if x != nil {
+		return x.Process
+	}
+	return ""
+}
+
+func (x *Connection) GetPid() uint32 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *Connection) GetProcessStartMs() int64 {
+	if x != nil {
+		return x.ProcessStartMs
+	}
+	return 0
+}
+
+func (x *Connection) GetLocalAddr() string {
+	if x != nil {
+		return x.LocalAddr
+	}
+	return ""
+}
+
+func (x *Connection) GetLocalPort() uint32 {
+	if x != nil {
+		return x.LocalPort
+	}
+	return 0
+}
+
+func (x *Connection) GetLocalInterface() string {
+	if x != nil {
+		return x.LocalInterface
+	}
+	return ""
+}
+
+func (x *Connection) GetRemoteAddr() string {
+	if x != nil {
+		return x.RemoteAddr
+	}
+	return ""
+}
+
+func (x *Connection) GetRemotePort() uint32 {
+	if x != nil {
+		return x.RemotePort
+	}
+	return 0
+}
+
+func (x *Connection) GetRemoteHost() string {
+	if x != nil {
+		return x.RemoteHost
+	}
+	return ""
+}
+
+func (x *Connection) GetRemoteService() string {
+	if x != nil {
+		return x.RemoteService
+	}
+	return ""
+}
+
+func (x *Connection) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Connection) GetFirstSeenMs() int64 {
+	if x != nil {
+		return x.FirstSeenMs
+	}
+	return 0
+}
+
+func (x *Connection) GetImagePath() string {
+	if x != nil {
+		return x.ImagePath
+	}
+	return ""
+}
+
+func (x *Connection) GetCommandLine() string {
+	if x != nil {
+		return x.CommandLine
+	}
+	return ""
+}
+
+func (x *Connection) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *Connection) GetModule() string {
+	if x != nil {
+		return x.Module
+	}
+	return ""
+}
+
+func (x *Connection) GetServices() []string {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *Connection) GetContainer() string {
+	if x != nil {
+		return x.Container
+	}
+	return ""
+}
+
+func (x *Connection) GetInJob() bool {
+	if x != nil {
+		return x.InJob
+	}
+	return false
+}
+
+func (x *Connection) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *Connection) GetAsn() uint32 {
+	if x != nil {
+		return x.Asn
+	}
+	return 0
+}
+
+func (x *Connection) GetAsOrg() string {
+	if x != nil {
+		return x.AsOrg
+	}
+	return ""
+}
+
+// Event は接続の状態変化、または ALERT などの集計のイベント。
+type Event struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // NEW, CHANGE, CLOSED, FLAP, GROUP, RATE, ALERT
+	Connection    *Connection            `protobuf:"bytes,2,opt,name=connection,proto3" json:"connection,omitempty"`
+	PrevState     string                 `protobuf:"bytes,3,opt,name=prev_state,json=prevState,proto3" json:"prev_state,omitempty"`
+	Count         int32                  `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	Detail        string                 `protobuf:"bytes,5,opt,name=detail,proto3" json:"detail,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_obustat_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_obustat_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_obustat_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetConnection() *Connection {
+	if x != nil {
+		return x.Connection
+	}
+	return nil
+}
+
+func (x *Event) GetPrevState() string {
+	if x != nil {
+		return x.PrevState
+	}
+	return ""
+}
+
+func (x *Event) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Event) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+// AgentBatch は agent が 1 回の取得ごとに送るイベントのまとまり。
+// 接続した直後は、collector が現在の接続一覧を持てるよう is_snapshot を true にして接続一覧を送る。
+type AgentBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Host          string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	TimestampMs   int64                  `protobuf:"varint,2,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	Events        []*Event               `protobuf:"bytes,3,rep,name=events,proto3" json:"events,omitempty"`
+	IsSnapshot    bool                   `protobuf:"varint,4,opt,name=is_snapshot,json=isSnapshot,proto3" json:"is_snapshot,omitempty"`
+	Snapshot      []*Connection          `protobuf:"bytes,5,rep,name=snapshot,proto3" json:"snapshot,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentBatch) Reset() {
+	*x = AgentBatch{}
+	mi := &file_obustat_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentBatch) ProtoMessage() {}
+
+func (x *AgentBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_obustat_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentBatch.ProtoReflect.Descriptor instead.
+func (*AgentBatch) Descriptor() ([]byte, []int) {
+	return file_obustat_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AgentBatch) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *AgentBatch) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+func (x *AgentBatch) GetEvents() []*Event {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+func (x *AgentBatch) GetIsSnapshot() bool {
+	if x != nil {
+		return x.IsSnapshot
+	}
+	return false
+}
+
+func (x *AgentBatch) GetSnapshot() []*Connection {
+	if x != nil {
+		return x.Snapshot
+	}
+	return nil
+}
+
+type PushSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Batches       uint64                 `protobuf:"varint,1,opt,name=batches,proto3" json:"batches,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PushSummary) Reset() {
+	*x = PushSummary{}
+	mi := &file_obustat_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PushSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushSummary) ProtoMessage() {}
+
+func (x *PushSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_obustat_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushSummary.ProtoReflect.Descriptor instead.
+func (*PushSummary) Descriptor() ([]byte, []int) {
+	return file_obustat_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PushSummary) GetBatches() uint64 {
+	if x != nil {
+		return x.Batches
+	}
+	return 0
+}
+
+// ConnectionFilter は ListConnections と WatchEvents の絞り込み条件。全て省略すると全ての接続が対象になる。
+type ConnectionFilter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Processes     []string               `protobuf:"bytes,1,rep,name=processes,proto3" json:"processes,omitempty"` // プロセス名 (java* のようなワイルドカード可)
+	Pids          []uint32               `protobuf:"varint,2,rep,packed,name=pids,proto3" json:"pids,omitempty"`
+	States        []string               `protobuf:"bytes,3,rep,name=states,proto3" json:"states,omitempty"`       // TCP の状態 (ESTABLISHED など)
+	Ports         []uint32               `protobuf:"varint,4,rep,packed,name=ports,proto3" json:"ports,omitempty"` // ローカルまたはリモートのポート
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectionFilter) Reset() {
+	*x = ConnectionFilter{}
+	mi := &file_obustat_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectionFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectionFilter) ProtoMessage() {}
+
+func (x *ConnectionFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_obustat_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectionFilter.ProtoReflect.Descriptor instead.
+func (*ConnectionFilter) Descriptor() ([]byte, []int) {
+	return file_obustat_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ConnectionFilter) GetProcesses() []string {
+	if x != nil {
+		return x.Processes
+	}
+	return nil
+}
+
+func (x *ConnectionFilter) GetPids() []uint32 {
+	if x != nil {
+		return x.Pids
+	}
+	return nil
+}
+
+func (x *ConnectionFilter) GetStates() []string {
+	if x != nil {
+		return x.States
+	}
+	return nil
+}
+
+func (x *ConnectionFilter) GetPorts() []uint32 {
+	if x != nil {
+		return x.Ports
+	}
+	return nil
+}
+
+type ListConnectionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *ConnectionFilter      `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListConnectionsRequest) Reset() {
+	*x = ListConnectionsRequest{}
+	mi := &file_obustat_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListConnectionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListConnectionsRequest) ProtoMessage() {}
+
+func (x *ListConnectionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_obustat_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListConnectionsRequest.ProtoReflect.Descriptor instead.
+func (*ListConnectionsRequest) Descriptor() ([]byte, []int) {
+	return file_obustat_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListConnectionsRequest) GetFilter() *ConnectionFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type ListConnectionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimestampMs   int64                  `protobuf:"varint,1,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"` // 接続一覧を取得した時刻
+	Connections   []*Connection          `protobuf:"bytes,2,rep,name=connections,proto3" json:"connections,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListConnectionsResponse) Reset() {
+	*x = ListConnectionsResponse{}
+	mi := &file_obustat_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListConnectionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListConnectionsResponse) ProtoMessage() {}
+
+func (x *ListConnectionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_obustat_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListConnectionsResponse.ProtoReflect.Descriptor instead.
+func (*ListConnectionsResponse) Descriptor() ([]byte, []int) {
+	return file_obustat_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListConnectionsResponse) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+func (x *ListConnectionsResponse) GetConnections() []*Connection {
+	if x != nil {
+		return x.Connections
+	}
+	return nil
+}
+
+type WatchEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *ConnectionFilter      `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsRequest) Reset() {
+	*x = WatchEventsRequest{}
+	mi := &file_obustat_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsRequest) ProtoMessage() {}
+
+func (x *WatchEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_obustat_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsRequest.ProtoReflect.Descriptor instead.
+func (*WatchEventsRequest) Descriptor() ([]byte, []int) {
+	return file_obustat_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchEventsRequest) GetFilter() *ConnectionFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+// WatchEventsResponse は取得 1 回分の状態変化。条件に一致するイベントが無い回は送らない。
+type WatchEventsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimestampMs   int64                  `protobuf:"varint,1,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	Events        []*Event               `protobuf:"bytes,2,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsResponse) Reset() {
+	*x = WatchEventsResponse{}
+	mi := &file_obustat_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsResponse) ProtoMessage() {}
+
+func (x *WatchEventsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_obustat_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsResponse.ProtoReflect.Descriptor instead.
+func (*WatchEventsResponse) Descriptor() ([]byte, []int) {
+	return file_obustat_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WatchEventsResponse) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+func (x *WatchEventsResponse) GetEvents() []*Event {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+var File_obustat_proto protoreflect.FileDescriptor
+
+const file_obustat_proto_rawDesc = "" +
+	"\n" +
+	"\robustat.proto\x12\n" +
+	"obustat.v1\"\xab\x05\n" +
+	"\n" +
+	"Connection\x12\x1a\n" +
+	"\bprotocol\x18\x01 \x01(\tR\bprotocol\x12\x18\n" +
+	"\aprocess\x18\x02 \x01(\tR\aprocess\x12\x10\n" +
+	"\x03pid\x18\x03 \x01(\rR\x03pid\x12(\n" +
+	"\x10process_start_ms\x18\x04 \x01(\x03R\x0eprocessStartMs\x12\x1d\n" +
+	"\n" +
+	"local_addr\x18\x05 \x01(\tR\tlocalAddr\x12\x1d\n" +
+	"\n" +
+	"local_port\x18\x06 \x01(\rR\tlocalPort\x12'\n" +
+	"\x0flocal_interface\x18\a \x01(\tR\x0elocalInterface\x12\x1f\n" +
+	"\vremote_addr\x18\b \x01(\tR\n" +
+	"remoteAddr\x12\x1f\n" +
+	"\vremote_port\x18\t \x01(\rR\n" +
+	"remotePort\x12\x1f\n" +
+	"\vremote_host\x18\n" +
+	" \x01(\tR\n" +
+	"remoteHost\x12%\n" +
+	"\x0eremote_service\x18\v \x01(\tR\rremoteService\x12\x14\n" +
+	"\x05state\x18\f \x01(\tR\x05state\x12\"\n" +
+	"\rfirst_seen_ms\x18\r \x01(\x03R\vfirstSeenMs\x12\x1d\n" +
+	"\n" +
+	"image_path\x18\x0e \x01(\tR\timagePath\x12!\n" +
+	"\fcommand_line\x18\x0f \x01(\tR\vcommandLine\x12\x12\n" +
+	"\x04user\x18\x10 \x01(\tR\x04user\x12\x16\n" +
+	"\x06module\x18\x11 \x01(\tR\x06module\x12\x1a\n" +
+	"\bservices\x18\x12 \x03(\tR\bservices\x12\x1c\n" +
+	"\tcontainer\x18\x13 \x01(\tR\tcontainer\x12\x15\n" +
+	"\x06in_job\x18\x14 \x01(\bR\x05inJob\x12\x18\n" +
+	"\acountry\x18\x15 \x01(\tR\acountry\x12\x10\n" +
+	"\x03asn\x18\x16 \x01(\rR\x03asn\x12\x15\n" +
+	"\x06as_org\x18\x17 \x01(\tR\x05asOrg\"\xa0\x01\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x126\n" +
+	"\n" +
+	"connection\x18\x02 \x01(\v2\x16.obustat.v1.ConnectionR\n" +
+	"connection\x12\x1d\n" +
+	"\n" +
+	"prev_state\x18\x03 \x01(\tR\tprevState\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x05R\x05count\x12\x16\n" +
+	"\x06detail\x18\x05 \x01(\tR\x06detail\"\xc3\x01\n" +
+	"\n" +
+	"AgentBatch\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12!\n" +
+	"\ftimestamp_ms\x18\x02 \x01(\x03R\vtimestampMs\x12)\n" +
+	"\x06events\x18\x03 \x03(\v2\x11.obustat.v1.EventR\x06events\x12\x1f\n" +
+	"\vis_snapshot\x18\x04 \x01(\bR\n" +
+	"isSnapshot\x122\n" +
+	"\bsnapshot\x18\x05 \x03(\v2\x16.obustat.v1.ConnectionR\bsnapshot\"'\n" +
+	"\vPushSummary\x12\x18\n" +
+	"\abatches\x18\x01 \x01(\x04R\abatches\"r\n" +
+	"\x10ConnectionFilter\x12\x1c\n" +
+	"\tprocesses\x18\x01 \x03(\tR\tprocesses\x12\x12\n" +
+	"\x04pids\x18\x02 \x03(\rR\x04pids\x12\x16\n" +
+	"\x06states\x18\x03 \x03(\tR\x06states\x12\x14\n" +
+	"\x05ports\x18\x04 \x03(\rR\x05ports\"N\n" +
+	"\x16ListConnectionsRequest\x124\n" +
+	"\x06filter\x18\x01 \x01(\v2\x1c.obustat.v1.ConnectionFilterR\x06filter\"v\n" +
+	"\x17ListConnectionsResponse\x12!\n" +
+	"\ftimestamp_ms\x18\x01 \x01(\x03R\vtimestampMs\x128\n" +
+	"\vconnections\x18\x02 \x03(\v2\x16.obustat.v1.ConnectionR\vconnections\"J\n" +
+	"\x12WatchEventsRequest\x124\n" +
+	"\x06filter\x18\x01 \x01(\v2\x1c.obustat.v1.ConnectionFilterR\x06filter\"c\n" +
+	"\x13WatchEventsResponse\x12!\n" +
+	"\ftimestamp_ms\x18\x01 \x01(\x03R\vtimestampMs\x12)\n" +
+	"\x06events\x18\x02 \x03(\v2\x11.obustat.v1.EventR\x06events2F\n" +
+	"\tCollector\x129\n" +
+	"\x04Push\x12\x16.obustat.v1.AgentBatch\x1a\x17.obustat.v1.PushSummary(\x012\xb7\x01\n" +
+	"\aMonitor\x12Z\n" +
+	"\x0fListConnections\x12\".obustat.v1.ListConnectionsRequest\x1a#.obustat.v1.ListConnectionsResponse\x12P\n" +
+	"\vWatchEvents\x12\x1e.obustat.v1.WatchEventsRequest\x1a\x1f.obustat.v1.WatchEventsResponse0\x01B\x16Z\x14go-ObuStat/obustatpbb\x06proto3"
+
+var (
+	file_obustat_proto_rawDescOnce sync.Once
+	file_obustat_proto_rawDescData []byte
+)
+
+func file_obustat_proto_rawDescGZIP() []byte {
+	file_obustat_proto_rawDescOnce.Do(func() {
+		file_obustat_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_obustat_proto_rawDesc), len(file_obustat_proto_rawDesc)))
+	})
+	return file_obustat_proto_rawDescData
+}
+
+var file_obustat_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_obustat_proto_goTypes = []any{
+	(*Connection)(nil),              // 0: obustat.v1.Connection
+	(*Event)(nil),                   // 1: obustat.v1.Event
+	(*AgentBatch)(nil),              // 2: obustat.v1.AgentBatch
+	(*PushSummary)(nil),             // 3: obustat.v1.PushSummary
+	(*ConnectionFilter)(nil),        // 4: obustat.v1.ConnectionFilter
+	(*ListConnectionsRequest)(nil),  // 5: obustat.v1.ListConnectionsRequest
+	(*ListConnectionsResponse)(nil), // 6: obustat.v1.ListConnectionsResponse
+	(*WatchEventsRequest)(nil),      // 7: obustat.v1.WatchEventsRequest
+	(*WatchEventsResponse)(nil),     // 8: obustat.v1.WatchEventsResponse
+}
+var file_obustat_proto_depIdxs = []int32{
+	0,  // 0: obustat.v1.Event.connection:type_name -> obustat.v1.Connection
+	1,  // 1: obustat.v1.AgentBatch.events:type_name -> obustat.v1.Event
+	0,  // 2: obustat.v1.AgentBatch.snapshot:type_name -> obustat.v1.Connection
+	4,  // 3: obustat.v1.ListConnectionsRequest.filter:type_name -> obustat.v1.ConnectionFilter
+	0,  // 4: obustat.v1.ListConnectionsResponse.connections:type_name -> obustat.v1.Connection
+	4,  // 5: obustat.v1.WatchEventsRequest.filter:type_name -> obustat.v1.ConnectionFilter
+	1,  // 6: obustat.v1.WatchEventsResponse.events:type_name -> obustat.v1.Event
+	2,  // 7: obustat.v1.Collector.Push:input_type -> obustat.v1.AgentBatch
+	5,  // 8: obustat.v1.Monitor.ListConnections:input_type -> obustat.v1.ListConnectionsRequest
+	7,  // 9: obustat.v1.Monitor.WatchEvents:input_type -> obustat.v1.WatchEventsRequest
+	3,  // 10: obustat.v1.Collector.Push:output_type -> obustat.v1.PushSummary
+	6,  // 11: obustat.v1.Monitor.ListConnections:output_type -> obustat.v1.ListConnectionsResponse
+	8,  // 12: obustat.v1.Monitor.WatchEvents:output_type -> obustat.v1.WatchEventsResponse
+	10, // [10:13] is the sub-list for method output_type
+	7,  // [7:10] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_obustat_proto_init() }
+func file_obustat_proto_init() {
+	if File_obustat_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_obustat_proto_rawDesc), len(file_obustat_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_obustat_proto_goTypes,
+		DependencyIndexes: file_obustat_proto_depIdxs,
+		MessageInfos:      file_obustat_proto_msgTypes,
+	}.Build()
+	File_obustat_proto = out.File
+	file_obustat_proto_goTypes = nil
+	file_obustat_proto_depIdxs = nil
+}
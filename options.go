@@ -0,0 +1,610 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- オプション定義 ---
+type options struct {
+	processNames         string
+	pids                 string
+	outputs              outputList
+	outputDir            string
+	outputName           string
+	watchProcesses       bool
+	captureWhen          string
+	captureInterval      int
+	captureCooldown      time.Duration
+	flightWindow         time.Duration
+	flightDir            string
+	flightListen         string
+	flightOnAlert        bool
+	intervalMilliseconds int
+	ipv4Only             bool
+	ipv6Only             bool
+	dual                 bool
+	includeListen        bool
+	includeBound         bool
+	protocols            string
+	format               string
+	template             string
+	fields               string
+	redact               string
+	redactKey            string
+	columns              string
+	configFile           string
+	maxSizeMB            int
+	maxAge               time.Duration
+	maxFiles             int
+	compress             bool
+	flushInterval        time.Duration
+	remoteAddrs          string
+	scopes               string
+	localPorts           string
+	remotePorts          string
+	states               string
+	containers           string
+	jobInfo              bool
+	tree                 bool
+	regex                bool
+	excludeNames         string
+	excludePIDs          string
+	excludeRemoteAddrs   string
+	excludeRemotePorts   string
+	estats               bool
+	handleCounts         bool
+	module               bool
+	resolve              bool
+	resolveTTL           time.Duration
+	services             bool
+	servicesFile         string
+	interfaces           bool
+	pairLoopback         bool
+	eventLogSource       string
+	timestamp            string
+	commandLine          bool
+	owner                bool
+	requireAdmin         bool
+	lang                 string
+	logFile              string
+	quiet                bool
+	verbose              bool
+	veryVerbose          bool
+	hostedServices       bool
+	alertCount           int
+	baseline             string
+	exitOnAlert          bool
+	webhookURL           string
+	notify               string
+	webhookRate          int
+	wake                 string
+	adaptive             bool
+	intervalMin          int
+	intervalMax          int
+	duration             time.Duration
+	until                string
+	utc                  bool
+	store                string
+	storeSnapshot        time.Duration
+	maxWidth             int
+	collapseTimeWait     bool
+	ignoreTimeWait       bool
+	debounce             time.Duration
+	groupBy              string
+	rateWindow           time.Duration
+	rateInterval         time.Duration
+	sample               string
+	maxRSS               int
+	maxEventsPerSecond   int
+	alertRate            float64
+	scanWindow           time.Duration
+	scanHosts            int
+	scanPorts            int
+	portWarn             int
+	perf                 bool
+	geoIPFiles           string
+	alertCountries       string
+	alertASNs            string
+	heartbeatInterval    time.Duration
+	stateFile            string
+	stateMaxAge          time.Duration
+	watchConfig          bool
+	check                bool
+	procCacheTTL         time.Duration
+
+	command      string           // 解析した FlagSet の名前 (サブコマンド名と操作, 例: service run)
+	args         []string         // 解析したコマンドライン (-watch-config で解析し直す)
+	profiles     []monitorProfile // 設定ファイルの profiles
+	geo          *geoIPDB         // 読み込み済みの -geoip (enrichers と geoAlerter で共有する)
+	extraOutputs []io.Closer      // 2 つ目以降の -o で開いた出力先 (closeExtraOutputs で閉じる)
+}
+
+// --- 共通のオプションのまとまり ---
+// 共通のオプションは defineCommonFlags で全て定義し、各サブコマンドは setupFlags で読み取るまとまりだけを FlagSet に登録する。
+// -h と補完には登録したフラグだけが表示される。登録しないフラグの値は既定値のまま使われる。
+
+// flagGroup は共通のオプションのまとまり (フラグ名の一覧)。1 つのフラグは 1 つのまとまりにだけ含める。
+type flagGroup []string
+
+var (
+	// baseFlags は全てのサブコマンドで登録する、設定ファイルと表示のオプション。
+	baseFlags = flagGroup{"c", "check", "lang", "q", "v", "vv"}
+	// filterFlags は監視対象と絞り込みのオプション (connFilter が読み取る)。
+	filterFlags = flagGroup{"n", "p", "4", "6", "dual", "proto", "scope", "raddr", "lport", "rport", "include-listen", "include-bound",
+		"state", "container", "xn", "xp", "xraddr", "xrport", "regex", "tree", "estats", "module"}
+	// pollFlags は接続一覧を定期的に取得するサブコマンドのオプション。
+	pollFlags = flagGroup{"i", "require-admin", "max-rss"}
+	// periodFlags は実行する期間のオプション (runContext が読み取る)。
+	periodFlags = flagGroup{"duration", "until"}
+	// outputFlags は出力形式と出力先のオプション (setupLogging と出力形式が読み取る)。
+	outputFlags = flagGroup{"o", "log", "format", "template", "columns", "truncate", "ts", "utc",
+		"max-size", "max-age", "max-files", "compress", "flush-interval"}
+	// sinkFlags は追加の出力先のオプション (newOutputs が読み取る)。
+	sinkFlags = flagGroup{"eventlog", "store", "store-snapshot", "webhook", "notify", "webhook-rate",
+		"flight-recorder", "flight-dir", "flight-listen", "flight-dump-on-alert"}
+	// redactFlags は出力する項目の選択と秘匿のオプション (newFormatter が読み取る)。
+	redactFlags = flagGroup{"fields", "redact", "redact-key"}
+	// enrichFlags は付加情報のオプション (enrichers が読み取る)。
+	enrichFlags = flagGroup{"resolve", "resolve-ttl", "services", "services-file", "iface", "pair-loopback",
+		"cmdline", "owner", "proc-cache-ttl", "job", "svc", "geoip"}
+	// eventFlags は状態変化のイベントをまとめるオプション (monitor と replay が読み取る)。
+	eventFlags = flagGroup{"collapse-timewait", "ignore-timewait", "debounce", "group-by", "alert-count"}
+	// monitorFlags は runMonitor だけが読み取る、検知・通知・取得の契機のオプション。
+	monitorFlags = flagGroup{"baseline", "alert-country", "alert-asn", "alert-rate", "exit-on-alert", "sample", "max-eps",
+		"rate-window", "rate-interval", "scan-window", "scan-hosts", "scan-ports", "port-warn", "process-events",
+		"capture-when", "capture-interval", "capture-cooldown", "heartbeat", "state-file", "state-max-age",
+		"wake", "adaptive", "i-min", "i-max", "watch-config"}
+	// statsFlags は stats の出力項目のオプション。
+	statsFlags = flagGroup{"perf", "handles"}
+)
+
+// monitorFlagGroups は runMonitor で監視して newFormatter で出力するサブコマンド (monitor, service, baseline record) のまとまり。
+var monitorFlagGroups = []flagGroup{filterFlags, pollFlags, periodFlags, outputFlags, sinkFlags, redactFlags, enrichFlags, eventFlags, monitorFlags}
+
+// setupFlags は baseFlags と groups の共通のオプションを fs に定義する。
+func setupFlags(fs *flag.FlagSet, groups ...flagGroup) *options {
+	common := flag.NewFlagSet("", flag.ContinueOnError)
+	opts := defineCommonFlags(common)
+	for _, group := range append([]flagGroup{baseFlags}, groups...) {
+		for _, name := range group {
+			f := common.Lookup(name)
+			if f == nil {
+				panic("setupFlags: unknown flag -" + name)
+			}
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	}
+	return opts
+}
+
+// defineCommonFlags は全ての共通のオプションを fs に定義する。
+func defineCommonFlags(fs *flag.FlagSet) *options {
+	opts := &options{}
+	fs.StringVar(&opts.processNames, "n", "", tr("監視するプロセス名 (カンマ区切り, java* のようなワイルドカード可, svc:Dnscache でサービスを指定)"))
+	fs.StringVar(&opts.pids, "p", "", tr("監視するPID (カンマ区切り, '0'でデバッグモード)"))
+	fs.Var(&opts.outputs, "o", tr("データの出力ファイル名、名前付きパイプ (\\\\.\\pipe\\名前)、syslog の送信先 (syslog://host:514, syslog+tcp://host:601, syslog+tls://host:6514)、Webhook の URL、または - (標準出力)。指定するとデータは標準出力に書かない。繰り返し指定でき、形式=出力先 で出力先ごとに形式を選べる (例: -o json=events.jsonl -o text=-)"))
+	fs.StringVar(&opts.logFile, "log", "", tr("警告・エラーと -v/-vv の診断ログの出力先 (-o と同じ形式, 省略時は標準エラー)。-o で指定したデータの出力先とは分けて書く"))
+	fs.IntVar(&opts.intervalMilliseconds, "i", 1000, tr("実行間隔(ミリ秒)"))
+	fs.BoolVar(&opts.ipv4Only, "4", false, tr("IPv4 の接続のみ監視"))
+	fs.BoolVar(&opts.ipv6Only, "6", false, tr("IPv6 の接続のみ監視"))
+	fs.BoolVar(&opts.dual, "dual", false, tr("IPv4 と IPv6 の両方を監視 (既定)"))
+	fs.StringVar(&opts.protocols, "proto", "tcp", tr("監視するプロトコル (tcp,udp のカンマ区切り)"))
+	fs.StringVar(&opts.lang, "lang", "", tr("表示言語 (ja, en)。未指定の場合は環境変数 LANG に従い、対応していない言語なら ja"))
+	fs.BoolVar(&opts.quiet, "q", false, tr("開始時の表示やサマリーを出力せず、標準出力にはデータ (イベントなど) だけを書く"))
+	fs.BoolVar(&opts.verbose, "v", false, tr("内部の動作の詳細を標準エラーに出力する"))
+	fs.BoolVar(&opts.veryVerbose, "vv", false, tr("-v に加えて、取得ごとの件数と所要時間などを標準エラーに出力する"))
+	fs.StringVar(&opts.format, "format", "text", tr("出力形式 (text, json, csv, logfmt, template)"))
+	fs.StringVar(&opts.template, "template", "", tr("-format template で 1 件ごとに出力する Go の text/template (例: \"{{.TS}} {{.Event}} {{.ProcessName}} {{.RemoteAddr}}\", @ファイル名 でファイルから読み込む)"))
+	fs.StringVar(&opts.fields, "fields", "", tr("出力する接続の項目 (json のキー名のカンマ区切り, 例: process,pid,state)。含まれない項目は空にして出力する"))
+	fs.StringVar(&opts.redact, "redact", "", tr("ハッシュに置き換えて出力する項目 (例: remote_addr,remote_host,user)。イベントのキーと説明に含まれる値も置き換える"))
+	fs.StringVar(&opts.redactKey, "redact-key", "", tr("-redact のハッシュ (HMAC-SHA256) の鍵。未指定の場合は実行ごとに作るため、実行をまたいで同じ値を突き合わせるには指定する"))
+	fs.IntVar(&opts.maxWidth, "truncate", 0, tr("text 形式の表で、接続とプロセス名の列をこの表示幅で切り詰める (0で切り詰めない。列幅は内容に合わせて自動で調整する)"))
+	fs.StringVar(&opts.scopes, "scope", "", tr("リモートアドレスの種類で絞り込む (loopback, link-local, private, public, multicast, unspecified のカンマ区切り, 例: public でマシンの外への接続のみ)"))
+	fs.StringVar(&opts.remoteAddrs, "raddr", "", tr("リモートアドレスで絞り込む (IP または CIDR のカンマ区切り, 例: 10.0.0.0/8,192.168.1.5)"))
+	fs.StringVar(&opts.localPorts, "lport", "", tr("ローカルポートで絞り込む (例: 80,443,8000-8100)"))
+	fs.StringVar(&opts.remotePorts, "rport", "", tr("リモートポートで絞り込む (例: 1433,5432,8000-8100)"))
+	fs.BoolVar(&opts.includeListen, "include-listen", false, tr("待ち受け (LISTEN) の TCP ソケットも対象にする (既定ではリモートアドレスが未指定のソケットは出力しない)"))
+	fs.BoolVar(&opts.includeBound, "include-bound", false, tr("バインドしただけで接続していない TCP ソケット (リモートアドレスが未指定で LISTEN 以外) も対象にする"))
+	fs.StringVar(&opts.states, "state", "", tr("TCP の状態で絞り込む (例: ESTABLISHED,CLOSE_WAIT)"))
+	fs.StringVar(&opts.containers, "container", "", tr("Windows コンテナのプロセスのみ監視する (コンテナ ID の前方一致, カンマ区切り, * で全てのコンテナ, 要管理者権限)"))
+	fs.StringVar(&opts.excludeNames, "xn", "", tr("除外するプロセス名 (カンマ区切り, ワイルドカード可)"))
+	fs.StringVar(&opts.excludePIDs, "xp", "", tr("除外するPID (カンマ区切り)"))
+	fs.StringVar(&opts.excludeRemoteAddrs, "xraddr", "", tr("除外するリモートアドレス (IP または CIDR のカンマ区切り)"))
+	fs.StringVar(&opts.excludeRemotePorts, "xrport", "", tr("除外するリモートポート (例: 80,443,8000-8100)"))
+	fs.BoolVar(&opts.regex, "regex", false, tr("-n/-xn の各要素を正規表現として扱う (大文字小文字を区別しない)"))
+	fs.BoolVar(&opts.tree, "tree", false, tr("対象プロセスの子孫プロセスも監視する"))
+	fs.BoolVar(&opts.perf, "perf", false, tr("stats で TCPv4 のパフォーマンスカウンタ (再送/秒、リセット数、接続失敗数) も出力する"))
+	fs.BoolVar(&opts.handleCounts, "handles", false, tr("stats で監視対象のプロセスのハンドル数とスレッド数も出力する"))
+	fs.BoolVar(&opts.estats, "estats", false, tr("TCP 接続ごとの通信量・再送数・RTT を表示する (要管理者権限)"))
+	fs.BoolVar(&opts.module, "module", false, tr("TCP 接続を所有するモジュールを表示する (svchost.exe の場合はサービス名, 例: Dnscache)"))
+	fs.BoolVar(&opts.resolve, "resolve", false, tr("リモートアドレスをホスト名に逆引きして表示する (非同期)"))
+	fs.DurationVar(&opts.resolveTTL, "resolve-ttl", 5*time.Minute, tr("逆引き結果をキャッシュする期間の上限"))
+	fs.BoolVar(&opts.services, "services", false, tr("リモートポートのサービス名 (例: 443→https) を表示する"))
+	fs.StringVar(&opts.servicesFile, "services-file", "", tr("サービス名の対応表を上書きするファイル (YAML, 例: 1433: mssql)。指定すると -services も有効になる"))
+	fs.BoolVar(&opts.interfaces, "iface", false, tr("ローカルアドレスのネットワークインターフェース名を表示する"))
+	fs.BoolVar(&opts.pairLoopback, "pair-loopback", false, tr("ループバックの TCP 接続に相手側のプロセスを付けて「クライアント -> サーバー」として表示し、両端が出力に含まれる場合は 1 件にまとめる"))
+	fs.BoolVar(&opts.commandLine, "cmdline", false, tr("各 PID を初めて出力するときに、実行ファイルのパスとコマンドラインを表示する"))
+	fs.BoolVar(&opts.owner, "owner", false, tr("プロセスを所有するユーザー名を表示する (他ユーザーのプロセスは要管理者権限)"))
+	fs.DurationVar(&opts.procCacheTTL, "proc-cache-ttl", 30*time.Minute, tr("-cmdline/-owner/-job で取得したプロセスごとの情報をキャッシュする期間 (0で無期限。終了したプロセスの情報は期限前でも破棄する)"))
+	fs.BoolVar(&opts.requireAdmin, "require-admin", false, tr("管理者として実行していない場合は、警告の代わりにエラーで終了する"))
+	fs.BoolVar(&opts.jobInfo, "job", false, tr("プロセスが属する Windows コンテナの ID と、ジョブオブジェクトに属しているかを表示する (-container 指定時は常に有効)"))
+	fs.BoolVar(&opts.hostedServices, "svc", false, tr("svchost.exe などサービスをホストするプロセスに、実行中のサービス名を併記する (-n svc:名前 を指定した場合は常に有効)"))
+	fs.BoolVar(&opts.collapseTimeWait, "collapse-timewait", false, tr("TIME_WAIT/DELETE_TCB への変化を 1 件の CLOSED にまとめ、その後の変化と消滅は出力しない"))
+	fs.BoolVar(&opts.ignoreTimeWait, "ignore-timewait", false, tr("最初に検出した時点で TIME_WAIT/DELETE_TCB だった接続のイベントを出力しない"))
+	fs.DurationVar(&opts.debounce, "debounce", 0, tr("この期間内に現れて消えた接続を NEW と CLOSED の代わりに 1 件の FLAP で出力する (例: 2s, NEW はこの期間だけ遅れて出力される)"))
+	fs.StringVar(&opts.groupBy, "group-by", "", tr("monitor の状態変化を集約して件数で出力する単位 (remote-host, remote-port, process)"))
+	fs.StringVar(&opts.baseline, "baseline", "", tr("baseline record で記録したファイル。ベースラインに無い (プロセス, リモートアドレス, リモートポート) への接続を ANOMALY イベントとして出力する"))
+	fs.StringVar(&opts.geoIPFiles, "geoip", "", tr("MaxMind の GeoLite2/GeoIP2 データベース (Country/City と ASN の .mmdb, カンマ区切り)。パブリックなリモートアドレスに国と AS を表示する"))
+	fs.StringVar(&opts.alertCountries, "alert-country", "", tr("この国 (ISO 3166-1 の 2 文字, カンマ区切り, 例: CN,RU) への新しい接続で ALERT イベントを出力する (要 -geoip)"))
+	fs.StringVar(&opts.alertASNs, "alert-asn", "", tr("この AS 番号 (カンマ区切り, 例: AS13335,15169) への新しい接続で ALERT イベントを出力する (要 -geoip)"))
+	fs.IntVar(&opts.maxRSS, "max-rss", 0, tr("ツール自身のメモリ使用量 (ワーキングセット, MB) の上限。超えたらプロセス情報と名前解決のキャッシュを破棄する (0で無制限)"))
+	fs.StringVar(&opts.sample, "sample", "", tr("接続の変化のイベントを、接続のキーで選んだ一部だけ出力する (例: 1/10 で 10 件に 1 件の接続。選んだ接続は NEW から CLOSED まで全て出力する)"))
+	fs.IntVar(&opts.maxEventsPerSecond, "max-eps", 0, tr("1 秒あたりに出力する接続の変化のイベントの上限。超えた分は捨てて DROPPED イベントで件数を出力する (0で無制限)"))
+	fs.IntVar(&opts.alertCount, "alert-count", 0, tr("プロセスの同時接続数がこの値を超えたら ALERT イベントを出力する (0で無効)"))
+	fs.DurationVar(&opts.rateWindow, "rate-window", 10*time.Second, tr("接続・切断の頻度を計算する直近の期間"))
+	fs.DurationVar(&opts.rateInterval, "rate-interval", 0, tr("プロセスごとの接続・切断の頻度を RATE イベントとして出力する間隔 (例: 30s, 0で出力しない)"))
+	fs.DurationVar(&opts.scanWindow, "scan-window", time.Minute, tr("-scan-hosts と -scan-ports で接続先を数える直近の期間"))
+	fs.IntVar(&opts.scanHosts, "scan-hosts", 0, tr("1 つのプロセスが -scan-window の間にこの数を超える異なるリモートホストへ接続したら SCAN イベントを出力する (0で無効)"))
+	fs.IntVar(&opts.scanPorts, "scan-ports", 0, tr("1 つのプロセスが -scan-window の間にこの数を超える異なるリモートポートへ接続したら SCAN イベントを出力する (0で無効)"))
+	fs.IntVar(&opts.portWarn, "port-warn", 0, tr("全プロセスの TCP が使用する一時ポート (動的ポートの範囲) の割合がこの値 (%) を超えたら ALERT イベントを出力する (0で無効, 例: 80)"))
+	fs.Float64Var(&opts.alertRate, "alert-rate", 0, tr("プロセスの新規接続が 1 秒あたりこの値を超えたら ALERT イベントを出力する (0で無効)"))
+	fs.BoolVar(&opts.exitOnAlert, "exit-on-alert", false, tr("ALERT が発生したら監視を終了する (終了コード 4)"))
+	fs.BoolVar(&opts.watchProcesses, "process-events", false, tr("monitor で監視対象のプロセスの開始・終了を PROCESS_START/PROCESS_EXIT イベントとして出力する (要管理者権限)"))
+	fs.StringVar(&opts.captureWhen, "capture-when", "", tr("monitor で接続の変化を出力するのを、この条件のいずれかを満たしている間に限る (カンマ区切り, process:名前, host:IP または CIDR, state:状態>件数。例: state:CLOSE_WAIT>50)"))
+	fs.IntVar(&opts.captureInterval, "capture-interval", 100, tr("-capture-when の条件を満たしている間の取得間隔 (ミリ秒)"))
+	fs.DurationVar(&opts.captureCooldown, "capture-cooldown", time.Minute, tr("-capture-when のどの条件も満たさない状態がこの期間続いたら記録をやめる"))
+	fs.DurationVar(&opts.flightWindow, "flight-recorder", 0, tr("monitor のイベントをファイルに書かず、直近のこの期間だけメモリに保持する。flight-dump などの契機で -flight-dir へ書き出す (例: 10m, 0で無効)"))
+	fs.StringVar(&opts.flightDir, "flight-dir", ".", tr("-flight-recorder の記録を書き出すディレクトリ"))
+	fs.StringVar(&opts.flightListen, "flight-listen", "", tr("-flight-recorder の記録を POST /dump で書き出す HTTP の待ち受けアドレス (例: 127.0.0.1:9478, ループバックからの要求のみ受け付ける)"))
+	fs.BoolVar(&opts.flightOnAlert, "flight-dump-on-alert", false, tr("ALERT が発生したら -flight-recorder の記録を書き出す"))
+	fs.DurationVar(&opts.heartbeatInterval, "heartbeat", 0, tr("monitor で変化が無くても、この間隔でバージョン・稼働時間・接続数を HEARTBEAT イベントとして出力する (例: 1m, 0で出力しない)"))
+	fs.StringVar(&opts.stateFile, "state-file", "", tr("monitor の終了時に接続の一覧を保存し、次の起動時に読み込むファイル (再起動で既存の接続が NEW として出力されないようにする)"))
+	fs.DurationVar(&opts.stateMaxAge, "state-max-age", 15*time.Minute, tr("-state-file の保存からこの時間を過ぎていたら読み込まない (0で無制限)"))
+	fs.StringVar(&opts.webhookURL, "webhook", "", tr("イベントを JSON で POST する Webhook の URL (Slack/Teams の Incoming Webhook など)"))
+	fs.StringVar(&opts.notify, "notify", "ALERT", tr("Webhook に通知するイベント種別 (NEW, CHANGE, CLOSED, REBOUND, FLAP, RATE, ALERT, ANOMALY, SCAN, DROPPED, HEARTBEAT, CONFIG_RELOADED, CAPTURE_START, CAPTURE_STOP, PROCESS_START, PROCESS_EXIT のカンマ区切り)"))
+	fs.IntVar(&opts.webhookRate, "webhook-rate", 30, tr("Webhook の 1 分あたりの送信数の上限 (0で無制限)"))
+	fs.StringVar(&opts.eventLogSource, "eventlog", "", tr("状態変化を指定したソース名で Windows のアプリケーションイベントログにも書き込む (ID: NEW=101, CHANGE=102, CLOSED=103, REBOUND=104)"))
+	fs.StringVar(&opts.store, "store", "", tr("イベントと一定間隔ごとの接続一覧を保存する SQLite のデータベースファイル (query サブコマンドで検索できる)"))
+	fs.DurationVar(&opts.storeSnapshot, "store-snapshot", time.Minute, tr("-store に接続一覧を保存する間隔"))
+	fs.StringVar(&opts.wake, "wake", "poll", tr("monitor で接続一覧を取得する契機 (poll: -i ごと, etw: ETW の接続・切断通知ごと。要管理者権限)"))
+	fs.BoolVar(&opts.adaptive, "adaptive", false, tr("monitor の取得間隔を変化の量に応じて -i-min〜-i-max の範囲で調整する (-i は初期値)"))
+	fs.IntVar(&opts.intervalMin, "i-min", 50, tr("-adaptive の最短の取得間隔 (ミリ秒)"))
+	fs.IntVar(&opts.intervalMax, "i-max", 2000, tr("-adaptive の最長の取得間隔 (ミリ秒)"))
+	fs.DurationVar(&opts.duration, "duration", 0, tr("指定した時間が経過したら終了する (例: 30m, 0で無制限)"))
+	fs.StringVar(&opts.until, "until", "", tr("指定した時刻に終了する (例: 18:00, \"2006-01-02 18:00\")。過ぎている時刻は翌日とみなす"))
+	fs.StringVar(&opts.configFile, "c", "", tr("設定ファイル (YAML)。コマンドラインの指定が優先されます"))
+	fs.BoolVar(&opts.check, "check", false, tr("フラグと設定ファイルを解析して有効な設定を表示し、フィルターと出力の指定を検証して終了する (監視は始めない)"))
+	fs.BoolVar(&opts.watchConfig, "watch-config", false, tr("monitor で設定ファイル (-c) の変更を監視し、監視対象とフィルターを再起動せずに反映する (出力の設定は再起動が必要)"))
+	fs.IntVar(&opts.maxSizeMB, "max-size", 0, tr("出力ファイルをローテーションするサイズ (MB, 0で無効)"))
+	fs.DurationVar(&opts.maxAge, "max-age", 0, tr("出力ファイルをローテーションする経過時間 (例: 24h, 0で無効)"))
+	fs.IntVar(&opts.maxFiles, "max-files", 0, tr("残しておくローテーション済みファイルの数 (0で全て残す)"))
+	fs.BoolVar(&opts.compress, "compress", false, tr("ローテーション済みファイルを gzip 圧縮する"))
+	fs.DurationVar(&opts.flushInterval, "flush-interval", time.Second, tr("拡張子が .gz または .zst の出力先 (例: -o events.jsonl.gz) は圧縮して書き、この間隔で圧縮中のデータを書き出す (0で終了時のみ)"))
+	fs.StringVar(&opts.timestamp, "ts", "", tr("タイムスタンプの形式 (rfc3339, datetime, time, epoch-ms)。未指定時は text が time、その他は RFC 3339"))
+	fs.BoolVar(&opts.utc, "utc", false, tr("タイムスタンプを UTC で出力する (既定はローカル時刻)"))
+	fs.StringVar(&opts.columns, "columns", strings.Join(csvColumnNames, ","), tr("csv 形式で出力する列 (カンマ区切り, 順序も反映)"))
+	return opts
+}
+
+// commonOptions は o を返す。サブコマンドのオプションの構造体には、埋め込んだ *options から引き継がれる。
+func (o *options) commonOptions() *options { return o }
+
+// parseFlags はコマンドラインを解析し、-c が指定されていれば設定ファイルの値で未指定のフラグを補う。
+func parseFlags(fs *flag.FlagSet, opts *options, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts.command, opts.args = fs.Name(), args
+	if opts.configFile != "" {
+		if err := applyConfigFile(fs, opts.configFile); err != nil {
+			return usageErrorf(tr("設定ファイルを読み込めませんでした: %w"), err)
+		}
+		profiles, err := loadProfiles(opts.configFile)
+		if err != nil {
+			return usageErrorf(tr("設定ファイルを読み込めませんでした: %w"), err)
+		}
+		opts.profiles = profiles
+	}
+	// タイムスタンプの形式とエラーの表示形式は全ての出力形式で共通のため、ここで設定する。
+	errorFormat = opts.format
+	switch {
+	case opts.veryVerbose:
+		setLogLevel(opts.quiet, 2)
+	case opts.verbose:
+		setLogLevel(opts.quiet, 1)
+	default:
+		setLogLevel(opts.quiet, 0)
+	}
+	if opts.configFile != "" {
+		verboseLog.Printf(tr("設定ファイルを読み込みました: %s"), opts.configFile)
+	}
+	if opts.lang != "" {
+		// 設定ファイルで指定された場合は、ここから切り替わる。
+		if err := setLanguage(opts.lang); err != nil {
+			return &exitError{code: exitUsage, err: err}
+		}
+	}
+	if err := setTimestampStyle(opts.timestamp, opts.utc); err != nil {
+		return usageErrorf(tr("-ts の指定が不正です: %w"), err)
+	}
+	if opts.until != "" {
+		if _, err := parseUntil(opts.until, time.Now()); err != nil {
+			return usageErrorf(tr("-until の指定が不正です: %w"), err)
+		}
+	}
+	self.setMaxRSS(opts.maxRSS)
+	if opts.check {
+		return opts.runCheck(fs)
+	}
+	return nil
+}
+
+// addressFamilies は -4/-6/-dual の指定から取得対象のアドレスファミリを決定する。
+func (o *options) addressFamilies() []uint32 {
+	switch {
+	case o.dual || o.ipv4Only == o.ipv6Only:
+		return []uint32{windows.AF_INET, windows.AF_INET6}
+	case o.ipv4Only:
+		return []uint32{windows.AF_INET}
+	default:
+		return []uint32{windows.AF_INET6}
+	}
+}
+
+// protocolList は -proto の指定を検証し、監視対象のプロトコル一覧を返す。
+func (o *options) protocolList() ([]string, error) {
+	var protocols []string
+	for _, p := range strings.Split(o.protocols, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p != "tcp" && p != "udp" {
+			return nil, usageErrorf(tr("-proto には tcp または udp を指定してください: %q"), p)
+		}
+		protocols = append(protocols, p)
+	}
+	return protocols, nil
+}
+
+// untilLayouts は -until で受け付ける時刻の形式。日付を含まない形式は今日 (過ぎていれば明日) の時刻とみなす。
+var untilLayouts = []struct {
+	layout  string
+	hasDate bool
+}{
+	{"15:04", false},
+	{"15:04:05", false},
+	{"2006-01-02 15:04", true},
+	{"2006-01-02 15:04:05", true},
+	{time.RFC3339, true},
+}
+
+// deadline は -duration と -until から終了時刻を求める。両方を指定した場合は早い方を返す。
+// -until の形式は parseFlags で確認済みのため、ここでは解析できたものだけを使う。
+func (o *options) deadline(now time.Time) (time.Time, bool) {
+	var deadline time.Time
+	if o.duration > 0 {
+		deadline = now.Add(o.duration)
+	}
+	if o.until != "" {
+		until, err := parseUntil(o.until, now)
+		if err == nil && (deadline.IsZero() || until.Before(deadline)) {
+			deadline = until
+		}
+	}
+	return deadline, !deadline.IsZero()
+}
+
+func parseUntil(s string, now time.Time) (time.Time, error) {
+	for _, l := range untilLayouts {
+		t, err := time.ParseInLocation(l.layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		if l.hasDate {
+			return t, nil
+		}
+		t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf(tr("%q (例: 18:00, 2006-01-02 18:00)"), s)
+}
+
+func (o *options) rotateConfig() rotateConfig {
+	return rotateConfig{
+		maxSize:  int64(o.maxSizeMB) * 1024 * 1024,
+		maxAge:   o.maxAge,
+		maxFiles: o.maxFiles,
+		compress: o.compress,
+		flush:    o.flushInterval,
+	}
+}
+
+// connFilter はオプションから conn.Filter と、表示用の監視対象文字列を組み立てる。
+func (o *options) connFilter() (conn.Filter, string, error) {
+	var targets []string
+	var debugMode bool
+	var monitorTarget string
+	var err error
+	if len(o.profiles) > 0 && o.processNames == "" && o.pids == "" {
+		// プロファイルだけを指定した場合は、いずれかのプロファイルに一致する接続を全てのプロセスから探す。
+		debugMode = true
+		monitorTarget = fmt.Sprintf(tr("プロファイル: %s"), strings.Join(profileNames(o.profiles), ", "))
+	} else if targets, debugMode, monitorTarget, err = processArgs(o.processNames, o.pids); err != nil {
+		return conn.Filter{}, "", err
+	}
+	protocols, err := o.protocolList()
+	if err != nil {
+		return conn.Filter{}, "", err
+	}
+	filter := conn.Filter{
+		Protocols:    protocols,
+		Families:     o.addressFamilies(),
+		Targets:      targets,
+		AllProcesses: debugMode,
+
+		IncludeListen:      o.includeListen,
+		IncludeBound:       o.includeBound,
+		IncludeDescendants: o.tree,
+		CollectTraffic:     o.estats,
+		CollectModules:     o.module,
+	}
+	if o.regex && o.processNames != "" {
+		// 正規表現として解釈するため、-n の要素は Targets ではなく NameRegexps に入れる (svc: 指定を除く)。
+		filter.Targets = serviceTargets(o.processNames)
+		if o.pids != "" {
+			filter.Targets = append(filter.Targets, strings.Split(o.pids, ",")...)
+		}
+		if filter.NameRegexps, err = compileNameRegexps("n", o.processNames); err != nil {
+			return conn.Filter{}, "", err
+		}
+	}
+	if o.tree && !debugMode {
+		monitorTarget += tr(" (子孫プロセスを含む)")
+	}
+	if o.includeListen {
+		monitorTarget += tr(" (待ち受けを含む)")
+	}
+	if o.includeBound {
+		monitorTarget += tr(" (未接続のソケットを含む)")
+	}
+	if filter.RemoteNets, err = parsePrefixFlag("raddr", o.remoteAddrs); err != nil {
+		return conn.Filter{}, "", err
+	}
+	if o.remoteAddrs != "" {
+		monitorTarget += fmt.Sprintf(tr(" (リモート: %s)"), o.remoteAddrs)
+	}
+	if o.scopes != "" {
+		scopes, err := conn.ParseScopes(o.scopes)
+		if err != nil {
+			return conn.Filter{}, "", usageErrorf(tr("-scope の指定が不正です: %w"), connError(err))
+		}
+		filter.Scopes = scopes
+		monitorTarget += fmt.Sprintf(tr(" (リモートの種類: %s)"), o.scopes)
+	}
+	if filter.LocalPorts, err = parsePortFlag("lport", o.localPorts); err != nil {
+		return conn.Filter{}, "", err
+	}
+	if filter.RemotePorts, err = parsePortFlag("rport", o.remotePorts); err != nil {
+		return conn.Filter{}, "", err
+	}
+	if o.localPorts != "" {
+		monitorTarget += fmt.Sprintf(tr(" (ローカルポート: %s)"), o.localPorts)
+	}
+	if o.remotePorts != "" {
+		monitorTarget += fmt.Sprintf(tr(" (リモートポート: %s)"), o.remotePorts)
+	}
+	if o.states != "" {
+		states, err := conn.ParseStates(o.states)
+		if err != nil {
+			return conn.Filter{}, "", usageErrorf(tr("-state の指定が不正です: %w"), connError(err))
+		}
+		filter.States = states
+		monitorTarget += fmt.Sprintf(tr(" (状態: %s)"), strings.Join(states, ","))
+	}
+	if filter.Containers = splitList(o.containers); len(filter.Containers) > 0 {
+		monitorTarget += fmt.Sprintf(tr(" (コンテナ: %s)"), strings.Join(filter.Containers, ","))
+	}
+	if err := o.applyExcludes(&filter); err != nil {
+		return conn.Filter{}, "", err
+	}
+	if len(o.profiles) > 0 {
+		filter.Any = profileFilters(o.profiles)
+	}
+	if excludes := o.excludeDescription(); excludes != "" {
+		monitorTarget += fmt.Sprintf(tr(" (除外: %s)"), excludes)
+	}
+	return filter, monitorTarget, nil
+}
+
+// applyExcludes は -xn/-xp/-xraddr/-xrport の指定を filter に設定する。
+func (o *options) applyExcludes(filter *conn.Filter) error {
+	var err error
+	if o.excludeNames != "" {
+		if o.regex {
+			filter.ExcludeTargets = append(filter.ExcludeTargets, serviceTargets(o.excludeNames)...)
+			if filter.ExcludeRegexps, err = compileNameRegexps("xn", o.excludeNames); err != nil {
+				return err
+			}
+		} else {
+			filter.ExcludeTargets = append(filter.ExcludeTargets, strings.Split(o.excludeNames, ",")...)
+		}
+	}
+	if o.excludePIDs != "" {
+		filter.ExcludeTargets = append(filter.ExcludeTargets, strings.Split(o.excludePIDs, ",")...)
+	}
+	if filter.ExcludeRemoteNets, err = parsePrefixFlag("xraddr", o.excludeRemoteAddrs); err != nil {
+		return err
+	}
+	filter.ExcludeRemotePorts, err = parsePortFlag("xrport", o.excludeRemotePorts)
+	return err
+}
+
+func (o *options) excludeDescription() string {
+	var parts []string
+	for _, v := range []string{o.excludeNames, o.excludePIDs, o.excludeRemoteAddrs, o.excludeRemotePorts} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// compileNameRegexps は -n/-xn の各要素を正規表現としてコンパイルする。svc: 指定の要素は serviceTargets で扱うため除く。
+func compileNameRegexps(name, value string) ([]*regexp.Regexp, error) {
+	var regexps []*regexp.Regexp
+	for _, expr := range strings.Split(value, ",") {
+		if strings.HasPrefix(strings.ToLower(expr), conn.ServicePrefix) {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, usageErrorf(tr("-%s の正規表現が不正です: %w"), name, err)
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// serviceTargets は -n/-xn の要素のうち、svc: で始まるサービス名の指定を返す。
+func serviceTargets(value string) []string {
+	var targets []string
+	for _, t := range strings.Split(value, ",") {
+		if strings.HasPrefix(strings.ToLower(t), conn.ServicePrefix) {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+func parsePrefixFlag(name, value string) ([]netip.Prefix, error) {
+	if value == "" {
+		return nil, nil
+	}
+	prefixes, err := conn.ParsePrefixes(value)
+	if err != nil {
+		return nil, usageErrorf(tr("-%s の指定が不正です: %w"), name, err)
+	}
+	return prefixes, nil
+}
+
+func parsePortFlag(name, value string) ([]conn.PortRange, error) {
+	if value == "" {
+		return nil, nil
+	}
+	ranges, err := conn.ParsePortRanges(value)
+	if err != nil {
+		return nil, usageErrorf(tr("-%s の指定が不正です: %w"), name, connError(err))
+	}
+	return ranges, nil
+}
@@ -0,0 +1,832 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-ObuStat/conn"
+)
+
+// eventSnapshot は snapshot モードの出力で 1 件ごとの種別として使う。
+const eventSnapshot conn.EventType = "SNAPSHOT"
+
+// eventUnchanged は snapshot -changed-only で、前回から変化が無かったことを示す。
+const eventUnchanged conn.EventType = "UNCHANGED"
+
+// --- 出力形式 ---
+type outputFormatter interface {
+	writeEvents(timestamp time.Time, events []conn.Event)
+	writeSnapshot(timestamp time.Time, conns conn.Snapshot)
+	// writeUnchanged は前回のスナップショットから変化が無かったことを 1 行で出力する (snapshot -changed-only)。
+	writeUnchanged(timestamp time.Time, count int)
+	// perf は -perf 指定時の TCPv4 のパフォーマンスカウンタ (未指定の場合は nil)。
+	writeStats(timestamp time.Time, stats []processStats, perf *conn.TCPPerf)
+}
+
+// o の -columns/-max-width/-template/-perf/-handles の指定を各形式に渡す。出力は標準の log (-o の最初の出力先、未指定なら標準出力) へ書く。
+func newOutputFormatter(format string, o *options) (outputFormatter, error) {
+	return newOutputFormatterTo(format, o, log.Default())
+}
+
+// newOutputFormatterTo は newOutputFormatter と同じ形式で out へ書く outputFormatter を返す。
+func newOutputFormatterTo(format string, o *options, out *log.Logger) (outputFormatter, error) {
+	switch strings.ToLower(format) {
+	case "text":
+		return textFormatter{maxWidth: o.maxWidth, out: out}, nil
+	case "json":
+		return jsonFormatter{out: out}, nil
+	case "csv":
+		return newCSVFormatter(o.columns, csvStatsOptions{perf: o.perf, counters: o.handleCounts}, out)
+	case "logfmt":
+		return logfmtFormatter{out: out}, nil
+	case "template":
+		return newTemplateFormatter(o.template, out)
+	default:
+		return nil, usageErrorf(tr("不明な出力形式です: %q"), format)
+	}
+}
+
+// --- タイムスタンプ ---
+// timestampLayouts は -ts で指定できる形式。epoch-ms は Unix 時刻 (ミリ秒) で出力する。
+var timestampLayouts = map[string]string{
+	"rfc3339":  "2006-01-02T15:04:05.000Z07:00",
+	"datetime": "2006-01-02 15:04:05.000",
+	"time":     "15:04:05.000",
+	"epoch-ms": "",
+}
+
+// timestampStyle は -ts/-utc の指定。layout が未指定の場合、text 形式は時刻のみ、
+// それ以外の形式は RFC 3339 (ナノ秒) で出力する。
+var timestampStyle struct {
+	name string
+	utc  bool
+}
+
+func setTimestampStyle(name string, utc bool) error {
+	name = strings.ToLower(name)
+	if _, ok := timestampLayouts[name]; name != "" && !ok {
+		return fmt.Errorf(tr("不明なタイムスタンプ形式です: %q (指定可能: rfc3339, datetime, time, epoch-ms)"), name)
+	}
+	timestampStyle.name = name
+	timestampStyle.utc = utc
+	return nil
+}
+
+func formatTimestamp(t time.Time, defaultLayout string) string {
+	if timestampStyle.utc {
+		t = t.UTC()
+	}
+	switch timestampStyle.name {
+	case "":
+		return t.Format(defaultLayout)
+	case "epoch-ms":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	default:
+		return t.Format(timestampLayouts[timestampStyle.name])
+	}
+}
+
+// textTimestamp は text 形式の見出しに使うタイムスタンプを返す。
+func textTimestamp(t time.Time) string { return formatTimestamp(t, "15:04:05.000") }
+
+// machineTimestamp は json/csv/logfmt 形式で使うタイムスタンプを返す。
+func machineTimestamp(t time.Time) string { return formatTimestamp(t, time.RFC3339Nano) }
+
+func sortedKeys(conns conn.Snapshot) []string {
+	keys := make([]string, 0, len(conns))
+	for key := range conns {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortEvents(events []conn.Event) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Key < events[j].Key })
+}
+
+// formatLifetime は接続の継続時間を表示用に丸める。
+func formatLifetime(d time.Duration) string {
+	if d >= time.Minute {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// lifetimeMillis は NEW イベントでは 0 を、それ以外では継続時間をミリ秒で返す。
+func lifetimeMillis(e conn.Event, timestamp time.Time) int64 {
+	if e.Type == conn.EventNew {
+		return 0
+	}
+	return e.Conn.Lifetime(timestamp).Milliseconds()
+}
+
+// remoteScope は出力するリモートアドレスの種類。リモートを持たない UDP と待ち受けは "" にする。
+func remoteScope(c conn.Connection) conn.Scope {
+	if c.Protocol == "UDP" || c.RemotePort == 0 {
+		return ""
+	}
+	return conn.AddrScope(c.RemoteAddr)
+}
+
+// shortContainerID は docker ps と同じく、コンテナ ID の先頭 12 文字を返す。
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// connDetails は text 形式の行末に付ける、オプションで取得した付加情報を返す。
+func connDetails(c conn.Connection) string {
+	var b strings.Builder
+	if len(c.Profiles) > 0 {
+		fmt.Fprintf(&b, tr(" | プロファイル: %s"), strings.Join(c.Profiles, ", "))
+	}
+	if c.Host != "" {
+		fmt.Fprintf(&b, tr(" | 観測元: %s"), c.Host)
+	}
+	if pair := loopbackPair(c); pair != "" {
+		fmt.Fprintf(&b, tr(" | ループバック: %s"), pair)
+	}
+	if c.LocalInterface != "" {
+		fmt.Fprintf(&b, " | IF: %s", c.LocalInterface)
+	}
+	if c.RemoteHost != "" {
+		fmt.Fprintf(&b, tr(" | ホスト: %s"), c.RemoteHost)
+	}
+	if c.RemoteService != "" {
+		fmt.Fprintf(&b, tr(" | サービス: %s"), c.RemoteService)
+	}
+	if c.Country != "" {
+		fmt.Fprintf(&b, tr(" | 国: %s"), c.Country)
+	}
+	if c.ASN != 0 {
+		fmt.Fprintf(&b, " | AS%d %s", c.ASN, c.ASOrg)
+	}
+	if t := c.Traffic; t != nil {
+		fmt.Fprintf(&b, tr(" | 受信: %s 送信: %s 再送: %d RTT: %s"), formatBytes(t.BytesIn), formatBytes(t.BytesOut), t.Retransmits, t.RTT)
+	}
+	if c.Module != "" {
+		fmt.Fprintf(&b, tr(" | モジュール: %s"), c.Module)
+	}
+	if c.Container != "" {
+		fmt.Fprintf(&b, tr(" | コンテナ: %s"), shortContainerID(c.Container))
+	} else if c.InJob {
+		b.WriteString(tr(" | ジョブオブジェクト内"))
+	}
+	if c.User != "" {
+		fmt.Fprintf(&b, tr(" | ユーザー: %s"), c.User)
+	}
+	if c.ImagePath != "" {
+		fmt.Fprintf(&b, tr(" | パス: %s"), c.ImagePath)
+	}
+	if c.CommandLine != "" {
+		fmt.Fprintf(&b, tr(" | コマンドライン: %s"), c.CommandLine)
+	}
+	return b.String()
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// wideRanges は東アジアの文字幅が全角 (W, F) の主な範囲。
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},   // ハングル字母
+	{0x2E80, 0x303E},   // CJK 部首、記号と句読点
+	{0x3041, 0x33FF},   // ひらがな、カタカナ、CJK 互換文字
+	{0x3400, 0x4DBF},   // CJK 統合漢字拡張 A
+	{0x4E00, 0x9FFF},   // CJK 統合漢字
+	{0xA000, 0xA4CF},   // イ文字
+	{0xAC00, 0xD7A3},   // ハングル音節
+	{0xF900, 0xFAFF},   // CJK 互換漢字
+	{0xFE30, 0xFE4F},   // CJK 互換形
+	{0xFF00, 0xFF60},   // 全角英数・記号
+	{0xFFE0, 0xFFE6},   // 全角記号
+	{0x1F300, 0x1F64F}, // 絵文字
+	{0x1F900, 0x1F9FF}, // 補助絵文字
+	{0x20000, 0x3FFFD}, // CJK 統合漢字拡張 B 以降
+}
+
+// runeWidth は等幅フォントでの r の表示幅を返す。結合文字と書式文字は 0、全角文字は 2、それ以外は 1 とみなす。
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x80:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	for _, wr := range wideRanges {
+		if r >= wr.lo && r <= wr.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// displayWidth は等幅フォントでの表示幅を返す。
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// truncateWidth は表示幅が n を超える場合に、末尾を "~" にして n 桁に収める。n が 0 以下なら切り詰めない。
+func truncateWidth(s string, n int) string {
+	if n <= 0 || displayWidth(s) <= n {
+		return s
+	}
+	w := 0
+	for i, r := range s {
+		rw := runeWidth(r)
+		if w+rw > n-1 {
+			return s[:i] + "~"
+		}
+		w += rw
+	}
+	return s
+}
+
+func padRight(s string, n int) string {
+	if w := displayWidth(s); w < n {
+		return s + strings.Repeat(" ", n-w)
+	}
+	return s
+}
+
+func padLeft(s string, n int) string {
+	if w := displayWidth(s); w < n {
+		return strings.Repeat(" ", n-w) + s
+	}
+	return s
+}
+
+// --- text 形式 ---
+type textFormatter struct {
+	maxWidth int
+	out      *log.Logger
+}
+
+func (f textFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	sortEvents(events)
+	f.out.Printf(tr("--- %s 状態変化 ---"), textTimestamp(timestamp))
+	for _, e := range events {
+		if line := textEventLine(timestamp, e); line != "" {
+			f.out.Print(line)
+		}
+	}
+}
+
+// processDisplayName は text 形式で表示するプロセス名。サービスをホストするプロセスはサービス名を併記する。
+func processDisplayName(c conn.Connection) string {
+	if len(c.Services) == 0 {
+		return c.ProcessName
+	}
+	return fmt.Sprintf("%s [%s]", c.ProcessName, strings.Join(c.Services, ", "))
+}
+
+// textEventLine は 1 イベントを text 形式の 1 行にする。
+func textEventLine(timestamp time.Time, e conn.Event) string {
+	switch e.Type {
+	case conn.EventNew:
+		return fmt.Sprintf(tr("[NEW] %s | Process: %s (PID: %d) | 状態: %s%s"), e.Key, processDisplayName(e.Conn), e.Conn.PID, e.Conn.State, connDetails(e.Conn))
+	case conn.EventChange:
+		return fmt.Sprintf(tr("[CHANGE] %s | Process: %s (PID: %d) | 状態: %s -> %s | 継続時間: %s%s"), e.Key, processDisplayName(e.Conn), e.Conn.PID, e.PrevState, e.Conn.State, formatLifetime(e.Conn.Lifetime(timestamp)), connDetails(e.Conn))
+	case conn.EventClosed:
+		return fmt.Sprintf(tr("[CLOSED] %s | Process: %s (PID: %d) | 最後の状態: %s | 継続時間: %s%s"), e.Key, processDisplayName(e.Conn), e.Conn.PID, e.Conn.State, formatLifetime(e.Conn.Lifetime(timestamp)), connDetails(e.Conn))
+	case conn.EventRebound:
+		return fmt.Sprintf(tr("[REBOUND] %s | Process: %s (PID: %d) | 状態: %s | 所有プロセスの変化: %s%s"), e.Key, processDisplayName(e.Conn), e.Conn.PID, e.Conn.State, e.Detail, connDetails(e.Conn))
+	case eventFlap:
+		return fmt.Sprintf(tr("[FLAP] %s | Process: %s (PID: %d) | 最後の状態: %s | %s%s"), e.Key, processDisplayName(e.Conn), e.Conn.PID, e.Conn.State, e.Detail, connDetails(e.Conn))
+	case eventGroup:
+		return fmt.Sprintf("[GROUP] %s (PID: %d) | %s", e.Conn.ProcessName, e.Conn.PID, e.Detail)
+	case eventRate:
+		return fmt.Sprintf("[RATE] Process: %s (PID: %d) | %s", e.Conn.ProcessName, e.Conn.PID, e.Detail)
+	case eventScan:
+		return fmt.Sprintf("[SCAN] Process: %s (PID: %d) | %s", e.Conn.ProcessName, e.Conn.PID, e.Detail)
+	case eventAnomaly:
+		return fmt.Sprintf("[ANOMALY] %s | Process: %s (PID: %d) | %s%s", e.Key, processDisplayName(e.Conn), e.Conn.PID, e.Detail, connDetails(e.Conn))
+	case eventAlert:
+		return fmt.Sprintf("[ALERT] Process: %s (PID: %d) | %s", e.Conn.ProcessName, e.Conn.PID, e.Detail)
+	case eventHistory:
+		return fmt.Sprintf("[HISTORY] Process: %s (PID: %d) | %s%s", processDisplayName(e.Conn), e.Conn.PID, e.Detail, connDetails(e.Conn))
+	case eventDropped:
+		return fmt.Sprintf("[DROPPED] %s", e.Detail)
+	case eventHeartbeat:
+		return fmt.Sprintf("[HEARTBEAT] %s", e.Detail)
+	case eventConfigReloaded:
+		return fmt.Sprintf("[CONFIG_RELOADED] %s", e.Detail)
+	case eventProcessStart:
+		return fmt.Sprintf("[PROCESS_START] %s | %s", e.Key, e.Detail)
+	case eventProcessExit:
+		return fmt.Sprintf("[PROCESS_EXIT] %s | %s", e.Key, e.Detail)
+	case eventCaptureStart:
+		return fmt.Sprintf("[CAPTURE_START] %s", e.Detail)
+	case eventCaptureStop:
+		return fmt.Sprintf("[CAPTURE_STOP] %s", e.Detail)
+	case eventError:
+		return fmt.Sprintf("[ERROR] %s", e.Detail)
+	}
+	return ""
+}
+
+// writeSnapshot は接続を表形式で出力する。列の幅は出力する接続の最大の表示幅に合わせる。
+func (f textFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	ts := textTimestamp(timestamp)
+	if len(conns) == 0 {
+		f.out.Printf(tr("--- %s 監視対象に一致する接続は見つかりません ---"), ts)
+		return
+	}
+	keys := sortedKeys(conns)
+	var keyWidth, nameWidth, pidWidth, stateWidth int
+	for _, key := range keys {
+		c := conns[key]
+		keyWidth = max(keyWidth, displayWidth(truncateWidth(key, f.maxWidth)))
+		nameWidth = max(nameWidth, displayWidth(truncateWidth(processDisplayName(c), f.maxWidth)))
+		pidWidth = max(pidWidth, len(strconv.FormatUint(uint64(c.PID), 10)))
+		stateWidth = max(stateWidth, len(c.State))
+	}
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf(tr("--- %s 監視対象の接続 (%d件) ---\n"), ts, len(conns)))
+	for _, key := range keys {
+		c := conns[key]
+		report.WriteString(fmt.Sprintf(tr("%s | Process: %s (PID: %-*d) | 状態: %-*s%s\n"),
+			padRight(truncateWidth(key, f.maxWidth), keyWidth), padRight(truncateWidth(processDisplayName(c), f.maxWidth), nameWidth),
+			pidWidth, c.PID, stateWidth, c.State, connDetails(c)))
+	}
+	report.WriteString("-----------------------------------")
+	f.out.Println(report.String())
+}
+
+func (f textFormatter) writeUnchanged(timestamp time.Time, count int) {
+	f.out.Printf(tr("--- %s 変化なし (%d件) ---"), textTimestamp(timestamp), count)
+}
+
+func (f textFormatter) writeStats(timestamp time.Time, stats []processStats, perf *conn.TCPPerf) {
+	nameWidth := 0
+	for _, s := range stats {
+		nameWidth = max(nameWidth, displayWidth(truncateWidth(s.Process, f.maxWidth)))
+	}
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf(tr("--- %s プロセス別統計 (%dプロセス) ---\n"), textTimestamp(timestamp), len(stats)))
+	for _, s := range stats {
+		var states []string
+		for _, state := range sortedStates(s.States) {
+			states = append(states, fmt.Sprintf("%s: %d", state, s.States[state]))
+		}
+		var counters string
+		if s.Counters != nil {
+			counters = fmt.Sprintf(tr(" | ハンドル: %d / スレッド: %d"), s.Counters.Handles, s.Counters.Threads)
+		}
+		report.WriteString(fmt.Sprintf(tr("%s (PID: %-5d) | 接続: %-4d | リモートホスト: %-3d | 新規: +%d / 終了: -%d | %s\n"),
+			padRight(truncateWidth(s.Process, f.maxWidth), nameWidth), s.PID, s.Total, s.RemoteHosts, s.Opened, s.Closed, strings.Join(states, ", ")+counters))
+	}
+	if perf != nil {
+		report.WriteString(fmt.Sprintf(tr("TCPv4 | 再送: %.1f/秒 | リセット: +%d (累計 %d) | 接続失敗: +%d (累計 %d)\n"),
+			perf.SegmentsRetransmittedPerSec, perf.ConnectionsResetDelta, perf.ConnectionsReset, perf.ConnectionFailuresDelta, perf.ConnectionFailures))
+	}
+	report.WriteString("-----------------------------------")
+	f.out.Println(report.String())
+}
+
+// --- json 形式 (1 行 1 オブジェクト) ---
+type jsonFormatter struct {
+	out *log.Logger
+}
+
+type jsonConnection struct {
+	Protocol       string `json:"protocol"`
+	Process        string `json:"process"`
+	PID            uint32 `json:"pid"`
+	ProcessStart   string `json:"process_start,omitempty"`
+	LocalAddr      string `json:"local_addr"`
+	LocalPort      uint16 `json:"local_port"`
+	LocalInterface string `json:"local_interface,omitempty"`
+	RemoteAddr     string `json:"remote_addr,omitempty"`
+	RemotePort     uint16 `json:"remote_port,omitempty"`
+	RemoteHost     string `json:"remote_host,omitempty"`
+	RemoteService  string `json:"remote_service,omitempty"`
+	RemoteScope    string `json:"remote_scope,omitempty"`
+	State          string `json:"state"`
+
+	Traffic     *jsonTraffic `json:"traffic,omitempty"`
+	ImagePath   string       `json:"image_path,omitempty"`
+	CommandLine string       `json:"command_line,omitempty"`
+	User        string       `json:"user,omitempty"`
+	Module      string       `json:"module,omitempty"`
+	Services    []string     `json:"services,omitempty"`
+	Container   string       `json:"container,omitempty"`
+	InJob       bool         `json:"in_job,omitempty"`
+	Host        string       `json:"host,omitempty"`
+	Country     string       `json:"country,omitempty"`
+	ASN         uint32       `json:"asn,omitempty"`
+	ASOrg       string       `json:"as_org,omitempty"`
+	Profiles    []string     `json:"profiles,omitempty"`
+	Peer        *jsonPeer    `json:"peer,omitempty"`
+}
+
+// jsonPeer はループバック接続の相手側のプロセス。role は相手側の役割 (client または server)。
+type jsonPeer struct {
+	Process string `json:"process"`
+	PID     uint32 `json:"pid"`
+	Role    string `json:"role"`
+}
+
+type jsonTraffic struct {
+	BytesIn     uint64 `json:"bytes_in"`
+	BytesOut    uint64 `json:"bytes_out"`
+	Retransmits uint32 `json:"retransmits"`
+	RTTMs       int64  `json:"rtt_ms"`
+}
+
+type jsonEvent struct {
+	Timestamp string `json:"timestamp"`
+	Event     string `json:"event"`
+	Version   string `json:"version"` // イベントを出力したツールのバージョン
+	jsonConnection
+	PrevState  string         `json:"prev_state,omitempty"`
+	FirstSeen  string         `json:"first_seen,omitempty"`
+	LifetimeMs int64          `json:"lifetime_ms,omitempty"`
+	Count      int            `json:"count,omitempty"`
+	Detail     string         `json:"detail,omitempty"`
+	Heartbeat  *jsonHeartbeat `json:"heartbeat,omitempty"`
+}
+
+// jsonHeartbeat は HEARTBEAT イベントの内容。接続数が 0 の場合も省略しない。
+type jsonHeartbeat struct {
+	Version     string `json:"version"`
+	UptimeMs    int64  `json:"uptime_ms"`
+	Connections int    `json:"connections"`
+
+	// ツール自身の使用量
+	CPUPercent float64 `json:"cpu_percent"`
+	RSSBytes   uint64  `json:"rss_bytes"`
+	HeapBytes  uint64  `json:"heap_bytes"`
+}
+
+type jsonSnapshot struct {
+	Timestamp   string           `json:"timestamp"`
+	Event       string           `json:"event"`
+	Count       int              `json:"count"`
+	Connections []jsonConnection `json:"connections"`
+}
+
+func toJSONConnection(c conn.Connection) jsonConnection {
+	jc := jsonConnection{
+		Protocol: c.Protocol, Process: c.ProcessName, PID: c.PID,
+		LocalAddr: c.LocalAddr, LocalPort: c.LocalPort, LocalInterface: c.LocalInterface,
+		RemoteAddr: c.RemoteAddr, RemotePort: c.RemotePort, RemoteHost: c.RemoteHost,
+		RemoteService: c.RemoteService, RemoteScope: string(remoteScope(c)),
+		State:     c.State,
+		ImagePath: c.ImagePath, CommandLine: c.CommandLine, User: c.User,
+		Module: c.Module, Services: c.Services,
+		Container: c.Container, InJob: c.InJob, Host: c.Host,
+		Country: c.Country, ASN: c.ASN, ASOrg: c.ASOrg,
+		Profiles: c.Profiles,
+	}
+	if !c.ProcessStart.IsZero() {
+		jc.ProcessStart = machineTimestamp(c.ProcessStart)
+	}
+	if p := c.Peer; p != nil {
+		jc.Peer = &jsonPeer{Process: p.ProcessName, PID: p.PID, Role: peerRole(p)}
+	}
+	if t := c.Traffic; t != nil {
+		jc.Traffic = &jsonTraffic{BytesIn: t.BytesIn, BytesOut: t.BytesOut, Retransmits: t.Retransmits, RTTMs: t.RTT.Milliseconds()}
+	}
+	return jc
+}
+
+func writeJSONLine(out *log.Logger, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		out.Printf(tr("エラー: JSON への変換に失敗: %v"), err)
+		return
+	}
+	out.Println(string(b))
+}
+
+func toJSONEvent(timestamp time.Time, e conn.Event) jsonEvent {
+	je := jsonEvent{Timestamp: machineTimestamp(timestamp), Event: string(e.Type), Version: version, jsonConnection: toJSONConnection(e.Conn), PrevState: e.PrevState, Count: e.Count, Detail: e.Detail}
+	if !e.Conn.FirstSeen.IsZero() {
+		je.FirstSeen = machineTimestamp(e.Conn.FirstSeen)
+		je.LifetimeMs = lifetimeMillis(e, timestamp)
+	}
+	if e.Type == eventHeartbeat {
+		usage := self.current()
+		je.Heartbeat = &jsonHeartbeat{
+			Version: version, UptimeMs: uptime(timestamp).Milliseconds(), Connections: e.Count,
+			CPUPercent: usage.CPUPercent, RSSBytes: usage.RSS, HeapBytes: usage.HeapAlloc,
+		}
+	}
+	return je
+}
+
+func toJSONSnapshot(timestamp time.Time, conns conn.Snapshot) jsonSnapshot {
+	snapshot := jsonSnapshot{
+		Timestamp:   machineTimestamp(timestamp),
+		Event:       string(eventSnapshot),
+		Count:       len(conns),
+		Connections: []jsonConnection{},
+	}
+	for _, key := range sortedKeys(conns) {
+		snapshot.Connections = append(snapshot.Connections, toJSONConnection(conns[key]))
+	}
+	return snapshot
+}
+
+func (f jsonFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	sortEvents(events)
+	for _, e := range events {
+		writeJSONLine(f.out, toJSONEvent(timestamp, e))
+	}
+}
+
+func (f jsonFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	writeJSONLine(f.out, toJSONSnapshot(timestamp, conns))
+}
+
+type jsonUnchanged struct {
+	Timestamp string `json:"timestamp"`
+	Event     string `json:"event"`
+	Count     int    `json:"count"`
+}
+
+func (f jsonFormatter) writeUnchanged(timestamp time.Time, count int) {
+	writeJSONLine(f.out, jsonUnchanged{Timestamp: machineTimestamp(timestamp), Event: string(eventUnchanged), Count: count})
+}
+
+type jsonStats struct {
+	Timestamp   string         `json:"timestamp"`
+	Event       string         `json:"event"`
+	Process     string         `json:"process"`
+	PID         uint32         `json:"pid"`
+	Total       int            `json:"total"`
+	States      map[string]int `json:"states"`
+	RemoteHosts int            `json:"remote_hosts"`
+	Opened      int            `json:"opened"`
+	Closed      int            `json:"closed"`
+	Handles     *uint32        `json:"handles,omitempty"`
+	Threads     *uint32        `json:"threads,omitempty"`
+}
+
+// jsonPerf は stats -perf で、プロセス別の行の後に 1 行出力する TCPv4 のカウンタ。
+type jsonPerf struct {
+	Timestamp               string  `json:"timestamp"`
+	Event                   string  `json:"event"`
+	RetransmitsPerSec       float64 `json:"retransmits_per_sec"`
+	ConnectionsReset        uint64  `json:"connections_reset"`
+	ConnectionsResetDelta   uint64  `json:"connections_reset_delta"`
+	ConnectionFailures      uint64  `json:"connection_failures"`
+	ConnectionFailuresDelta uint64  `json:"connection_failures_delta"`
+}
+
+func (f jsonFormatter) writeStats(timestamp time.Time, stats []processStats, perf *conn.TCPPerf) {
+	ts := machineTimestamp(timestamp)
+	for _, s := range stats {
+		line := jsonStats{
+			Timestamp: ts, Event: "STATS", Process: s.Process, PID: s.PID, Total: s.Total,
+			States: s.States, RemoteHosts: s.RemoteHosts, Opened: s.Opened, Closed: s.Closed,
+		}
+		if s.Counters != nil {
+			line.Handles, line.Threads = &s.Counters.Handles, &s.Counters.Threads
+		}
+		writeJSONLine(f.out, line)
+	}
+	if perf != nil {
+		writeJSONLine(f.out, jsonPerf{
+			Timestamp: ts, Event: "PERF", RetransmitsPerSec: perf.SegmentsRetransmittedPerSec,
+			ConnectionsReset: perf.ConnectionsReset, ConnectionsResetDelta: perf.ConnectionsResetDelta,
+			ConnectionFailures: perf.ConnectionFailures, ConnectionFailuresDelta: perf.ConnectionFailuresDelta,
+		})
+	}
+}
+
+// --- csv 形式 ---
+type csvColumn func(timestamp time.Time, e conn.Event) string
+
+var csvColumns = map[string]csvColumn{
+	"timestamp": func(t time.Time, _ conn.Event) string { return machineTimestamp(t) },
+	"event":     func(_ time.Time, e conn.Event) string { return string(e.Type) },
+	"protocol":  func(_ time.Time, e conn.Event) string { return e.Conn.Protocol },
+	"process":   func(_ time.Time, e conn.Event) string { return e.Conn.ProcessName },
+	"pid":       func(_ time.Time, e conn.Event) string { return strconv.FormatUint(uint64(e.Conn.PID), 10) },
+	"process_start": func(_ time.Time, e conn.Event) string {
+		if e.Conn.ProcessStart.IsZero() {
+			return ""
+		}
+		return machineTimestamp(e.Conn.ProcessStart)
+	},
+	"local_addr":      func(_ time.Time, e conn.Event) string { return e.Conn.LocalAddr },
+	"local_interface": func(_ time.Time, e conn.Event) string { return e.Conn.LocalInterface },
+	"local_port":      func(_ time.Time, e conn.Event) string { return strconv.Itoa(int(e.Conn.LocalPort)) },
+	"remote_addr":     func(_ time.Time, e conn.Event) string { return e.Conn.RemoteAddr },
+	"remote_port":     func(_ time.Time, e conn.Event) string { return optionalPort(e.Conn.RemotePort) },
+	"remote_host":     func(_ time.Time, e conn.Event) string { return e.Conn.RemoteHost },
+	"remote_service":  func(_ time.Time, e conn.Event) string { return e.Conn.RemoteService },
+	"remote_scope":    func(_ time.Time, e conn.Event) string { return string(remoteScope(e.Conn)) },
+	"state":           func(_ time.Time, e conn.Event) string { return e.Conn.State },
+	"prev_state":      func(_ time.Time, e conn.Event) string { return e.PrevState },
+	"detail":          func(_ time.Time, e conn.Event) string { return e.Detail },
+	"lifetime_ms": func(t time.Time, e conn.Event) string {
+		// 接続一覧と、接続を持たない集計のイベント (ALERT, RATE など) は空欄にする。
+		if e.Type == eventSnapshot || e.Conn.FirstSeen.IsZero() {
+			return ""
+		}
+		return strconv.FormatInt(lifetimeMillis(e, t), 10)
+	},
+	"bytes_in":     trafficColumn(func(t *conn.TrafficStats) string { return strconv.FormatUint(t.BytesIn, 10) }),
+	"bytes_out":    trafficColumn(func(t *conn.TrafficStats) string { return strconv.FormatUint(t.BytesOut, 10) }),
+	"retransmits":  trafficColumn(func(t *conn.TrafficStats) string { return strconv.FormatUint(uint64(t.Retransmits), 10) }),
+	"rtt_ms":       trafficColumn(func(t *conn.TrafficStats) string { return strconv.FormatInt(t.RTT.Milliseconds(), 10) }),
+	"image_path":   func(_ time.Time, e conn.Event) string { return e.Conn.ImagePath },
+	"command_line": func(_ time.Time, e conn.Event) string { return e.Conn.CommandLine },
+	"user":         func(_ time.Time, e conn.Event) string { return e.Conn.User },
+	"module":       func(_ time.Time, e conn.Event) string { return e.Conn.Module },
+	"services":     func(_ time.Time, e conn.Event) string { return strings.Join(e.Conn.Services, ";") },
+	"container":    func(_ time.Time, e conn.Event) string { return e.Conn.Container },
+	"in_job":       func(_ time.Time, e conn.Event) string { return strconv.FormatBool(e.Conn.InJob) },
+	"host":         func(_ time.Time, e conn.Event) string { return e.Conn.Host },
+	"country":      func(_ time.Time, e conn.Event) string { return e.Conn.Country },
+	"asn": func(_ time.Time, e conn.Event) string {
+		if e.Conn.ASN == 0 {
+			return ""
+		}
+		return strconv.FormatUint(uint64(e.Conn.ASN), 10)
+	},
+	"as_org":   func(_ time.Time, e conn.Event) string { return e.Conn.ASOrg },
+	"profiles": func(_ time.Time, e conn.Event) string { return strings.Join(e.Conn.Profiles, ";") },
+	"peer":     func(_ time.Time, e conn.Event) string { return loopbackPair(e.Conn) },
+}
+
+// trafficColumn は TrafficStats が無い接続では空欄になる列を作る。
+func trafficColumn(value func(*conn.TrafficStats) string) csvColumn {
+	return func(_ time.Time, e conn.Event) string {
+		if e.Conn.Traffic == nil {
+			return ""
+		}
+		return value(e.Conn.Traffic)
+	}
+}
+
+// csvColumnNames は -columns 未指定時の列順。
+var csvColumnNames = []string{"timestamp", "event", "protocol", "process", "pid", "local_addr", "local_port", "remote_addr", "remote_port", "state", "prev_state", "lifetime_ms"}
+
+func availableCSVColumns() []string {
+	names := make([]string, 0, len(csvColumns))
+	for name := range csvColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type csvFormatter struct {
+	names         []string
+	columns       []csvColumn
+	stats         csvStatsOptions
+	headerWritten bool
+	out           *log.Logger
+}
+
+// csvStatsColumns は stats モードで出力する列。状態別の件数は TCP の状態ごとに列を持つ。
+var csvStatsColumns = append([]string{"timestamp", "process", "pid", "total", "remote_hosts", "opened", "closed"}, conn.TCPStateNames...)
+
+func newCSVFormatter(columns string, stats csvStatsOptions, out *log.Logger) (*csvFormatter, error) {
+	f := &csvFormatter{stats: stats, out: out}
+	for _, name := range strings.Split(columns, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		column, ok := csvColumns[name]
+		if !ok {
+			return nil, usageErrorf(tr("不明な列名です: %q (指定可能: %s)"), name, strings.Join(availableCSVColumns(), ","))
+		}
+		f.names = append(f.names, name)
+		f.columns = append(f.columns, column)
+	}
+	return f, nil
+}
+
+func optionalPort(port uint16) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(port))
+}
+
+func (f *csvFormatter) writeRecords(records [][]string) {
+	var buf strings.Builder
+	w := csv.NewWriter(&buf)
+	if !f.headerWritten {
+		w.Write(f.names)
+		f.headerWritten = true
+	}
+	w.WriteAll(records)
+	if buf.Len() > 0 {
+		f.out.Print(buf.String())
+	}
+}
+
+func (f *csvFormatter) record(timestamp time.Time, e conn.Event) []string {
+	record := make([]string, len(f.columns))
+	for i, column := range f.columns {
+		record[i] = column(timestamp, e)
+	}
+	return record
+}
+
+func (f *csvFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	sortEvents(events)
+	records := make([][]string, 0, len(events))
+	for _, e := range events {
+		records = append(records, f.record(timestamp, e))
+	}
+	f.writeRecords(records)
+}
+
+func (f *csvFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	records := make([][]string, 0, len(conns))
+	for _, key := range sortedKeys(conns) {
+		records = append(records, f.record(timestamp, conn.Event{Type: eventSnapshot, Key: key, Conn: conns[key]}))
+	}
+	f.writeRecords(records)
+}
+
+func (f *csvFormatter) writeUnchanged(timestamp time.Time, count int) {
+	f.writeRecords([][]string{f.record(timestamp, conn.Event{Type: eventUnchanged, Count: count, Detail: fmt.Sprintf(tr("%d件"), count)})})
+}
+
+// csvPerfColumns は stats -perf で各行の末尾に追加する、TCPv4 のカウンタの列。
+var csvPerfColumns = []string{"retransmits_per_sec", "connections_reset_delta", "connection_failures_delta"}
+
+// csvStatsOptions は stats で -perf と -handles を指定したか。csv 形式の見出しに追加する列は、取得した値ではなくこの指定で決め、
+// 最初の取得に失敗しても列が欠けないようにする。
+type csvStatsOptions struct {
+	perf     bool
+	counters bool
+}
+
+// csvCounterColumns は stats -handles で状態別の件数の後に追加する、プロセスのハンドル数とスレッド数の列。
+var csvCounterColumns = []string{"handles", "threads"}
+
+func (f *csvFormatter) writeStats(timestamp time.Time, stats []processStats, perf *conn.TCPPerf) {
+	var buf strings.Builder
+	w := csv.NewWriter(&buf)
+	if !f.headerWritten {
+		header := slices.Clone(csvStatsColumns)
+		if f.stats.counters {
+			header = append(header, csvCounterColumns...)
+		}
+		if f.stats.perf {
+			header = append(header, csvPerfColumns...)
+		}
+		w.Write(header)
+		f.headerWritten = true
+	}
+	ts := machineTimestamp(timestamp)
+	for _, s := range stats {
+		record := []string{ts, s.Process, strconv.FormatUint(uint64(s.PID), 10), strconv.Itoa(s.Total),
+			strconv.Itoa(s.RemoteHosts), strconv.Itoa(s.Opened), strconv.Itoa(s.Closed)}
+		for _, state := range conn.TCPStateNames {
+			record = append(record, strconv.Itoa(s.States[state]))
+		}
+		switch {
+		case s.Counters != nil && f.stats.counters:
+			record = append(record, strconv.FormatUint(uint64(s.Counters.Handles), 10), strconv.FormatUint(uint64(s.Counters.Threads), 10))
+		case f.stats.counters:
+			// 取得できなかったプロセスは空欄にする。
+			record = append(record, "", "")
+		}
+		switch {
+		case perf != nil && f.stats.perf:
+			record = append(record, strconv.FormatFloat(perf.SegmentsRetransmittedPerSec, 'f', 1, 64),
+				strconv.FormatUint(perf.ConnectionsResetDelta, 10), strconv.FormatUint(perf.ConnectionFailuresDelta, 10))
+		case f.stats.perf:
+			// カウンタの取得に失敗した回は空欄にする。
+			record = append(record, "", "", "")
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if buf.Len() > 0 {
+		f.out.Print(buf.String())
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 複数の出力先 (-o の繰り返し) ---
+// -o は繰り返し指定でき、それぞれ "形式=出力先" の形で出力形式を選べる (例: -o json=events.jsonl -o text=-)。
+// 形式を省略した出力先は -format に従う。最初の出力先 (Webhook を除く) が標準の log の出力先になり、
+// 2 つ目以降の出力先には同じイベントをそれぞれの形式で書く。
+
+// outputList は繰り返し指定された -o の値。
+type outputList []string
+
+func (l *outputList) String() string { return strings.Join(*l, ", ") }
+
+func (l *outputList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// stdoutTarget は -o で標準出力を表す出力先。
+const stdoutTarget = "-"
+
+// outputFormats は -o の "形式=" に指定できる形式。webhook は出力先を Webhook の URL とみなす。
+var outputFormats = []string{"text", "json", "csv", "logfmt", "template", "webhook"}
+
+// outputTarget は -o の 1 つの出力先。
+type outputTarget struct {
+	format string // 出力形式 (outputFormats のいずれか)
+	path   string // ファイル名、名前付きパイプ、syslog の URL、stdoutTarget、または Webhook の URL
+}
+
+func (t outputTarget) isWebhook() bool { return t.format == "webhook" }
+
+// parseOutputTarget は -o の値を解析する。http:// または https:// で始まる出力先は Webhook とみなす。
+func parseOutputTarget(s, defaultFormat string) outputTarget {
+	if format, path, ok := strings.Cut(s, "="); ok && slices.Contains(outputFormats, strings.ToLower(format)) {
+		return outputTarget{format: strings.ToLower(format), path: path}
+	}
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		return outputTarget{format: "webhook", path: s}
+	}
+	return outputTarget{format: defaultFormat, path: s}
+}
+
+// outputTargets は -o の指定を、標準の log に書く最初の出力先と、それ以外の出力先に分ける。
+// primary の path が空の場合は標準出力に書く。
+func (o *options) outputTargets() (primary outputTarget, extras []outputTarget) {
+	primary.format = o.format
+	found := false
+	for _, s := range o.outputs {
+		t := parseOutputTarget(s, o.format)
+		if !found && !t.isWebhook() {
+			primary, found = t, true
+			continue
+		}
+		extras = append(extras, t)
+	}
+	return primary, extras
+}
+
+// withExtraOutputs は 2 つ目以降の -o の出力先を開き、formatter と同じ内容をそれぞれの形式でも書く outputFormatter を返す。
+// 開いた出力先は closeExtraOutputs で閉じる。
+func (o *options) withExtraOutputs(formatter outputFormatter, extras []outputTarget) (outputFormatter, error) {
+	tee := teeFormatter{formatter}
+	for _, t := range extras {
+		if t.isWebhook() {
+			f, err := withWebhook(discardFormatter{}, t.path, o.notify, o.webhookRate)
+			if err != nil {
+				return nil, err
+			}
+			tee = append(tee, f)
+			continue
+		}
+		var out io.Writer = os.Stdout
+		if t.path != stdoutTarget {
+			file, err := openOutput(t.path, o.rotateConfig())
+			if err != nil {
+				o.closeExtraOutputs()
+				return nil, fmt.Errorf(tr("出力先を開けませんでした: %w"), err)
+			}
+			o.extraOutputs = append(o.extraOutputs, file)
+			out = file
+		}
+		f, err := newOutputFormatterTo(t.format, o, log.New(out, "", 0))
+		if err != nil {
+			o.closeExtraOutputs()
+			return nil, err
+		}
+		verboseLog.Printf(tr("追加の出力先: %s (%s)"), t.path, t.format)
+		tee = append(tee, f)
+	}
+	if len(tee) == 1 {
+		return formatter, nil
+	}
+	return tee, nil
+}
+
+// closeExtraOutputs は withExtraOutputs で開いた出力先を閉じる。
+func (o *options) closeExtraOutputs() {
+	for _, c := range o.extraOutputs {
+		c.Close()
+	}
+	o.extraOutputs = nil
+}
+
+// teeFormatter は同じ出力を複数の outputFormatter に渡す。
+type teeFormatter []outputFormatter
+
+func (t teeFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	for _, f := range t {
+		f.writeEvents(timestamp, events)
+	}
+}
+
+func (t teeFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	for _, f := range t {
+		f.writeSnapshot(timestamp, conns)
+	}
+}
+
+func (t teeFormatter) writeUnchanged(timestamp time.Time, count int) {
+	for _, f := range t {
+		f.writeUnchanged(timestamp, count)
+	}
+}
+
+func (t teeFormatter) writeStats(timestamp time.Time, stats []processStats, perf *conn.TCPPerf) {
+	for _, f := range t {
+		f.writeStats(timestamp, stats, perf)
+	}
+}
+
+// discardFormatter は何も出力しない outputFormatter。Webhook だけの出力先に使う。
+type discardFormatter struct{}
+
+func (discardFormatter) writeEvents(time.Time, []conn.Event)                 {}
+func (discardFormatter) writeSnapshot(time.Time, conn.Snapshot)              {}
+func (discardFormatter) writeUnchanged(time.Time, int)                       {}
+func (discardFormatter) writeStats(time.Time, []processStats, *conn.TCPPerf) {}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- 名前付きパイプへの出力 ---
+// -o \\.\pipe\名前 を指定すると、パイプを作成して読み取り側の接続を待つ。
+// 読み取り側が接続していない間の出力は破棄し、切断された場合は次の接続を待ち直す。
+const pipeBufferSize = 64 * 1024
+
+func isPipeTarget(target string) bool {
+	return strings.HasPrefix(strings.ToLower(target), `\\.\pipe\`)
+}
+
+type pipeWriter struct {
+	name   string
+	handle windows.Handle
+
+	mu        sync.Mutex
+	connected bool
+	closed    bool
+	detached  chan struct{} // 書き込みの失敗 (切断) または Close を待ち受けループに伝える
+}
+
+func newPipeWriter(name string) (*pipeWriter, error) {
+	name16, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateNamedPipe(name16,
+		windows.PIPE_ACCESS_OUTBOUND,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		1, pipeBufferSize, pipeBufferSize, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	w := &pipeWriter{name: name, handle: h, detached: make(chan struct{}, 1)}
+	go w.accept()
+	return w, nil
+}
+
+// accept は読み取り側の接続を待ち、切断されたら次の接続を待つ。
+func (w *pipeWriter) accept() {
+	for {
+		err := windows.ConnectNamedPipe(w.handle, nil)
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			return
+		}
+		if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+			w.mu.Unlock()
+			time.Sleep(time.Second)
+			continue
+		}
+		w.connected = true
+		w.mu.Unlock()
+
+		<-w.detached
+		w.mu.Lock()
+		closed := w.closed
+		w.mu.Unlock()
+		if closed {
+			return
+		}
+		windows.DisconnectNamedPipe(w.handle)
+	}
+}
+
+// Write は読み取り側が接続していれば書き込む。接続が無い間や切断時のエラーは呼び出し元に返さない。
+func (w *pipeWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.connected {
+		return len(p), nil
+	}
+	var written uint32
+	if err := windows.WriteFile(w.handle, p, &written, nil); err != nil {
+		w.connected = false
+		w.detached <- struct{}{}
+	}
+	return len(p), nil
+}
+
+func (w *pipeWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	connected := w.connected
+	w.mu.Unlock()
+
+	if connected {
+		w.detached <- struct{}{}
+	} else if name16, err := windows.UTF16PtrFromString(w.name); err == nil {
+		// ConnectNamedPipe の待ちを解除するため、自分自身で接続してすぐに閉じる。
+		if h, err := windows.CreateFile(name16, windows.GENERIC_READ, 0, nil, windows.OPEN_EXISTING, 0, 0); err == nil {
+			windows.CloseHandle(h)
+		}
+	}
+	return windows.CloseHandle(w.handle)
+}
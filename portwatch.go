@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-ObuStat/conn"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- 一時ポートの枯渇の監視 (-port-warn) ---
+// portWatchdog は監視対象に関わらず全プロセスの TCP ソケットを取得し、動的ポートの範囲のローカルポートの使用数が
+// -port-warn (%) を超えた時点で 1 回だけ ALERT を発生させる。TIME_WAIT のソケットは PID 0 が所有するため、
+// プロセスごとの内訳とは別に数える。
+type portWatchdog struct {
+	threshold int // 使用率 (%)
+	ports     conn.PortRange
+	collector *conn.Collector
+	alerting  bool
+}
+
+// portUsage は動的ポートの使用状況。
+type portUsage struct {
+	used     int // 使用中の異なるローカルポートの数
+	timeWait int // TIME_WAIT のソケットの数
+	byProc   map[processKey]int
+}
+
+func newPortWatchdog(threshold int) *portWatchdog {
+	ports, err := conn.DynamicPortRange()
+	if err != nil {
+		ports = conn.DefaultDynamicPorts
+		warnLog.Printf(tr("警告: 動的ポートの範囲を取得できないため、既定の %d-%d とみなします: %v"), ports.Low, ports.High, connError(err))
+	}
+	return &portWatchdog{
+		threshold: threshold,
+		ports:     ports,
+		collector: conn.NewCollector(conn.Filter{
+			Protocols:     []string{"tcp"},
+			Families:      []uint32{windows.AF_INET, windows.AF_INET6},
+			AllProcesses:  true,
+			IncludeListen: true,
+		}),
+	}
+}
+
+// usage は全プロセスの TCP ソケットから、動的ポートの使用状況を集計する。
+func (w *portWatchdog) usage() (portUsage, error) {
+	conns, err := w.collector.Collect()
+	if err != nil {
+		return portUsage{}, connError(err)
+	}
+	u := portUsage{byProc: make(map[processKey]int)}
+	used := make(map[uint16]bool)
+	for _, c := range conns {
+		if !w.ports.Contains(c.LocalPort) {
+			continue
+		}
+		used[c.LocalPort] = true
+		if c.State == "TIME_WAIT" {
+			u.timeWait++
+			continue
+		}
+		u.byProc[processKey{c.ProcessName, c.PID}]++
+	}
+	u.used = len(used)
+	return u, nil
+}
+
+// check は使用率がしきい値を超えた時点の ALERT イベントを返す。しきい値を下回ると、再び超えたときに改めて発生させる。
+func (w *portWatchdog) check() []conn.Event {
+	if w == nil {
+		return nil
+	}
+	u, err := w.usage()
+	if err != nil {
+		warnLog.Printf(tr("エラー: 一時ポートの使用状況を取得できません: %v"), err)
+		return nil
+	}
+	percent := u.used * 100 / w.ports.Size()
+	if percent < w.threshold {
+		w.alerting = false
+		return nil
+	}
+	if w.alerting {
+		return nil
+	}
+	w.alerting = true
+
+	top := u.topProcesses(3)
+	sample := conn.Connection{ProcessName: "System", PID: 0}
+	if len(top) > 0 {
+		sample = conn.Connection{ProcessName: top[0].name, PID: top[0].pid}
+	}
+	names := make([]string, len(top))
+	for i, key := range top {
+		names[i] = fmt.Sprintf(tr("%s %d 件"), processLabel(conn.Connection{ProcessName: key.name, PID: key.pid}), u.byProc[key])
+	}
+	detail := fmt.Sprintf(tr("一時ポート (%d-%d) の使用数が %d / %d (%d%%) になり、しきい値 %d%% を超えました (TIME_WAIT: %d 件"),
+		w.ports.Low, w.ports.High, u.used, w.ports.Size(), percent, w.threshold, u.timeWait)
+	if len(names) > 0 {
+		detail += fmt.Sprintf(tr(", 使用数の多いプロセス: %s"), strings.Join(names, ", "))
+	}
+	detail += ")"
+	return []conn.Event{{Type: eventAlert, Key: tr("一時ポート"), Conn: sample, Count: u.used, Detail: detail}}
+}
+
+// topProcesses は一時ポートの使用数が多い順に、最大 n 個のプロセスを返す。
+func (u portUsage) topProcesses(n int) []processKey {
+	keys := make([]processKey, 0, len(u.byProc))
+	for key := range u.byProc {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if u.byProc[keys[i]] != u.byProc[keys[j]] {
+			return u.byProc[keys[i]] > u.byProc[keys[j]]
+		}
+		return keys[i].pid < keys[j].pid
+	})
+	return keys[:min(n, len(keys))]
+}
+
+// portWatchdog は -port-warn が指定されていれば portWatchdog を返す。未指定の場合は nil を返す。
+func (o *options) portWatchdog() (*portWatchdog, error) {
+	if o.portWarn <= 0 {
+		return nil, nil
+	}
+	if o.portWarn > 100 {
+		return nil, usageError(tr("-port-warn には 1 から 100 までの使用率 (%) を指定してください。"))
+	}
+	return newPortWatchdog(o.portWarn), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- 管理者権限の確認 (-require-admin) ---
+// 管理者として実行していなくても接続の取得自体は成功するため、他ユーザーのプロセス名が N/A になるなど、
+// 権限の不足に気付きにくい。起動時に確認し、制限される機能を警告する。
+
+// isElevated は管理者として (UAC で昇格して) 実行しているかを返す。
+func isElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+// degradedCapabilities は管理者権限が無い場合に、指定したオプションのうち制限される機能を返す。
+func (o *options) degradedCapabilities() []string {
+	degraded := []string{tr("他のユーザーやサービスのプロセスは、プロセス名が N/A になる場合があります")}
+	if o.owner {
+		degraded = append(degraded, tr("-owner: 他のユーザーのプロセスの所有者を取得できません"))
+	}
+	if o.commandLine {
+		degraded = append(degraded, tr("-cmdline: 他のユーザーのプロセスのパスとコマンドラインを取得できません"))
+	}
+	if o.estats {
+		degraded = append(degraded, tr("-estats: 通信量・再送数・RTT を取得できません"))
+	}
+	if o.containers != "" || o.jobInfo {
+		degraded = append(degraded, tr("-container, -job: コンテナとジョブオブジェクトの情報を取得できません"))
+	}
+	if strings.EqualFold(o.wake, "etw") {
+		degraded = append(degraded, tr("-wake etw: ETW セッションを開始できません"))
+	}
+	return degraded
+}
+
+// checkPrivileges は管理者として実行していない場合に、制限される機能を警告する。
+// -require-admin を指定した場合は、警告の代わりにエラーを返す。
+func (o *options) checkPrivileges() error {
+	if isElevated() {
+		return nil
+	}
+	degraded := o.degradedCapabilities()
+	if o.requireAdmin {
+		return fmt.Errorf(tr("管理者として実行していません (-require-admin): %s"), strings.Join(degraded, "; "))
+	}
+	warnLog.Print(tr("警告: 管理者として実行していないため、次の機能が制限されます (doctor で詳しく確認できます):"))
+	for _, d := range degraded {
+		warnLog.Printf("  - %s", d)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"container/list"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- プロセスごとの付加情報のキャッシュ ---
+// processCachePruneInterval は、終了したプロセスのエントリを削除して -vv に統計を出力する間隔。
+const processCachePruneInterval = time.Minute
+
+type processCacheEntry[V any] struct {
+	id     processIdentity
+	value  V
+	stored time.Time
+}
+
+// processCache はプロセス (PID と開始時刻) ごとの値を保持する LRU キャッシュ。
+// 保存してから ttl が過ぎた値は取得し直し、件数が maxTrackedPIDs を超えたら最も長く使われていないものから削除する。
+// 終了したプロセスのエントリも processCachePruneInterval ごとに削除する。並行して使用してはならない。
+type processCache[V any] struct {
+	name      string        // -vv の統計で表示する名前
+	ttl       time.Duration // 0 の場合は期限切れにしない
+	entries   map[processIdentity]*list.Element
+	lru       *list.List // 先頭ほど最近使ったエントリ
+	lastPrune time.Time
+
+	hits, misses             int
+	expired, evicted, exited int
+}
+
+func newProcessCache[V any](name string, ttl time.Duration) *processCache[V] {
+	c := &processCache[V]{
+		name:      name,
+		ttl:       ttl,
+		entries:   make(map[processIdentity]*list.Element),
+		lru:       list.New(),
+		lastPrune: time.Now(),
+	}
+	registerTrimmer(c)
+	return c
+}
+
+// trim は全てのエントリを削除する (-max-rss)。
+func (c *processCache[V]) trim() {
+	c.evicted += c.lru.Len()
+	c.entries = make(map[processIdentity]*list.Element)
+	c.lru.Init()
+}
+
+// get は id の値を返す。無い場合と期限切れの場合は false を返す。
+func (c *processCache[V]) get(id processIdentity) (V, bool) {
+	now := time.Now()
+	c.prune(now)
+	e, ok := c.entries[id]
+	if !ok {
+		c.misses++
+		var zero V
+		return zero, false
+	}
+	entry := e.Value.(*processCacheEntry[V])
+	if c.ttl > 0 && now.Sub(entry.stored) >= c.ttl {
+		c.remove(e)
+		c.expired++
+		c.misses++
+		var zero V
+		return zero, false
+	}
+	c.lru.MoveToFront(e)
+	c.hits++
+	return entry.value, true
+}
+
+// put は id の値を保存する。上限を超えた場合は最も長く使われていないエントリを削除する。
+func (c *processCache[V]) put(id processIdentity, value V) {
+	now := time.Now()
+	if e, ok := c.entries[id]; ok {
+		entry := e.Value.(*processCacheEntry[V])
+		entry.value, entry.stored = value, now
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[id] = c.lru.PushFront(&processCacheEntry[V]{id: id, value: value, stored: now})
+	if c.lru.Len() > maxTrackedPIDs {
+		c.remove(c.lru.Back())
+		c.evicted++
+	}
+}
+
+func (c *processCache[V]) remove(e *list.Element) {
+	delete(c.entries, e.Value.(*processCacheEntry[V]).id)
+	c.lru.Remove(e)
+}
+
+// prune は前回から processCachePruneInterval 以上経っていれば、終了したプロセスのエントリを削除し、統計を -vv に出力する。
+func (c *processCache[V]) prune(now time.Time) {
+	if now.Sub(c.lastPrune) < processCachePruneInterval {
+		return
+	}
+	c.lastPrune = now
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		id := e.Value.(*processCacheEntry[V]).id
+		var start time.Time
+		if id.start != 0 {
+			start = time.Unix(0, id.start)
+		}
+		if !conn.ProcessRunning(id.pid, start) {
+			c.remove(e)
+			c.exited++
+		}
+		e = next
+	}
+	debugLog.Printf(tr("プロセス情報のキャッシュ (%s): %d 件, ヒット %d, ミス %d, 削除 (期限切れ %d, 上限 %d, 終了 %d)"),
+		c.name, c.lru.Len(), c.hits, c.misses, c.expired, c.evicted, c.exited)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+
+	"go-ObuStat/conn"
+)
+
+// --- プロセスの開始・終了 (-process-events) ---
+// 監視対象のプロセスの開始と終了を、接続のイベントと同じ流れに PROCESS_START/PROCESS_EXIT として出力する。
+// プロセスが終了した直後の CLOSED は、接続を閉じたのではなくプロセスの終了で消えたものと分かる。
+// Kernel-Process の ETW セッションを使うため管理者権限が必要。
+
+const (
+	// eventProcessStart は、監視対象のプロセスが開始したことを示すイベントの種別。
+	eventProcessStart conn.EventType = "PROCESS_START"
+	// eventProcessExit は、監視対象のプロセスが終了したことを示すイベントの種別。
+	eventProcessExit conn.EventType = "PROCESS_EXIT"
+)
+
+// processEventsSessionName は -process-events で使う ETW のセッション名。
+const processEventsSessionName = "ObuStat-Process"
+
+// processEvents はプロセスの開始・終了の通知を受け取る。
+type processEvents struct {
+	watcher *conn.ProcessWatcher
+}
+
+// processEvents は -process-events が指定されていれば processEvents を返す。未指定の場合は nil を返す。
+// ETW のセッションを開始できない場合 (管理者権限が無いなど) は、警告を出して nil を返す。
+func (o *options) processEvents(filter conn.Filter) *processEvents {
+	if !o.watchProcesses {
+		return nil
+	}
+	watcher, err := conn.WatchProcesses(processEventsSessionName, filter)
+	if err != nil {
+		warnLog.Printf(tr("警告: プロセスの開始・終了を取得できないため、PROCESS_START/PROCESS_EXIT を出力しません: %v"), err)
+		return nil
+	}
+	return &processEvents{watcher: watcher}
+}
+
+// C はプロセスの開始・終了を受け取るチャネルを返す。nil の場合は何も届かないチャネル (nil) を返す。
+func (p *processEvents) C() <-chan conn.ProcessEvent {
+	if p == nil || p.watcher == nil {
+		return nil
+	}
+	return p.watcher.Events()
+}
+
+// ended は C が閉じられた (ETW のセッションが終了した) ときに呼び出し、以降の通知をやめる。
+func (p *processEvents) ended() {
+	if err := p.watcher.Err(); err != nil {
+		warnLog.Printf(tr("警告: ETW のセッションが終了したため、プロセスの開始・終了を出力しません: %v"), err)
+	}
+	p.watcher = nil
+}
+
+// setFilter は対象のプロセスの判定を f に切り替える。
+func (p *processEvents) setFilter(f conn.Filter) {
+	if p != nil && p.watcher != nil {
+		p.watcher.SetFilter(f)
+	}
+}
+
+func (p *processEvents) Stop() {
+	if p != nil && p.watcher != nil {
+		if dropped := p.watcher.Dropped(); dropped > 0 {
+			warnLog.Printf(tr("警告: 処理が追いつかず、プロセスの開始・終了を %d 件破棄しました"), dropped)
+		}
+		p.watcher.Close()
+	}
+}
+
+// processEvent は pe を PROCESS_START または PROCESS_EXIT のイベントにする。
+func processEvent(pe conn.ProcessEvent) conn.Event {
+	e := conn.Event{
+		Time: pe.Time,
+		Type: eventProcessStart,
+		Conn: conn.Connection{ProcessName: pe.Name, PID: pe.PID, ProcessStart: pe.Created},
+	}
+	e.Key = processLabel(e.Conn)
+	if !pe.Exit {
+		e.Detail = fmt.Sprintf(tr("親 PID: %d"), pe.ParentPID)
+		return e
+	}
+	e.Type = eventProcessExit
+	e.Detail = fmt.Sprintf(tr("終了コード: %d"), pe.ExitCode)
+	if !pe.Created.IsZero() {
+		e.Detail += fmt.Sprintf(tr(", 稼働時間: %s"), formatLifetime(pe.Time.Sub(pe.Created)))
+	}
+	return e
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- プロセスの詳細情報 ---
+// maxTrackedPIDs は付加情報のために覚えておくプロセスの上限。超えた場合は最も長く使われていないものから忘れる。
+const maxTrackedPIDs = 65536
+
+// processIdentity は PID が再利用されても別のプロセスとして区別できるよう、開始時刻を含めた識別子。
+type processIdentity struct {
+	pid   uint32
+	start int64 // プロセスの開始時刻 (UnixNano, 取得できない場合は 0)
+}
+
+func identityOf(c conn.Connection) processIdentity {
+	id := processIdentity{pid: c.PID}
+	if !c.ProcessStart.IsZero() {
+		id.start = c.ProcessStart.UnixNano()
+	}
+	return id
+}
+
+// processDetails は各プロセスを初めて出力するときに、実行ファイルのパスとコマンドラインを設定する connEnricher。
+// 同じプロセスの 2 回目以降の出力には設定しない (長いコマンドラインを繰り返さないため)。
+// キャッシュの期限 (-proc-cache-ttl) が過ぎたプロセスは、再び初めての出力として扱う。
+type processDetails struct {
+	mu   sync.Mutex
+	seen *processCache[struct{}]
+}
+
+func newProcessDetails(ttl time.Duration) *processDetails {
+	return &processDetails{seen: newProcessCache[struct{}]("cmdline", ttl)}
+}
+
+func (p *processDetails) enrich(c *conn.Connection) {
+	if c.PID == 0 || c.PID == 4 {
+		return // Idle と System はファイルを持たない
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := identityOf(*c)
+	if _, ok := p.seen.get(id); ok {
+		return
+	}
+	p.seen.put(id, struct{}{})
+	if d, err := conn.QueryProcessDetails(c.PID); err == nil {
+		c.ImagePath = d.ImagePath
+		c.CommandLine = d.CommandLine
+	}
+}
+
+// processOwners はプロセスの所有者のアカウント名を設定する connEnricher。
+// 所有者はプロセス (PID と開始時刻) ごとにキャッシュし、取得に失敗した PID も繰り返し問い合わせない。
+type processOwners struct {
+	mu     sync.Mutex
+	owners *processCache[string]
+}
+
+func newProcessOwners(ttl time.Duration) *processOwners {
+	return &processOwners{owners: newProcessCache[string]("owner", ttl)}
+}
+
+func (p *processOwners) enrich(c *conn.Connection) {
+	if c.User != "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := identityOf(*c)
+	owner, ok := p.owners.get(id)
+	if !ok {
+		owner, _ = conn.ProcessOwner(c.PID)
+		p.owners.put(id, owner)
+	}
+	c.User = owner
+}
+
+// hostedServices はサービスをホストするプロセスに、実行中のサービス名を設定する connEnricher。
+// サービスの一覧は conn パッケージでキャッシュするため、ここでは保持しない。
+type hostedServices struct{}
+
+func (hostedServices) enrich(c *conn.Connection) {
+	if c.Services == nil {
+		c.Services = conn.ServiceNames(c.PID)
+	}
+}
+
+// jobInfo はプロセスが属するコンテナの ID と、ジョブオブジェクトに属しているかを設定する connEnricher。
+// ジョブへの所属はプロセスの終了まで変わらないため、プロセスごとにキャッシュする。
+type jobInfo struct {
+	mu    sync.Mutex
+	inJob *processCache[bool]
+}
+
+func newJobInfo(ttl time.Duration) *jobInfo {
+	return &jobInfo{inJob: newProcessCache[bool]("job", ttl)}
+}
+
+func (j *jobInfo) enrich(c *conn.Connection) {
+	if c.PID == 0 || c.PID == 4 {
+		return
+	}
+	if c.Container == "" {
+		c.Container = conn.ContainerOf(c.PID)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id := identityOf(*c)
+	inJob, ok := j.inJob.get(id)
+	if !ok {
+		inJob, _ = conn.ProcessInJob(c.PID)
+		j.inJob.put(id, inJob)
+	}
+	c.InJob = inJob
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"go-ObuStat/conn"
+
+	"golang.org/x/sys/windows"
+	"gopkg.in/yaml.v3"
+)
+
+// --- 名前付きの監視プロファイル (設定ファイルの profiles) ---
+// 設定ファイルの profiles に、チームごとの監視対象を名前を付けて複数定義できる。
+//
+//	profiles:
+//	  web:
+//	    n: w3wp.exe
+//	    lport: 80,443
+//	  db-clients:
+//	    rport: 1433
+//
+// 各イベントの接続には一致したプロファイルの名前を付けて出力する。-n/-p を省略した場合は、
+// いずれかのプロファイルに一致する接続だけを監視する。
+
+// profileKeys はプロファイルに指定できる項目 (フラグ名と同じ意味)。
+var profileKeys = []string{"n", "p", "proto", "lport", "rport", "raddr", "state", "scope"}
+
+// monitorProfile は 1 つのプロファイル。
+type monitorProfile struct {
+	name   string
+	filter conn.Filter
+}
+
+// loadProfiles は設定ファイルの profiles を読み込む。定義が無い場合は nil を返す。
+func loadProfiles(path string) ([]monitorProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Profiles map[string]map[string]any `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var profiles []monitorProfile
+	for _, name := range names {
+		p, err := newMonitorProfile(name, config.Profiles[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: profiles.%s: %w", path, name, err)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+func newMonitorProfile(name string, values map[string]any) (monitorProfile, error) {
+	settings := make(map[string]string)
+	for key, value := range values {
+		if alias, ok := configKeyAliases[key]; ok {
+			key = alias
+		}
+		if !slices.Contains(profileKeys, key) {
+			return monitorProfile{}, fmt.Errorf(tr("不明な項目です: %q (指定可能: %s)"), key, strings.Join(profileKeys, ", "))
+		}
+		s, err := configValueString(value)
+		if err != nil {
+			return monitorProfile{}, fmt.Errorf("%s: %w", key, err)
+		}
+		settings[key] = s
+	}
+	f := conn.Filter{
+		Protocols:     []string{"tcp", "udp"},
+		Families:      []uint32{windows.AF_INET, windows.AF_INET6},
+		Targets:       append(splitList(settings["n"]), splitList(settings["p"])...),
+		IncludeListen: true,
+	}
+	f.AllProcesses = len(f.Targets) == 0
+	var err error
+	if settings["proto"] != "" {
+		f.Protocols = splitList(strings.ToLower(settings["proto"]))
+	}
+	if f.LocalPorts, err = conn.ParsePortRanges(settings["lport"]); err != nil {
+		return monitorProfile{}, fmt.Errorf("lport: %w", connError(err))
+	}
+	if f.RemotePorts, err = conn.ParsePortRanges(settings["rport"]); err != nil {
+		return monitorProfile{}, fmt.Errorf("rport: %w", connError(err))
+	}
+	if f.RemoteNets, err = conn.ParsePrefixes(settings["raddr"]); err != nil {
+		return monitorProfile{}, fmt.Errorf("raddr: %w", err)
+	}
+	if settings["state"] != "" {
+		if f.States, err = conn.ParseStates(settings["state"]); err != nil {
+			return monitorProfile{}, fmt.Errorf("state: %w", connError(err))
+		}
+	}
+	if settings["scope"] != "" {
+		if f.Scopes, err = conn.ParseScopes(settings["scope"]); err != nil {
+			return monitorProfile{}, fmt.Errorf("scope: %w", connError(err))
+		}
+	}
+	return monitorProfile{name: name, filter: f}, nil
+}
+
+func profileNames(profiles []monitorProfile) []string {
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.name
+	}
+	return names
+}
+
+// profileFilters は、いずれかのプロファイルに一致する接続だけを対象にするための conn.Filter.Any の値を返す。
+func profileFilters(profiles []monitorProfile) []conn.Filter {
+	filters := make([]conn.Filter, len(profiles))
+	for i, p := range profiles {
+		filters[i] = p.filter
+	}
+	return filters
+}
+
+// profileTagger は接続に、一致したプロファイルの名前を設定する connEnricher。
+// -watch-config で再読み込みしたプロファイルにも従うよう、options の値をその都度参照する。
+type profileTagger struct {
+	o *options
+}
+
+func (t profileTagger) enrich(c *conn.Connection) {
+	if c.Profiles != nil {
+		return
+	}
+	for _, p := range t.o.profiles {
+		if p.filter.Match(*c) {
+			c.Profiles = append(c.Profiles, p.name)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"net/netip"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- query モード (-store で保存したイベントの検索) ---
+// 例: query -store events.db -p 1234 -host db01* -from 14:00 -to 15:00
+func runQueryMode(args []string) error {
+	fs := newFlagSet("query")
+	opts := setupQueryFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+	if opts.store == "" {
+		return usageError(tr("-store で検索するデータベースを指定してください。"))
+	}
+
+	q, err := opts.storeQuery(opts.from, opts.to)
+	if err != nil {
+		return err
+	}
+	q.hosts = splitList(strings.ToLower(opts.host))
+	q.events = splitList(strings.ToUpper(opts.events))
+	q.sources = splitList(strings.ToLower(opts.hostname))
+	atTime, err := parseQueryTime("at", opts.at)
+	if err != nil {
+		return err
+	}
+	if opts.history != "" {
+		if opts.at != "" {
+			return usageError(tr("-history と -at は同時に指定できません。"))
+		}
+		if err := q.setHistoryTarget(opts.history); err != nil {
+			return err
+		}
+	}
+
+	db, err := openStoreDB(opts.store)
+	if err != nil {
+		return fmt.Errorf(tr("-store のデータベースを開けませんでした: %w"), err)
+	}
+	defer db.Close()
+
+	// 保存済みの値をそのまま表示するため、付加情報や -store は適用しない。
+	formatter, err := newOutputFormatter(opts.format, opts.options)
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	switch {
+	case opts.at != "":
+		err = q.writeSnapshot(db, atTime, formatter)
+	case opts.history != "":
+		err = q.writeHistory(db, formatter)
+	default:
+		err = q.writeEvents(db, formatter)
+	}
+	if err != nil {
+		return fmt.Errorf(tr("検索に失敗しました: %w"), err)
+	}
+	return nil
+}
+
+// queryOptions は query のオプション。
+type queryOptions struct {
+	*options
+	from, to string
+	host     string
+	events   string
+	hostname string
+	at       string
+	history  string
+}
+
+// setupQueryFlags は query のフラグを定義する。共通のオプションのうち、絞り込みは storeQuery が読み取る -n/-p/-raddr だけを定義する。
+func setupQueryFlags(fs *flag.FlagSet) *queryOptions {
+	opts := &queryOptions{options: setupFlags(fs, outputFlags, flagGroup{"store", "n", "p", "raddr"})}
+	fs.StringVar(&opts.from, "from", "", tr("この時刻以降のイベントを表示する (例: 14:00, \"2006-01-02 14:00\")。日付を省略すると今日とみなす"))
+	fs.StringVar(&opts.to, "to", "", tr("この時刻より前のイベントを表示する (書式は -from と同じ)"))
+	fs.StringVar(&opts.host, "host", "", tr("リモートのアドレスまたはホスト名で絞り込む (カンマ区切り, *.example.com のようなワイルドカード可)"))
+	fs.StringVar(&opts.events, "event", "", tr("イベント種別で絞り込む (NEW, CHANGE, CLOSED, ALERT のカンマ区切り)"))
+	fs.StringVar(&opts.hostname, "hostname", "", tr("collector で保存した送信元ホスト名で絞り込む (カンマ区切り, web* のようなワイルドカード可)"))
+	fs.StringVar(&opts.at, "at", "", tr("イベントの代わりに、指定した時刻の直前に保存した接続一覧を表示する (書式は -from と同じ)"))
+	fs.StringVar(&opts.history, "history", "", tr("イベントの代わりに、指定したリモート (ホスト:ポート、ホストのみも可) へ接続したことのあるプロセスと、最初と最後に接続した時刻を表示する"))
+	return opts
+}
+
+// storeQuery は -n/-p/-raddr と、from から to までの期間の検索条件を返す。
+func (o *options) storeQuery(from, to string) (storeQuery, error) {
+	var q storeQuery
+	var err error
+	q.names = splitList(o.processNames)
+	if q.prefixes, err = parsePrefixFlag("raddr", o.remoteAddrs); err != nil {
+		return q, err
+	}
+	if q.pids, err = parsePIDList(o.pids); err != nil {
+		return q, err
+	}
+	if q.from, err = parseQueryTime("from", from); err != nil {
+		return q, err
+	}
+	q.to, err = parseQueryTime("to", to)
+	return q, err
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePIDList は -p のカンマ区切りの PID を解析する。
+func parsePIDList(s string) ([]uint32, error) {
+	var pids []uint32
+	for _, p := range splitList(s) {
+		pid, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, usageErrorf(tr("-p の指定が不正です: %q"), p)
+		}
+		pids = append(pids, uint32(pid))
+	}
+	return pids, nil
+}
+
+// parseQueryTime は -from/-to/-at の時刻を解析する。日付を含まない形式は今日の時刻とみなす。
+func parseQueryTime(name, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	now := time.Now()
+	for _, l := range untilLayouts {
+		t, err := time.ParseInLocation(l.layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		if !l.hasDate {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+		}
+		return t, nil
+	}
+	return time.Time{}, usageErrorf(tr("-%s の指定が不正です: %q (例: 14:00, 2006-01-02 14:00)"), name, s)
+}
+
+// storeQuery は query サブコマンドの検索条件。時刻・PID・イベント種別は SQL で、
+// ワイルドカードや CIDR を使うプロセス名とリモートの条件は読み込んだ行に対して判定する。
+type storeQuery struct {
+	pids       []uint32
+	names      []string
+	hosts      []string
+	prefixes   []netip.Prefix
+	events     []string
+	sources    []string // 送信元ホスト名 (小文字)
+	remotePort uint16   // -history で指定したリモートポート (0 は全てのポート)
+	from, to   time.Time
+}
+
+// eventConditions は events テーブルに対して SQL で絞り込める条件と引数を返す。
+func (q storeQuery) eventConditions() ([]string, []any) {
+	conds, args := q.pidConditions()
+	if !q.from.IsZero() {
+		conds = append(conds, "ts >= ?")
+		args = append(args, q.from.UnixMilli())
+	}
+	if !q.to.IsZero() {
+		conds = append(conds, "ts < ?")
+		args = append(args, q.to.UnixMilli())
+	}
+	if q.remotePort != 0 {
+		conds = append(conds, "remote_port = ?")
+		args = append(args, q.remotePort)
+	}
+	if len(q.events) > 0 {
+		conds = append(conds, "event IN ("+placeholders(len(q.events))+")")
+		for _, e := range q.events {
+			args = append(args, e)
+		}
+	}
+	return conds, args
+}
+
+func (q storeQuery) pidConditions() ([]string, []any) {
+	var conds []string
+	var args []any
+	if len(q.pids) > 0 {
+		conds = append(conds, "pid IN ("+placeholders(len(q.pids))+")")
+		for _, pid := range q.pids {
+			args = append(args, pid)
+		}
+	}
+	return conds, args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// matches はプロセス名とリモートの条件を判定する。
+func (q storeQuery) matches(c conn.Connection) bool {
+	if len(q.names) > 0 {
+		matched := false
+		for _, name := range q.names {
+			if conn.MatchProcessName(c.ProcessName, name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(q.prefixes) > 0 {
+		ip, err := netip.ParseAddr(c.RemoteAddr)
+		if err != nil || !containsAddr(q.prefixes, ip.Unmap()) {
+			return false
+		}
+	}
+	if len(q.sources) > 0 && !slices.ContainsFunc(q.sources, func(p string) bool { return matchHost(strings.ToLower(c.Host), p) }) {
+		return false
+	}
+	if len(q.hosts) > 0 {
+		matched := false
+		for _, pattern := range q.hosts {
+			if matchHost(c.RemoteAddr, pattern) || (c.RemoteHost != "" && matchHost(strings.ToLower(c.RemoteHost), pattern)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAddr(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchHost(value, pattern string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// writeEvents は条件に一致するイベントを時刻順に、保存時と同じ単位でまとめて出力する。
+func (q storeQuery) writeEvents(db *sql.DB, formatter outputFormatter) error {
+	var batch []conn.Event
+	var batchTime time.Time
+	err := q.eachEvent(db, func(timestamp time.Time, e conn.Event) {
+		if len(batch) > 0 && !timestamp.Equal(batchTime) {
+			formatter.writeEvents(batchTime, batch)
+			batch = nil
+		}
+		batchTime = timestamp
+		batch = append(batch, e)
+	})
+	if len(batch) > 0 {
+		formatter.writeEvents(batchTime, batch)
+	}
+	return err
+}
+
+// eachEvent は条件に一致するイベントを時刻順に読み込み、1 件ずつ fn に渡す。
+func (q storeQuery) eachEvent(db *sql.DB, fn func(timestamp time.Time, e conn.Event)) error {
+	conds, args := q.eventConditions()
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+	rows, err := db.Query(`SELECT ts, event, protocol, process, pid, process_start, local_addr, local_port,
+		remote_addr, remote_port, remote_host, state, prev_state, first_seen, count, detail, host
+		FROM events`+where+` ORDER BY ts, id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			ts                    int64
+			eventType             string
+			processStart, firstMs sql.NullInt64
+			e                     conn.Event
+		)
+		c := &e.Conn
+		if err := rows.Scan(&ts, &eventType, &c.Protocol, &c.ProcessName, &c.PID, &processStart, &c.LocalAddr, &c.LocalPort,
+			&c.RemoteAddr, &c.RemotePort, &c.RemoteHost, &c.State, &e.PrevState, &firstMs, &e.Count, &e.Detail, &c.Host); err != nil {
+			return err
+		}
+		if !q.matches(*c) {
+			continue
+		}
+		e.Type = conn.EventType(eventType)
+		e.Key = c.Key()
+		c.ProcessStart = timeFromMillis(processStart)
+		c.FirstSeen = timeFromMillis(firstMs)
+		fn(time.UnixMilli(ts), e)
+	}
+	return rows.Err()
+}
+
+// writeSnapshot は at の直前に保存した接続一覧のうち、条件に一致するものを出力する。
+// collector で複数のホストを保存した場合は、ホストごとの直前の接続一覧をまとめて出力する。
+func (q storeQuery) writeSnapshot(db *sql.DB, at time.Time, formatter outputFormatter) error {
+	rows, err := db.Query(`SELECT id, ts FROM snapshots s WHERE id = (
+		SELECT id FROM snapshots WHERE host = s.host AND ts <= ? ORDER BY ts DESC, id DESC LIMIT 1)`, at.UnixMilli())
+	if err != nil {
+		return err
+	}
+	type storedSnapshot struct{ id, ts int64 }
+	var snapshots []storedSnapshot
+	for rows.Next() {
+		var s storedSnapshot
+		if err := rows.Scan(&s.id, &s.ts); err != nil {
+			rows.Close()
+			return err
+		}
+		snapshots = append(snapshots, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf(tr("%s 以前に保存された接続一覧はありません"), at.Format("2006-01-02 15:04:05"))
+	}
+
+	conns := make(conn.Snapshot)
+	var latest int64
+	for _, s := range snapshots {
+		hostConns, err := q.snapshotConnections(db, s.id)
+		if err != nil {
+			return err
+		}
+		for _, c := range hostConns {
+			conns[hostKey(c)] = c
+		}
+		latest = max(latest, s.ts)
+	}
+	formatter.writeSnapshot(time.UnixMilli(latest), conns)
+	return nil
+}
+
+// hostKey は複数のホストの接続を 1 つの Snapshot にまとめるときのキー。送信元ホストが無い場合は Key と同じ。
+func hostKey(c conn.Connection) string {
+	if c.Host == "" {
+		return c.Key()
+	}
+	return c.Host + " " + c.Key()
+}
+
+// snapshotConnections は保存した接続一覧 id のうち、条件に一致するものを返す。
+func (q storeQuery) snapshotConnections(db *sql.DB, id int64) (conn.Snapshot, error) {
+	conds, args := q.pidConditions()
+	conds = append([]string{"snapshot_id = ?"}, conds...)
+	args = append([]any{id}, args...)
+	rows, err := db.Query(`SELECT protocol, process, pid, process_start, local_addr, local_port,
+		remote_addr, remote_port, remote_host, state, first_seen,
+		(SELECT host FROM snapshots WHERE id = snapshot_id)
+		FROM snapshot_connections WHERE `+strings.Join(conds, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conns := make(conn.Snapshot)
+	for rows.Next() {
+		var c conn.Connection
+		var processStart, firstMs sql.NullInt64
+		if err := rows.Scan(&c.Protocol, &c.ProcessName, &c.PID, &processStart, &c.LocalAddr, &c.LocalPort,
+			&c.RemoteAddr, &c.RemotePort, &c.RemoteHost, &c.State, &firstMs, &c.Host); err != nil {
+			return nil, err
+		}
+		if !q.matches(c) {
+			continue
+		}
+		c.ProcessStart = timeFromMillis(processStart)
+		c.FirstSeen = timeFromMillis(firstMs)
+		conns[c.Key()] = c
+	}
+	return conns, rows.Err()
+}
+
+func timeFromMillis(ms sql.NullInt64) time.Time {
+	if !ms.Valid {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms.Int64)
+}
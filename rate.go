@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 接続・切断の頻度 (-rate-window) ---
+// eventRate は、プロセスごとの直近の接続・切断の頻度を -rate-interval ごとに出力するイベントの種別。
+// 再接続を繰り返す障害 (リトライストーム) を、個々の NEW/CLOSED ではなく頻度で捉えるために使う。
+const eventRate conn.EventType = "RATE"
+
+// rateTracker はプロセスごとの NEW/CLOSED の件数を、取得ごとに window の期間だけ保持する。
+type rateTracker struct {
+	window    time.Duration
+	interval  time.Duration // RATE を出力する間隔 (0で出力しない)
+	threshold float64       // 1 秒あたりの接続数がこれを超えたら ALERT (0で無効)
+	lastEmit  time.Time
+	samples   map[processKey][]rateSample
+	alerting  map[processKey]bool
+}
+
+type rateSample struct {
+	at             time.Time
+	opened, closed int
+}
+
+type processRate struct {
+	key            processKey
+	opened, closed int
+}
+
+func newRateTracker(window, interval time.Duration, threshold float64) *rateTracker {
+	return &rateTracker{
+		window: window, interval: interval, threshold: threshold,
+		samples:  make(map[processKey][]rateSample),
+		alerting: make(map[processKey]bool),
+	}
+}
+
+// observe は now に検出した状態変化を記録し、window より古い記録を捨てる。
+func (t *rateTracker) observe(now time.Time, events []conn.Event) {
+	if t == nil {
+		return
+	}
+	counts := make(map[processKey]*rateSample)
+	for _, e := range events {
+		if !e.Opened() && e.Type != conn.EventClosed {
+			continue
+		}
+		key := processKey{e.Conn.ProcessName, e.Conn.PID}
+		s, ok := counts[key]
+		if !ok {
+			s = &rateSample{at: now}
+			counts[key] = s
+		}
+		if e.Opened() {
+			s.opened++
+		} else {
+			s.closed++
+		}
+	}
+	for key, s := range counts {
+		t.samples[key] = append(t.samples[key], *s)
+	}
+	for key, samples := range t.samples {
+		i := 0
+		for i < len(samples) && now.Sub(samples[i].at) >= t.window {
+			i++
+		}
+		if i == len(samples) {
+			delete(t.samples, key)
+		} else {
+			t.samples[key] = samples[i:]
+		}
+	}
+}
+
+// rates は window 内の件数をプロセスごとに、接続数の多い順で返す。
+func (t *rateTracker) rates() []processRate {
+	rates := make([]processRate, 0, len(t.samples))
+	for key, samples := range t.samples {
+		r := processRate{key: key}
+		for _, s := range samples {
+			r.opened += s.opened
+			r.closed += s.closed
+		}
+		rates = append(rates, r)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].opened != rates[j].opened {
+			return rates[i].opened > rates[j].opened
+		}
+		return processLabel(conn.Connection{ProcessName: rates[i].key.name, PID: rates[i].key.pid}) <
+			processLabel(conn.Connection{ProcessName: rates[j].key.name, PID: rates[j].key.pid})
+	})
+	return rates
+}
+
+func (t *rateTracker) perSecond(n int) float64 { return float64(n) / t.window.Seconds() }
+
+// check は -rate-interval ごとの RATE イベントと、新たにしきい値を超えたプロセスの ALERT イベントを返す。
+func (t *rateTracker) check(now time.Time) (rateEvents, alertEvents []conn.Event) {
+	if t == nil {
+		return nil, nil
+	}
+	rates := t.rates()
+	emit := t.interval > 0 && now.Sub(t.lastEmit) >= t.interval
+	if emit {
+		t.lastEmit = now
+	}
+	over := make(map[processKey]bool)
+	for _, r := range rates {
+		c := conn.Connection{ProcessName: r.key.name, PID: r.key.pid}
+		opens, closes := t.perSecond(r.opened), t.perSecond(r.closed)
+		if emit {
+			rateEvents = append(rateEvents, conn.Event{
+				Type: eventRate, Key: processLabel(c), Conn: c, Count: r.opened,
+				Detail: fmt.Sprintf(tr("新規: %.1f/秒, 終了: %.1f/秒 (直近 %s)"), opens, closes, t.window),
+			})
+		}
+		if t.threshold > 0 && opens > t.threshold {
+			over[r.key] = true
+			if !t.alerting[r.key] {
+				t.alerting[r.key] = true
+				alertEvents = append(alertEvents, conn.Event{
+					Type: eventAlert, Key: processLabel(c), Conn: c, Count: r.opened,
+					Detail: fmt.Sprintf(tr("新規接続が %.1f/秒 (直近 %s で %d 件) になり、しきい値 %g/秒 を超えました"), opens, t.window, r.opened, t.threshold),
+				})
+			}
+		}
+	}
+	for key := range t.alerting {
+		if !over[key] {
+			delete(t.alerting, key)
+		}
+	}
+	return rateEvents, alertEvents
+}
+
+// rateTracker は -rate-interval か -alert-rate が指定されていれば rateTracker を返す。どちらも未指定の場合は nil を返す。
+func (o *options) rateTracker() *rateTracker {
+	if o.rateInterval <= 0 && o.alertRate <= 0 {
+		return nil
+	}
+	return newRateTracker(o.rateWindow, o.rateInterval, o.alertRate)
+}
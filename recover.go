@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- パニックからの復旧 ---
+// 長期間の監視では、1 回の取得で想定外のデータ (壊れた接続テーブルなど) を受け取ってパニックしても、
+// その取得だけを捨てて監視を続ける。
+
+// maxConsecutivePanics は、この回数だけ連続でパニックした場合に復旧をあきらめて終了する回数。
+// 毎回パニックする状態でスタックトレースを出力し続けないようにする。
+const maxConsecutivePanics = 5
+
+// panicGuard は連続したパニックの回数を数える。
+type panicGuard struct {
+	consecutive int
+	formatter   outputFormatter // パニックを ERROR イベントとして出力する先
+}
+
+// run は f を実行して結果を返す。f がパニックした場合は回復し、スタックトレースを警告として出力して false を返す。
+// 出力を読むプログラムも気付けるよう、パニックの内容は formatter へ ERROR イベントとしても出力する。
+// maxConsecutivePanics 回連続でパニックした場合はエラーを返す。
+func (g *panicGuard) run(f func() bool) (result bool, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			g.consecutive = 0
+			return
+		}
+		g.consecutive++
+		warnLog.Printf(tr("エラー: 取得中にパニックが発生したため、この回の結果を破棄して監視を続けます (%d 回連続): %v\n%s"), g.consecutive, r, debug.Stack())
+		g.formatter.writeEvents(time.Now(), []conn.Event{{
+			Type:   eventError,
+			Detail: fmt.Sprintf(tr("取得中にパニックが発生しました (%d 回連続): %v"), g.consecutive, r),
+		}})
+		if g.consecutive >= maxConsecutivePanics {
+			err = fmt.Errorf(tr("%d 回連続でパニックが発生したため監視を終了します: %v"), g.consecutive, r)
+		}
+	}()
+	return f(), nil
+}
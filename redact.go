@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 出力する項目の選択と秘匿 (-fields, -redact) ---
+// 第三者に渡すログから、リモートアドレスやユーザー名などを取り除く (-fields) か、ハッシュに置き換える (-redact)。
+// ハッシュは -redact-key を鍵にした HMAC-SHA256 のため、同じ値は同じハッシュになり、鍵を知らなければ元の値に戻せない。
+// 項目名は json 形式のキー名と同じ。イベントのキーと説明に含まれる値も同じように置き換える。
+
+// redactField は -fields と -redact で指定できる接続の項目。
+type redactField struct {
+	// str は文字列の項目を返す。-redact はこの項目だけに指定できる。
+	str func(c *conn.Connection) *string
+	// clear は文字列以外の項目を空にする。nil の場合は str の値を空文字列にする。
+	clear func(c *conn.Connection)
+	// peer はループバック接続の相手側 (Connection.Peer) の同じ文字列の項目を返す。相手側に無い項目は nil。
+	peer func(p *conn.Peer) *string
+	// enum は値が短い列挙値 (TCP, ESTABLISHED など) で、キーと説明の中の同じ文字列は置き換えないことを示す。
+	enum bool
+}
+
+// values は c と相手側のプロセス (Peer) の、この項目の文字列を返す。
+func (f redactField) values(c *conn.Connection) []*string {
+	values := []*string{f.str(c)}
+	if f.peer != nil && c.Peer != nil {
+		values = append(values, f.peer(c.Peer))
+	}
+	return values
+}
+
+var redactFields = map[string]redactField{
+	"protocol":        {str: func(c *conn.Connection) *string { return &c.Protocol }, enum: true},
+	"process":         {str: func(c *conn.Connection) *string { return &c.ProcessName }, peer: func(p *conn.Peer) *string { return &p.ProcessName }},
+	"pid":             {clear: clearPID},
+	"process_start":   {clear: func(c *conn.Connection) { c.ProcessStart = time.Time{} }},
+	"local_addr":      {str: func(c *conn.Connection) *string { return &c.LocalAddr }},
+	"local_port":      {clear: func(c *conn.Connection) { c.LocalPort = 0 }},
+	"local_interface": {str: func(c *conn.Connection) *string { return &c.LocalInterface }},
+	"remote_addr":     {str: func(c *conn.Connection) *string { return &c.RemoteAddr }},
+	"remote_port":     {clear: func(c *conn.Connection) { c.RemotePort = 0 }},
+	"remote_host":     {str: func(c *conn.Connection) *string { return &c.RemoteHost }},
+	"remote_service":  {str: func(c *conn.Connection) *string { return &c.RemoteService }, enum: true},
+	"state":           {str: func(c *conn.Connection) *string { return &c.State }, enum: true},
+	"traffic":         {clear: func(c *conn.Connection) { c.Traffic = nil }},
+	"image_path":      {str: func(c *conn.Connection) *string { return &c.ImagePath }},
+	"command_line":    {str: func(c *conn.Connection) *string { return &c.CommandLine }},
+	"user":            {str: func(c *conn.Connection) *string { return &c.User }},
+	"module":          {str: func(c *conn.Connection) *string { return &c.Module }},
+	"services":        {clear: func(c *conn.Connection) { c.Services = nil }},
+	"container":       {str: func(c *conn.Connection) *string { return &c.Container }},
+	"in_job":          {clear: func(c *conn.Connection) { c.InJob = false }},
+	"host":            {str: func(c *conn.Connection) *string { return &c.Host }},
+	"country":         {str: func(c *conn.Connection) *string { return &c.Country }, enum: true},
+	"asn":             {clear: func(c *conn.Connection) { c.ASN = 0 }},
+	"as_org":          {str: func(c *conn.Connection) *string { return &c.ASOrg }},
+	"profiles":        {clear: func(c *conn.Connection) { c.Profiles = nil }},
+	"peer":            {clear: func(c *conn.Connection) { c.Peer = nil }},
+}
+
+// clearPID は c と相手側のプロセス (Peer) の PID を空にする。
+func clearPID(c *conn.Connection) {
+	c.PID = 0
+	if c.Peer != nil {
+		c.Peer.PID = 0
+	}
+}
+
+func availableRedactFields() []string {
+	names := make([]string, 0, len(redactFields))
+	for name := range redactFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// redactor は出力の前に、-fields に含まれない項目を空にし、-redact の項目をハッシュに置き換える。
+type redactor struct {
+	drop   []redactField
+	redact []redactField
+	key    []byte
+}
+
+// redactor は -fields または -redact が指定されていれば redactor を返す。未指定の場合は nil を返す。
+func (o *options) redactor() (*redactor, error) {
+	if o.fields == "" && o.redact == "" {
+		return nil, nil
+	}
+	r := &redactor{key: []byte(o.redactKey)}
+	if o.fields != "" {
+		keep := splitList(strings.ToLower(o.fields))
+		for _, name := range keep {
+			if _, ok := redactFields[name]; !ok {
+				return nil, usageErrorf(tr("-fields に不明な項目があります: %q (指定可能: %s)"), name, strings.Join(availableRedactFields(), ","))
+			}
+		}
+		for _, name := range availableRedactFields() {
+			if !slices.Contains(keep, name) {
+				r.drop = append(r.drop, redactFields[name])
+			}
+		}
+	}
+	for _, name := range splitList(strings.ToLower(o.redact)) {
+		f, ok := redactFields[name]
+		if !ok || f.str == nil {
+			return nil, usageErrorf(tr("-redact に指定できない項目です: %q (文字列の項目のみ指定可能)"), name)
+		}
+		r.redact = append(r.redact, f)
+	}
+	if len(r.key) == 0 {
+		// 鍵が無い場合は実行ごとに作る。同じ実行の中では同じ値が同じハッシュになる。
+		r.key = make([]byte, 32)
+		rand.Read(r.key)
+	}
+	return r, nil
+}
+
+// hash は value を HMAC-SHA256 の先頭 8 バイトで表した文字列に置き換える。
+func (r *redactor) hash(value string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return "h-" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// apply は接続の項目を置き換え、置き換えた文字列の組 (元の値, 新しい値) を返す。
+// 相手側のプロセス (Peer) の同じ項目も置き換える。Peer は呼び出し側と共有しているため、複製してから書き換える。
+func (r *redactor) apply(c *conn.Connection) []string {
+	if c.Peer != nil {
+		peer := *c.Peer
+		c.Peer = &peer
+	}
+	var replaced []string
+	for _, f := range r.redact {
+		for _, p := range f.values(c) {
+			if *p == "" {
+				continue
+			}
+			h := r.hash(*p)
+			if !f.enum {
+				replaced = append(replaced, *p, h)
+			}
+			*p = h
+		}
+	}
+	for _, f := range r.drop {
+		if f.clear != nil {
+			f.clear(c)
+			continue
+		}
+		for _, p := range f.values(c) {
+			if *p == "" {
+				continue
+			}
+			if !f.enum {
+				replaced = append(replaced, *p, "")
+			}
+			*p = ""
+		}
+	}
+	return replaced
+}
+
+// event は e の接続を置き換え、キーと説明に含まれる元の値も置き換えたイベントを返す。
+func (r *redactor) event(e conn.Event) conn.Event {
+	standardKey := e.Key == e.Conn.Key()
+	replaced := r.apply(&e.Conn)
+	if standardKey {
+		e.Key = e.Conn.Key()
+	}
+	if len(replaced) == 0 {
+		return e
+	}
+	if !standardKey {
+		e.Key = strings.NewReplacer(replaced...).Replace(e.Key)
+	}
+	e.Detail = strings.NewReplacer(replaced...).Replace(e.Detail)
+	return e
+}
+
+// redactingFormatter は項目を置き換えてから元の outputFormatter へ出力を渡す。
+// 呼び出し側が保持している接続を書き換えないよう、イベントと接続一覧は複製してから置き換える。
+type redactingFormatter struct {
+	outputFormatter
+	r *redactor
+}
+
+func (f *redactingFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	out := make([]conn.Event, len(events))
+	for i, e := range events {
+		out[i] = f.r.event(e)
+	}
+	f.outputFormatter.writeEvents(timestamp, out)
+}
+
+func (f *redactingFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	out := make(conn.Snapshot, len(conns))
+	for key, c := range conns {
+		e := f.r.event(conn.Event{Key: key, Conn: c})
+		// 項目を空にしたことでキーが重なった場合も、接続を失わないよう番号を付けて区別する。
+		newKey := e.Key
+		for n := 2; ; n++ {
+			if _, exists := out[newKey]; !exists {
+				break
+			}
+			newKey = fmt.Sprintf("%s (%d)", e.Key, n)
+		}
+		out[newKey] = e.Conn
+	}
+	f.outputFormatter.writeSnapshot(timestamp, out)
+}
+
+func (f *redactingFormatter) writeStats(timestamp time.Time, stats []processStats, perf *conn.TCPPerf) {
+	out := slices.Clone(stats)
+	for i := range out {
+		c := conn.Connection{ProcessName: out[i].Process, PID: out[i].PID}
+		f.r.apply(&c)
+		out[i].Process, out[i].PID = c.ProcessName, c.PID
+	}
+	f.outputFormatter.writeStats(timestamp, out, perf)
+}
+
+// withRedaction は -fields/-redact が指定されていれば、項目を置き換えてから formatter へ渡す outputFormatter を返す。
+func (o *options) withRedaction(formatter outputFormatter) (outputFormatter, error) {
+	r, err := o.redactor()
+	if err != nil || r == nil {
+		return formatter, err
+	}
+	return &redactingFormatter{outputFormatter: formatter, r: r}, nil
+}
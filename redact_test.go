@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// loopbackConn は app.exe から db.exe (PID 200) が待ち受ける 127.0.0.1:5432 へのループバック接続を返す。
+func loopbackConn() conn.Connection {
+	return conn.Connection{
+		Protocol: "TCP", ProcessName: "app.exe", PID: 100,
+		LocalAddr: "127.0.0.1", LocalPort: 50000, RemoteAddr: "127.0.0.1", RemotePort: 5432, State: "ESTABLISHED",
+		Peer: &conn.Peer{ProcessName: "db.exe", PID: 200, Server: true},
+	}
+}
+
+func TestRedactorPeer(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     options
+		wantName func(r *redactor) string // 相手側のプロセス名
+		wantPID  uint32
+	}{
+		{"-redact process", options{redact: "process"}, func(r *redactor) string { return r.hash("db.exe") }, 200},
+		{"-fields に process が無い", options{fields: "pid,peer"}, func(*redactor) string { return "" }, 200},
+		{"-fields に pid が無い", options{fields: "process,peer"}, func(*redactor) string { return "db.exe" }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.redactKey = "key"
+			r, err := tt.opts.redactor()
+			if err != nil {
+				t.Fatal(err)
+			}
+			original := loopbackConn()
+			e := r.event(conn.Event{Type: conn.EventNew, Key: original.Key(), Conn: original, Detail: "peer: db.exe (PID 200)"})
+			if e.Conn.Peer == nil {
+				t.Fatal("Peer = nil")
+			}
+			if want := tt.wantName(r); e.Conn.Peer.ProcessName != want {
+				t.Errorf("Peer.ProcessName = %q, want %q", e.Conn.Peer.ProcessName, want)
+			}
+			if e.Conn.Peer.PID != tt.wantPID {
+				t.Errorf("Peer.PID = %d, want %d", e.Conn.Peer.PID, tt.wantPID)
+			}
+			if tt.wantName(r) != "db.exe" && strings.Contains(e.Detail, "db.exe") {
+				t.Errorf("Detail = %q に相手側のプロセス名が残っている", e.Detail)
+			}
+			if original.Peer.ProcessName != "db.exe" || original.Peer.PID != 200 {
+				t.Errorf("元の接続の Peer = %+v が書き換えられた", *original.Peer)
+			}
+		})
+	}
+}
+
+func TestRedactorOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    options
+		wantNil bool
+		wantErr bool
+	}{
+		{"未指定", options{}, true, false},
+		{"-fields", options{fields: "process, Remote_Addr"}, false, false},
+		{"-fields に不明な項目", options{fields: "process,address"}, false, true},
+		{"-redact", options{redact: "user,remote_addr"}, false, false},
+		{"-redact に文字列以外の項目", options{redact: "pid"}, false, true},
+		{"-redact に不明な項目", options{redact: "password"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := tt.opts.redactor()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("redactor = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (r == nil) != tt.wantNil {
+				t.Errorf("redactor = %v, wantNil %v", r, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestRedactorEvent(t *testing.T) {
+	base := conn.Connection{
+		Protocol: "TCP", ProcessName: "app.exe", PID: 100, User: `CORP\alice`,
+		LocalAddr: "10.0.0.1", LocalPort: 50000, RemoteAddr: "10.0.0.2", RemotePort: 443, State: "ESTABLISHED",
+	}
+	detail := `app.exe (CORP\alice) → 10.0.0.2 ESTABLISHED`
+
+	tests := []struct {
+		name  string
+		opts  options
+		owner bool // キーに OwnerKey (Key 以外の形式) を使う
+		// want は base を置き換えた後の接続に書き換え、置き換えた後のキーと説明を返す。
+		want func(r *redactor, c *conn.Connection) (key, detail string)
+	}{
+		{"-fields に含まれない項目を空にする", options{fields: "protocol,process,local_addr,local_port,remote_port,state"}, false,
+			func(r *redactor, c *conn.Connection) (string, string) {
+				c.PID, c.User, c.RemoteAddr = 0, "", ""
+				return "TCP 10.0.0.1:50000 -> :443", "app.exe () →  ESTABLISHED"
+			}},
+		{"-redact をハッシュに置き換える", options{redact: "remote_addr,user"}, false,
+			func(r *redactor, c *conn.Connection) (string, string) {
+				c.User, c.RemoteAddr = r.hash(`CORP\alice`), r.hash("10.0.0.2")
+				return "TCP 10.0.0.1:50000 -> " + net.JoinHostPort(c.RemoteAddr, "443"), "app.exe (" + c.User + ") → " + c.RemoteAddr + " ESTABLISHED"
+			}},
+		{"列挙値は説明の中を置き換えない", options{redact: "state"}, false,
+			func(r *redactor, c *conn.Connection) (string, string) {
+				c.State = r.hash("ESTABLISHED")
+				return c.Key(), detail
+			}},
+		{"Key 以外の形式のキー", options{redact: "remote_addr"}, true,
+			func(r *redactor, c *conn.Connection) (string, string) {
+				c.RemoteAddr = r.hash("10.0.0.2")
+				return c.OwnerKey(), "app.exe (CORP\\alice) → " + c.RemoteAddr + " ESTABLISHED"
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.redactKey = "key"
+			r, err := tt.opts.redactor()
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := base.Key()
+			if tt.owner {
+				key = base.OwnerKey()
+			}
+			got := r.event(conn.Event{Type: conn.EventNew, Key: key, Conn: base, Detail: detail})
+			want := base
+			wantKey, wantDetail := tt.want(r, &want)
+			if !reflect.DeepEqual(got.Conn, want) {
+				t.Errorf("Conn = %+v, want %+v", got.Conn, want)
+			}
+			if got.Key != wantKey {
+				t.Errorf("Key = %q, want %q", got.Key, wantKey)
+			}
+			if got.Detail != wantDetail {
+				t.Errorf("Detail = %q, want %q", got.Detail, wantDetail)
+			}
+		})
+	}
+}
+
+// snapshotRecorder は writeSnapshot に渡された接続一覧を保持する outputFormatter。
+type snapshotRecorder struct {
+	discardFormatter
+	conns conn.Snapshot
+}
+
+func (f *snapshotRecorder) writeSnapshot(_ time.Time, conns conn.Snapshot) { f.conns = conns }
+
+func TestRedactingFormatterSnapshotKeys(t *testing.T) {
+	a := conn.Connection{Protocol: "TCP", ProcessName: "app.exe", LocalAddr: "10.0.0.1", LocalPort: 50000, RemoteAddr: "10.0.0.2", RemotePort: 443}
+	b := a
+	b.RemoteAddr = "10.0.0.3"
+	out := &snapshotRecorder{}
+	f, err := (&options{fields: "process,local_addr,local_port,remote_port"}).withRedaction(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.writeSnapshot(time.Now(), conn.Snapshot{a.Key(): a, b.Key(): b})
+
+	keys := make([]string, 0, len(out.conns))
+	for key := range out.conns {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	// 項目を空にして重なったキーには番号を付け、どちらの接続も残す。
+	want := []string{"TCP 10.0.0.1:50000 -> :443", "TCP 10.0.0.1:50000 -> :443 (2)"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("キー = %q, want %q", keys, want)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 設定ファイルの再読み込み (-watch-config) ---
+// monitor を再起動すると前回の接続の状態が失われ、既存の接続が全て NEW として出力されてしまう。
+// -watch-config を指定すると設定ファイルの変更を検出し、監視対象とフィルターだけを状態を保ったまま入れ替える。
+// 出力形式や出力先などの変更は再起動するまで反映しない。
+
+// eventConfigReloaded は、設定ファイルを再読み込みして新しい監視対象を適用したことを示すイベントの種別。
+const eventConfigReloaded conn.EventType = "CONFIG_RELOADED"
+
+// configWatchInterval は設定ファイルの更新日時を確認する間隔。
+const configWatchInterval = 2 * time.Second
+
+// configWatcher は設定ファイルの更新日時を一定間隔で確認する。
+type configWatcher struct {
+	path    string
+	modTime time.Time
+	ticker  *time.Ticker
+}
+
+// configWatcher は -watch-config と -c が指定されていれば configWatcher を返す。未指定の場合は nil を返す。
+func (o *options) configWatcher() *configWatcher {
+	if !o.watchConfig || o.configFile == "" {
+		return nil
+	}
+	w := &configWatcher{path: o.configFile, ticker: time.NewTicker(configWatchInterval)}
+	if info, err := os.Stat(o.configFile); err == nil {
+		w.modTime = info.ModTime()
+	}
+	return w
+}
+
+// C は更新日時を確認する時刻を知らせる。nil の場合は何も届かないチャネル (nil) を返す。
+func (w *configWatcher) C() <-chan time.Time {
+	if w == nil {
+		return nil
+	}
+	return w.ticker.C
+}
+
+func (w *configWatcher) Stop() {
+	if w != nil {
+		w.ticker.Stop()
+	}
+}
+
+// changed は前回の確認から設定ファイルが更新されたかを返す。
+func (w *configWatcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil || info.ModTime().Equal(w.modTime) {
+		return false
+	}
+	w.modTime = info.ModTime()
+	return true
+}
+
+// reloadFilters は起動時のコマンドラインと設定ファイルを解析し直し、監視対象とフィルターの指定を o に反映する。
+// コマンドラインには monitor 以外 (agent, service など) のフラグも含まれるため、実行中のサブコマンドのフラグで解析する。
+// 解析に失敗した場合や新しい指定が不正な場合は、o を変更せずにエラーを返す。
+func (o *options) reloadFilters() (conn.Filter, string, error) {
+	name, _, _ := strings.Cut(o.command, " ")
+	fs := flag.NewFlagSet(o.command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var next *options
+	if c, ok := findCommand(name); ok && c.flags != nil {
+		next = c.flags(fs)
+	}
+	if next == nil {
+		return conn.Filter{}, "", fmt.Errorf(tr("%s は設定ファイルの再読み込みに対応していません"), o.command)
+	}
+	if err := fs.Parse(o.args); err != nil {
+		return conn.Filter{}, "", err
+	}
+	if err := applyConfigFile(fs, o.configFile); err != nil {
+		return conn.Filter{}, "", err
+	}
+	profiles, err := loadProfiles(o.configFile)
+	if err != nil {
+		return conn.Filter{}, "", err
+	}
+	next.profiles = profiles
+
+	candidate := *o
+	candidate.processNames, candidate.pids = next.processNames, next.pids
+	candidate.ipv4Only, candidate.ipv6Only, candidate.dual = next.ipv4Only, next.ipv6Only, next.dual
+	candidate.includeListen, candidate.includeBound = next.includeListen, next.includeBound
+	candidate.protocols, candidate.remoteAddrs, candidate.scopes = next.protocols, next.remoteAddrs, next.scopes
+	candidate.localPorts, candidate.remotePorts, candidate.states = next.localPorts, next.remotePorts, next.states
+	candidate.containers, candidate.tree, candidate.regex = next.containers, next.tree, next.regex
+	candidate.excludeNames, candidate.excludePIDs = next.excludeNames, next.excludePIDs
+	candidate.excludeRemoteAddrs, candidate.excludeRemotePorts = next.excludeRemoteAddrs, next.excludeRemotePorts
+	candidate.profiles = next.profiles
+	filter, monitorTarget, err := candidate.connFilter()
+	if err != nil {
+		return conn.Filter{}, "", err
+	}
+	*o = candidate
+	return filter, monitorTarget, nil
+}
+
+// pruneSnapshot は新しい filter の対象外になった接続を prev から取り除き、次の取得で CLOSED として出力されないようにする。
+// 子孫プロセスの判定 (-tree) は記録済みの接続だけでは行えないため、その場合は取り除かない。
+func pruneSnapshot(prev conn.Snapshot, filter conn.Filter) {
+	if filter.IncludeDescendants {
+		return
+	}
+	for key, c := range prev {
+		if !filter.Match(c) {
+			delete(prev, key)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReloadFiltersCommandFlags は monitor 以外のサブコマンドのコマンドラインと設定ファイルでも、
+// -watch-config の再読み込みで新しい監視対象を反映できることを確認する。
+func TestReloadFiltersCommandFlags(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		config  string // 再読み込みの前後で共通の設定 (サブコマンド固有の項目)
+	}{
+		{"agent", []string{"-collector", "collector01:9479", "-insecure", "-hostname", "web01"}, "ca: ca.pem\n"},
+		{"service run", []string{"-name", "ObuStat2"}, "name: ObuStat2\n"},
+		{"baseline record", []string{"-out", "baseline.yaml"}, ""},
+		{"export", []string{"-out", "conn.ndjson", "-duration", "1h"}, "to-format: parquet\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			config := filepath.Join(t.TempDir(), "obustat.yaml")
+			writeConfig := func(target string) {
+				if err := os.WriteFile(config, []byte(tt.config+"n: "+target+"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeConfig("app.exe")
+
+			name, _, _ := strings.Cut(tt.command, " ")
+			c, ok := findCommand(name)
+			if !ok {
+				t.Fatalf("findCommand(%q) が見つからない", name)
+			}
+			fs := flag.NewFlagSet(tt.command, flag.ContinueOnError)
+			opts := c.flags(fs)
+			args := append([]string{"-c", config}, tt.args...)
+			if err := parseFlags(fs, opts, args); err != nil {
+				t.Fatalf("parseFlags = %v", err)
+			}
+
+			writeConfig("other.exe")
+			if _, target, err := opts.reloadFilters(); err != nil {
+				t.Fatalf("reloadFilters = %v", err)
+			} else if target != "other.exe" {
+				t.Errorf("監視対象 = %q, want %q", target, "other.exe")
+			}
+			if opts.processNames != "other.exe" {
+				t.Errorf("processNames = %q, want %q", opts.processNames, "other.exe")
+			}
+		})
+	}
+}
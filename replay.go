@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- replay モード (記録の再生) ---
+// -format json の出力、または -store の SQLite データベースから接続の推移を組み立て直し、
+// 指定したフィルタで差分と ALERT の判定 (-collapse-timewait, -debounce を含む) をやり直す。
+// 例: replay -n java.exe -rport 1433 -alert-count 50 monitor.json
+func runReplayMode(args []string) error {
+	fs := newFlagSet("replay")
+	opts := setupReplayFlags(fs)
+	if err := parseFlags(fs, opts, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitStatus(exitUsage)
+	}
+	if opts.processNames == "" && opts.pids == "" {
+		// 記録の再生では、プロセスを指定しなければ全てのプロセスを対象にする。
+		opts.pids = "0"
+	}
+
+	frames, err := loadRecording(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf(tr("記録を読み込めませんでした: %w"), err)
+	}
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	grouper, err := opts.eventGrouper()
+	if err != nil {
+		return err
+	}
+	formatter, err := opts.newFormatter()
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	infoLog.Print(tr("--- 再生モード開始 ---"))
+	infoLog.Printf(tr("記録: %s (%d 件)"), fs.Arg(0), len(frames))
+	infoLog.Printf(tr("監視対象: %s"), monitorTarget)
+	replayFrames(frames, filter, opts.alertTracker(), opts.timeWaitCollapser(), opts.debouncer(), grouper, formatter)
+	return nil
+}
+
+// setupReplayFlags は replay のフラグを定義する。
+func setupReplayFlags(fs *flag.FlagSet) *options {
+	return setupFlags(fs, filterFlags, outputFlags, sinkFlags, redactFlags, enrichFlags, eventFlags)
+}
+
+// replayFrames は記録から各時点の接続一覧を組み立て、filter で絞り込んだうえで差分を出力する。
+func replayFrames(frames []replayFrame, filter conn.Filter, alerts *alertTracker, timeWait *timeWaitCollapser, debounce *debouncer, grouper *eventGrouper, formatter outputFormatter) {
+	stats := newSessionStats()
+	if len(frames) > 0 {
+		stats.start = frames[0].timestamp
+		stats.end = frames[len(frames)-1].timestamp
+	}
+	recorded := make(conn.Snapshot)
+	prevConns := make(conn.Snapshot)
+	for _, frame := range frames {
+		if frame.snapshot != nil {
+			recorded = maps.Clone(frame.snapshot)
+		}
+		for _, e := range frame.events {
+			switch e.Type {
+			case conn.EventNew, conn.EventChange, conn.EventRebound:
+				recorded[e.Key] = e.Conn
+			case conn.EventClosed:
+				delete(recorded, e.Key)
+			}
+		}
+
+		currentConns := make(conn.Snapshot)
+		for key, c := range recorded {
+			if filter.Match(c) {
+				currentConns[key] = c
+			}
+		}
+		stats.observe(currentConns)
+		events := grouper.group(debounce.apply(frame.timestamp, timeWait.apply(conn.Diff(prevConns, currentConns))))
+		events = append(events, alerts.check(currentConns)...)
+		if len(events) > 0 {
+			stats.countEvents(events)
+			formatter.writeEvents(frame.timestamp, events)
+		}
+		prevConns = currentConns
+	}
+	if pending := grouper.group(debounce.flush()); len(pending) > 0 && len(frames) > 0 {
+		stats.countEvents(pending)
+		formatter.writeEvents(frames[len(frames)-1].timestamp, pending)
+	}
+	stats.logSummary()
+}
+
+// replayFrame は記録の 1 時点分で、状態変化か接続一覧のどちらかを持つ。
+type replayFrame struct {
+	timestamp time.Time
+	events    []conn.Event
+	snapshot  conn.Snapshot
+}
+
+// frameRecorder は出力の代わりに記録を replayFrame として蓄える outputFormatter。
+type frameRecorder struct {
+	frames []replayFrame
+}
+
+func (r *frameRecorder) writeEvents(timestamp time.Time, events []conn.Event) {
+	r.frames = append(r.frames, replayFrame{timestamp: timestamp, events: events})
+}
+
+func (r *frameRecorder) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	r.frames = append(r.frames, replayFrame{timestamp: timestamp, snapshot: conns})
+}
+
+func (r *frameRecorder) writeUnchanged(time.Time, int) {}
+
+func (r *frameRecorder) writeStats(time.Time, []processStats, *conn.TCPPerf) {}
+
+// loadRecording は拡張子が .db/.sqlite の場合は -store のデータベース、それ以外は JSON の出力として読み込む。
+func loadRecording(path string) ([]replayFrame, error) {
+	var rec frameRecorder
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".sqlite", ".sqlite3":
+		err = loadStore(path, &rec)
+	default:
+		err = loadJSONLines(path, &rec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 同じ時刻の状態変化と接続一覧は、記録した順 (状態変化が先) のまま並べる。
+	sort.SliceStable(rec.frames, func(i, j int) bool { return rec.frames[i].timestamp.Before(rec.frames[j].timestamp) })
+	return rec.frames, nil
+}
+
+func loadStore(path string, rec *frameRecorder) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := openStoreDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var q storeQuery
+	if err := q.writeEvents(db, rec); err != nil {
+		return err
+	}
+	rows, err := db.Query(`SELECT id, ts FROM snapshots ORDER BY ts, id`)
+	if err != nil {
+		return err
+	}
+	type storedSnapshot struct{ id, ts int64 }
+	var snapshots []storedSnapshot
+	for rows.Next() {
+		var s storedSnapshot
+		if err := rows.Scan(&s.id, &s.ts); err != nil {
+			rows.Close()
+			return err
+		}
+		snapshots = append(snapshots, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		conns, err := q.snapshotConnections(db, s.id)
+		if err != nil {
+			return err
+		}
+		rec.writeSnapshot(time.UnixMilli(s.ts), conns)
+	}
+	return nil
+}
+
+// loadJSONLines は -format json の出力を読み込む。JSON 以外の行 (開始・終了のログなど) と STATS は読み飛ばす。
+func loadJSONLines(path string, rec *frameRecorder) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// 接続一覧は 1 行にまとめて出力されるため、大きな行も読めるようにする。
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var batch []conn.Event
+	var batchTime string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		t, err := parseRecordedTime(batchTime)
+		if err != nil {
+			return err
+		}
+		rec.writeEvents(t, batch)
+		batch = nil
+		return nil
+	}
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var header struct {
+			Timestamp string `json:"timestamp"`
+			Event     string `json:"event"`
+		}
+		if err := json.Unmarshal(line, &header); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if header.Timestamp != batchTime {
+			if err := flush(); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+		}
+		switch conn.EventType(header.Event) {
+		case "STATS":
+			continue
+		case eventSnapshot:
+			var js jsonSnapshot
+			if err := json.Unmarshal(line, &js); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			t, err := parseRecordedTime(js.Timestamp)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			conns := make(conn.Snapshot, len(js.Connections))
+			for _, jc := range js.Connections {
+				c := fromJSONConnection(jc)
+				conns[c.Key()] = c
+			}
+			rec.writeSnapshot(t, conns)
+		default:
+			var je jsonEvent
+			if err := json.Unmarshal(line, &je); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			e := conn.Event{Type: conn.EventType(je.Event), Conn: fromJSONConnection(je.jsonConnection), PrevState: je.PrevState, Count: je.Count, Detail: je.Detail}
+			e.Key = e.Conn.Key()
+			e.Conn.FirstSeen, _ = parseRecordedTime(je.FirstSeen)
+			batch = append(batch, e)
+		}
+		batchTime = header.Timestamp
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+func fromJSONConnection(jc jsonConnection) conn.Connection {
+	c := conn.Connection{
+		Protocol: jc.Protocol, ProcessName: jc.Process, PID: jc.PID,
+		LocalAddr: jc.LocalAddr, LocalPort: jc.LocalPort, LocalInterface: jc.LocalInterface,
+		RemoteAddr: jc.RemoteAddr, RemotePort: jc.RemotePort, RemoteHost: jc.RemoteHost,
+		RemoteService: jc.RemoteService,
+		State:         jc.State,
+		ImagePath:     jc.ImagePath, CommandLine: jc.CommandLine, User: jc.User,
+		Module: jc.Module, Services: jc.Services,
+		Container: jc.Container, InJob: jc.InJob, Host: jc.Host,
+		Country: jc.Country, ASN: jc.ASN, ASOrg: jc.ASOrg,
+	}
+	c.ProcessStart, _ = parseRecordedTime(jc.ProcessStart)
+	return c
+}
+
+// parseRecordedTime は json 出力のタイムスタンプを解析する。-ts time の記録は日付を含まないため再生できない。
+func parseRecordedTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, timestampLayouts["datetime"]} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(tr("タイムスタンプを解析できません: %q (-ts time の記録は再生できません)"), s)
+}
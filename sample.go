@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- イベントの間引き (-sample, -max-eps) ---
+// 数万の接続があるターミナルサーバーなどで、出力先が接続の変化のイベントで溢れないようにする。
+// -sample 1/10 は接続のキーのハッシュで 10 件に 1 件の接続を選び、選んだ接続の NEW から CLOSED までを全て出力する。
+// -max-eps は 1 秒あたりに出力するイベントの上限で、超えた分は捨てて DROPPED イベントで件数を知らせる。
+// ALERT、ANOMALY などの判定結果は間引かない。
+
+// eventDropped は、-max-eps の上限を超えて捨てたイベントの件数を知らせるイベントの種別。
+const eventDropped conn.EventType = "DROPPED"
+
+// eventSampler は接続の変化のイベントを -sample と -max-eps に従って間引く。
+type eventSampler struct {
+	every   uint32 // -sample 1/N の N (1 は間引かない)
+	maxRate int    // -max-eps (0 は上限なし)
+
+	window   time.Time // 現在の 1 秒間の始まり
+	inWindow int       // 現在の 1 秒間に出力したイベントの数
+	dropped  int       // 現在の 1 秒間に上限を超えて捨てたイベントの数
+
+	totalSampled int // -sample で出力しなかったイベントの総数
+	totalDropped int // -max-eps で捨てたイベントの総数
+}
+
+// eventSampler は -sample または -max-eps が指定されていれば eventSampler を返す。未指定の場合は nil を返す。
+func (o *options) eventSampler() (*eventSampler, error) {
+	if o.sample == "" && o.maxEventsPerSecond <= 0 {
+		return nil, nil
+	}
+	s := &eventSampler{every: 1, maxRate: o.maxEventsPerSecond}
+	if o.sample != "" {
+		denominator, ok := strings.CutPrefix(strings.TrimSpace(o.sample), "1/")
+		n, err := strconv.ParseUint(denominator, 10, 32)
+		if !ok || err != nil || n == 0 {
+			return nil, usageErrorf(tr("-sample の指定が不正です: %q (例: 1/10)"), o.sample)
+		}
+		s.every = uint32(n)
+	}
+	return s, nil
+}
+
+// isConnectionEvent は、間引きの対象になる接続の変化のイベントかを返す。
+func isConnectionEvent(e conn.Event) bool {
+	switch e.Type {
+	case conn.EventNew, conn.EventChange, conn.EventClosed, conn.EventRebound, eventFlap:
+		return true
+	}
+	return false
+}
+
+// sampled は、キーのハッシュで接続を選ぶ。同じ接続のイベントは常に同じ結果になる。
+func (s *eventSampler) sampled(key string) bool {
+	if s.every <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()%s.every == 0
+}
+
+// apply は now に検出したイベントを間引いて返す。前の 1 秒間に上限を超えて捨てたイベントがあれば、DROPPED を先頭に加える。
+func (s *eventSampler) apply(now time.Time, events []conn.Event) []conn.Event {
+	if s == nil {
+		return events
+	}
+	var out []conn.Event
+	if now.Sub(s.window) >= time.Second {
+		if s.dropped > 0 {
+			out = append(out, conn.Event{
+				Type:   eventDropped,
+				Count:  s.dropped,
+				Detail: fmt.Sprintf(tr("1 秒あたり %d 件の上限を超えたため、%d 件のイベントを出力しませんでした"), s.maxRate, s.dropped),
+			})
+		}
+		s.window, s.inWindow, s.dropped = now, 0, 0
+	}
+	for _, e := range events {
+		if !isConnectionEvent(e) {
+			out = append(out, e)
+			continue
+		}
+		if !s.sampled(e.Key) {
+			s.totalSampled++
+			continue
+		}
+		if s.maxRate > 0 && s.inWindow >= s.maxRate {
+			s.dropped++
+			s.totalDropped++
+			continue
+		}
+		s.inWindow++
+		out = append(out, e)
+	}
+	return out
+}
+
+// logSummary は間引いたイベントの件数を、監視終了時のサマリーに加える。
+func (s *eventSampler) logSummary() {
+	if s == nil {
+		return
+	}
+	if s.every > 1 {
+		infoLog.Printf(tr("-sample 1/%d で出力しなかったイベント: %d 件"), s.every, s.totalSampled)
+	}
+	if s.maxRate > 0 {
+		infoLog.Printf(tr("-max-eps の上限を超えて出力しなかったイベント: %d 件"), s.totalDropped)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- ポートスキャン・接続先の急増の検出 (-scan-hosts, -scan-ports) ---
+// eventScan は、1 つのプロセスが -scan-window の期間内に多数の異なるリモートホストまたはポートへ接続したことを表すイベントの種別。
+// 乗っ取られたプロセスによる内部の探索などを、早い段階で捉えるために使う。
+const eventScan conn.EventType = "SCAN"
+
+// scanDetector はプロセスごとに、window 内に新しく接続したリモートのアドレスとポートを保持する。
+// しきい値を超えた時点で 1 回だけ SCAN を発生させ、下回ったら再び発生させられるようにする。
+type scanDetector struct {
+	window   time.Duration
+	maxHosts int // 異なるリモートホストの数がこれを超えたら SCAN (0で無効)
+	maxPorts int // 異なるリモートポートの数がこれを超えたら SCAN (0で無効)
+	targets  map[processKey][]scanTarget
+	alerting map[processKey]bool
+}
+
+type scanTarget struct {
+	at   time.Time
+	addr string
+	port uint16
+}
+
+func newScanDetector(window time.Duration, maxHosts, maxPorts int) *scanDetector {
+	return &scanDetector{
+		window: window, maxHosts: maxHosts, maxPorts: maxPorts,
+		targets:  make(map[processKey][]scanTarget),
+		alerting: make(map[processKey]bool),
+	}
+}
+
+// check は now に検出した NEW を記録し、新たにしきい値を超えたプロセスの SCAN イベントを返す。
+func (d *scanDetector) check(now time.Time, events []conn.Event) []conn.Event {
+	if d == nil {
+		return nil
+	}
+	for _, e := range events {
+		if !e.Opened() || e.Conn.RemotePort == 0 {
+			continue
+		}
+		key := processKey{e.Conn.ProcessName, e.Conn.PID}
+		d.targets[key] = append(d.targets[key], scanTarget{at: now, addr: e.Conn.RemoteAddr, port: e.Conn.RemotePort})
+	}
+
+	var scans []conn.Event
+	for _, key := range d.expire(now) {
+		hosts := make(map[string]bool)
+		ports := make(map[uint16]bool)
+		for _, t := range d.targets[key] {
+			hosts[t.addr] = true
+			ports[t.port] = true
+		}
+		var reason string
+		switch {
+		case d.maxHosts > 0 && len(hosts) > d.maxHosts:
+			reason = fmt.Sprintf(tr("直近 %s に %d 個の異なるリモートホストへ接続しました (しきい値 %d)"), d.window, len(hosts), d.maxHosts)
+		case d.maxPorts > 0 && len(ports) > d.maxPorts:
+			reason = fmt.Sprintf(tr("直近 %s に %d 個の異なるリモートポートへ接続しました (しきい値 %d)"), d.window, len(ports), d.maxPorts)
+		default:
+			delete(d.alerting, key)
+			continue
+		}
+		if d.alerting[key] {
+			continue
+		}
+		d.alerting[key] = true
+		c := conn.Connection{ProcessName: key.name, PID: key.pid}
+		scans = append(scans, conn.Event{
+			Type: eventScan, Key: processLabel(c), Conn: c, Count: len(d.targets[key]),
+			Detail: fmt.Sprintf(tr("%s (ホスト: %d, ポート: %d)"), reason, len(hosts), len(ports)),
+		})
+	}
+	return scans
+}
+
+// expire は window より古い記録を捨て、記録が残っているプロセスを名前順に返す。
+func (d *scanDetector) expire(now time.Time) []processKey {
+	keys := make([]processKey, 0, len(d.targets))
+	for key, targets := range d.targets {
+		i := 0
+		for i < len(targets) && now.Sub(targets[i].at) >= d.window {
+			i++
+		}
+		if i == len(targets) {
+			delete(d.targets, key)
+			delete(d.alerting, key)
+			continue
+		}
+		d.targets[key] = targets[i:]
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return processLabel(conn.Connection{ProcessName: keys[i].name, PID: keys[i].pid}) <
+			processLabel(conn.Connection{ProcessName: keys[j].name, PID: keys[j].pid})
+	})
+	return keys
+}
+
+// scanDetector は -scan-hosts か -scan-ports が指定されていれば scanDetector を返す。どちらも未指定の場合は nil を返す。
+func (o *options) scanDetector() *scanDetector {
+	if o.scanHosts <= 0 && o.scanPorts <= 0 {
+		return nil
+	}
+	return newScanDetector(o.scanWindow, o.scanHosts, o.scanPorts)
+}
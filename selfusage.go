@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- ツール自身の CPU・メモリ使用量 (-max-rss) ---
+// 監視対象のアプリケーションの担当者に、監視そのものの負荷が小さいことを示せるよう、
+// 取得ごとに自身の CPU 使用率とワーキングセットを計測して HEARTBEAT と exporter の /metrics に含める。
+// -max-rss を超えた場合は、プロセス情報や名前解決のキャッシュを捨ててメモリを OS に返す。
+
+var (
+	psapi                    = windows.NewLazySystemDLL("psapi.dll")
+	procGetProcessMemoryInfo = psapi.NewProc("GetProcessMemoryInfo")
+)
+
+// processMemoryCounters は PROCESS_MEMORY_COUNTERS 構造体。
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// selfUsage はある時点のツール自身の使用量。
+type selfUsage struct {
+	CPUPercent float64       // 前回の計測からの CPU 使用率 (全コアの合計を 100% とする)
+	CPUTime    time.Duration // 起動からの CPU 時間 (ユーザー + カーネル)
+	RSS        uint64        // ワーキングセット (バイト)
+	HeapAlloc  uint64        // Go のヒープで使用中のバイト数
+}
+
+// cacheTrimmer は -max-rss を超えたときに、保持している内容を捨てられるキャッシュ。
+type cacheTrimmer interface {
+	trim()
+}
+
+// selfMonitor はツール自身の使用量を計測し、-max-rss を超えたらキャッシュを捨てる。
+type selfMonitor struct {
+	mu       sync.Mutex
+	maxRSS   uint64 // 0 は上限なし
+	lastCPU  time.Duration
+	lastAt   time.Time
+	latest   selfUsage
+	trimmers []cacheTrimmer
+}
+
+// self はツール自身の使用量。キャッシュは作成時に registerTrimmer で登録する。
+var self = &selfMonitor{}
+
+func registerTrimmer(t cacheTrimmer) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.trimmers = append(self.trimmers, t)
+}
+
+// setMaxRSS は -max-rss (MB) を設定する。
+func (m *selfMonitor) setMaxRSS(mb int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxRSS = uint64(max(mb, 0)) << 20
+}
+
+// sample は now 時点の使用量を計測して返す。取得できない値は 0 のままにする。
+func (m *selfMonitor) sample(now time.Time) selfUsage {
+	var usage selfUsage
+	h := windows.CurrentProcess()
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err == nil {
+		usage.CPUTime = filetimeDuration(kernel) + filetimeDuration(user)
+	}
+	var counters processMemoryCounters
+	counters.cb = uint32(unsafe.Sizeof(counters))
+	if r, _, _ := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&counters)), uintptr(counters.cb)); r != 0 {
+		usage.RSS = uint64(counters.WorkingSetSize)
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	usage.HeapAlloc = mem.HeapAlloc
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elapsed := now.Sub(m.lastAt); !m.lastAt.IsZero() && elapsed > 0 {
+		usage.CPUPercent = float64(usage.CPUTime-m.lastCPU) / float64(elapsed) / float64(runtime.NumCPU()) * 100
+	}
+	m.lastCPU, m.lastAt, m.latest = usage.CPUTime, now, usage
+	return usage
+}
+
+// current は最後に計測した使用量を返す。
+func (m *selfMonitor) current() selfUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.latest
+}
+
+// enforce は usage が -max-rss を超えていれば、登録されたキャッシュを捨ててメモリを OS に返す。
+// キャッシュは監視のループから使われるため、同じループから呼び出すこと。
+func (m *selfMonitor) enforce(usage selfUsage) {
+	m.mu.Lock()
+	if m.maxRSS == 0 || usage.RSS <= m.maxRSS {
+		m.mu.Unlock()
+		return
+	}
+	trimmers := m.trimmers
+	m.mu.Unlock()
+
+	for _, t := range trimmers {
+		t.trim()
+	}
+	debug.FreeOSMemory()
+	warnLog.Printf(tr("警告: メモリ使用量 (%s) が -max-rss を超えたため、キャッシュを破棄しました"), formatBytes(usage.RSS))
+}
+
+// describe は -vv と HEARTBEAT に出力する使用量の説明。
+func (u selfUsage) describe() string {
+	return fmt.Sprintf("CPU: %.1f%%, RSS: %s, heap: %s", u.CPUPercent, formatBytes(u.RSS), formatBytes(u.HeapAlloc))
+}
+
+// filetimeDuration は FILETIME で表された期間 (100 ナノ秒単位) を time.Duration にする。
+func filetimeDuration(ft windows.Filetime) time.Duration {
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
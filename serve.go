@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- serve モード (HTTP API) ---
+// eventSubscriberBuffer は /events の購読者ごとに保持するイベントのまとまりの数。
+// 読み出しが追いつかない購読者には、あふれた分を届けない。
+const eventSubscriberBuffer = 64
+
+func runServeMode(args []string) error {
+	fs := newFlagSet("serve")
+	opts := setupServeFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+	if err := opts.security.loadFlags(false); err != nil {
+		return err
+	}
+
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	enrichers, err := opts.enrichers()
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	if err := opts.checkPrivileges(); err != nil {
+		return err
+	}
+
+	ctx, stop := opts.runContext()
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	state := newLiveState(enrichers)
+	metrics := newMetricsRegistry()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/connections", state.serveConnections)
+	mux.HandleFunc("/events", state.serveEvents)
+	mux.Handle("/metrics", metrics)
+	if !opts.noDashboard {
+		mux.Handle("/", dashboardHandler())
+		opts.security.publicRoot = true
+	}
+	server := &http.Server{Addr: opts.listenAddr, Handler: mux, BaseContext: func(net.Listener) context.Context { return ctx }}
+
+	infoLog.Print(tr("--- HTTP API モード開始 ---"))
+	infoLog.Printf(tr("監視対象: %s"), monitorTarget)
+	infoLog.Printf(tr("待ち受け: %s://%s/ (ダッシュボード), /connections, /events, /metrics (実行間隔: %d ミリ秒, Ctrl+Cで停止)"), opts.security.scheme(), opts.listenAddr, opts.intervalMilliseconds)
+	if opts.security.token != "" && !opts.noDashboard {
+		infoLog.Print(tr("ダッシュボードは /?token=<トークン> で開いてください"))
+	}
+
+	listener, err := opts.security.listen(opts.listenAddr)
+	if err != nil {
+		return fmt.Errorf(tr("HTTP サーバーを開始できませんでした: %w"), err)
+	}
+	serveErr := opts.security.startHTTP(server, listener, cancel)
+
+	if opts.grpcListen != "" {
+		stopGRPC, err := serveGRPC(ctx, opts.grpcListen, state, opts.security)
+		if err != nil {
+			server.Close()
+			return fmt.Errorf(tr("gRPC API を開始できませんでした: %w"), err)
+		}
+		defer stopGRPC()
+		infoLog.Printf("gRPC API: %s (ListConnections, WatchEvents)", opts.grpcListen)
+	}
+
+	pollLiveState(ctx, filter, time.Duration(opts.intervalMilliseconds)*time.Millisecond, state, metrics)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	server.Shutdown(shutdownCtx)
+	return <-serveErr
+}
+
+// serveOptions は serve のオプション。
+type serveOptions struct {
+	*options
+	listenAddr  string
+	noDashboard bool
+	grpcListen  string
+	security    *listenSecurity
+}
+
+// setupServeFlags は serve のフラグを定義する。
+func setupServeFlags(fs *flag.FlagSet) *serveOptions {
+	opts := &serveOptions{options: setupFlags(fs, filterFlags, pollFlags, periodFlags, outputFlags, enrichFlags)}
+	fs.StringVar(&opts.listenAddr, "listen", ":9478", tr("HTTP の待ち受けアドレス"))
+	fs.BoolVar(&opts.noDashboard, "no-dashboard", false, tr("Web ダッシュボード (/) を提供しない"))
+	fs.StringVar(&opts.grpcListen, "grpc-listen", "", tr("gRPC API (obustatpb/obustat.proto の Monitor サービス) の待ち受けアドレス (例: :9480, 省略時は提供しない)"))
+	opts.security = setupListenSecurity(fs)
+	return opts
+}
+
+func pollLiveState(ctx context.Context, filter conn.Filter, interval time.Duration, state *liveState, metrics *metricsRegistry) {
+	collector := conn.NewCollector(filter)
+	prevConns := make(conn.Snapshot)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		currentConns, err := collector.Collect()
+		if err != nil {
+			warnLog.Printf(tr("エラー: 接続情報の取得に失敗: %v"), connError(err))
+			metrics.recordError()
+			continue
+		}
+		events := conn.Diff(prevConns, currentConns)
+		metrics.update(currentConns, events)
+		state.update(currentConns, events)
+		prevConns = currentConns
+	}
+}
+
+// liveState は最新の接続一覧を保持し、状態変化を /events の購読者へ配信する。
+type liveState struct {
+	enrichers connEnrichers
+
+	mu          sync.Mutex
+	current     conn.Snapshot
+	updated     time.Time
+	subscribers map[chan eventBatch]struct{}
+}
+
+type eventBatch struct {
+	time   time.Time
+	events []conn.Event
+}
+
+func newLiveState(enrichers connEnrichers) *liveState {
+	return &liveState{
+		enrichers:   enrichers,
+		current:     make(conn.Snapshot),
+		subscribers: make(map[chan eventBatch]struct{}),
+	}
+}
+
+func (s *liveState) update(current conn.Snapshot, events []conn.Event) {
+	s.enrichers.snapshot(current)
+	s.enrichers.events(events)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = current
+	s.updated = now
+	if len(events) == 0 {
+		return
+	}
+	sortEvents(events)
+	for ch := range s.subscribers {
+		select {
+		case ch <- eventBatch{time: now, events: events}:
+		default:
+		}
+	}
+}
+
+func (s *liveState) subscribe() chan eventBatch {
+	ch := make(chan eventBatch, eventSubscriberBuffer)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (s *liveState) unsubscribe(ch chan eventBatch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscribers, ch)
+}
+
+// connectionQuery は /connections と /events のクエリパラメータによる絞り込み条件。
+// process はワイルドカード可、pid・state・port は完全一致 (port はローカル・リモートのどちらか)。
+type connectionQuery struct {
+	processes []string
+	pids      []uint32
+	states    []string
+	ports     []uint16
+}
+
+func parseConnectionQuery(r *http.Request) (connectionQuery, error) {
+	var q connectionQuery
+	values := r.URL.Query()
+	q.processes = splitQueryValues(values["process"])
+	for _, v := range splitQueryValues(values["pid"]) {
+		pid, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return q, fmt.Errorf(tr("不正な pid の指定です: %q"), v)
+		}
+		q.pids = append(q.pids, uint32(pid))
+	}
+	if states := strings.Join(values["state"], ","); states != "" {
+		parsed, err := conn.ParseStates(states)
+		if err != nil {
+			return q, connError(err)
+		}
+		q.states = parsed
+	}
+	for _, v := range splitQueryValues(values["port"]) {
+		port, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return q, fmt.Errorf(tr("不正な port の指定です: %q"), v)
+		}
+		q.ports = append(q.ports, uint16(port))
+	}
+	return q, nil
+}
+
+// splitQueryValues は ?process=a&process=b と ?process=a,b のどちらの指定も受け付ける。
+func splitQueryValues(values []string) []string {
+	var items []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+func (q connectionQuery) match(c conn.Connection) bool {
+	if len(q.processes) > 0 && !matchAny(q.processes, func(p string) bool { return conn.MatchProcessName(c.ProcessName, p) }) {
+		return false
+	}
+	if len(q.pids) > 0 && !matchAny(q.pids, func(pid uint32) bool { return c.PID == pid }) {
+		return false
+	}
+	if len(q.states) > 0 && !matchAny(q.states, func(state string) bool { return c.State == state }) {
+		return false
+	}
+	if len(q.ports) > 0 && !matchAny(q.ports, func(port uint16) bool { return c.LocalPort == port || c.RemotePort == port }) {
+		return false
+	}
+	return true
+}
+
+func matchAny[T any](items []T, match func(T) bool) bool {
+	for _, item := range items {
+		if match(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveConnections は最新の接続一覧を snapshot と同じ JSON 形式で返す。
+func (s *liveState) serveConnections(w http.ResponseWriter, r *http.Request) {
+	q, err := parseConnectionQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	updated := s.updated
+	matched := make(conn.Snapshot)
+	for key, c := range s.current {
+		if q.match(c) {
+			matched[key] = c
+		}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(toJSONSnapshot(updated, matched))
+}
+
+// serveEvents は状態変化をクライアントが切断するまで送り続ける。
+// Accept: text/event-stream (または ?stream=sse) の場合は Server-Sent Events で、
+// それ以外は 1 行 1 イベントの JSON (NDJSON) で送る。
+func (s *liveState) serveEvents(w http.ResponseWriter, r *http.Request) {
+	q, err := parseConnectionQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, tr("ストリーミングに対応していません"), http.StatusInternalServerError)
+		return
+	}
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	sse := wantsSSE(r)
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if sse {
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	}
+	flusher.Flush()
+
+	// SSE ではプロキシに切断されないよう、イベントが無い間も定期的にコメント行を送る。
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if !sse {
+				continue
+			}
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case batch := <-ch:
+			for _, e := range batch.events {
+				if !q.match(e.Conn) {
+					continue
+				}
+				if err := writeStreamEvent(w, sse, toJSONEvent(batch.time, e)); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+const (
+	sseRetry     = 3 * time.Second  // 切断時にブラウザが再接続するまでの待ち時間
+	sseKeepAlive = 15 * time.Second // SSE のコメント行を送る間隔
+)
+
+func wantsSSE(r *http.Request) bool {
+	if r.URL.Query().Get("stream") == "sse" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// writeStreamEvent は 1 イベントを書き込む。SSE ではイベント種別 (NEW/CHANGE/CLOSED) を event 行に入れる。
+func writeStreamEvent(w io.Writer, sse bool, je jsonEvent) error {
+	b, err := json.Marshal(je)
+	if err != nil {
+		return err
+	}
+	if sse {
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", je.Event, b)
+	} else {
+		_, err = fmt.Fprintf(w, "%s\n", b)
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// --- service モード ---
+const (
+	defaultServiceName = "ObuStat"
+	serviceDisplayName = "go-ObuStat 接続監視"
+	serviceDescription = "指定したプロセスの TCP/UDP 接続の状態変化を監視し、ログファイルへ記録します。"
+)
+
+func runServiceCommand(args []string) error {
+	c, _ := findCommand("service")
+	if len(args) < 1 {
+		commandFlags(c, "").Usage()
+		return exitStatus(exitUsage)
+	}
+	action, args := args[0], args[1:]
+	if !slices.Contains(c.actions, action) {
+		return usageErrorf(tr("不明なサブコマンドです: %s"), "service "+action)
+	}
+
+	fs := newFlagSet("service " + action)
+	opts := setupServiceFlags(fs)
+	if err := parseFlags(fs, opts.options, args); err != nil {
+		return err
+	}
+
+	var err error
+	switch action {
+	case "install":
+		err = installService(opts.name, opts.options, args)
+	case "uninstall":
+		err = uninstallService(opts.name, opts.eventLogSource)
+	case "run":
+		err = runService(opts.name, opts.options)
+	}
+	if err != nil {
+		return fmt.Errorf(tr("service %s に失敗しました: %w"), action, err)
+	}
+	return nil
+}
+
+// serviceOptions は service のオプション。
+type serviceOptions struct {
+	*options
+	name string
+}
+
+// setupServiceFlags は service のフラグ (monitor のオプションと -name) を定義する。
+func setupServiceFlags(fs *flag.FlagSet) *serviceOptions {
+	opts := &serviceOptions{options: setupFlags(fs, monitorFlagGroups...)}
+	fs.StringVar(&opts.name, "name", defaultServiceName, tr("サービス名"))
+	return opts
+}
+
+func installService(name string, opts *options, args []string) error {
+	// 起動時に誤りに気付けるよう、登録前に監視対象の指定を検証しておく。
+	if _, _, err := opts.connFilter(); err != nil {
+		return err
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exePath, err = filepath.Abs(exePath)
+	if err != nil {
+		return err
+	}
+	if primary, _ := opts.outputTargets(); primary.path == "" {
+		// サービスには標準出力が無いため、既定では実行ファイルと同じ場所に記録する。
+		args = append(args, "-o", filepath.Join(filepath.Dir(exePath), "obustat.log"))
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf(tr("サービス %s は既に登録されています"), name)
+	}
+	s, err := m.CreateService(name, exePath, mgr.Config{
+		DisplayName: serviceDisplayName,
+		Description: serviceDescription,
+		StartType:   mgr.StartAutomatic,
+	}, append([]string{"service", "run"}, args...)...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	fmt.Printf(tr("サービス %s を登録しました。\n"), name)
+	if opts.eventLogSource != "" {
+		if err := installEventSource(opts.eventLogSource); err != nil {
+			return fmt.Errorf(tr("イベントログのソース %s を登録できませんでした: %w"), opts.eventLogSource, err)
+		}
+		fmt.Printf(tr("イベントログのソース %s を登録しました。\n"), opts.eventLogSource)
+	}
+	return nil
+}
+
+// uninstallService はサービスを削除する。eventLogSource を指定した場合はイベントログのソースも削除する。
+func uninstallService(name, eventLogSource string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf(tr("サービス %s が見つかりません: %w"), name, err)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	fmt.Printf(tr("サービス %s を削除しました。\n"), name)
+	if eventLogSource != "" {
+		if err := eventlog.Remove(eventLogSource); err != nil {
+			return fmt.Errorf(tr("イベントログのソース %s を削除できませんでした: %w"), eventLogSource, err)
+		}
+		fmt.Printf(tr("イベントログのソース %s を削除しました。\n"), eventLogSource)
+	}
+	return nil
+}
+
+func runService(name string, opts *options) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		// コンソールから直接起動された場合は通常の monitor と同様に動作させる。
+		return runMonitorModeWith(opts)
+	}
+	return svc.Run(name, &obustatService{opts: opts})
+}
+
+type obustatService struct {
+	opts *options
+}
+
+func (s *obustatService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptPauseAndContinue
+	status <- svc.Status{State: svc.StartPending}
+
+	filter, monitorTarget, err := s.opts.connFilter()
+	if err != nil {
+		return true, uint32(exitCodeOf(err))
+	}
+	formatter, err := s.opts.newFormatter()
+	if err != nil {
+		return true, uint32(exitCodeOf(err))
+	}
+	cfg := s.opts.rotateConfig()
+	if cfg.maxSize == 0 && cfg.maxAge == 0 {
+		// 長期間動かし続けるため、指定が無ければ 1 日ごとにローテーションする。
+		cfg.maxAge = 24 * time.Hour
+	}
+	defer s.opts.closeExtraOutputs()
+	primary, _ := s.opts.outputTargets()
+	output, err := openOutput(primary.path, cfg)
+	if err != nil {
+		return true, 1
+	}
+	defer output.Close()
+	log.SetFlags(0)
+	log.SetOutput(output)
+	// サービスにはコンソールが無いため、-log が無ければ診断ログもデータと同じ出力先に書く。
+	warnLog.SetOutput(output)
+	if s.opts.logFile != "" {
+		logOutput, err := openOutput(s.opts.logFile, cfg)
+		if err != nil {
+			return true, 1
+		}
+		defer logOutput.Close()
+		warnLog.SetOutput(logOutput)
+		infoOutput = warnLog.Writer
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	paused := new(atomic.Bool)
+	done := make(chan struct{})
+	var monitorErr error
+	go func() {
+		defer close(done)
+		monitorErr = runMonitor(ctx, s.opts, filter, monitorTarget, formatter, paused)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+	for {
+		select {
+		case <-done:
+			status <- svc.Status{State: svc.StopPending}
+			if monitorErr != nil {
+				// サービスマネージャーが回復動作を取れるよう、終了コードをサービス固有のコードとして返す。
+				warnLog.Printf(tr("エラー: %v"), monitorErr)
+				return true, uint32(exitCodeOf(monitorErr))
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+				// ドキュメントにある通り、Interrogate には 2 回応答する。
+				time.Sleep(100 * time.Millisecond)
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				<-done
+				return false, 0
+			case svc.Pause:
+				paused.Store(true)
+				infoLog.Print(tr("--- 監視を一時停止しました ---"))
+				status <- svc.Status{State: svc.Paused, Accepts: accepted}
+			case svc.Continue:
+				paused.Store(false)
+				infoLog.Print(tr("--- 監視を再開しました ---"))
+				status <- svc.Status{State: svc.Running, Accepts: accepted}
+			}
+		}
+	}
+}
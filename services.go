@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go-ObuStat/conn"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- リモートポートのサービス名 ---
+// wellKnownServices は主要なポート番号と IANA サービス名の対応表。
+// キーは "ポート/プロトコル" で、TCP/UDP で共通の場合は "ポート" のみとする。
+var wellKnownServices = map[string]string{
+	"20":       "ftp-data",
+	"21":       "ftp",
+	"22":       "ssh",
+	"23":       "telnet",
+	"25":       "smtp",
+	"53":       "domain",
+	"67/udp":   "bootps",
+	"68/udp":   "bootpc",
+	"69/udp":   "tftp",
+	"80":       "http",
+	"88":       "kerberos",
+	"110":      "pop3",
+	"123/udp":  "ntp",
+	"135":      "epmap",
+	"137/udp":  "netbios-ns",
+	"138/udp":  "netbios-dgm",
+	"139":      "netbios-ssn",
+	"143":      "imap",
+	"161/udp":  "snmp",
+	"162/udp":  "snmptrap",
+	"389":      "ldap",
+	"443":      "https",
+	"445":      "microsoft-ds",
+	"464":      "kpasswd",
+	"465":      "submissions",
+	"500/udp":  "isakmp",
+	"514/udp":  "syslog",
+	"587":      "submission",
+	"636":      "ldaps",
+	"853":      "domain-s",
+	"989":      "ftps-data",
+	"990":      "ftps",
+	"993":      "imaps",
+	"995":      "pop3s",
+	"1433":     "ms-sql-s",
+	"1434/udp": "ms-sql-m",
+	"1521":     "oracle",
+	"1723":     "pptp",
+	"1812/udp": "radius",
+	"1813/udp": "radius-acct",
+	"1883":     "mqtt",
+	"2049":     "nfs",
+	"3268":     "msft-gc",
+	"3269":     "msft-gc-ssl",
+	"3306":     "mysql",
+	"3389":     "ms-wbt-server",
+	"4500/udp": "ipsec-nat-t",
+	"5060":     "sip",
+	"5061":     "sips",
+	"5353/udp": "mdns",
+	"5355/udp": "llmnr",
+	"5432":     "postgresql",
+	"5671":     "amqps",
+	"5672":     "amqp",
+	"5900":     "rfb",
+	"5985":     "wsman",
+	"5986":     "wsmans",
+	"6379":     "redis",
+	"8080":     "http-alt",
+	"8443":     "https-alt",
+	"8883":     "secure-mqtt",
+	"9092":     "kafka",
+	"9200":     "elasticsearch",
+	"11211":    "memcache",
+	"27017":    "mongodb",
+}
+
+// serviceNames はリモートポートにサービス名を設定する connEnricher。
+type serviceNames map[string]string
+
+// newServiceNames は組み込みの対応表に、ユーザー定義ファイル (YAML) の内容を上書きしたものを返す。
+// ファイルは `1433: mssql` や `"8000/tcp": myapp` の形式で記述する。
+func newServiceNames(path string) (serviceNames, error) {
+	names := make(serviceNames, len(wellKnownServices))
+	for key, name := range wellKnownServices {
+		names[key] = name
+	}
+	if path == "" {
+		return names, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var overrides map[string]string
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for key, name := range overrides {
+		port, proto, _ := strings.Cut(key, "/")
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, fmt.Errorf(tr("%s: 不正なポート番号です: %q"), path, key)
+		}
+		if proto != "" && proto != "tcp" && proto != "udp" {
+			return nil, fmt.Errorf(tr("%s: 不正なプロトコルです: %q"), path, key)
+		}
+		names[strings.ToLower(key)] = name
+	}
+	return names, nil
+}
+
+func (s serviceNames) enrich(c *conn.Connection) {
+	if c.RemotePort == 0 || c.RemoteService != "" {
+		return
+	}
+	c.RemoteService = s.lookup(c.Protocol, c.RemotePort)
+}
+
+// lookup はプロトコル固有の定義を優先してサービス名を返す。
+func (s serviceNames) lookup(protocol string, port uint16) string {
+	p := strconv.Itoa(int(port))
+	if name, ok := s[p+"/"+strings.ToLower(protocol)]; ok {
+		return name
+	}
+	return s[p]
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- スナップショットごとのファイル (snapshot -o-dir) ---
+// 時点ごとのファイルを扱う保管の仕組みに渡せるよう、1 つの出力先に追記する代わりに、
+// スナップショットを 1 回ごとに -o-dir の別のファイルへ書く。
+// ファイル名は -o-name (Go の時刻の書式) に出力形式の拡張子を付けたもの。例: snapshot-20240101-150000.json
+// 書きかけのファイルを読まれないよう、一時ファイルに書いてから名前を変える。
+
+// snapshotFileExtensions は出力形式ごとのファイルの拡張子。
+var snapshotFileExtensions = map[string]string{
+	"text": ".txt", "json": ".json", "csv": ".csv", "logfmt": ".log", "template": ".txt",
+}
+
+// snapshotFiles は writeSnapshot と writeUnchanged の内容を 1 回ごとに別のファイルへ書く outputFormatter。
+// イベントと集計は出力しない (snapshot モードでのみ使う)。
+type snapshotFiles struct {
+	dir, name string
+	format    string
+	newFormat func(out *log.Logger) (outputFormatter, error)
+	last      string // 直前に書いたファイル。同じ名前になった場合は番号を付けて区別する
+	seq       int
+}
+
+// snapshotFiles は -o-dir に format の形式で書く snapshotFiles を返す。
+func (o *options) snapshotFiles(format string) (*snapshotFiles, error) {
+	if primary, _ := o.outputTargets(); primary.path != "" {
+		return nil, usageError(tr("-o-dir と -o (Webhook 以外) は同時に指定できません。"))
+	}
+	if o.outputName == "" || filepath.IsAbs(o.outputName) {
+		return nil, usageError(tr("-o-name には -o-dir からの相対的なファイル名を指定してください。"))
+	}
+	f, err := o.timestampedFiles(o.outputDir, o.outputName, format)
+	if err != nil {
+		return nil, err
+	}
+	verboseLog.Printf(tr("出力先: %s (スナップショットごとのファイル)"), filepath.Join(f.dir, f.name))
+	return f, nil
+}
+
+// timestampedFiles は dir に、name (Go の時刻の書式) に format の拡張子を付けた名前で書く snapshotFiles を返す。
+func (o *options) timestampedFiles(dir, name, format string) (*snapshotFiles, error) {
+	ext, ok := snapshotFileExtensions[format]
+	if !ok {
+		return nil, usageErrorf(tr("不明な出力形式です: %q"), format)
+	}
+	f := &snapshotFiles{
+		dir: dir, name: name + ext, format: format,
+		newFormat: func(out *log.Logger) (outputFormatter, error) {
+			return newOutputFormatterTo(format, o, out)
+		},
+	}
+	// -columns や -template の誤りは、最初のスナップショットを待たずに知らせる。
+	if _, err := f.newFormat(log.New(&bytes.Buffer{}, "", 0)); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return nil, fmt.Errorf(tr("出力先を開けませんでした: %w"), err)
+	}
+	return f, nil
+}
+
+// path は timestamp のスナップショットを書くファイル名を返す。
+func (f *snapshotFiles) path(timestamp time.Time) string {
+	if timestampStyle.utc {
+		timestamp = timestamp.UTC()
+	}
+	path := filepath.Join(f.dir, timestamp.Format(f.name))
+	if path != f.last {
+		f.last, f.seq = path, 1
+		return path
+	}
+	// 取得間隔が -o-name の時刻の細かさより短い場合。
+	f.seq++
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", path[:len(path)-len(ext)], f.seq, ext)
+}
+
+// write は fill が出力形式で書いた内容を、timestamp のファイルに書く。
+func (f *snapshotFiles) write(timestamp time.Time, fill func(out outputFormatter)) {
+	if _, err := f.create(timestamp, fill); err != nil {
+		warnLog.Printf(tr("エラー: スナップショットのファイルを書き込めませんでした: %v"), err)
+	}
+}
+
+// create は write と同じようにファイルを書き、書いたファイル名を返す。
+func (f *snapshotFiles) create(timestamp time.Time, fill func(out outputFormatter)) (string, error) {
+	var buf bytes.Buffer
+	out, err := f.newFormat(log.New(&buf, "", 0))
+	if err != nil {
+		return "", err
+	}
+	fill(out)
+	path := f.path(timestamp)
+	return path, writeFileAtomic(path, buf.Bytes())
+}
+
+// writeFileAtomic は data を一時ファイルに書いてから path に名前を変える。
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (f *snapshotFiles) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	f.write(timestamp, func(out outputFormatter) { out.writeSnapshot(timestamp, conns) })
+}
+
+func (f *snapshotFiles) writeUnchanged(timestamp time.Time, count int) {
+	f.write(timestamp, func(out outputFormatter) { out.writeUnchanged(timestamp, count) })
+}
+
+func (f *snapshotFiles) writeEvents(time.Time, []conn.Event)                 {}
+func (f *snapshotFiles) writeStats(time.Time, []processStats, *conn.TCPPerf) {}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 再起動をまたいだ接続の状態の保存 (-state-file) ---
+// monitor を再起動すると前回の接続の一覧が失われ、既存の接続が全て NEW として出力される。
+// -state-file を指定すると終了時に接続の一覧をファイルに保存し、次の起動時に読み込んで差分の起点にする。
+// 停止していた間の変化は、最初の取得で NEW・CLOSED として出力される。
+
+// monitorState は -state-file に保存する内容。
+type monitorState struct {
+	Saved       time.Time     `json:"saved"`
+	Connections conn.Snapshot `json:"connections"`
+}
+
+// loadState は -state-file のファイルから前回の接続の一覧を読み込む。
+// ファイルが無い場合や、保存から -state-max-age を過ぎている場合は空の一覧を返す。
+// 古い一覧を起点にすると停止中の変化を誤って判定するため、期限を過ぎたものは使わない。
+func (o *options) loadState(filter conn.Filter) (conn.Snapshot, error) {
+	prev := make(conn.Snapshot)
+	if o.stateFile == "" {
+		return prev, nil
+	}
+	data, err := os.ReadFile(o.stateFile)
+	if os.IsNotExist(err) {
+		return prev, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state monitorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %w", o.stateFile, err)
+	}
+	if age := time.Since(state.Saved); o.stateMaxAge > 0 && age > o.stateMaxAge {
+		infoLog.Printf(tr("保存された接続の状態は %s 前のものため使用しません: %s"), age.Round(time.Second), o.stateFile)
+		return prev, nil
+	}
+	for key, c := range state.Connections {
+		prev[key] = c
+	}
+	// 前回とフィルターが異なる場合に、対象外の接続が CLOSED として出力されないようにする。
+	pruneSnapshot(prev, filter)
+	infoLog.Printf(tr("保存された接続の状態を読み込みました: %d 件 (%s)"), len(prev), state.Saved.Format("2006-01-02 15:04:05"))
+	return prev, nil
+}
+
+// saveState は接続の一覧を -state-file のファイルに保存する。
+// 書き込みの途中で終了しても前回のファイルが壊れないよう、一時ファイルに書いてから置き換える。
+func (o *options) saveState(conns conn.Snapshot) error {
+	if o.stateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(monitorState{Saved: time.Now(), Connections: conns})
+	if err != nil {
+		return err
+	}
+	tmp := o.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.stateFile)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- stats モード (プロセス別の集計) ---
+type processKey struct {
+	name string
+	pid  uint32
+}
+
+type processStats struct {
+	Process     string
+	PID         uint32
+	Total       int
+	States      map[string]int
+	RemoteHosts int
+	Opened      int // 前回の取得以降に新しく現れた接続数
+	Closed      int // 前回の取得以降に消えた接続数
+
+	Counters *conn.ProcessCounters // -handles 指定時のハンドル数とスレッド数。取得できなかった場合は nil
+}
+
+func runStatsMode(args []string) error {
+	fs := newFlagSet("stats")
+	opts := setupStatsFlags(fs)
+	if err := parseFlags(fs, opts, args); err != nil {
+		return err
+	}
+
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	formatter, err := opts.newFormatter()
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	if err := opts.checkPrivileges(); err != nil {
+		return err
+	}
+
+	ctx, stop := opts.runContext()
+	defer stop()
+
+	infoLog.Print(tr("--- 統計モード開始 ---"))
+	infoLog.Printf(tr("監視対象: %s"), monitorTarget)
+	infoLog.Printf(tr("実行間隔: %d ミリ秒... (Ctrl+Cで停止)"), opts.intervalMilliseconds)
+
+	perf := opts.perfCounters()
+	defer perf.Close()
+
+	session := newSessionStats()
+	collector := conn.NewCollector(filter)
+	prevConns := make(conn.Snapshot)
+	ticker := time.NewTicker(time.Duration(opts.intervalMilliseconds) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		var currentTime time.Time
+		select {
+		case <-ctx.Done():
+			session.logSummary()
+			return nil
+		case currentTime = <-ticker.C:
+		}
+		currentConns, err := collector.Collect()
+		if err != nil {
+			warnLog.Printf(tr("エラー: 接続情報の取得に失敗: %v"), connError(err))
+			continue
+		}
+		events := conn.Diff(prevConns, currentConns)
+		session.observe(currentConns)
+		session.countEvents(events)
+		stats := aggregateStats(currentConns, events)
+		if opts.handleCounts {
+			sampleCounters(stats)
+		}
+		formatter.writeStats(currentTime, stats, perf.collect())
+		prevConns = currentConns
+	}
+}
+
+// setupStatsFlags は stats のフラグを定義する。
+func setupStatsFlags(fs *flag.FlagSet) *options {
+	return setupFlags(fs, filterFlags, pollFlags, periodFlags, outputFlags, sinkFlags, redactFlags, enrichFlags, statsFlags)
+}
+
+// tcpPerfCounters は -perf 指定時の TCPv4 のパフォーマンスカウンタ。nil の場合は何も取得しない。
+type tcpPerfCounters struct {
+	counters *conn.PerfCounters
+}
+
+// perfCounters は -perf が指定されていればカウンタを開く。開けない場合は警告を出して nil を返す。
+func (o *options) perfCounters() *tcpPerfCounters {
+	if !o.perf {
+		return nil
+	}
+	counters, err := conn.OpenTCPPerfCounters()
+	if err != nil {
+		warnLog.Printf(tr("警告: パフォーマンスカウンタを取得できないため、-perf を無視します: %v"), connError(err))
+		return nil
+	}
+	return &tcpPerfCounters{counters: counters}
+}
+
+// collect は現在の値を返す。取得に失敗した場合は警告を出して nil を返す。
+func (p *tcpPerfCounters) collect() *conn.TCPPerf {
+	if p == nil {
+		return nil
+	}
+	perf, err := p.counters.Collect()
+	if err != nil {
+		warnLog.Printf(tr("警告: パフォーマンスカウンタの取得に失敗: %v"), connError(err))
+		return nil
+	}
+	return &perf
+}
+
+func (p *tcpPerfCounters) Close() {
+	if p != nil {
+		p.counters.Close()
+	}
+}
+
+// sampleCounters は stats の各プロセスのハンドル数とスレッド数を取得して設定する。
+func sampleCounters(stats []processStats) {
+	pids := make([]uint32, len(stats))
+	for i, s := range stats {
+		pids[i] = s.PID
+	}
+	counters := conn.SampleProcessCounters(pids)
+	for i := range stats {
+		if c, ok := counters[stats[i].PID]; ok {
+			stats[i].Counters = &c
+		}
+	}
+}
+
+// aggregateStats は現在の接続とイベントをプロセスごとに集計する。
+func aggregateStats(current conn.Snapshot, events []conn.Event) []processStats {
+	byProcess := make(map[processKey]*processStats)
+	remoteHosts := make(map[processKey]map[string]bool)
+	get := func(c conn.Connection) *processStats {
+		key := processKey{c.ProcessName, c.PID}
+		s, ok := byProcess[key]
+		if !ok {
+			s = &processStats{Process: c.ProcessName, PID: c.PID, States: make(map[string]int)}
+			byProcess[key] = s
+			remoteHosts[key] = make(map[string]bool)
+		}
+		return s
+	}
+	for _, c := range current {
+		s := get(c)
+		s.Total++
+		s.States[c.State]++
+		if c.RemoteAddr != "" {
+			remoteHosts[processKey{c.ProcessName, c.PID}][c.RemoteAddr] = true
+		}
+	}
+	for _, e := range events {
+		switch e.Type {
+		case conn.EventNew, conn.EventRebound:
+			get(e.Conn).Opened++
+		case conn.EventClosed:
+			get(e.Conn).Closed++
+		}
+	}
+
+	stats := make([]processStats, 0, len(byProcess))
+	for key, s := range byProcess {
+		s.RemoteHosts = len(remoteHosts[key])
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Total != stats[j].Total {
+			return stats[i].Total > stats[j].Total
+		}
+		if stats[i].Process != stats[j].Process {
+			return stats[i].Process < stats[j].Process
+		}
+		return stats[i].PID < stats[j].PID
+	})
+	return stats
+}
+
+// sortedStates は状態名を conn.TCPStateNames の順 (UDP は末尾) に並べて返す。
+func sortedStates(states map[string]int) []string {
+	order := make(map[string]int, len(conn.TCPStateNames))
+	for i, name := range conn.TCPStateNames {
+		order[name] = i
+	}
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		oi, iok := order[names[i]]
+		oj, jok := order[names[j]]
+		if iok != jok {
+			return iok
+		}
+		if oi != oj {
+			return oi < oj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
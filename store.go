@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"maps"
+	"time"
+
+	"go-ObuStat/conn"
+
+	_ "modernc.org/sqlite"
+)
+
+// --- SQLite へのイベントとスナップショットの保存 (-store) ---
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	id            INTEGER PRIMARY KEY,
+	ts            INTEGER NOT NULL, -- Unix 時刻 (ミリ秒)
+	event         TEXT NOT NULL,
+	protocol      TEXT NOT NULL,
+	process       TEXT NOT NULL,
+	pid           INTEGER NOT NULL,
+	process_start INTEGER,          -- Unix 時刻 (ミリ秒)
+	local_addr    TEXT NOT NULL,
+	local_port    INTEGER NOT NULL,
+	remote_addr   TEXT NOT NULL,
+	remote_port   INTEGER NOT NULL,
+	remote_host   TEXT NOT NULL,
+	state         TEXT NOT NULL,
+	prev_state    TEXT NOT NULL,
+	first_seen    INTEGER,          -- Unix 時刻 (ミリ秒)
+	count         INTEGER NOT NULL,
+	detail        TEXT NOT NULL,
+	host          TEXT NOT NULL DEFAULT '' -- collector が受け取ったイベントの送信元ホスト (ローカルの -store では空)
+);
+CREATE INDEX IF NOT EXISTS events_ts ON events (ts);
+CREATE INDEX IF NOT EXISTS events_pid ON events (pid, ts);
+CREATE INDEX IF NOT EXISTS events_remote ON events (remote_addr, ts);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+	id    INTEGER PRIMARY KEY,
+	ts    INTEGER NOT NULL,
+	count INTEGER NOT NULL,
+	host  TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS snapshots_ts ON snapshots (ts);
+
+CREATE TABLE IF NOT EXISTS snapshot_connections (
+	snapshot_id   INTEGER NOT NULL REFERENCES snapshots (id),
+	protocol      TEXT NOT NULL,
+	process       TEXT NOT NULL,
+	pid           INTEGER NOT NULL,
+	process_start INTEGER,
+	local_addr    TEXT NOT NULL,
+	local_port    INTEGER NOT NULL,
+	remote_addr   TEXT NOT NULL,
+	remote_port   INTEGER NOT NULL,
+	remote_host   TEXT NOT NULL,
+	state         TEXT NOT NULL,
+	first_seen    INTEGER
+);
+CREATE INDEX IF NOT EXISTS snapshot_connections_snapshot ON snapshot_connections (snapshot_id);
+`
+
+// storeMigrations は host 列が無い以前のデータベースに列を追加する。索引は列を追加した後に作る。
+var storeMigrations = []struct{ table, column, ddl string }{
+	{"events", "host", `ALTER TABLE events ADD COLUMN host TEXT NOT NULL DEFAULT ''`},
+	{"snapshots", "host", `ALTER TABLE snapshots ADD COLUMN host TEXT NOT NULL DEFAULT ''`},
+}
+
+const storeIndexes = `
+CREATE INDEX IF NOT EXISTS events_host ON events (host, ts);
+CREATE INDEX IF NOT EXISTS snapshots_host ON snapshots (host, ts);
+`
+
+// storeFormatter は元の出力に加えて、イベントと一定間隔ごとの接続一覧を SQLite に保存する。
+// 監視モードではスナップショットが渡されないため、イベントから現在の接続一覧を組み立てて保存する。
+type storeFormatter struct {
+	outputFormatter
+	db               *sql.DB
+	snapshotInterval time.Duration
+	lastSnapshot     time.Time
+	current          conn.Snapshot
+	host             string // 保存する行の送信元ホスト (collector のみ設定する)
+}
+
+// withStore は path の SQLite データベースにも保存する outputFormatter を返す。
+// データベースが無ければ作成する。
+func withStore(formatter outputFormatter, path string, snapshotInterval time.Duration) (outputFormatter, error) {
+	db, err := openStoreDB(path)
+	if err != nil {
+		return nil, fmt.Errorf(tr("-store のデータベースを開けませんでした: %w"), err)
+	}
+	return &storeFormatter{outputFormatter: formatter, db: db, snapshotInterval: snapshotInterval, current: make(conn.Snapshot)}, nil
+}
+
+func openStoreDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// 書き込みは 1 本の接続にまとめ、query サブコマンドからの読み取りと並行できるよう WAL にする。
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000", storeSchema} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := migrateStore(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+func migrateStore(db *sql.DB) error {
+	for _, m := range storeMigrations {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, m.table, m.column).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			if _, err := db.Exec(m.ddl); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := db.Exec(storeIndexes)
+	return err
+}
+
+func (f *storeFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	f.outputFormatter.writeEvents(timestamp, events)
+	if err := f.insertEvents(timestamp, events); err != nil {
+		warnLog.Printf(tr("エラー: イベントの保存に失敗: %v"), err)
+	}
+	for _, e := range events {
+		switch e.Type {
+		case conn.EventNew, conn.EventChange, conn.EventRebound:
+			f.current[e.Key] = e.Conn
+		case conn.EventClosed:
+			delete(f.current, e.Key)
+		}
+	}
+	if timestamp.Sub(f.lastSnapshot) >= f.snapshotInterval {
+		f.saveSnapshot(timestamp, f.current)
+	}
+}
+
+func (f *storeFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	f.outputFormatter.writeSnapshot(timestamp, conns)
+	f.current = maps.Clone(conns)
+	f.saveSnapshot(timestamp, conns)
+}
+
+func (f *storeFormatter) saveSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	f.lastSnapshot = timestamp
+	if err := f.insertSnapshot(timestamp, conns); err != nil {
+		warnLog.Printf(tr("エラー: スナップショットの保存に失敗: %v"), err)
+	}
+}
+
+func unixMillisOrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UnixMilli()
+}
+
+// insertEvents はイベントを 1 つのトランザクションで保存する。
+func (f *storeFormatter) insertEvents(timestamp time.Time, events []conn.Event) error {
+	return inTx(f.db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`INSERT INTO events (ts, event, protocol, process, pid, process_start, local_addr, local_port,
+			remote_addr, remote_port, remote_host, state, prev_state, first_seen, count, detail, host)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, e := range events {
+			c := e.Conn
+			if _, err := stmt.Exec(timestamp.UnixMilli(), string(e.Type), c.Protocol, c.ProcessName, c.PID, unixMillisOrNil(c.ProcessStart),
+				c.LocalAddr, c.LocalPort, c.RemoteAddr, c.RemotePort, c.RemoteHost, c.State, e.PrevState,
+				unixMillisOrNil(c.FirstSeen), e.Count, e.Detail, f.host); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// insertSnapshot は接続一覧を 1 つのトランザクションで保存する。
+func (f *storeFormatter) insertSnapshot(timestamp time.Time, conns conn.Snapshot) error {
+	return inTx(f.db, func(tx *sql.Tx) error {
+		res, err := tx.Exec(`INSERT INTO snapshots (ts, count, host) VALUES (?, ?, ?)`, timestamp.UnixMilli(), len(conns), f.host)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.Prepare(`INSERT INTO snapshot_connections (snapshot_id, protocol, process, pid, process_start,
+			local_addr, local_port, remote_addr, remote_port, remote_host, state, first_seen)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, c := range conns {
+			if _, err := stmt.Exec(id, c.Protocol, c.ProcessName, c.PID, unixMillisOrNil(c.ProcessStart),
+				c.LocalAddr, c.LocalPort, c.RemoteAddr, c.RemotePort, c.RemoteHost, c.State, unixMillisOrNil(c.FirstSeen)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func inTx(db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
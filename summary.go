@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- セッションの集計 (Ctrl+C 時のサマリー表示用) ---
+type sessionStats struct {
+	start  time.Time
+	end    time.Time // 記録の再生時に、最後の記録の時刻を設定する (ゼロ値の場合は現在時刻まで)
+	polls  int
+	events map[conn.EventType]int
+	peak   map[string]int
+}
+
+func newSessionStats() *sessionStats {
+	return &sessionStats{
+		start:  time.Now(),
+		events: make(map[conn.EventType]int),
+		peak:   make(map[string]int),
+	}
+}
+
+// observe は取得した接続一覧から、プロセスごとの同時接続数の最大値を更新する。
+func (s *sessionStats) observe(current conn.Snapshot) {
+	s.polls++
+	counts := make(map[string]int)
+	for _, c := range current {
+		counts[processLabel(c)]++
+	}
+	for process, n := range counts {
+		if n > s.peak[process] {
+			s.peak[process] = n
+		}
+	}
+}
+
+func (s *sessionStats) countEvents(events []conn.Event) {
+	for _, e := range events {
+		s.events[e.Type]++
+	}
+}
+
+func (s *sessionStats) totalEvents() int {
+	total := 0
+	for _, n := range s.events {
+		total += n
+	}
+	return total
+}
+
+func processLabel(c conn.Connection) string {
+	return fmt.Sprintf("%s (PID: %d)", c.ProcessName, c.PID)
+}
+
+func (s *sessionStats) logSummary() {
+	infoLog.Print(tr("--- 監視終了サマリー ---"))
+	end := s.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	infoLog.Printf(tr("監視時間: %s (取得回数: %d)"), end.Sub(s.start).Round(time.Second), s.polls)
+	infoLog.Printf(tr("イベント総数: %d (NEW: %d, CHANGE: %d, CLOSED: %d)"), s.totalEvents(),
+		s.events[conn.EventNew], s.events[conn.EventChange], s.events[conn.EventClosed])
+	if n := s.events[conn.EventRebound]; n > 0 {
+		infoLog.Printf(tr("REBOUND: %d 件"), n)
+	}
+	if n := s.events[eventFlap]; n > 0 {
+		infoLog.Printf(tr("FLAP: %d 件"), n)
+	}
+	if n := s.events[eventAlert]; n > 0 {
+		infoLog.Printf(tr("ALERT: %d 回"), n)
+	}
+	if n := s.events[eventAnomaly]; n > 0 {
+		infoLog.Printf(tr("ANOMALY: %d 件"), n)
+	}
+	if n := s.events[eventScan]; n > 0 {
+		infoLog.Printf(tr("SCAN: %d 回"), n)
+	}
+	if len(s.peak) == 0 {
+		infoLog.Print(tr("最大同時接続数: 該当なし"))
+		return
+	}
+	processes := make([]string, 0, len(s.peak))
+	for process := range s.peak {
+		processes = append(processes, process)
+	}
+	sort.Slice(processes, func(i, j int) bool {
+		if s.peak[processes[i]] != s.peak[processes[j]] {
+			return s.peak[processes[i]] > s.peak[processes[j]]
+		}
+		return processes[i] < processes[j]
+	})
+	infoLog.Print(tr("最大同時接続数 (プロセス別):"))
+	for _, process := range processes {
+		infoLog.Printf("  %-30s %d", process, s.peak[process])
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- syslog への出力 (RFC 5424) ---
+// -o syslog://host:514 (UDP), syslog+tcp://host:601, syslog+tls://host:6514 の形式で指定する。
+// クエリで facility (既定 local0)、app (既定 obustat)、ca (TLS の CA 証明書ファイル) を指定できる。
+const (
+	syslogSeverityInfo = 6
+	syslogDialTimeout  = 5 * time.Second
+	syslogMaxUDPSize   = 2048 // UDP で送る 1 メッセージの上限 (これを超える部分は切り捨てる)
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func isSyslogTarget(target string) bool {
+	return strings.HasPrefix(target, "syslog://") || strings.HasPrefix(target, "syslog+tcp://") || strings.HasPrefix(target, "syslog+tls://")
+}
+
+// syslogWriter は書き込まれた内容を 1 行ずつ syslog メッセージとして送る。
+// TCP/TLS は RFC 6587 のオクテットカウント形式で送り、切断された場合は次の書き込みで接続し直す。
+type syslogWriter struct {
+	mu       sync.Mutex
+	network  string // udp, tcp
+	addr     string
+	tls      *tls.Config
+	priority int
+	hostname string
+	appName  string
+	conn     net.Conn
+}
+
+func newSyslogWriter(target string) (*syslogWriter, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	w := &syslogWriter{network: "udp", addr: u.Host, appName: "obustat"}
+	switch u.Scheme {
+	case "syslog":
+		if u.Port() == "" {
+			w.addr = net.JoinHostPort(u.Hostname(), "514")
+		}
+	case "syslog+tcp":
+		w.network = "tcp"
+		if u.Port() == "" {
+			w.addr = net.JoinHostPort(u.Hostname(), "601")
+		}
+	case "syslog+tls":
+		w.network = "tcp"
+		if u.Port() == "" {
+			w.addr = net.JoinHostPort(u.Hostname(), "6514")
+		}
+		w.tls = &tls.Config{ServerName: u.Hostname()}
+		if ca := u.Query().Get("ca"); ca != "" {
+			pem, err := os.ReadFile(ca)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf(tr("CA 証明書を読み込めません: %s"), ca)
+			}
+			w.tls.RootCAs = pool
+		}
+	default:
+		return nil, fmt.Errorf(tr("不明なスキームです: %q"), u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf(tr("syslog サーバーのホスト名がありません: %q"), target)
+	}
+
+	facility := "local0"
+	if f := u.Query().Get("facility"); f != "" {
+		facility = strings.ToLower(f)
+	}
+	code, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf(tr("不明な facility です: %q"), facility)
+	}
+	w.priority = code*8 + syslogSeverityInfo
+	if app := u.Query().Get("app"); app != "" {
+		w.appName = app
+	}
+	w.hostname, _ = os.Hostname()
+	if w.hostname == "" {
+		w.hostname = "-"
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *syslogWriter) connect() error {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	var err error
+	if w.tls != nil {
+		w.conn, err = tls.DialWithDialer(dialer, w.network, w.addr, w.tls)
+	} else {
+		w.conn, err = dialer.Dial(w.network, w.addr)
+	}
+	return err
+}
+
+// Write は p を行ごとに分割して送る。送信に失敗した行は 1 回だけ接続し直して再送する。
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		msg := w.format(line)
+		if err := w.send(msg); err != nil {
+			if w.conn != nil {
+				w.conn.Close()
+			}
+			if err := w.connect(); err != nil {
+				w.conn = nil
+				return 0, err
+			}
+			if err := w.send(msg); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// format は RFC 5424 形式の 1 メッセージを作る。MSGID と構造化データは使わない。
+func (w *syslogWriter) format(line []byte) []byte {
+	header := fmt.Sprintf("<%d>1 %s %s %s %d - - ", w.priority, time.Now().Format(time.RFC3339Nano), w.hostname, w.appName, os.Getpid())
+	msg := append([]byte(header), line...)
+	if w.network == "udp" && len(msg) > syslogMaxUDPSize {
+		msg = msg[:syslogMaxUDPSize]
+	}
+	return msg
+}
+
+func (w *syslogWriter) send(msg []byte) error {
+	if w.conn == nil {
+		return net.ErrClosed
+	}
+	if w.network == "tcp" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_, err := w.conn.Write(msg)
+	return err
+}
+
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
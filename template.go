@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- template 形式 (-format template) ---
+// -template の text/template を 1 件ごとに実行し、その結果を 1 行として出力する。
+// 既存の解析プログラムが期待する行の形式に、出力形式を追加せずに合わせるために使う。
+// 例: -format template -template "{{.TS}} {{.Event}} {{.ProcessName}}({{.PID}}) {{.RemoteAddr}}:{{.RemotePort}}"
+
+// templateRecord はテンプレートに渡す 1 件分の値。接続の項目は conn.Connection のフィールド名
+// (.ProcessName, .PID, .LocalAddr, .RemoteAddr, .State など) で参照できる。
+type templateRecord struct {
+	conn.Connection
+	Timestamp time.Time     // 出力の時刻
+	TS        string        // -ts/-utc に従って整形した時刻
+	Event     string        // イベントの種別 (NEW, CLOSED, SNAPSHOT, STATS など)
+	Key       string        // 接続を識別するキー
+	PrevState string        // CHANGE の変化前の状態
+	Count     int           // 件数 (ALERT, GROUP, UNCHANGED など)
+	Detail    string        // イベントの説明
+	Lifetime  time.Duration // 接続を最初に検出してからの時間 (NEW では 0)
+	Scope     string        // リモートアドレスの種類 (public, private など)
+	Stats     *processStats // STATS の場合のみ
+	Perf      *conn.TCPPerf // PERF の場合のみ
+}
+
+// templateFuncs はテンプレートで使える関数。text/template の組み込み関数 (printf など) も使える。
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+	// pad は s の表示幅が width に満たない場合、右を空白で埋める (全角文字は幅 2 として数える)。
+	"pad": func(width int, s string) string {
+		return s + strings.Repeat(" ", max(0, width-displayWidth(s)))
+	},
+}
+
+type templateFormatter struct {
+	tmpl *template.Template
+	out  *log.Logger
+}
+
+// newTemplateFormatter は -template のテンプレートを解析する。先頭が @ の場合は続くファイル名から読み込む。
+func newTemplateFormatter(text string, out *log.Logger) (*templateFormatter, error) {
+	if text == "" {
+		return nil, usageError(tr("-format template には -template でテンプレートを指定してください。"))
+	}
+	if path, ok := strings.CutPrefix(text, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, usageErrorf(tr("-template のファイルを読み込めません: %w"), err)
+		}
+		text = strings.TrimRight(string(data), "\r\n")
+	}
+	tmpl, err := template.New("template").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, usageErrorf(tr("-template を解析できません: %w"), err)
+	}
+	return &templateFormatter{tmpl: tmpl, out: out}, nil
+}
+
+func (f *templateFormatter) write(r templateRecord) {
+	r.TS = machineTimestamp(r.Timestamp)
+	r.Scope = string(remoteScope(r.Connection))
+	var b strings.Builder
+	if err := f.tmpl.Execute(&b, r); err != nil {
+		warnLog.Printf(tr("エラー: テンプレートの実行に失敗: %v"), err)
+		return
+	}
+	f.out.Print(b.String())
+}
+
+func (f *templateFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	sortEvents(events)
+	for _, e := range events {
+		f.write(templateRecord{
+			Connection: e.Conn, Timestamp: timestamp, Event: string(e.Type), Key: e.Key,
+			PrevState: e.PrevState, Count: e.Count, Detail: e.Detail,
+			Lifetime: time.Duration(lifetimeMillis(e, timestamp)) * time.Millisecond,
+		})
+	}
+}
+
+func (f *templateFormatter) writeSnapshot(timestamp time.Time, conns conn.Snapshot) {
+	for _, key := range sortedKeys(conns) {
+		c := conns[key]
+		f.write(templateRecord{Connection: c, Timestamp: timestamp, Event: string(eventSnapshot), Key: key, Lifetime: c.Lifetime(timestamp)})
+	}
+}
+
+func (f *templateFormatter) writeUnchanged(timestamp time.Time, count int) {
+	f.write(templateRecord{Timestamp: timestamp, Event: string(eventUnchanged), Count: count, Detail: fmt.Sprintf(tr("%d件"), count)})
+}
+
+func (f *templateFormatter) writeStats(timestamp time.Time, stats []processStats, perf *conn.TCPPerf) {
+	for i := range stats {
+		s := &stats[i]
+		f.write(templateRecord{
+			Connection: conn.Connection{ProcessName: s.Process, PID: s.PID},
+			Timestamp:  timestamp, Event: "STATS", Count: s.Total, Stats: s,
+		})
+	}
+	if perf != nil {
+		f.write(templateRecord{Timestamp: timestamp, Event: "PERF", Perf: perf})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+func TestTemplateFormatter(t *testing.T) {
+	at := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	app := conn.Connection{Protocol: "TCP", ProcessName: "app.exe", PID: 100, LocalAddr: "10.0.0.1", LocalPort: 50000, RemoteAddr: "10.0.0.2", RemotePort: 443, State: "ESTABLISHED"}
+	svc := conn.Connection{Protocol: "TCP", ProcessName: "svc.exe", PID: 200, LocalAddr: "10.0.0.1", LocalPort: 50001, RemoteAddr: "8.8.8.8", RemotePort: 53, State: "CLOSE_WAIT"}
+
+	tests := []struct {
+		name  string
+		tmpl  string
+		write func(f outputFormatter)
+		want  string
+	}{
+		{"イベントはキーの順", "{{.Event}} {{.ProcessName}}({{.PID}}) {{.RemoteAddr}}:{{.RemotePort}}", func(f outputFormatter) {
+			f.writeEvents(at, []conn.Event{
+				{Type: conn.EventClosed, Key: svc.Key(), Conn: svc},
+				{Type: conn.EventNew, Key: app.Key(), Conn: app},
+			})
+		}, "NEW app.exe(100) 10.0.0.2:443\nCLOSED svc.exe(200) 8.8.8.8:53\n"},
+		{"CHANGE の変化前の状態", "{{.PrevState}} -> {{.State}}", func(f outputFormatter) {
+			f.writeEvents(at, []conn.Event{{Type: conn.EventChange, Key: svc.Key(), Conn: svc, PrevState: "ESTABLISHED"}})
+		}, "ESTABLISHED -> CLOSE_WAIT\n"},
+		{"関数", "{{lower .Protocol}} {{pad 8 .ProcessName}}|{{upper .Scope}}", func(f outputFormatter) {
+			f.writeSnapshot(at, conn.Snapshot{app.Key(): app})
+		}, "tcp app.exe |PRIVATE\n"},
+		{"スナップショット", "{{.Event}} {{.Key}} {{.Scope}}", func(f outputFormatter) {
+			f.writeSnapshot(at, conn.Snapshot{svc.Key(): svc, app.Key(): app})
+		}, "SNAPSHOT " + app.Key() + " private\nSNAPSHOT " + svc.Key() + " public\n"},
+		{"変化なし", "{{.Event}} {{.Count}}", func(f outputFormatter) {
+			f.writeUnchanged(at, 3)
+		}, "UNCHANGED 3\n"},
+		{"統計とパフォーマンスカウンタ", "{{.Event}} {{.ProcessName}}{{with .Stats}} {{.Total}}{{end}}{{with .Perf}} {{.ConnectionsResetDelta}}{{end}}", func(f outputFormatter) {
+			f.writeStats(at, []processStats{{Process: "app.exe", PID: 100, Total: 5}}, &conn.TCPPerf{ConnectionsResetDelta: 2})
+		}, "STATS app.exe 5\nPERF  2\n"},
+		{"実行に失敗した行は出力しない", "{{.Stats.Total}}", func(f outputFormatter) {
+			f.writeEvents(at, []conn.Event{{Type: conn.EventNew, Key: app.Key(), Conn: app}})
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			f, err := newTemplateFormatter(tt.tmpl, log.New(&buf, "", 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.write(f)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("出力 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTemplateFormatter(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "line.tmpl")
+	if err := os.WriteFile(file, []byte("{{.Event}} {{.ProcessName}}\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string // Event: NEW, ProcessName: app.exe の出力
+		wantErr bool
+	}{
+		{"テンプレート", "{{.Event}}", "NEW\n", false},
+		{"ファイルから読み込み、末尾の改行を除く", "@" + file, "NEW app.exe\n", false},
+		{"未指定", "", "", true},
+		{"ファイルが無い", "@" + filepath.Join(dir, "missing.tmpl"), "", true},
+		{"構文の誤り", "{{.Event", "", true},
+		{"未定義の関数", "{{trim .Event}}", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			f, err := newTemplateFormatter(tt.tmpl, log.New(&buf, "", 0))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newTemplateFormatter = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if exitCodeOf(err) != exitUsage {
+					t.Errorf("終了コード = %d, want %d", exitCodeOf(err), exitUsage)
+				}
+				return
+			}
+			f.writeEvents(time.Now(), []conn.Event{{Type: conn.EventNew, Conn: conn.Connection{ProcessName: "app.exe"}}})
+			if got := buf.String(); got != tt.want {
+				t.Errorf("出力 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
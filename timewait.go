@@ -0,0 +1,75 @@
+package main
+
+import (
+	"go-ObuStat/conn"
+)
+
+// --- TIME_WAIT を経由する終了イベントのまとめ ---
+// 短命なクライアント接続は ESTABLISHED → TIME_WAIT → (DELETE_TCB →) 消滅 と変化するため、
+// 1 本の接続につき CHANGE と CLOSED が何件も出力される。
+// -collapse-timewait では TIME_WAIT/DELETE_TCB に入った時点の 1 件の CLOSED にまとめ、
+// -ignore-timewait では最初から TIME_WAIT だった接続 (検出前に閉じられた接続) のイベントを出力しない。
+
+// isClosingState は接続が既に閉じられ、消滅を待っているだけの状態かを判定する。
+func isClosingState(state string) bool {
+	return state == "TIME_WAIT" || state == "DELETE_TCB"
+}
+
+// timeWaitCollapser は終了イベントをまとめた接続を、テーブルから消えるまで覚えておく。
+type timeWaitCollapser struct {
+	collapse bool
+	ignore   bool
+	closed   map[string]bool // CLOSED を出力済み、または無視している接続の Key
+}
+
+func newTimeWaitCollapser(collapse, ignore bool) *timeWaitCollapser {
+	return &timeWaitCollapser{collapse: collapse, ignore: ignore, closed: make(map[string]bool)}
+}
+
+// apply は Diff のイベントから、まとめた接続の途中経過を取り除く。
+func (t *timeWaitCollapser) apply(events []conn.Event) []conn.Event {
+	if t == nil {
+		return events
+	}
+	kept := events[:0]
+	for _, e := range events {
+		switch e.Type {
+		case conn.EventNew:
+			if t.ignore && isClosingState(e.Conn.State) {
+				t.closed[e.Key] = true
+				continue
+			}
+		case conn.EventChange:
+			if t.closed[e.Key] {
+				if isClosingState(e.Conn.State) {
+					continue
+				}
+				// 同じアドレスとポートの組で新しい接続が始まった。
+				delete(t.closed, e.Key)
+				e.Type, e.PrevState = conn.EventNew, ""
+			} else if t.collapse && isClosingState(e.Conn.State) {
+				t.closed[e.Key] = true
+				e.Type = conn.EventClosed
+			}
+		case conn.EventRebound:
+			// 別のプロセスが同じアドレスとポートの組で新しい接続を始めた。
+			delete(t.closed, e.Key)
+		case conn.EventClosed:
+			if t.closed[e.Key] {
+				delete(t.closed, e.Key)
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// timeWaitCollapser は -collapse-timewait/-ignore-timewait が指定されていれば timeWaitCollapser を返す。
+// どちらも未指定の場合は nil を返す。
+func (o *options) timeWaitCollapser() *timeWaitCollapser {
+	if !o.collapseTimeWait && !o.ignoreTimeWait {
+		return nil
+	}
+	return newTimeWaitCollapser(o.collapseTimeWait, o.ignoreTimeWait)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- top モード (対話型のプロセス別一覧) ---
+// 画面をその場で書き換え、プロセス別の接続数を並べ替えながら表示する。
+// キー操作: c=接続数順, s=状態別件数順 (押すたびに状態を切り替え), n=プロセス名順, r=逆順, q=終了
+
+// topStates は状態別の列として表示する状態。それ以外は「その他」にまとめる。
+var topStates = []string{"ESTABLISHED", "TIME_WAIT", "CLOSE_WAIT", "LISTEN"}
+
+type topSortKey int
+
+const (
+	topSortTotal topSortKey = iota
+	topSortState
+	topSortName
+)
+
+// topView は並べ替えの状態を持つ。
+type topView struct {
+	key      topSortKey
+	state    int // topSortState で並べ替える topStates の添字
+	reversed bool
+}
+
+func runTopMode(args []string) error {
+	fs := newFlagSet("top")
+	opts := setupTopFlags(fs)
+	if err := parseFlags(fs, opts, args); err != nil {
+		return err
+	}
+
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	if err := opts.checkPrivileges(); err != nil {
+		return err
+	}
+	restore, err := enterConsoleUI()
+	if err != nil {
+		return fmt.Errorf(tr("コンソールを対話モードにできませんでした: %w"), err)
+	}
+	defer restore()
+
+	ctx, stop := opts.runContext()
+	defer stop()
+	keys := readKeys()
+
+	view := &topView{}
+	collector := conn.NewCollector(filter)
+	prevConns := make(conn.Snapshot)
+	var stats []processStats
+	var lastErr error
+	ticker := time.NewTicker(time.Duration(opts.intervalMilliseconds) * time.Millisecond)
+	defer ticker.Stop()
+
+	refresh := func() {
+		currentConns, err := collector.Collect()
+		lastErr = connError(err)
+		if err != nil {
+			return
+		}
+		stats = aggregateStats(currentConns, conn.Diff(prevConns, currentConns))
+		prevConns = currentConns
+	}
+	refresh()
+	for {
+		view.render(os.Stdout, monitorTarget, stats, len(prevConns), lastErr)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			refresh()
+		case key, ok := <-keys:
+			if !ok || !view.handleKey(key) {
+				return nil
+			}
+		}
+	}
+}
+
+// setupTopFlags は top のフラグを定義する。
+func setupTopFlags(fs *flag.FlagSet) *options {
+	return setupFlags(fs, filterFlags, pollFlags, periodFlags)
+}
+
+// handleKey はキー入力で並べ替えを変更する。終了する場合は false を返す。
+func (v *topView) handleKey(key byte) bool {
+	switch key {
+	case 'q', 'Q', 0x1b:
+		return false
+	case 'c', 'C':
+		v.key = topSortTotal
+	case 's', 'S':
+		if v.key == topSortState {
+			v.state = (v.state + 1) % len(topStates)
+		}
+		v.key = topSortState
+	case 'n', 'N':
+		v.key = topSortName
+	case 'r', 'R':
+		v.reversed = !v.reversed
+	}
+	return true
+}
+
+func (v *topView) describe() string {
+	var s string
+	switch v.key {
+	case topSortState:
+		s = fmt.Sprintf(tr("%s の件数"), topStates[v.state])
+	case topSortName:
+		s = tr("プロセス名")
+	default:
+		s = tr("接続数")
+	}
+	if v.reversed {
+		s += tr(" (逆順)")
+	}
+	return s
+}
+
+func (v *topView) sort(stats []processStats) {
+	less := func(a, b processStats) bool {
+		switch v.key {
+		case topSortState:
+			state := topStates[v.state]
+			if a.States[state] != b.States[state] {
+				return a.States[state] > b.States[state]
+			}
+		case topSortName:
+			if !strings.EqualFold(a.Process, b.Process) {
+				return strings.ToLower(a.Process) < strings.ToLower(b.Process)
+			}
+		}
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.PID < b.PID
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if v.reversed {
+			return less(stats[j], stats[i])
+		}
+		return less(stats[i], stats[j])
+	})
+}
+
+// render は画面を消さずにカーソルを左上へ戻して上書きし、残りの行だけを消去する (ちらつき防止)。
+func (v *topView) render(out *os.File, monitorTarget string, stats []processStats, total int, err error) {
+	width, height := consoleSize(out)
+	v.sort(stats)
+
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		b.WriteString(truncateWidth(fmt.Sprintf(format, args...), width))
+		b.WriteString("\x1b[K\r\n")
+	}
+	b.WriteString("\x1b[H")
+	line(tr("ObuStat top - %s  監視対象: %s"), time.Now().Format("15:04:05"), monitorTarget)
+	line(tr("プロセス: %d  接続: %d  並べ替え: %s"), len(stats), total, v.describe())
+	if err != nil {
+		line(tr("エラー: 接続情報の取得に失敗: %v"), err)
+	} else {
+		line("%s", tr("[c]接続数 [s]状態 [n]プロセス名 [r]逆順 [q]終了"))
+	}
+	line("")
+	// 見出しは全角文字を含むため、表示幅でそろえる。
+	header := fmt.Sprintf("%7s %s %s", "PID", padRight(tr("プロセス"), 24), padLeft(tr("合計"), 6))
+	for _, state := range topStates {
+		header += fmt.Sprintf(" %11s", state)
+	}
+	b.WriteString("\x1b[7m")
+	line("%s %s %s %s %s", header, padLeft(tr("その他"), 6), padLeft(tr("リモート"), 8), padLeft(tr("新規"), 5), padLeft(tr("終了"), 5))
+	b.WriteString("\x1b[0m")
+
+	rows := height - 6
+	for i, s := range stats {
+		if i >= rows {
+			break
+		}
+		row := fmt.Sprintf("%7d %s %6d", s.PID, padRight(truncateWidth(s.Process, 24), 24), s.Total)
+		other := s.Total
+		for _, state := range topStates {
+			row += fmt.Sprintf(" %11d", s.States[state])
+			other -= s.States[state]
+		}
+		line("%s %6d %8d %5d %5d", row, other, s.RemoteHosts, s.Opened, s.Closed)
+	}
+	b.WriteString("\x1b[J")
+	out.WriteString(b.String())
+}
+
+// --- コンソールの制御 ---
+// enterConsoleUI は仮想端末シーケンスを有効にして代替画面に切り替え、キー入力を 1 文字ずつ読めるようにする。
+// 返す関数で元の状態に戻す。
+func enterConsoleUI() (func(), error) {
+	stdout, stdin := windows.Handle(os.Stdout.Fd()), windows.Handle(os.Stdin.Fd())
+	var outMode, inMode uint32
+	if err := windows.GetConsoleMode(stdout, &outMode); err != nil {
+		return nil, err
+	}
+	if err := windows.GetConsoleMode(stdin, &inMode); err != nil {
+		return nil, err
+	}
+	if err := windows.SetConsoleMode(stdout, outMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		return nil, err
+	}
+	// Ctrl+C で終了できるよう、ENABLE_PROCESSED_INPUT は残す。
+	windows.SetConsoleMode(stdin, inMode&^(windows.ENABLE_LINE_INPUT|windows.ENABLE_ECHO_INPUT))
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	return func() {
+		os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+		windows.SetConsoleMode(stdin, inMode)
+		windows.SetConsoleMode(stdout, outMode)
+	}, nil
+}
+
+// consoleSize はコンソールの表示領域の幅と高さを返す。取得できない場合は 80x25 とする。
+func consoleSize(out *os.File) (int, int) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(out.Fd()), &info); err != nil {
+		return 80, 25
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1
+}
+
+// readKeys は標準入力から読んだキーを送るチャネルを返す。入力が閉じられるとチャネルも閉じる。
+func readKeys() <-chan byte {
+	keys := make(chan byte)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+	return keys
+}
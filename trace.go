@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- trace モード (ETW によるイベント駆動の監視) ---
+const traceSessionName = "ObuStat-Trace"
+
+func runTraceMode(args []string) error {
+	fs := newFlagSet("trace")
+	opts := setupTraceFlags(fs)
+	if err := parseFlags(fs, opts, args); err != nil {
+		return err
+	}
+
+	filter, monitorTarget, err := opts.connFilter()
+	if err != nil {
+		return err
+	}
+	formatter, err := opts.newFormatter()
+	if err != nil {
+		return err
+	}
+	closeOutput, err := opts.setupLogging()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	if err := opts.checkPrivileges(); err != nil {
+		return err
+	}
+
+	tracer, err := conn.StartTrace(traceSessionName, filter)
+	if err != nil {
+		return fmt.Errorf(tr("ETW セッションを開始できませんでした (管理者として実行してください): %w"), err)
+	}
+	defer tracer.Close()
+
+	ctx, stop := opts.runContext()
+	defer stop()
+
+	infoLog.Print(tr("--- トレースモード開始 (ETW: Microsoft-Windows-Kernel-Network) ---"))
+	infoLog.Printf(tr("監視対象: %s"), monitorTarget)
+	infoLog.Printf(tr("出力間隔: %d ミリ秒... (Ctrl+Cで停止)"), opts.intervalMilliseconds)
+
+	// イベントは到着順に溜めておき、-i の間隔でまとめて出力する。
+	stats := newSessionStats()
+	ticker := time.NewTicker(time.Duration(opts.intervalMilliseconds) * time.Millisecond)
+	defer ticker.Stop()
+	var pending []conn.Event
+	var reportedDrops uint64
+	for {
+		select {
+		case <-ctx.Done():
+			stats.logSummary()
+			return nil
+		case e, ok := <-tracer.Events():
+			if !ok {
+				stats.logSummary()
+				if err := tracer.Err(); err != nil {
+					return fmt.Errorf(tr("ETW セッションが終了しました: %w"), err)
+				}
+				return nil
+			}
+			pending = append(pending, e)
+		case now := <-ticker.C:
+			if dropped := tracer.Dropped(); dropped > reportedDrops {
+				warnLog.Printf(tr("警告: 出力が追いつかず %d 件のイベントを破棄しました"), dropped-reportedDrops)
+				reportedDrops = dropped
+			}
+			if len(pending) == 0 {
+				continue
+			}
+			stats.countEvents(pending)
+			formatter.writeEvents(now, pending)
+			pending = nil
+		}
+	}
+}
+
+// setupTraceFlags は trace のフラグを定義する。
+func setupTraceFlags(fs *flag.FlagSet) *options {
+	return setupFlags(fs, filterFlags, pollFlags, periodFlags, outputFlags, sinkFlags, redactFlags, enrichFlags)
+}
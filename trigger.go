@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- 接続一覧を取得する契機 ---
+// pollTrigger は monitor モードで接続一覧を取得するタイミングを知らせる。
+type pollTrigger interface {
+	C() <-chan time.Time
+	Stop()
+	describe() string
+	// observe は取得した結果のイベント数を受け取る。取得間隔の調整に使う。
+	observe(changes int)
+}
+
+// newPollTrigger は -wake の指定に応じた pollTrigger を返す。
+// etw を開始できない場合 (管理者権限が無いなど) は、警告を出して一定間隔の取得に切り替える。
+func (o *options) newPollTrigger() (pollTrigger, error) {
+	interval := time.Duration(o.intervalMilliseconds) * time.Millisecond
+	if o.adaptive && strings.ToLower(o.wake) != "poll" {
+		return nil, usageError(tr("-adaptive は -wake poll の場合のみ指定できます。"))
+	}
+	switch strings.ToLower(o.wake) {
+	case "poll":
+		if o.adaptive {
+			return o.newAdaptiveTrigger(interval)
+		}
+		return newTickerTrigger(interval), nil
+	case "etw":
+		t, err := newETWTrigger(interval)
+		if err != nil {
+			warnLog.Printf(tr("警告: ETW による変化の通知を開始できないため、一定間隔で取得します: %v"), err)
+			return newTickerTrigger(interval), nil
+		}
+		return t, nil
+	default:
+		return nil, usageErrorf(tr("-wake には poll または etw を指定してください: %q"), o.wake)
+	}
+}
+
+// tickerTrigger は -i の間隔で取得する。
+type tickerTrigger struct {
+	*time.Ticker
+	interval time.Duration
+}
+
+func newTickerTrigger(interval time.Duration) *tickerTrigger {
+	return &tickerTrigger{Ticker: time.NewTicker(interval), interval: interval}
+}
+
+func (t *tickerTrigger) C() <-chan time.Time { return t.Ticker.C }
+
+func (t *tickerTrigger) describe() string {
+	return fmt.Sprintf(tr("実行間隔: %d ミリ秒"), t.interval.Milliseconds())
+}
+
+func (t *tickerTrigger) observe(int) {}
+
+// etwFallbackFactor は ETW の通知が無い間に取得する間隔 (-i の倍数)。
+// 状態の遷移 (CLOSE_WAIT など) は通知されないため、この間隔で取りこぼしを補う。
+const etwFallbackFactor = 10
+
+// etwTrigger は ETW で TCP の接続・切断を検出したときに取得する。
+// 連続した通知は -i の間隔にまとめ、通知が無い間も -i の etwFallbackFactor 倍の間隔で取得する。
+type etwTrigger struct {
+	notifier *conn.ChangeNotifier
+	interval time.Duration
+	c        chan time.Time
+	stop     chan struct{}
+}
+
+func newETWTrigger(interval time.Duration) (*etwTrigger, error) {
+	notifier, err := conn.NotifyChanges("ObuStat-Wake")
+	if err != nil {
+		return nil, err
+	}
+	t := &etwTrigger{notifier: notifier, interval: interval, c: make(chan time.Time, 1), stop: make(chan struct{})}
+	go t.run()
+	return t, nil
+}
+
+func (t *etwTrigger) C() <-chan time.Time { return t.c }
+
+func (t *etwTrigger) Stop() {
+	close(t.stop)
+	t.notifier.Close()
+}
+
+func (t *etwTrigger) describe() string {
+	return fmt.Sprintf(tr("取得契機: ETW の接続・切断通知 (最短 %d ミリ秒, 通知が無い間は %d ミリ秒ごと)"),
+		t.interval.Milliseconds(), (t.interval * etwFallbackFactor).Milliseconds())
+}
+
+func (t *etwTrigger) observe(int) {}
+
+func (t *etwTrigger) run() {
+	fallback := t.interval * etwFallbackFactor
+	timer := time.NewTimer(fallback)
+	defer timer.Stop()
+	notify, done := t.notifier.C(), t.notifier.Done()
+	var last time.Time
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-done:
+			if err := t.notifier.Err(); err != nil {
+				warnLog.Printf(tr("警告: ETW のセッションが終了したため、一定間隔で取得します: %v"), err)
+			}
+			// 以降は通知が来ないため、-i の間隔で取得する。
+			notify, done, fallback = nil, nil, t.interval
+		case <-notify:
+		case <-timer.C:
+		}
+		if wait := t.interval - time.Since(last); wait > 0 {
+			select {
+			case <-t.stop:
+				return
+			case <-time.After(wait):
+			}
+		}
+		last = time.Now()
+		select {
+		case t.c <- last:
+		default:
+		}
+		timer.Reset(fallback)
+	}
+}
+
+// adaptiveTrigger は変化があると取得間隔を半分に縮め、変化が無いと 1.25 倍ずつ戻す。
+// 間隔は -i-min と -i-max の範囲に収める。
+type adaptiveTrigger struct {
+	min, max time.Duration
+	current  atomic.Int64 // 現在の取得間隔 (time.Duration)
+	c        chan time.Time
+	stop     chan struct{}
+}
+
+func (o *options) newAdaptiveTrigger(initial time.Duration) (*adaptiveTrigger, error) {
+	t := &adaptiveTrigger{
+		min:  time.Duration(o.intervalMin) * time.Millisecond,
+		max:  time.Duration(o.intervalMax) * time.Millisecond,
+		c:    make(chan time.Time, 1),
+		stop: make(chan struct{}),
+	}
+	if t.min <= 0 || t.min > t.max {
+		return nil, usageErrorf(tr("-i-min と -i-max の指定が不正です: %d, %d"), o.intervalMin, o.intervalMax)
+	}
+	t.current.Store(int64(t.clamp(initial)))
+	go t.run()
+	return t, nil
+}
+
+func (t *adaptiveTrigger) C() <-chan time.Time { return t.c }
+
+func (t *adaptiveTrigger) Stop() { close(t.stop) }
+
+func (t *adaptiveTrigger) describe() string {
+	return fmt.Sprintf(tr("実行間隔: %d〜%d ミリ秒 (変化の量に応じて調整)"), t.min.Milliseconds(), t.max.Milliseconds())
+}
+
+func (t *adaptiveTrigger) observe(changes int) {
+	current := time.Duration(t.current.Load())
+	if changes > 0 {
+		current /= 2
+	} else {
+		current = current * 5 / 4
+	}
+	next := t.clamp(current)
+	if prev := time.Duration(t.current.Swap(int64(next))); prev != next {
+		debugLog.Printf(tr("取得間隔を変更: %s -> %s (変化: %d 件)"), prev, next, changes)
+	}
+}
+
+func (t *adaptiveTrigger) clamp(d time.Duration) time.Duration {
+	return max(t.min, min(t.max, d))
+}
+
+func (t *adaptiveTrigger) run() {
+	for {
+		select {
+		case <-t.stop:
+			return
+		case now := <-time.After(time.Duration(t.current.Load())):
+			select {
+			case t.c <- now:
+			default:
+			}
+		}
+	}
+}
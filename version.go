@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"golang.org/x/sys/windows"
+)
+
+// --- バージョンとビルド情報 (version サブコマンド) ---
+// どのビルドがどのログを出力したかを追えるよう、バージョンは json のイベント、HEARTBEAT、exporter のメトリクスにも含める。
+// version・commit・buildDate はビルド時に -ldflags で設定する。
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=2024-05-01T12:00:00Z"
+//
+// commit と buildDate を設定しなかった場合は、go build が埋め込んだ VCS の情報 (リビジョンとコミット日時) を使う。
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildInfo は version サブコマンドで表示するビルド情報。
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // 未コミットの変更を含む作業ツリーからビルドした (VCS の情報を使った場合のみ)
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`          // GOOS/GOARCH
+	Windows   string `json:"windows,omitempty"` // 実行中の Windows のバージョン (メジャー.マイナー.ビルド番号)
+}
+
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version: version, Commit: commit, BuildDate: buildDate,
+		GoVersion: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && commit == "" {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Commit = s.Value
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if v := windows.RtlGetVersion(); v != nil {
+		info.Windows = fmt.Sprintf("%d.%d.%d", v.MajorVersion, v.MinorVersion, v.BuildNumber)
+	}
+	return info
+}
+
+// 例: version -json
+func runVersionCommand(args []string) error {
+	fs := newFlagSet("version")
+	asJSON := setupVersionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	info := currentBuildInfo()
+	if *asJSON {
+		b, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Printf("go-ObuStat %s\n", info.Version)
+	if info.Commit != "" {
+		if info.Modified {
+			fmt.Printf(tr("  コミット:   %s (未コミットの変更を含む)\n"), info.Commit)
+		} else {
+			fmt.Printf(tr("  コミット:   %s\n"), info.Commit)
+		}
+	}
+	if info.BuildDate != "" {
+		fmt.Printf(tr("  ビルド日時: %s\n"), info.BuildDate)
+	}
+	fmt.Printf("  Go:         %s (%s)\n", info.GoVersion, info.Platform)
+	if info.Windows != "" {
+		fmt.Printf("  Windows:    %s\n", info.Windows)
+	}
+	return nil
+}
+
+// setupVersionFlags は version のフラグを定義し、-json の値の格納先を返す。
+func setupVersionFlags(fs *flag.FlagSet) (asJSON *bool) {
+	return fs.Bool("json", false, tr("JSON で出力する"))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go-ObuStat/conn"
+)
+
+// --- Webhook 通知 ---
+const (
+	webhookQueueSize = 256
+	webhookRetries   = 3
+	webhookTimeout   = 10 * time.Second
+)
+
+// webhookPayload は Slack/Teams の Incoming Webhook がそのまま表示できる text と、機械処理用の events を持つ。
+type webhookPayload struct {
+	Text   string      `json:"text"`
+	Events []jsonEvent `json:"events"`
+}
+
+// webhookFormatter は元の出力に加えて、-notify で選んだ種別のイベントを Webhook に POST する。
+// 送信は別の goroutine で行い、失敗時は間隔を空けて再送する。1 分あたりの送信数が上限を超えた分は破棄する。
+type webhookFormatter struct {
+	outputFormatter
+	url    string
+	notify map[conn.EventType]bool
+	queue  chan webhookPayload
+	client *http.Client
+	limit  int // 1 分あたりの送信数の上限 (0 は無制限)
+	sent   []time.Time
+}
+
+func withWebhook(formatter outputFormatter, url, notify string, limit int) (outputFormatter, error) {
+	f := &webhookFormatter{
+		outputFormatter: formatter,
+		url:             url,
+		notify:          make(map[conn.EventType]bool),
+		queue:           make(chan webhookPayload, webhookQueueSize),
+		client:          &http.Client{Timeout: webhookTimeout},
+		limit:           limit,
+	}
+	for _, t := range strings.Split(notify, ",") {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		switch conn.EventType(t) {
+		case conn.EventNew, conn.EventChange, conn.EventClosed, conn.EventRebound, eventFlap, eventRate, eventAlert, eventAnomaly, eventScan, eventDropped, eventHeartbeat, eventConfigReloaded, eventCaptureStart, eventCaptureStop, eventProcessStart, eventProcessExit:
+			f.notify[conn.EventType(t)] = true
+		case "":
+		default:
+			return nil, usageErrorf(tr("-notify に不明なイベント種別があります: %q (指定可能: NEW, CHANGE, CLOSED, REBOUND, FLAP, RATE, ALERT, ANOMALY, SCAN, DROPPED, HEARTBEAT, CONFIG_RELOADED, CAPTURE_START, CAPTURE_STOP, PROCESS_START, PROCESS_EXIT)"), t)
+		}
+	}
+	go f.run()
+	return f, nil
+}
+
+func (f *webhookFormatter) writeEvents(timestamp time.Time, events []conn.Event) {
+	f.outputFormatter.writeEvents(timestamp, events)
+
+	payload := webhookPayload{Events: []jsonEvent{}}
+	var lines []string
+	for _, e := range events {
+		if !f.notify[e.Type] {
+			continue
+		}
+		payload.Events = append(payload.Events, toJSONEvent(timestamp, e))
+		lines = append(lines, textEventLine(timestamp, e))
+	}
+	if len(payload.Events) == 0 {
+		return
+	}
+	hostname, _ := os.Hostname()
+	payload.Text = fmt.Sprintf("ObuStat (%s) %s\n%s", hostname, textTimestamp(timestamp), strings.Join(lines, "\n"))
+	select {
+	case f.queue <- payload:
+	default:
+		warnLog.Printf(tr("エラー: Webhook の送信待ちがあふれたため、%d 件のイベントの通知を破棄しました"), len(payload.Events))
+	}
+}
+
+func (f *webhookFormatter) run() {
+	for payload := range f.queue {
+		if !f.allow() {
+			warnLog.Printf(tr("エラー: Webhook の送信数が上限 (%d 件/分) を超えたため、%d 件のイベントの通知を破棄しました"), f.limit, len(payload.Events))
+			continue
+		}
+		if err := f.post(payload); err != nil {
+			warnLog.Printf(tr("エラー: Webhook の送信に失敗: %v"), err)
+			continue
+		}
+		debugLog.Printf(tr("Webhook に %d 件のイベントを通知しました"), len(payload.Events))
+	}
+}
+
+// allow は直近 1 分間の送信数が上限未満であれば送信を記録して true を返す。
+func (f *webhookFormatter) allow() bool {
+	if f.limit <= 0 {
+		return true
+	}
+	now := time.Now()
+	recent := f.sent[:0]
+	for _, t := range f.sent {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	f.sent = recent
+	if len(f.sent) >= f.limit {
+		return false
+	}
+	f.sent = append(f.sent, now)
+	return true
+}
+
+// post は payload を送信する。失敗した場合は 1, 2, 4 秒と間隔を空けて再送する。
+func (f *webhookFormatter) post(payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err = f.postOnce(body)
+		if err == nil || attempt >= webhookRetries {
+			return err
+		}
+		verboseLog.Printf(tr("Webhook の送信に失敗したため、%s 後に再送します: %v"), backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (f *webhookFormatter) postOnce(body []byte) error {
+	resp, err := f.client.Post(f.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", f.url, resp.Status)
+	}
+	return nil
+}